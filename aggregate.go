@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/csv"
+	"github.com/GaryBoone/GoStats/stats"
+	"math"
+	"strconv"
+)
+
+/* Streaming aggregation */
+
+/*
+ * By default every run's trajectory is kept in the results matrices until
+ * the experiment ends. With StreamResults, each run is instead folded into
+ * its regime's Summary as soon as it completes -- running statistics of the
+ * wealth SD at every turn and of the run's gradient -- and then dropped, so
+ * memory stays flat however large NumRuns is. If RawRowsFile is set, the
+ * dropped rows are written there first.
+ */
+
+// A Summary holds running statistics over the runs of one regime.
+type Summary struct {
+	Turns     []stats.Stats // wealth SD at each turn
+	Gradients stats.Stats   // slope of log wealth SD against turn
+}
+
+// Add folds one run's wealth SDs into the Summary.
+func (s *Summary) Add(sds []float64) {
+	for len(s.Turns) < len(sds) {
+		s.Turns = append(s.Turns, stats.Stats{})
+	}
+	for t, sd := range sds {
+		s.Turns[t].Update(sd)
+	}
+	s.Gradients.Update(gradient(sds))
+}
+
+// gradient returns the slope of the regression of log wealth SD on
+// simulated time, the experiment's measure of how fast a run levels out.
+func gradient(sds []float64) float64 {
+	return gradientOver(sds, TurnLength)
+}
+
+// gradientOver is gradient for turns of the given length.
+func gradientOver(sds []float64, length float64) float64 {
+	_, slope := fitOver(sds, length)
+	return slope
+}
+
+// fitOver returns the intercept and slope of gradientOver's regression.
+func fitOver(sds []float64, length float64) (intercept, slope float64) {
+	runArray := make([]float64, len(sds))
+	seq_along := make([]float64, len(sds))
+	for k := 0; k < len(sds); k++ {
+		runArray[k] = sds[k]
+		if runArray[k] == 0 {
+			runArray[k] = 0.00000000001
+		}
+		runArray[k] = math.Log(runArray[k])
+		seq_along[k] = float64(k) * length // +1?
+	}
+	var r stats.Regression
+	r.UpdateArray(seq_along, runArray)
+	return r.Intercept(), r.Slope()
+}
+
+// streamSummaries returns a Summary per regime and the collect function that
+// folds results into them, first writing each run's row to raw (regime, run,
+// then the SDs) if raw isn't nil. The caller flushes raw and checks its error.
+func streamSummaries(acts []ActivationOrder, raw *csv.Writer) ([]*Summary, func(cellResult)) {
+	summaries := make([]*Summary, len(acts))
+	for a := range summaries {
+		summaries[a] = &Summary{}
+	}
+	return summaries, func(res cellResult) {
+		sds := res.sds
+		if len(sds) > NumTurns {
+			sds = sds[:NumTurns] // the same turns the results matrices keep
+		}
+		if raw != nil {
+			row := []string{acts[res.act].String(), strconv.Itoa(res.run + 1)}
+			for _, sd := range sds {
+				row = append(row, strconv.FormatFloat(sd, 'g', -1, 64))
+			}
+			raw.Write(row)
+		}
+		summaries[res.act].Add(sds)
+	}
+}
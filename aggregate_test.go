@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"math"
+	"strings"
+	"testing"
+)
+
+// TestStreamMatchesMatrices checks that streaming summaries give the same
+// gradients as the results matrices, and that the raw rows hold every run.
+func TestStreamMatchesMatrices(t *testing.T) {
+	defer func(runs, turns int) { NumRuns, NumTurns = runs, turns }(NumRuns, NumTurns)
+	NumRuns, NumTurns = 3, 5
+	acts := []ActivationOrder{uniform, poisson}
+
+	matrices, collect := resultMatrices(acts)
+	if err := RunExperiment(acts, 7, collect); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	raw := csv.NewWriter(&buf)
+	summaries, collect := streamSummaries(acts, raw)
+	if err := RunExperiment(acts, 7, collect); err != nil {
+		t.Fatal(err)
+	}
+	raw.Flush()
+
+	for a := range acts {
+		sum := 0.0
+		row := make([]float64, NumTurns)
+		for r := 0; r < NumRuns; r++ {
+			matrices[a].Row(row, r)
+			sum += gradient(row)
+		}
+		if mean := sum / float64(NumRuns); math.Abs(mean-summaries[a].Gradients.Mean()) > 1e-12 {
+			t.Errorf("%v: mean gradient %v from matrices, %v streamed", acts[a], mean, summaries[a].Gradients.Mean())
+		}
+		if n := len(summaries[a].Turns); n != NumTurns {
+			t.Errorf("%v: %d turns summarized, want %d", acts[a], n, NumTurns)
+		}
+	}
+	if n := strings.Count(buf.String(), "\n"); n != len(acts)*NumRuns {
+		t.Errorf("%d raw rows, want %d", n, len(acts)*NumRuns)
+	}
+}
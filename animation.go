@@ -0,0 +1,201 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"fmt"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+	"gonum.org/v1/plot/vg"
+	vgdraw "gonum.org/v1/plot/vg/draw"
+	"gonum.org/v1/plot/vg/vgimg"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"sync"
+)
+
+/* Animation */
+
+/*
+ * With AnimateRun set, run AnimateRun of every regime is also drawn as an
+ * animated GIF, animation_<regime>_run<N>.gif: a frame per turn of the
+ * histogram of wealth, on axes fixed for the whole run -- wealth from the
+ * poorest to the richest agent at the start, counts up to the tallest bar
+ * of any turn -- so that what changes from frame to frame is only the
+ * distribution. Only the counts are kept as the run goes; the frames are
+ * drawn, with gonum/plot, once it's over. Runs longer than
+ * animationFrames turns are shown every so many turns, always ending on
+ * the last.
+ */
+
+const (
+	animationFrames = 200
+	animationDelay  = 10  // hundredths of a second per frame
+	animationHold   = 200 // on the last frame
+)
+
+// An animation is the wealth histogram of one run, turn by turn.
+type animation struct {
+	lo, hi float64   // the range of the bins, from the first turn
+	turns  []int     // the turns shown
+	sds    []float64 // the wealth SD at each
+	counts [][]float64
+}
+
+// add records the histogram of wealth after the given turn.
+func (a *animation) add(turn int, sd float64, wealth []float64) {
+	if len(a.turns) == 0 {
+		a.lo, a.hi = math.Inf(1), math.Inf(-1)
+		for _, w := range wealth {
+			a.lo, a.hi = math.Min(a.lo, w), math.Max(a.hi, w)
+		}
+	}
+	a.turns = append(a.turns, turn)
+	a.sds = append(a.sds, sd)
+	a.counts = append(a.counts, binWealth(wealth, a.lo, a.hi, AnimationBins))
+}
+
+// binWealth counts wealth into bins equal-width bins from lo to hi, putting
+// anything outside them into the first or last.
+func binWealth(wealth []float64, lo, hi float64, bins int) []float64 {
+	counts := make([]float64, bins)
+	for _, w := range wealth {
+		b := 0
+		if hi > lo {
+			b = int(float64(bins) * (w - lo) / (hi - lo))
+		}
+		if b < 0 {
+			b = 0
+		} else if b >= bins {
+			b = bins - 1
+		}
+		counts[b]++
+	}
+	return counts
+}
+
+// render draws the animation in color c, titling each frame with title
+// and its turn.
+func (a *animation) render(title string, c int) *gif.GIF {
+	tallest := 0.0
+	for _, counts := range a.counts {
+		for _, n := range counts {
+			tallest = math.Max(tallest, n)
+		}
+	}
+	width := (a.hi - a.lo) / float64(AnimationBins)
+	if width == 0 {
+		width = 1
+	}
+	anim := &gif.GIF{}
+	cache := make(map[color.RGBA]uint8)
+	for f, counts := range a.counts {
+		p := plot.New()
+		p.Title.Text = fmt.Sprintf("%s: turn %d, wealth SD %.3g", title, a.turns[f], a.sds[f])
+		p.X.Label.Text = "Wealth"
+		p.Y.Label.Text = "Agents"
+		p.X.Min, p.X.Max = a.lo, a.lo+width*float64(AnimationBins)
+		p.Y.Min, p.Y.Max = 0, tallest
+		hist := &plotter.Histogram{FillColor: plotutil.Color(c), Width: width}
+		hist.LineStyle.Width = 0
+		for b, n := range counts {
+			lo := a.lo + width*float64(b)
+			hist.Bins = append(hist.Bins, plotter.HistogramBin{Min: lo, Max: lo + width, Weight: n})
+		}
+		p.Add(hist)
+		canvas := vgimg.New(6*vg.Inch, 4*vg.Inch)
+		p.Draw(vgdraw.New(canvas))
+		anim.Image = append(anim.Image, paletted(canvas.Image(), cache))
+		anim.Delay = append(anim.Delay, animationDelay)
+	}
+	if n := len(anim.Delay); n > 0 {
+		anim.Delay[n-1] = animationHold
+	}
+	return anim
+}
+
+// paletted converts img to the Plan 9 palette. A plot has few distinct
+// colors, so each one's nearest in the palette is looked up once, in cache,
+// rather than for every pixel as draw.Draw would.
+func paletted(img image.Image, cache map[color.RGBA]uint8) *image.Paletted {
+	b := img.Bounds()
+	frame := image.NewPaletted(b, palette.Plan9)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			i, ok := cache[c]
+			if !ok {
+				i = uint8(frame.Palette.Index(c))
+				cache[c] = i
+			}
+			frame.SetColorIndex(x, y, i)
+		}
+	}
+	return frame
+}
+
+// An animator is an Observer that animates run AnimateRun of each regime.
+type animator struct {
+	mu    sync.Mutex
+	acts  []ActivationOrder
+	every int // turns between frames
+	anims map[ActivationOrder]*animation
+}
+
+func newAnimator(acts []ActivationOrder) *animator {
+	every := (NumTurns + animationFrames - 1) / animationFrames
+	if every < 1 {
+		every = 1
+	}
+	return &animator{acts: acts, every: every, anims: make(map[ActivationOrder]*animation)}
+}
+
+func (an *animator) Turn(act ActivationOrder, ri, turn int, sd float64, wealth []float64) {
+	if ri != AnimateRun-1 || (turn%an.every != 0 && turn != NumTurns) {
+		return
+	}
+	an.mu.Lock()
+	defer an.mu.Unlock()
+	a, ok := an.anims[act]
+	if !ok {
+		a = &animation{}
+		an.anims[act] = a
+	}
+	a.add(turn, sd, wealth)
+}
+
+// Done draws the run's animation and writes it out.
+func (an *animator) Done(act ActivationOrder, ri int) {
+	if ri != AnimateRun-1 {
+		return
+	}
+	an.mu.Lock()
+	a := an.anims[act]
+	delete(an.anims, act)
+	an.mu.Unlock()
+	c := 0
+	for i, other := range an.acts {
+		if other == act {
+			c = i
+		}
+	}
+	anim := a.render(fmt.Sprintf("%s run %d", act, ri+1), c)
+	name := fmt.Sprintf("animation_%s_run%d.gif", strings.Replace(act.String(), " ", "_", -1), ri+1)
+	f, err := os.Create(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := gif.EncodeAll(f, anim); err != nil {
+		log.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,35 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBinWealth(t *testing.T) {
+	got := binWealth([]float64{-5, 0, 2.5, 5, 7.5, 10, 20}, 0, 10, 4)
+	if want := []float64{2, 1, 1, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("binWealth = %v, want %v", got, want)
+	}
+}
+
+func TestAnimatorFrames(t *testing.T) {
+	defer func(turns, bins, run int) {
+		NumTurns, AnimationBins, AnimateRun = turns, bins, run
+	}(NumTurns, AnimationBins, AnimateRun)
+	NumTurns, AnimationBins, AnimateRun = 2*animationFrames, 5, 1
+	an := newAnimator([]ActivationOrder{uniform})
+	wealth := []float64{1, 2, 3, 4}
+	for turn := 0; turn <= NumTurns; turn++ {
+		an.Turn(uniform, 0, turn, 1, wealth)
+	}
+	a := an.anims[uniform]
+	if n := len(a.turns); n != animationFrames+1 || a.turns[n-1] != NumTurns || a.turns[1] != an.every {
+		t.Fatalf("frames at turns %v", a.turns)
+	}
+	anim := a.render("test", 0)
+	if len(anim.Image) != len(a.turns) || anim.Delay[len(anim.Delay)-1] != animationHold {
+		t.Errorf("%d frames, last held %d", len(anim.Image), anim.Delay[len(anim.Delay)-1])
+	}
+}
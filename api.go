@@ -0,0 +1,79 @@
+package main
+
+import "math/rand"
+
+/* Embedding API */
+
+/*
+ * Run is the model with everything but the simulation stripped away: no
+ * files, no printing, no goroutines beyond the pairing engine's. It is what
+ * the WebAssembly build exposes to JavaScript as runModel. Run sets
+ * NumOfAgents and NumTurns for the length of the run, restoring them after,
+ * so a call's zero fields take the defaults whatever the call before it
+ * asked for; but calls shouldn't overlap with each other or with an
+ * experiment.
+ */
+
+// RunConfig describes a single run. Zero fields take the current defaults.
+type RunConfig struct {
+	Activation string `json:"activation"` // e.g. "uniform" or "inverse poisson"
+	Agents     int    `json:"agents"`
+	Turns      int    `json:"turns"`
+	Seed       int64  `json:"seed"`
+	RNG        string `json:"rng"`
+}
+
+// RunResult is what a run produced.
+type RunResult struct {
+	SDs    []float64 `json:"sds"`    // wealth SD before the first turn and after each
+	Wealth []float64 `json:"wealth"` // every agent's final wealth
+}
+
+// Run performs the run described by cfg.
+func Run(cfg RunConfig) (*RunResult, error) {
+	return runConfig(cfg, nil)
+}
+
+// runConfig is Run, calling onTurn, if it isn't nil, with the wealth SD and
+// the agents' wealth before the first turn and after each.
+func runConfig(cfg RunConfig, onTurn func(turn int, sd float64, wealth []float64)) (*RunResult, error) {
+	act := uniform
+	if cfg.Activation != "" {
+		var err error
+		if act, err = ParseActivation(cfg.Activation); err != nil {
+			return nil, err
+		}
+	}
+	defer func(agents, turns int) { NumOfAgents, NumTurns = agents, turns }(NumOfAgents, NumTurns)
+	if cfg.Agents > 0 {
+		NumOfAgents = cfg.Agents
+	}
+	if cfg.Turns > 0 {
+		NumTurns = cfg.Turns
+	}
+	if cfg.RNG == "" {
+		cfg.RNG = RNG
+	}
+	src, err := NewSource(cfg.RNG, cfg.Seed)
+	if err != nil {
+		return nil, err
+	}
+
+	m := NewModel(act, rand.New(src))
+	res := &RunResult{}
+	_, sd := Asdw(m.Pop)
+	res.SDs = append(res.SDs, sd)
+	if onTurn != nil {
+		onTurn(0, sd, m.Pop.Wealth)
+	}
+	for t := 0; t < NumTurns; t++ {
+		m.Step()
+		_, sd = Asdw(m.Pop)
+		res.SDs = append(res.SDs, sd)
+		if onTurn != nil {
+			onTurn(t+1, sd, m.Pop.Wealth)
+		}
+	}
+	res.Wealth = m.Pop.Wealth
+	return res, nil
+}
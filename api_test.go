@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+// TestRunDefaults checks a run's sizes don't carry over to the next: zero
+// fields take the defaults whatever the last call asked for.
+func TestRunDefaults(t *testing.T) {
+	agents, turns := NumOfAgents, NumTurns
+	res, err := Run(RunConfig{Agents: 50, Turns: 3, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Wealth) != 50 || len(res.SDs) != 4 {
+		t.Fatalf("%d agents over %d turns, want 50 over 3", len(res.Wealth), len(res.SDs)-1)
+	}
+	if NumOfAgents != agents || NumTurns != turns {
+		t.Errorf("Run left %d agents and %d turns, not %d and %d", NumOfAgents, NumTurns, agents, turns)
+	}
+	res, err = Run(RunConfig{Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Wealth) != agents || len(res.SDs) != turns+1 {
+		t.Errorf("defaults ran %d agents over %d turns, want %d over %d", len(res.Wealth), len(res.SDs)-1, agents, turns)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/* Auditing random draws */
+
+/*
+ * Checking a run against another implementation's -- the Python original,
+ * say -- comes down to consuming random numbers in the same order, and
+ * when they part ways, it takes finding the first draw where they do. With
+ * AuditDraws set, every run writes each number its Model's generator gives
+ * it to draws_<regime>_run<N>.txt, a line each, in hex as the generator
+ * gave it (63 bits from Int63, 64 from Uint64), under a line naming the
+ * turn and what the draws were for, whenever that changes:
+ *
+ *	# turn 3 activation (draw 2041)
+ *	5e0d9c2a33f1b7a
+ *	...
+ *
+ * The purposes are setup (populating the Model), activation (choosing
+ * whom to activate, or when), permutation (shuffling the uniform regime's
+ * pairs), partner (choosing whom the activated agent meets), mobility,
+ * demography, intervention and custom (a registered regime's turn).
+ * Diffing two logs finds the divergence. Statistics' samples, which come
+ * from their own stream, aren't logged. Runs of Compare only log from
+ * their first turn, since their populations are drawn once for every
+ * regime.
+ */
+
+// A drawAudit is a generator that logs its draws.
+type drawAudit struct {
+	src     rand.Source64
+	f       *os.File
+	w       *bufio.Writer
+	turn    int
+	purpose string
+	changed bool   // whether the purpose has changed since the last draw
+	draws   uint64 // made so far
+}
+
+// auditedRand returns a generator like newRand(seed) that logs its draws
+// to the file of run ri of act.
+func auditedRand(seed int64, act ActivationOrder, ri int) (*rand.Rand, *drawAudit) {
+	src, err := NewSource(RNG, seed)
+	if err != nil {
+		panic(err)
+	}
+	name := fmt.Sprintf("draws_%s_run%d.txt", strings.Replace(act.String(), " ", "_", -1), ri+1)
+	f, err := os.Create(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	a := &drawAudit{src: src, f: f, w: bufio.NewWriter(f), purpose: "setup", changed: true}
+	return rand.New(a), a
+}
+
+func (a *drawAudit) Seed(seed int64) {
+	a.src.Seed(seed)
+}
+
+func (a *drawAudit) Int63() int64 {
+	v := a.src.Int63()
+	a.record(uint64(v))
+	return v
+}
+
+func (a *drawAudit) Uint64() uint64 {
+	v := a.src.Uint64()
+	a.record(v)
+	return v
+}
+
+// record logs draw v.
+func (a *drawAudit) record(v uint64) {
+	if a.changed {
+		fmt.Fprintf(a.w, "# turn %d %s (draw %d)\n", a.turn, a.purpose, a.draws)
+		a.changed = false
+	}
+	a.w.WriteString(strconv.FormatUint(v, 16))
+	a.w.WriteByte('\n')
+	a.draws++
+}
+
+// close finishes the log.
+func (a *drawAudit) close() {
+	err := a.w.Flush()
+	if cerr := a.f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// drawing notes that m's next draws are for purpose, if they're audited.
+func (m *Model) drawing(purpose string) {
+	if a := m.audit; a != nil && (a.purpose != purpose || a.turn != m.Turn) {
+		a.turn, a.purpose, a.changed = m.Turn, purpose, true
+	}
+}
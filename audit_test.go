@@ -0,0 +1,40 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestAuditDraws checks that auditing draws leaves a run as it was, and
+// logs every draw it made under its purpose.
+func TestAuditDraws(t *testing.T) {
+	defer func(audit bool, agents, turns int) { AuditDraws, NumOfAgents, NumTurns = audit, agents, turns }(AuditDraws, NumOfAgents, NumTurns)
+	NumOfAgents, NumTurns = 20, 3
+	dir, _ := os.Getwd()
+	defer os.Chdir(dir)
+	os.Chdir(t.TempDir())
+
+	acts := []ActivationOrder{uniform, random, poisson}
+	for a, act := range acts {
+		AuditDraws = false
+		want := runCell(experimentModel(acts, 7, cell{a, 0}), 0, io.Discard)
+		AuditDraws = true
+		got := runCell(experimentModel(acts, 7, cell{a, 0}), 0, io.Discard)
+		for turn := range want {
+			if got[turn] != want[turn] {
+				t.Fatalf("%s: SDs %v audited, %v not", act, got, want)
+			}
+		}
+		data, err := os.ReadFile("draws_" + strings.Replace(act.String(), " ", "_", -1) + "_run1.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		log := string(data)
+		purpose := map[ActivationOrder]string{uniform: "# turn 1 permutation (draw 0)", random: "# turn 3 partner", poisson: "# turn 2 activation"}[act]
+		if !strings.Contains(log, purpose) || strings.Count(log, "\n") < 3*NumOfAgents/2 {
+			t.Errorf("%s: log lacks %q or draws:\n%s", act, purpose, log)
+		}
+	}
+}
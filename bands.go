@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"github.com/GaryBoone/GoStats/stats"
+	"math"
+	"sort"
+)
+
+/* Bands across runs */
+
+/*
+ * A regime's runs are summarized turn by turn as Bands: at each turn, the
+ * mean over the runs that reached it, the mean's standard error, and the
+ * runs' 5th, 25th, 50th, 75th and 95th percentiles. NewBands computes them
+ * from any runs' series, and with PlotsDir set the experiment writes those
+ * of the wealth SD and Gini to bands.csv beside trajectories.csv, a row per
+ * regime, measure and turn:
+ *
+ *	regime,measure,turn,time,runs,mean,se,q05,q25,q50,q75,q95
+ *
+ * so the trajectories needn't be aggregated downstream to be drawn with
+ * confidence or percentile bands.
+ */
+
+// A Band is a series' values at one turn, over runs.
+type Band struct {
+	Turn      int
+	Time      float64 // simulated, after the turn
+	Runs      int     // that have a value at the turn
+	Mean, SE  float64
+	Quantiles []float64 // at bandLevels
+}
+
+// bandLevels are the quantiles a Band gives.
+var bandLevels = []float64{0.05, 0.25, 0.5, 0.75, 0.95}
+
+// NewBands returns the Bands of runs, each a series by turn over turns of
+// the given length, up to the last turn any run reached. NaNs are missing
+// values. A turn no run has a value at has a Band of no runs, whose mean
+// and quantiles are NaN.
+func NewBands(runs [][]float64, length float64) []Band {
+	var bands []Band
+	for turn := 0; ; turn++ {
+		var s stats.Stats
+		var values []float64
+		reached := false
+		for _, run := range runs {
+			if turn < len(run) {
+				reached = true
+				if !math.IsNaN(run[turn]) {
+					s.Update(run[turn])
+					values = append(values, run[turn])
+				}
+			}
+		}
+		if !reached {
+			return bands
+		}
+		sort.Float64s(values)
+		b := Band{Turn: turn, Time: float64(turn) * length, Runs: len(values), Mean: math.NaN(),
+			Quantiles: make([]float64, len(bandLevels))}
+		if len(values) > 0 {
+			b.Mean = s.Mean()
+		}
+		if len(values) > 1 {
+			b.SE = s.SampleStandardDeviation() / math.Sqrt(float64(len(values)))
+		}
+		for i, p := range bandLevels {
+			b.Quantiles[i] = quantile(values, p)
+		}
+		bands = append(bands, b)
+	}
+}
+
+// bandColumns names the quantile columns of bands.csv.
+func bandColumns() []string {
+	cols := make([]string, len(bandLevels))
+	for i, p := range bandLevels {
+		cols[i] = fmt.Sprintf("q%02.0f", 100*p)
+	}
+	return cols
+}
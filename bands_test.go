@@ -0,0 +1,47 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNewBands(t *testing.T) {
+	runs := [][]float64{{1, 2, 3}, {3, 4}, {5, math.NaN()}, {7, 6}}
+	bands := NewBands(runs, 0.5)
+	if len(bands) != 3 {
+		t.Fatalf("%d bands, want 3", len(bands))
+	}
+	b := bands[0]
+	if b.Turn != 0 || b.Time != 0 || b.Runs != 4 || b.Mean != 4 {
+		t.Errorf("turn 0: %+v", b)
+	}
+	if want := math.Sqrt(20.0/3) / 2; math.Abs(b.SE-want) > 1e-12 {
+		t.Errorf("turn 0: SE %v, want %v", b.SE, want)
+	}
+	if want := []float64{1.3, 2.5, 4, 5.5, 6.7}; !closeSlices(b.Quantiles, want, 1e-12) {
+		t.Errorf("turn 0: quantiles %v, want %v", b.Quantiles, want)
+	}
+	if b := bands[1]; b.Time != 0.5 || b.Runs != 3 || b.Mean != 4 || b.Quantiles[2] != 4 {
+		t.Errorf("turn 1: %+v", b)
+	}
+	if b := bands[2]; b.Runs != 1 || b.Mean != 3 || b.SE != 0 || b.Quantiles[0] != 3 || b.Quantiles[4] != 3 {
+		t.Errorf("turn 2: %+v", b)
+	}
+	if b := NewBands([][]float64{{math.NaN()}}, 1)[0]; b.Runs != 0 || !math.IsNaN(b.Mean) || !math.IsNaN(b.Quantiles[2]) {
+		t.Errorf("turn without values: %+v", b)
+	}
+}
+
+// closeSlices reports whether a and b are the same length and within tol of
+// each other throughout.
+func closeSlices(a, b []float64, tol float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > tol {
+			return false
+		}
+	}
+	return true
+}
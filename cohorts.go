@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/* Cohorts */
+
+/*
+ * Agents' tags -- the groups homophily pairs within and "group" lambdas are
+ * measured against -- can mark cohorts. With Cohorts n > 0 agents are
+ * tagged by their quantile of initial wealth, from 0 (the poorest nth) to
+ * n-1; with CohortFile, by the group in that CSV file, one integer per
+ * agent: a single column, or with a header row, the column headed "cohort"
+ * (or else the first). Either overrides the regions of CoordinatesFile.
+ *
+ * With CohortMetrics set, every turn of a run is then summarized for each
+ * cohort and for the whole population ("all") in cohorts_<regime>_run<N>.csv:
+ * the agents, their share of wealth, and its mean, SD, Gini and Theil index,
+ * then any Metrics. Inequality is also decomposed into its parts within and
+ * between cohorts, both as variance and as the Theil index, whose parts add
+ * up exactly. A cohort's row gives its contribution to each part -- to the
+ * variance within, its share of agents times its own variance; to the Theil
+ * index within, its share of wealth times its own index -- and the "all" row
+ * their totals.
+ */
+
+var cohortColumns = []string{"turn", "cohort", "agents", "share", "mean", "sd", "gini", "theil",
+	"var_within", "var_between", "theil_within", "theil_between"}
+
+// LoadCohorts reads n agents' cohorts from CSV.
+func LoadCohorts(r io.Reader, n int) ([]int, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	col := 0
+	if len(rows) > 0 && len(rows[0]) > 0 {
+		if _, err := strconv.Atoi(strings.TrimSpace(rows[0][0])); err != nil {
+			for i, name := range rows[0] {
+				if strings.EqualFold(strings.TrimSpace(name), "cohort") {
+					col = i
+				}
+			}
+			rows = rows[1:]
+		}
+	}
+	if len(rows) != n {
+		return nil, fmt.Errorf("cohorts: got %d rows for %d agents", len(rows), n)
+	}
+	cohorts := make([]int, n)
+	for i, row := range rows {
+		if col >= len(row) {
+			return nil, fmt.Errorf("cohorts row %d: no column %d", i+1, col+1)
+		}
+		if cohorts[i], err = strconv.Atoi(strings.TrimSpace(row[col])); err != nil {
+			return nil, fmt.Errorf("cohorts row %d: %v", i+1, err)
+		}
+	}
+	return cohorts, nil
+}
+
+// checkCohorts reports whether the cohort Choices make sense together.
+func checkCohorts() error {
+	if Cohorts < 0 {
+		return errors.New("Cohorts can't be negative")
+	} else if Cohorts > 0 && CohortFile != "" {
+		return errors.New("set Cohorts or CohortFile, not both")
+	} else if CohortMetrics && Cohorts == 0 && CohortFile == "" && CoordinatesFile == "" {
+		return errors.New("CohortMetrics needs Cohorts, CohortFile or the regions of CoordinatesFile")
+	}
+	return nil
+}
+
+// tagCohorts tags Pop's agents with their cohorts, if the Choices say how.
+func tagCohorts(Pop Population) {
+	if cohortTags != nil {
+		for i := range Pop.Agents {
+			Pop.Agents[i].tag = cohortTags[i]
+		}
+	} else if Cohorts > 0 {
+		order := make([]int, Pop.Len())
+		for i := range order {
+			order[i] = i
+		}
+		sort.Stable(byWealth{Pop.Wealth, order})
+		for rank, i := range order {
+			Pop.Agents[i].tag = rank * Cohorts / Pop.Len()
+		}
+	}
+}
+
+// A cohortRecorder summarizes every turn of a run by cohort, and keeps the
+// rows until the run is over.
+type cohortRecorder struct {
+	metrics []Metric
+	wealth  map[int][]float64 // by cohort, reused from turn to turn
+	snap    snapshot
+	rows    bytes.Buffer
+}
+
+// newCohortRecorder returns a recorder if CohortMetrics is set, or else
+// nil.
+func newCohortRecorder() *cohortRecorder {
+	if !CohortMetrics {
+		return nil
+	}
+	metrics, err := lookupMetrics(Metrics)
+	if err != nil {
+		log.Fatal(err)
+	}
+	r := &cohortRecorder{metrics: metrics, wealth: make(map[int][]float64)}
+	r.rows.WriteString(strings.Join(cohortColumns, ","))
+	for _, metric := range metrics {
+		r.rows.WriteString("," + strings.Join(metric.Columns, ","))
+	}
+	r.rows.WriteString("\n")
+	return r
+}
+
+// A cohortStats is the summary of one cohort's wealth, or everyone's.
+type cohortStats struct {
+	agents, total, mean, sd, gini, theil float64
+	metrics                              [][]float64
+}
+
+// summarize returns the summary of wealth, which it sorts.
+func (r *cohortRecorder) summarize(wealth []float64) cohortStats {
+	r.snap.wealth = wealth
+	metrics := computeMetrics(r.metrics, &r.snap, 1)
+	sort.Float64s(wealth)
+	s := cohortStats{agents: float64(len(wealth)), metrics: metrics}
+	for _, w := range wealth {
+		s.total += w
+	}
+	s.mean = s.total / s.agents
+	ss := 0.0
+	for _, w := range wealth {
+		ss += (w - s.mean) * (w - s.mean)
+		if w > 0 && s.mean > 0 {
+			s.theil += w / s.mean * math.Log(w/s.mean)
+		}
+	}
+	s.sd = math.Sqrt(ss / s.agents)
+	s.theil /= s.agents
+	s.gini = gini(wealth, s.total)
+	return s
+}
+
+// record adds the rows summarizing Pop after the given turn.
+func (r *cohortRecorder) record(Pop Population, turn int) {
+	for c := range r.wealth {
+		r.wealth[c] = r.wealth[c][:0]
+	}
+	all := make([]float64, 0, Pop.Len())
+	for i := range Pop.Agents {
+		c := Pop.Agents[i].tag
+		r.wealth[c] = append(r.wealth[c], Pop.Wealth[i])
+		all = append(all, Pop.Wealth[i])
+	}
+	var cohorts []int
+	for c, w := range r.wealth {
+		if len(w) > 0 {
+			cohorts = append(cohorts, c)
+		}
+	}
+	sort.Ints(cohorts)
+	everyone := r.summarize(all)
+	var varWithin, varBetween, theilWithin, theilBetween float64
+	for _, c := range cohorts {
+		s := r.summarize(r.wealth[c])
+		agentShare, share := s.agents/everyone.agents, 0.0
+		if everyone.total > 0 {
+			share = s.total / everyone.total
+		}
+		vw, vb := agentShare*s.sd*s.sd, agentShare*(s.mean-everyone.mean)*(s.mean-everyone.mean)
+		tw, tb := share*s.theil, 0.0
+		if share > 0 {
+			tb = share * math.Log(s.mean/everyone.mean)
+		}
+		varWithin, varBetween, theilWithin, theilBetween = varWithin+vw, varBetween+vb, theilWithin+tw, theilBetween+tb
+		r.row(turn, strconv.Itoa(c), s, share, vw, vb, tw, tb)
+	}
+	share := 0.0
+	if everyone.total > 0 {
+		share = 1
+	}
+	r.row(turn, "all", everyone, share, varWithin, varBetween, theilWithin, theilBetween)
+}
+
+// row adds a row of the summary of a cohort, or everyone.
+func (r *cohortRecorder) row(turn int, cohort string, s cohortStats, share float64, parts ...float64) {
+	fmt.Fprintf(&r.rows, "%d,%s,%d", turn, cohort, int(s.agents))
+	for _, v := range append([]float64{share, s.mean, s.sd, s.gini, s.theil}, parts...) {
+		r.rows.WriteString("," + strconv.FormatFloat(v, 'g', -1, 64))
+	}
+	for _, values := range s.metrics {
+		for _, v := range values {
+			r.rows.WriteString("," + strconv.FormatFloat(v, 'g', -1, 64))
+		}
+	}
+	r.rows.WriteString("\n")
+}
+
+// save writes the recorded rows for the given run of act.
+func (r *cohortRecorder) save(act ActivationOrder, run int) {
+	name := fmt.Sprintf("cohorts_%s_run%d.csv", strings.Replace(act.String(), " ", "_", -1), run+1)
+	if err := os.WriteFile(name, r.rows.Bytes(), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLoadCohorts(t *testing.T) {
+	got, err := LoadCohorts(strings.NewReader("wealth,cohort\n5,2\n6,0\n7,2\n"), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []int{2, 0, 2}) {
+		t.Errorf("cohorts %v", got)
+	}
+	for _, in := range []string{"1\n2\n", "1\nx\n3\n"} {
+		if _, err := LoadCohorts(strings.NewReader(in), 3); err == nil {
+			t.Errorf("loaded %q", in)
+		}
+	}
+}
+
+func TestTagCohorts(t *testing.T) {
+	defer func(n int) { Cohorts = n }(Cohorts)
+	Cohorts = 5
+	Pop := NewPopulation(10)
+	copy(Pop.Wealth, []float64{10, 9, 8, 7, 6, 5, 4, 3, 2, 1})
+	tagCohorts(Pop)
+	for i, a := range Pop.Agents {
+		if want := (9 - i) / 2; a.tag != want {
+			t.Errorf("agent %d with wealth %v in cohort %d, want %d", i, Pop.Wealth[i], a.tag, want)
+		}
+	}
+}
+
+// TestCohortDecomposition checks that each turn's cohort rows add up to the
+// whole population's variance and Theil index.
+func TestCohortDecomposition(t *testing.T) {
+	defer func(n, agents, turns int, on bool, metrics []string) {
+		Cohorts, NumOfAgents, NumTurns, CohortMetrics, Metrics = n, agents, turns, on, metrics
+	}(Cohorts, NumOfAgents, NumTurns, CohortMetrics, Metrics)
+	Cohorts, NumOfAgents, NumTurns, CohortMetrics, Metrics = 4, 40, 3, true, []string{"entropy"}
+	dir, _ := os.Getwd()
+	defer os.Chdir(dir)
+	os.Chdir(t.TempDir())
+
+	runCell(NewModel(random, rand.New(rand.NewSource(6))), 0, io.Discard)
+	f, err := os.Open("cohorts_random_run1.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := append(append([]string(nil), cohortColumns...), "entropy"); !reflect.DeepEqual(rows[0], want) {
+		t.Fatalf("header %v, want %v", rows[0], want)
+	}
+	if len(rows) != 1+(NumTurns+1)*(Cohorts+1) {
+		t.Fatalf("%d rows", len(rows))
+	}
+	col := func(row []string, name string) float64 {
+		for i, c := range rows[0] {
+			if c == name {
+				v, _ := strconv.ParseFloat(row[i], 64)
+				return v
+			}
+		}
+		t.Fatalf("no column %s", name)
+		return 0
+	}
+	for turn := 0; turn <= NumTurns; turn++ {
+		group := rows[1+turn*(Cohorts+1) : 1+(turn+1)*(Cohorts+1)]
+		all := group[Cohorts]
+		if all[1] != "all" || col(all, "agents") != float64(NumOfAgents) {
+			t.Fatalf("turn %d: %v", turn, all)
+		}
+		sd, theil := col(all, "sd"), col(all, "theil")
+		if v := col(all, "var_within") + col(all, "var_between"); math.Abs(v-sd*sd) > 1e-9*sd*sd {
+			t.Errorf("turn %d: variance %v, parts %v", turn, sd*sd, v)
+		}
+		if v := col(all, "theil_within") + col(all, "theil_between"); math.Abs(v-theil) > 1e-12 {
+			t.Errorf("turn %d: Theil %v, parts %v", turn, theil, v)
+		}
+		agents, within := 0.0, 0.0
+		for _, row := range group[:Cohorts] {
+			agents += col(row, "agents")
+			within += col(row, "theil_within")
+		}
+		if agents != float64(NumOfAgents) || math.Abs(within-col(all, "theil_within")) > 1e-12 {
+			t.Errorf("turn %d: cohorts of %v agents, Theil within %v", turn, agents, within)
+		}
+	}
+	if col(rows[1], "var_between") <= 0 || col(rows[1+Cohorts], "entropy") <= 0 {
+		t.Errorf("quartiles of 1..N don't differ: %v", rows[1])
+	}
+}
+
+func TestCheckCohorts(t *testing.T) {
+	defer func(n int, file string, on bool) { Cohorts, CohortFile, CohortMetrics = n, file, on }(Cohorts, CohortFile, CohortMetrics)
+	Cohorts, CohortFile = 5, "cohorts.csv"
+	if checkCohorts() == nil {
+		t.Error("accepted both Cohorts and CohortFile")
+	}
+	Cohorts, CohortFile, CohortMetrics = 0, "", true
+	if checkCohorts() == nil {
+		t.Error("accepted CohortMetrics without cohorts")
+	}
+}
@@ -0,0 +1,104 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+/* Experiment configuration files */
+
+/*
+ * "-config experiment.json" sets Choices from a JSON object, so that an
+ * experiment can be described, and kept under version control, in a file of
+ * its own rather than in a command line:
+ *
+ *	{"agents": 1000, "turns": 50, "runs": 10,
+ *	 "activations": ["uniform", "inverse poisson"],
+ *	 "rule": "yardsale", "yardsalefraction": 0.2, "seed": 42}
+ *
+ * Keys name the sweepable or configurable Choices (see settings.go), matched
+ * as a sweep's parameters are, and "seed" the master seed; a list of regimes
+ * can also be a single name, RegionActivations are named too, and
+ * RemoteTimeout is a duration such as "30m". Keys that match nothing are an
+ * error rather than silently ignored. The file is read where -config appears
+ * among the flags, so flags after it override what it sets, and flags before
+ * it are overridden.
+ */
+
+// loadConfigFile sets the Choices, and seed, from the configuration file
+// name.
+func loadConfigFile(name string, seed *int64) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := loadConfig(f, seed); err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	return nil
+}
+
+// loadConfig sets the Choices, and seed, from the configuration in r.
+func loadConfig(r io.Reader, seed *int64) error {
+	var config map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&config); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(config))
+	for key := range config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := setConfig(key, config[key], seed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setConfig sets the Choice key names, or seed, to value.
+func setConfig(key string, value json.RawMessage, seed *int64) error {
+	var err error
+	if normalizeParam(key) == "seed" {
+		err = json.Unmarshal(value, seed)
+	} else if choice, ok := configChoice(key); !ok {
+		return fmt.Errorf("unknown setting %q: it isn't a Choice or the seed", key)
+	} else if list, ok := choice.(*[]string); ok && len(value) > 0 && value[0] == '"' {
+		var name string
+		err = json.Unmarshal(value, &name)
+		*list = []string{name}
+	} else if d, ok := choice.(*time.Duration); ok {
+		var text string
+		if err = json.Unmarshal(value, &text); err == nil {
+			*d, err = time.ParseDuration(text)
+		}
+	} else if acts, ok := choice.(*[]ActivationOrder); ok {
+		var names []string
+		if err = json.Unmarshal(value, &names); err == nil {
+			*acts, err = parseActivations(names)
+		}
+	} else {
+		err = json.Unmarshal(value, choice)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", key, err)
+	}
+	return nil
+}
+
+// configChoice returns the sweepable or configurable Choice key names.
+func configChoice(key string) (interface{}, bool) {
+	if choice, ok := sweepable[normalizeParam(key)]; ok {
+		return choice, true
+	}
+	choice, ok := configurable[normalizeParam(key)]
+	return choice, ok
+}
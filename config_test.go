@@ -0,0 +1,78 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	defer func(agents, turns, runs int, acts []string, rule string, fraction float64) {
+		NumOfAgents, NumTurns, NumRuns, Activations, RuleName, YardSaleFraction = agents, turns, runs, acts, rule, fraction
+	}(NumOfAgents, NumTurns, NumRuns, Activations, RuleName, YardSaleFraction)
+	var seed int64
+	config := `{"agents": 1000, "turns": 50, "num-runs": 10, "activations": ["uniform", "inverse poisson"],
+		"rule": "yardsale", "YardSaleFraction": 0.2, "seed": 42}`
+	if err := loadConfig(strings.NewReader(config), &seed); err != nil {
+		t.Fatal(err)
+	}
+	if NumOfAgents != 1000 || NumTurns != 50 || NumRuns != 10 || RuleName != "yardsale" || YardSaleFraction != 0.2 || seed != 42 {
+		t.Errorf("loaded %d agents, %d turns, %d runs, rule %q at %v, seed %d",
+			NumOfAgents, NumTurns, NumRuns, RuleName, YardSaleFraction, seed)
+	}
+	if !reflect.DeepEqual(Activations, []string{"uniform", "inverse poisson"}) {
+		t.Errorf("loaded regimes %q", Activations)
+	}
+	if err := loadConfig(strings.NewReader(`{"regime": "poisson"}`), &seed); err != nil || !reflect.DeepEqual(Activations, []string{"poisson"}) {
+		t.Errorf("a single regime loaded %q, %v", Activations, err)
+	}
+	for _, bad := range []string{`{"agnets": 10}`, `{"turns": "many"}`, `{"turns": 2.5}`, `[1, 2]`, `{"seed": 1`} {
+		if err := loadConfig(strings.NewReader(bad), &seed); err == nil {
+			t.Errorf("loaded %s", bad)
+		}
+	}
+}
+
+// TestLoadConfigurable checks that a configuration file reaches the Choices
+// a sweep can't vary: input files, feature toggles and the regions' regimes.
+func TestLoadConfigurable(t *testing.T) {
+	defer func(edges string, market bool, regions []ActivationOrder, limit int64, metrics []string) {
+		EdgeListFile, BipartiteMarket, RegionActivations, MemoryLimit, Metrics = edges, market, regions, limit, metrics
+	}(EdgeListFile, BipartiteMarket, RegionActivations, MemoryLimit, Metrics)
+	var seed int64
+	config := `{"edge-list-file": "net.txt", "BipartiteMarket": true, "region_activations": ["uniform", "poisson"],
+		"memorylimit": 1000000, "metrics": "gini"}`
+	if err := loadConfig(strings.NewReader(config), &seed); err != nil {
+		t.Fatal(err)
+	}
+	if EdgeListFile != "net.txt" || !BipartiteMarket || MemoryLimit != 1000000 || !reflect.DeepEqual(Metrics, []string{"gini"}) {
+		t.Errorf("loaded edges %q, market %v, memory limit %d, metrics %q", EdgeListFile, BipartiteMarket, MemoryLimit, Metrics)
+	}
+	if !reflect.DeepEqual(RegionActivations, []ActivationOrder{uniform, poisson}) {
+		t.Errorf("loaded regions %v", RegionActivations)
+	}
+	if err := loadConfig(strings.NewReader(`{"regionactivations": ["sometimes"]}`), &seed); err == nil {
+		t.Error("loaded an unknown region regime")
+	}
+}
+
+// TestLoadRemote checks that a configuration file can name remote workers
+// and how long their cells may take.
+func TestLoadRemote(t *testing.T) {
+	defer func(workers []string, timeout time.Duration) {
+		RemoteWorkers, RemoteTimeout = workers, timeout
+	}(RemoteWorkers, RemoteTimeout)
+	var seed int64
+	if err := loadConfig(strings.NewReader(`{"remote-workers": ["a:7070", "b:7070"], "remotetimeout": "90s"}`), &seed); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(RemoteWorkers, []string{"a:7070", "b:7070"}) || RemoteTimeout != 90*time.Second {
+		t.Errorf("loaded workers %q, timeout %v", RemoteWorkers, RemoteTimeout)
+	}
+	if err := loadConfig(strings.NewReader(`{"remotetimeout": 90}`), &seed); err == nil {
+		t.Error("loaded a timeout without units")
+	}
+}
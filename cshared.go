@@ -0,0 +1,129 @@
+//go:build cshared
+
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"encoding/json"
+	"sync"
+	"unsafe"
+)
+
+/* C shared library */
+
+/*
+ * Built with
+ *
+ *	go build -tags cshared -buildmode=c-shared -o libleveler.so .
+ *
+ * the model is a C library (with libleveler.h alongside) that other
+ * languages can call to do single runs -- python/leveler.py wraps it for
+ * Python with ctypes. LevelerRun takes the JSON form of a RunConfig, does
+ * the run and returns a handle to its results; LevelerSDs and LevelerWealth
+ * copy them into the caller's buffer, and LevelerRelease drops them. Runs
+ * are done one at a time, since Run sets the configuration, however many
+ * threads call in.
+ */
+
+var (
+	runMu   sync.Mutex // Run isn't reentrant
+	resMu   sync.Mutex
+	results = make(map[int64]*RunResult)
+	nextID  int64
+)
+
+// LevelerRun does the run config describes and returns a handle to its
+// results, or -1 with *errOut set to a message the caller frees with
+// LevelerFree.
+//
+//export LevelerRun
+func LevelerRun(config *C.char, errOut **C.char) C.longlong {
+	h, err := runJSON(C.GoString(config))
+	if err != nil {
+		*errOut = C.CString(err.Error())
+		return -1
+	}
+	return C.longlong(h)
+}
+
+// runJSON does the run described by the JSON form of a RunConfig and files
+// its results under a new handle.
+func runJSON(config string) (int64, error) {
+	var cfg RunConfig
+	if err := json.Unmarshal([]byte(config), &cfg); err != nil {
+		return -1, err
+	}
+	runMu.Lock()
+	res, err := Run(cfg)
+	runMu.Unlock()
+	if err != nil {
+		return -1, err
+	}
+	resMu.Lock()
+	defer resMu.Unlock()
+	nextID++
+	results[nextID] = res
+	return nextID, nil
+}
+
+// LevelerSDs copies up to n of run h's wealth SDs -- before the first turn
+// and after each -- into buf, and returns how many there are in all, or -1
+// if there is no run h.
+//
+//export LevelerSDs
+func LevelerSDs(h C.longlong, buf *C.double, n C.int) C.int {
+	return copyResult(h, buf, n, func(res *RunResult) []float64 { return res.SDs })
+}
+
+// LevelerWealth copies up to n of run h's agents' final wealth into buf, and
+// returns how many agents there are, or -1 if there is no run h.
+//
+//export LevelerWealth
+func LevelerWealth(h C.longlong, buf *C.double, n C.int) C.int {
+	return copyResult(h, buf, n, func(res *RunResult) []float64 { return res.Wealth })
+}
+
+func copyResult(h C.longlong, buf *C.double, n C.int, field func(*RunResult) []float64) C.int {
+	res, ok := lookupResult(int64(h))
+	if !ok {
+		return -1
+	}
+	values := field(res)
+	if n > 0 && buf != nil {
+		dst := unsafe.Slice((*float64)(unsafe.Pointer(buf)), int(n))
+		copy(dst, values)
+	}
+	return C.int(len(values))
+}
+
+// lookupResult returns run h's results, if it hasn't been released.
+func lookupResult(h int64) (*RunResult, bool) {
+	resMu.Lock()
+	defer resMu.Unlock()
+	res, ok := results[h]
+	return res, ok
+}
+
+// LevelerRelease drops run h's results.
+//
+//export LevelerRelease
+func LevelerRelease(h C.longlong) {
+	releaseResult(int64(h))
+}
+
+func releaseResult(h int64) {
+	resMu.Lock()
+	defer resMu.Unlock()
+	delete(results, h)
+}
+
+// LevelerFree frees a string the library returned.
+//
+//export LevelerFree
+func LevelerFree(p *C.char) {
+	C.free(unsafe.Pointer(p))
+}
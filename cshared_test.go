@@ -0,0 +1,74 @@
+//go:build cshared
+
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestRunJSON checks that a run's results are filed under a handle of
+// their own until released, and that bad configurations are refused.
+func TestRunJSON(t *testing.T) {
+	h, err := runJSON(`{"activation": "poisson", "agents": 40, "turns": 5, "seed": 3}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, ok := lookupResult(h)
+	if !ok || len(res.SDs) != 6 || len(res.Wealth) != 40 {
+		t.Fatalf("handle %d: %+v", h, res)
+	}
+	h2, err := runJSON(`{"agents": 40, "turns": 5, "seed": 3}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h2 == h {
+		t.Errorf("two runs share handle %d", h)
+	}
+	releaseResult(h)
+	if _, ok := lookupResult(h); ok {
+		t.Error("results still there after release")
+	}
+	if _, ok := lookupResult(h2); !ok {
+		t.Error("releasing one run dropped another")
+	}
+	releaseResult(h2)
+	for _, bad := range []string{`{"agents": `, `{"activation": "sometimes"}`} {
+		if _, err := runJSON(bad); err == nil {
+			t.Errorf("%s accepted", bad)
+		}
+	}
+}
+
+// TestRunJSONConcurrent checks that runs called in from many threads at once
+// come out as they would one at a time.
+func TestRunJSONConcurrent(t *testing.T) {
+	const config = `{"activation": "random", "agents": 60, "turns": 8, "seed": 9}`
+	h, err := runJSON(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := lookupResult(h)
+	releaseResult(h)
+	var wg sync.WaitGroup
+	for k := 0; k < 8; k++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h, err := runJSON(config)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer releaseResult(h)
+			got, _ := lookupResult(h)
+			for i := range want.SDs {
+				if got.SDs[i] != want.SDs[i] {
+					t.Errorf("turn %d: SD %v, want %v", i, got.SDs[i], want.SDs[i])
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* Live dashboard */
+
+/*
+ * With DashboardAddr set, the experiment serves a page there that follows it
+ * live: every run's SD and Gini trajectories, a histogram of wealth in the
+ * latest turn of each regime, and how many runs are done. The dashboard
+ * observes every turn and pushes it to every open page over a
+ * WebSocket (RFC 6455, the server's half of which is small enough to write
+ * out here rather than take on a dependency). Pages that join late are sent
+ * everything so far; a page too slow to keep up misses turns rather than
+ * holding up the runs. Changes to the live settings (see live.go) are
+ * pushed too, and listed above the plots.
+ */
+
+//go:embed dashboard.html
+var dashboardPage []byte
+
+// A Dashboard is an Observer that fans an experiment's progress out to the pages watching it.
+type Dashboard struct {
+	mu      sync.Mutex
+	intro   []byte            // the "experiment" message
+	log     [][]byte          // every "turn" and "done" message so far, without histograms
+	hists   map[string][]byte // the latest "turn" message of each regime, with its histogram
+	clients map[chan []byte]bool
+	stopped chan struct{} // closed when the server stops
+}
+
+// dashboardMessage is what pages are sent, as JSON.
+type dashboardMessage struct {
+	Type    string    `json:"type"` // "experiment", "turn", "done" or "setting"
+	Regimes []string  `json:"regimes,omitempty"`
+	Runs    int       `json:"runs,omitempty"`
+	Turns   int       `json:"turns,omitempty"`
+	Regime  string    `json:"regime,omitempty"`
+	Run     int       `json:"run,omitempty"`
+	Turn    int       `json:"turn,omitempty"`
+	SD      float64   `json:"sd,omitempty"`
+	Gini    float64   `json:"gini,omitempty"`
+	Hist    []float64 `json:"hist,omitempty"`
+	Lo      float64   `json:"lo,omitempty"`
+	Hi      float64   `json:"hi,omitempty"`
+	Name    string    `json:"name,omitempty"`
+	Value   *float64  `json:"value,omitempty"`
+	Source  string    `json:"source,omitempty"`
+}
+
+// NewDashboard returns a Dashboard for an experiment over acts.
+func NewDashboard(acts []ActivationOrder) *Dashboard {
+	names := make([]string, len(acts))
+	for i, act := range acts {
+		names[i] = act.String()
+	}
+	intro, _ := json.Marshal(dashboardMessage{Type: "experiment", Regimes: names, Runs: NumRuns, Turns: NumTurns})
+	return &Dashboard{intro: intro, hists: make(map[string][]byte), clients: make(map[chan []byte]bool),
+		stopped: make(chan struct{})}
+}
+
+// Turn publishes run ri of act's wealth after the given turn.
+func (d *Dashboard) Turn(act ActivationOrder, ri, turn int, sd float64, wealth []float64) {
+	sorted := append([]float64(nil), wealth...)
+	sort.Float64s(sorted)
+	total := 0.0
+	for _, w := range sorted {
+		total += w
+	}
+	msg := dashboardMessage{Type: "turn", Regime: act.String(), Run: ri + 1, Turn: turn, SD: sd, Gini: gini(sorted, total)}
+	short, _ := json.Marshal(msg)
+	msg.Hist = histogram(sorted, HistogramBins)
+	if len(sorted) > 0 {
+		msg.Lo, msg.Hi = sorted[0], sorted[len(sorted)-1]
+	}
+	full, _ := json.Marshal(msg)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.log = append(d.log, short)
+	d.hists[msg.Regime] = full
+	d.send(full)
+}
+
+// Done publishes the end of run ri of act.
+func (d *Dashboard) Done(act ActivationOrder, ri int) {
+	msg, _ := json.Marshal(dashboardMessage{Type: "done", Regime: act.String(), Run: ri + 1})
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.log = append(d.log, msg)
+	d.send(msg)
+}
+
+// Setting publishes a change to the live settings.
+func (d *Dashboard) Setting(name string, value float64, source string) {
+	msg, _ := json.Marshal(dashboardMessage{Type: "setting", Name: name, Value: &value, Source: source})
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.log = append(d.log, msg)
+	d.send(msg)
+}
+
+// send queues msg for every client that has room for it. The caller holds
+// d.mu.
+func (d *Dashboard) send(msg []byte) {
+	for c := range d.clients {
+		select {
+		case c <- msg:
+		default: // too slow; it misses this one
+		}
+	}
+}
+
+// subscribe returns a channel of messages, starting with everything so far.
+func (d *Dashboard) subscribe() chan []byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c := make(chan []byte, len(d.log)+len(d.hists)+1024)
+	c <- d.intro
+	for _, msg := range d.log {
+		c <- msg
+	}
+	for _, msg := range d.hists {
+		c <- msg
+	}
+	d.clients[c] = true
+	return c
+}
+
+func (d *Dashboard) unsubscribe(c chan []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.clients, c)
+}
+
+func (d *Dashboard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/ws" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(dashboardPage)
+		return
+	}
+	conn, rw, err := acceptWebSocket(w, r)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	c := d.subscribe()
+	defer d.unsubscribe(c)
+	closed := make(chan struct{})
+	go func() { // the page has nothing to say, but its close must be noticed
+		discardWebSocket(rw.Reader)
+		close(closed)
+	}()
+	for {
+		select {
+		case msg := <-c:
+			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if err := writeFrame(rw.Writer, opText, msg); err != nil {
+				return
+			}
+			if len(c) == 0 {
+				if err := rw.Flush(); err != nil {
+					return
+				}
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// serveDashboard serves d at addr until the server fails.
+func serveDashboard(d *Dashboard, addr string) {
+	log.Printf("dashboard at http://%s/", addr)
+	server := &http.Server{Addr: addr, Handler: d, ReadHeaderTimeout: 10 * time.Second}
+	log.Print(server.ListenAndServe())
+	close(d.stopped)
+}
+
+// hold keeps the dashboard up once the experiment is over, until the
+// process is interrupted.
+func (d *Dashboard) hold() {
+	fmt.Printf("Experiment finished; the dashboard is still up (interrupt to quit)\n")
+	<-d.stopped
+}
+
+/* WebSockets */
+
+const (
+	opText  = 0x1
+	opClose = 0x8
+)
+
+// websocketGUID is the fixed key suffix of RFC 6455's opening handshake.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// acceptWebSocket completes the opening handshake for r and takes over its
+// connection.
+func acceptWebSocket(w http.ResponseWriter, r *http.Request) (net.Conn, *bufio.ReadWriter, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !headerHas(r.Header, "Connection", "upgrade") || !headerHas(r.Header, "Upgrade", "websocket") || key == "" {
+		http.Error(w, "expected a WebSocket handshake", http.StatusBadRequest)
+		return nil, nil, errors.New("not a WebSocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported WebSocket version", http.StatusUpgradeRequired)
+		return nil, nil, errors.New("unsupported WebSocket version")
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "can't take over the connection", http.StatusInternalServerError)
+		return nil, nil, errors.New("connection can't be hijacked")
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	rw.WriteString("Sec-WebSocket-Accept: " + websocketAccept(key) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, rw, nil
+}
+
+// websocketAccept returns the Sec-WebSocket-Accept answer to key.
+func websocketAccept(key string) string {
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// headerHas reports whether the comma-separated header name lists token.
+func headerHas(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeFrame writes payload as one unmasked frame, as servers send them.
+func writeFrame(w io.Writer, opcode byte, payload []byte) error {
+	header := []byte{0x80 | opcode} // final fragment
+	if n := len(payload); n < 126 {
+		header = append(header, byte(n))
+	} else if n <= 0xffff {
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	} else {
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// discardWebSocket reads and drops the client's frames until the client
+// closes the connection or it fails. Nothing is written back -- not even
+// the closing handshake -- since the writer has the connection to itself.
+func discardWebSocket(r *bufio.Reader) {
+	var head [2]byte
+	for {
+		if _, err := io.ReadFull(r, head[:]); err != nil {
+			return
+		}
+		opcode, n := head[0]&0x0f, uint64(head[1]&0x7f)
+		if n == 126 {
+			var ext [2]byte
+			if _, err := io.ReadFull(r, ext[:]); err != nil {
+				return
+			}
+			n = uint64(binary.BigEndian.Uint16(ext[:]))
+		} else if n == 127 {
+			var ext [8]byte
+			if _, err := io.ReadFull(r, ext[:]); err != nil {
+				return
+			}
+			n = binary.BigEndian.Uint64(ext[:])
+		}
+		if head[1]&0x80 != 0 {
+			n += 4 // the mask
+		}
+		if opcode == opClose {
+			return
+		}
+		if _, err := io.CopyN(io.Discard, r, int64(n)); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestWebSocketAccept checks the handshake answer against RFC 6455's example.
+func TestWebSocketAccept(t *testing.T) {
+	if got := websocketAccept("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("accept %q", got)
+	}
+}
+
+// readFrame reads one unmasked frame's payload.
+func readFrame(r *bufio.Reader) ([]byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return nil, err
+	}
+	n := int(head[1] & 0x7f)
+	if n == 126 {
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return nil, err
+		}
+		n = int(ext[0])<<8 | int(ext[1])
+	}
+	payload := make([]byte, n)
+	_, err := io.ReadFull(r, payload)
+	return payload, err
+}
+
+// TestDashboardPushesTurns connects to a dashboard as a browser would and
+// checks that it is sent the experiment, the turns published before it
+// joined, and those published after.
+func TestDashboardPushesTurns(t *testing.T) {
+	d := NewDashboard([]ActivationOrder{uniform, poisson})
+	server := httptest.NewServer(d)
+	defer server.Close()
+	d.Turn(uniform, 0, 0, 10, []float64{1, 2, 3})
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	conn.Write([]byte("GET /ws HTTP/1.1\r\nHost: x\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n"))
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("handshake: %v", resp.Status)
+	}
+
+	var types []string
+	for want := 0; want < 4; want++ {
+		if want == 3 {
+			d.Turn(poisson, 1, 1, 5, []float64{2, 2, 2})
+		}
+		payload, err := readFrame(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var msg dashboardMessage
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			t.Fatal(err)
+		}
+		types = append(types, msg.Type+" "+msg.Regime)
+	}
+	want := []string{"experiment ", "turn uniform", "turn uniform", "turn poisson"} // the log, then the latest histogram
+	if strings.Join(types, ",") != strings.Join(want, ",") {
+		t.Errorf("sent %v, want %v", types, want)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+)
+
+/* Births and exits */
+
+/*
+ * With BirthRate or ExitRate set, the population changes size as a run goes
+ * on. After each turn's exchanges, every agent exits with probability
+ * ExitRate -- unless that would leave fewer than two -- and then every
+ * agent still there has a child with probability BirthRate. A child is a
+ * copy of its parent (district, region, class, place, type, cohort and
+ * risk aversion alike) with a new ID and wealth of its own: the population's
+ * mean wealth if BirthWealth is "mean", or else drawn from BirthWealth, any
+ * of the distributions InitialWealth draws from (see initial.go), with
+ * BirthParams as its parameters.
+ *
+ * What an exiting agent leaves goes with it if ExitWealth is "remove", or
+ * is shared equally among those who stay if it's "redistribute". Either
+ * way, and with births, total wealth is no longer conserved, which
+ * AuditPrecision's drift will show. A population on a network that gains
+ * or loses agents is given a new ring lattice, as a World's regions are when
+ * agents migrate, and each run ends with a report of its births and exits.
+ * A run with births and exits never counts as equalized (see Equalized):
+ * children's wealth can unsettle a levelled population.
+ */
+
+// Demography is a population's births and exits.
+type Demography struct {
+	BirthRate, ExitRate float64
+	Redistribute        bool                         // whether exits' wealth is shared among those who stay
+	Draw                func(rng *rand.Rand) float64 // a child's wealth; nil for the mean
+
+	nextID                  *int // the next child's ID, shared by a World's regions
+	Births, Exits           int
+	WealthAdded, WealthLost float64 // with births, and with exits not redistributed
+}
+
+// checkDemography reports whether the birth and exit Choices make sense.
+func checkDemography() error {
+	if BirthRate < 0 || BirthRate > 1 || ExitRate < 0 || ExitRate > 1 {
+		return errors.New("BirthRate and ExitRate must be from 0 to 1")
+	} else if ExitWealth != "remove" && ExitWealth != "redistribute" {
+		return fmt.Errorf("unknown ExitWealth %q", ExitWealth)
+	} else if BirthWealth == "mean" {
+		return nil
+	}
+	_, err := namedDistribution("BirthWealth", BirthWealth, "BirthParams", BirthParams)
+	return err
+}
+
+// newDemography returns the Demography the Choices ask for, with IDs
+// following those of Pop, or nil if they ask for none.
+func newDemography(Pop Population) *Demography {
+	if BirthRate == 0 && ExitRate == 0 {
+		return nil
+	}
+	if err := checkDemography(); err != nil {
+		log.Fatal(err)
+	}
+	d := &Demography{BirthRate: BirthRate, ExitRate: ExitRate, Redistribute: ExitWealth == "redistribute", nextID: new(int)}
+	if BirthWealth != "mean" {
+		d.Draw, _ = namedDistribution("BirthWealth", BirthWealth, "BirthParams", BirthParams)
+	}
+	for i := range Pop.Agents {
+		if Pop.Agents[i].id >= *d.nextID {
+			*d.nextID = Pop.Agents[i].id + 1
+		}
+	}
+	return d
+}
+
+// clone returns a copy of d with no births or exits yet and IDs of its own.
+func (d *Demography) clone() *Demography {
+	c := *d
+	next := *d.nextID
+	c.nextID = &next
+	c.Births, c.Exits, c.WealthAdded, c.WealthLost = 0, 0, 0, 0
+	return &c
+}
+
+// Turn has m's agents exit and give birth, drawing from its random stream,
+// and fits its network to the new population.
+func (d *Demography) Turn(m *Model) {
+	if d.ExitRate > 0 {
+		d.exit(m)
+	}
+	if d.BirthRate > 0 {
+		d.birth(m)
+	}
+	m.fitNetwork(m.rng)
+}
+
+// exit removes the agents that exit from m's population.
+func (d *Demography) exit(m *Model) {
+	stay := Population{m.Pop.Wealth[:0], m.Pop.Lam[:0], m.Pop.Agents[:0]}
+	n, left := m.Pop.Len(), 0.0
+	for i := 0; i < m.Pop.Len(); i++ {
+		if n > 2 && m.rng.Float64() < d.ExitRate {
+			n--
+			d.Exits++
+			left += m.Pop.Wealth[i]
+			continue
+		}
+		stay.Add(m.Pop, i)
+	}
+	m.Pop = stay
+	if d.Redistribute {
+		for i := range m.Pop.Wealth {
+			m.Pop.Wealth[i] += left / float64(m.Pop.Len())
+		}
+	} else {
+		d.WealthLost += left
+	}
+}
+
+// birth adds the children of m's agents to its population.
+func (d *Demography) birth(m *Model) {
+	mean, _ := Asdw(m.Pop)
+	n := m.Pop.Len()
+	for i := 0; i < n; i++ {
+		if m.rng.Float64() >= d.BirthRate {
+			continue
+		}
+		m.Pop.Add(m.Pop, i)
+		child := &m.Pop.Agents[m.Pop.Len()-1]
+		child.id, child.activations = *d.nextID, 0
+		*d.nextID++
+		w := mean
+		if d.Draw != nil {
+			w = d.Draw(m.rng)
+		}
+		m.Pop.Wealth[m.Pop.Len()-1], child.seen = w, w
+		d.Births++
+		d.WealthAdded += w
+	}
+}
+
+// fitNetwork gives m a new ring lattice, drawn from rng, if its population
+// no longer fits its network. Neighbours are found by index, so once agents
+// have been born or have left, and those after them have moved up, the
+// population needs a new network even if its size is unchanged, and can no
+// longer follow a temporal edge list.
+func (m *Model) fitNetwork(rng *rand.Rand) {
+	if m.Net == nil {
+		return
+	}
+	fits := m.Net.Size() == m.Pop.Len()
+	for i := 0; fits && i < m.Pop.Len(); i++ {
+		fits = m.Pop.Agents[i].node == i
+	}
+	if !fits {
+		m.Temporal = nil
+		m.SetNetwork(RingLattice(m.Pop.Len(), NeighborhoodRadius, rng))
+	}
+}
+
+// printDemography reports the births and exits over the given run of act.
+func printDemography(w io.Writer, m *Model, act ActivationOrder, run int) {
+	d := m.Demography
+	fmt.Fprintf(w, "Population (%s run %d): %d agents at the end, %d births adding wealth %f, %d exits removing %f\n",
+		act, run+1, m.Pop.Len(), d.Births, d.WealthAdded, d.Exits, d.WealthLost)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestDemography checks that births and exits change the population's size
+// under every regime, keep IDs unique, and account for the wealth they add
+// and take away.
+func TestDemography(t *testing.T) {
+	defer func(births, exits float64, birthWealth, exitWealth, rule string, agents int) {
+		BirthRate, ExitRate, BirthWealth, ExitWealth, RuleName, NumOfAgents = births, exits, birthWealth, exitWealth, rule, agents
+	}(BirthRate, ExitRate, BirthWealth, ExitWealth, RuleName, NumOfAgents)
+	BirthRate, ExitRate, BirthWealth, RuleName, NumOfAgents = 0.05, 0.1, "exponential", "bargain", 100
+	for _, exitWealth := range []string{"remove", "redistribute"} {
+		ExitWealth = exitWealth
+		for _, act := range []ActivationOrder{uniform, random, poisson} {
+			m := NewModel(act, rand.New(rand.NewSource(9)))
+			start := 0.0
+			for _, w := range m.Pop.Wealth {
+				start += w
+			}
+			for turn := 0; turn < 10; turn++ {
+				m.Step()
+			}
+			d := m.Demography
+			if d.Births == 0 || d.Exits == 0 || m.Pop.Len() != NumOfAgents+d.Births-d.Exits {
+				t.Errorf("%s: %d agents after %d births and %d exits", act, m.Pop.Len(), d.Births, d.Exits)
+			}
+			seen := make(map[int]bool)
+			total := 0.0
+			for i, a := range m.Pop.Agents {
+				if seen[a.id] {
+					t.Errorf("%s: ID %d twice", act, a.id)
+				}
+				seen[a.id] = true
+				total += m.Pop.Wealth[i]
+			}
+			if want := start + d.WealthAdded - d.WealthLost; math.Abs(total-want) > 1e-9*want {
+				t.Errorf("%s, %s: total wealth %v, want %v", act, exitWealth, total, want)
+			}
+			if exitWealth == "redistribute" && d.WealthLost != 0 {
+				t.Errorf("%s: %v lost though redistributed", act, d.WealthLost)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	printDemography(&buf, NewModel(random, rand.New(rand.NewSource(1))), random, 0)
+	if !strings.HasPrefix(buf.String(), "Population (random run 1): 100 agents at the end, 0 births") {
+		t.Errorf("report %q", buf.String())
+	}
+}
+
+// TestExitsLeaveTwo checks that exits never empty the population.
+func TestExitsLeaveTwo(t *testing.T) {
+	defer func(exits float64, agents int) { ExitRate, NumOfAgents = exits, agents }(ExitRate, NumOfAgents)
+	ExitRate, NumOfAgents = 1, 10
+	m := NewModel(uniform, rand.New(rand.NewSource(2)))
+	m.Step()
+	if m.Pop.Len() != 2 || m.Demography.Exits != 8 {
+		t.Errorf("%d agents left after %d exits", m.Pop.Len(), m.Demography.Exits)
+	}
+}
+
+// TestDemographyNetwork checks that births and exits leave every agent at
+// the network node of its index, even in turns when they're as many, and
+// that a population with births is never taken as equalized.
+func TestDemographyNetwork(t *testing.T) {
+	defer func(births, exits float64, agents int, skip bool) {
+		BirthRate, ExitRate, NumOfAgents, SkipEqualized = births, exits, agents, skip
+	}(BirthRate, ExitRate, NumOfAgents, SkipEqualized)
+	BirthRate, ExitRate, NumOfAgents, SkipEqualized = 0.1, 0.1, 40, true
+	m := NewModel(localPoisson, rand.New(rand.NewSource(5)))
+	for turn := 0; turn < 20; turn++ {
+		m.Step()
+		if m.Net.Size() != m.Pop.Len() {
+			t.Fatalf("turn %d: %d agents on %d nodes", turn, m.Pop.Len(), m.Net.Size())
+		}
+		for i, a := range m.Pop.Agents {
+			if a.node != i {
+				t.Fatalf("turn %d: agent %d at node %d", turn, i, a.node)
+			}
+		}
+	}
+	if m.Equalized(0) {
+		t.Error("equalized with births to come")
+	}
+}
+
+func TestCheckDemography(t *testing.T) {
+	defer func(births float64, birthWealth, exitWealth string) {
+		BirthRate, BirthWealth, ExitWealth = births, birthWealth, exitWealth
+	}(BirthRate, BirthWealth, ExitWealth)
+	for _, bad := range []struct {
+		births            float64
+		birthWealth, exit string
+	}{{-1, "mean", "remove"}, {0.1, "linear", "remove"}, {0.1, "mean", "bequeath"}} {
+		BirthRate, BirthWealth, ExitWealth = bad.births, bad.birthWealth, bad.exit
+		if err := checkDemography(); err == nil {
+			t.Errorf("accepted %+v", bad)
+		}
+	}
+}
+
+// TestDemographyRecorded checks that runs whose population changes size
+// can be recorded, under every regime.
+func TestDemographyRecorded(t *testing.T) {
+	defer func(births, exits float64, metrics, names []string, cohorts, agents, turns int, cohortMetrics bool) {
+		BirthRate, ExitRate, Metrics, TrackAgents, Cohorts, NumOfAgents, NumTurns, CohortMetrics = births, exits, metrics, names, cohorts, agents, turns, cohortMetrics
+	}(BirthRate, ExitRate, Metrics, TrackAgents, Cohorts, NumOfAgents, NumTurns, CohortMetrics)
+	BirthRate, ExitRate, Metrics, TrackAgents, Cohorts, NumOfAgents, NumTurns, CohortMetrics =
+		0.1, 0.2, []string{"gini", "population"}, []string{"poorest", "richest"}, 3, 40, 8, true
+	dir, _ := os.Getwd()
+	defer os.Chdir(dir)
+	os.Chdir(t.TempDir())
+
+	rng := rand.New(rand.NewSource(3))
+	for _, act := range []ActivationOrder{uniform, random, poisson, localPoisson} {
+		m := NewModel(act, rng)
+		var out bytes.Buffer
+		sds := runCell(m, 0, &out)
+		if len(sds) != NumTurns+1 {
+			t.Errorf("%s: %d SDs", act, len(sds))
+		}
+		if !strings.Contains(out.String(), "births") {
+			t.Errorf("%s: no report of births in %q", act, out.String())
+		}
+	}
+	data, err := os.ReadFile("metrics_random_run1.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); !strings.Contains(lines[0], "agents") || len(lines) != NumTurns+2 {
+		t.Errorf("metrics:\n%s", data)
+	}
+}
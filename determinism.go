@@ -0,0 +1,147 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"runtime"
+	"time"
+)
+
+/* Determinism self-check */
+
+/*
+ * "verify-determinism" runs the experiment, as the Choices are, twice from
+ * the same master seed and reports every run whose wealth SD trajectory
+ * differs between the two, and the first turn it does. With -parallel the
+ * first pass is entirely serial -- one worker, and pairs and events never
+ * split across goroutines -- and the second has every parallel path on, so
+ * any hidden dependence on scheduling in the concurrent code (Unifact's
+ * batched exchanges, the sharded event sort) shows up as a divergence. The
+ * subcommand fails if there is any, so it can guard a CI job.
+ */
+
+const maxReportedDivergences = 20
+
+// experimentSDs runs the experiment over acts with the given master seed
+// and returns every run's wealth SDs, by regime then run.
+func experimentSDs(acts []ActivationOrder, seed int64) ([][][]float64, error) {
+	sds := make([][][]float64, len(acts))
+	for a := range sds {
+		sds[a] = make([][]float64, NumRuns)
+	}
+	err := Experiment{acts, seed}.Run(func(res cellResult) { sds[res.act][res.run] = res.sds })
+	return sds, err
+}
+
+// A divergence is a run whose trajectories differ between two passes.
+type divergence struct {
+	act         ActivationOrder
+	run, turn   int
+	first, then float64 // the SDs at turn
+}
+
+func (d divergence) String() string {
+	return fmt.Sprintf("%s run %d diverges at turn %d: SD %v, then %v", d.act, d.run+1, d.turn, d.first, d.then)
+}
+
+// divergences returns the runs whose SDs differ between passes a and b,
+// bit for bit, each at the first turn they do.
+func divergences(acts []ActivationOrder, a, b [][][]float64) []divergence {
+	var ds []divergence
+	for ai := range acts {
+		for ri := range a[ai] {
+			x, y := a[ai][ri], b[ai][ri]
+			for turn := 0; turn < len(x) || turn < len(y); turn++ {
+				if turn >= len(x) || turn >= len(y) {
+					ds = append(ds, divergence{acts[ai], ri, turn, sdAt(x, turn), sdAt(y, turn)})
+					break
+				} else if math.Float64bits(x[turn]) != math.Float64bits(y[turn]) {
+					ds = append(ds, divergence{acts[ai], ri, turn, x[turn], y[turn]})
+					break
+				}
+			}
+		}
+	}
+	return ds
+}
+
+// sdAt returns sds[turn], or NaN if the run stopped short of it.
+func sdAt(sds []float64, turn int) float64 {
+	if turn < len(sds) {
+		return sds[turn]
+	}
+	return math.NaN()
+}
+
+// verifyDeterminism runs the experiment twice from seed, the first pass
+// serially and the second in parallel if parallel is set, and writes what
+// it finds to w. It returns the runs that diverged.
+func verifyDeterminism(seed int64, parallel bool, w io.Writer) ([]divergence, error) {
+	defer func(workers, pairs, sorts int) {
+		Workers, ParallelThreshold, ParallelSortThreshold = workers, pairs, sorts
+	}(Workers, ParallelThreshold, ParallelSortThreshold)
+	acts, err := experimentActivations()
+	if err != nil {
+		return nil, err
+	}
+	passes := make([][][][]float64, 2)
+	for pass := range passes {
+		mode := "as configured"
+		if parallel && pass == 0 {
+			Workers, ParallelThreshold, ParallelSortThreshold = 1, math.MaxInt32, math.MaxInt32
+			mode = "serially"
+		} else if parallel {
+			Workers, ParallelThreshold, ParallelSortThreshold = runtime.NumCPU(), 1, 1
+			if Workers < 2 {
+				Workers = 2
+			}
+			mode = fmt.Sprintf("in parallel, %d workers", Workers)
+		}
+		fmt.Fprintf(w, "Pass %d: %d regimes, %d runs of %d turns, %s\n", pass+1, len(acts), NumRuns, NumTurns, mode)
+		if passes[pass], err = experimentSDs(acts, seed); err != nil {
+			return nil, err
+		}
+	}
+	ds := divergences(acts, passes[0], passes[1])
+	for i, d := range ds {
+		if i == maxReportedDivergences {
+			fmt.Fprintf(w, "... and %d more\n", len(ds)-i)
+			break
+		}
+		fmt.Fprintln(w, d)
+	}
+	if len(ds) == 0 {
+		fmt.Fprintf(w, "All %d runs identical (master seed %d)\n", len(acts)*NumRuns, seed)
+	} else {
+		fmt.Fprintf(w, "%d of %d runs diverged (master seed %d)\n", len(ds), len(acts)*NumRuns, seed)
+	}
+	return ds, nil
+}
+
+// runVerifyDeterminism is the verify-determinism subcommand.
+func runVerifyDeterminism(args []string) error {
+	fs := flag.NewFlagSet("verify-determinism", flag.ExitOnError)
+	seed := fs.Int64("seed", time.Now().UTC().UnixNano(), "master seed of both passes")
+	parallel := fs.Bool("parallel", false, "run the first pass serially and the second with every parallel path on")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		return errors.New("usage: verify-determinism [-seed n] [-parallel]")
+	}
+	if err := checkChoices(*seed); err != nil {
+		return err
+	}
+	cellOutput = io.Discard
+	ds, err := verifyDeterminism(*seed, *parallel, os.Stdout)
+	if err != nil {
+		return err
+	} else if len(ds) > 0 {
+		return errors.New("verify-determinism: trajectories diverged")
+	}
+	return nil
+}
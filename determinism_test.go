@@ -0,0 +1,149 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"testing"
+)
+
+// TestWorkersDeterministic checks that an experiment gives the same results
+// whatever the number of Workers, with every parallel path switched on, and
+// whether or not LargeScale skips the network statistics.
+func TestWorkersDeterministic(t *testing.T) {
+	defer func(runs, turns, workers, pairs, sorts int, large bool) {
+		NumRuns, NumTurns, Workers, ParallelThreshold, ParallelSortThreshold, LargeScale = runs, turns, workers, pairs, sorts, large
+	}(NumRuns, NumTurns, Workers, ParallelThreshold, ParallelSortThreshold, LargeScale)
+	NumRuns, NumTurns, ParallelThreshold, ParallelSortThreshold = 2, 5, 1, 1
+	acts := []ActivationOrder{uniform, random, poisson, inversePoisson, naturalPoisson, localPoisson}
+
+	var want []float64
+	for _, large := range []bool{false, true} {
+		for _, workers := range []int{1, 8} {
+			LargeScale, Workers = large, workers
+			matrices, collect := resultMatrices(acts)
+			if err := RunExperiment(acts, 11, collect); err != nil {
+				t.Fatal(err)
+			}
+			var got []float64
+			for _, m := range matrices {
+				for r := 0; r < NumRuns; r++ {
+					for turn := 0; turn < NumTurns; turn++ {
+						got = append(got, m.At(r, turn))
+					}
+				}
+			}
+			if want == nil {
+				want = got
+				continue
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Fatalf("large scale %v, %d workers: result %d is %v, want %v", large, workers, i, got[i], want[i])
+				}
+			}
+		}
+	}
+}
+
+// TestCompareWorkersDeterministic checks that a controlled comparison, in
+// which every regime shares its run's draws, is likewise unaffected by the
+// number of Workers.
+func TestCompareWorkersDeterministic(t *testing.T) {
+	defer func(runs, turns, workers, pairs, sorts int, compare bool) {
+		NumRuns, NumTurns, Workers, ParallelThreshold, ParallelSortThreshold, CompareRegimes = runs, turns, workers, pairs, sorts, compare
+	}(NumRuns, NumTurns, Workers, ParallelThreshold, ParallelSortThreshold, CompareRegimes)
+	NumRuns, NumTurns, ParallelThreshold, ParallelSortThreshold, CompareRegimes = 2, 5, 1, 1, true
+	acts := []ActivationOrder{uniform, random, poisson, inversePoisson, naturalPoisson, localPoisson}
+
+	var want []float64
+	for _, workers := range []int{1, 8} {
+		Workers = workers
+		matrices, collect := resultMatrices(acts)
+		if err := (Experiment{acts, 11}).Run(collect); err != nil {
+			t.Fatal(err)
+		}
+		var got []float64
+		for _, m := range matrices {
+			for r := 0; r < NumRuns; r++ {
+				for turn := 0; turn < NumTurns; turn++ {
+					got = append(got, m.At(r, turn))
+				}
+			}
+		}
+		if want == nil {
+			want = got
+			continue
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("%d workers: result %d is %v, want %v", workers, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+// TestSeedReproducesGradients checks that the master seed alone decides the
+// gradient table, and that another seed gives another.
+func TestSeedReproducesGradients(t *testing.T) {
+	defer func(runs, turns, workers int, out io.Writer) {
+		NumRuns, NumTurns, Workers, cellOutput = runs, turns, workers, out
+	}(NumRuns, NumTurns, Workers, cellOutput)
+	NumRuns, NumTurns, cellOutput = 3, 6, io.Discard
+	acts := []ActivationOrder{uniform, random, poisson}
+	table := func(seed int64, workers int) string {
+		Workers = workers
+		analyzer := newMatrixAnalyzer(acts)
+		if err := (Experiment{acts, seed}).Run(analyzer.Collect); err != nil {
+			t.Fatal(err)
+		}
+		results, err := analyzer.Analyze()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		Reporter{&buf}.Gradients(NumRuns, results)
+		return buf.String()
+	}
+	want := table(7, 1)
+	if got := table(7, 4); got != want {
+		t.Errorf("seed 7 gave\n%s\nthen\n%s", want, got)
+	}
+	if table(8, 1) == want {
+		t.Error("seeds 7 and 8 gave the same gradients")
+	}
+}
+
+func TestVerifyDeterminism(t *testing.T) {
+	defer func(runs, turns int, out io.Writer) {
+		NumRuns, NumTurns, cellOutput = runs, turns, out
+	}(NumRuns, NumTurns, cellOutput)
+	NumRuns, NumTurns, cellOutput = 2, 4, io.Discard
+	for _, parallel := range []bool{false, true} {
+		ds, err := verifyDeterminism(5, parallel, io.Discard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, d := range ds {
+			t.Errorf("parallel %v: %v", parallel, d)
+		}
+	}
+}
+
+func TestDivergences(t *testing.T) {
+	acts := []ActivationOrder{uniform, poisson}
+	a := [][][]float64{{{3, 2, 1}, {3, 2, 1}}, {{3, 2, 1}, {3, 2}}}
+	b := [][][]float64{{{3, 2, 1}, {3, 2.5, 1}}, {{3, 2, 1}, {3, 2, 1}}}
+	ds := divergences(acts, a, b)
+	if len(ds) != 2 {
+		t.Fatalf("%d divergences, want 2: %v", len(ds), ds)
+	}
+	if d := ds[0]; d.act != uniform || d.run != 1 || d.turn != 1 || d.first != 2 || d.then != 2.5 {
+		t.Errorf("first divergence %v", d)
+	}
+	if d := ds[1]; d.act != poisson || d.run != 1 || d.turn != 2 || !math.IsNaN(d.first) || d.then != 1 {
+		t.Errorf("second divergence %v", d)
+	}
+}
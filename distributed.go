@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+/* Distributed runs */
+
+/*
+ * A cell is fully determined by the configuration, the master seed, its
+ * regime and its run: its RNG is seeded with cellSeed, and under Compare its
+ * initial Model is rebuilt from the run's seed. So a coordinator can farm
+ * cells out to worker processes on other machines ("worker -listen :7070",
+ * with the coordinator run as "-workers host:7070,...") over plain HTTP and
+ * merge what comes back exactly as if the cells had run locally. Each request carries the Params below; everything else -- rules,
+ * networks, input files -- comes from the worker's own configuration, which
+ * has to match the coordinator's. So that a mismatch fails the experiment
+ * rather than merging wrong results into it, each request also carries a
+ * digest of the coordinator's other sweepable Choices (see settings.go) and
+ * of the regimes it knows, in order, and a worker whose own differ turns the
+ * cell down. Settings outside those, such as input files, aren't checked.
+ */
+
+// Params are the settings sent along with every remote cell.
+type Params struct {
+	NumRuns        int
+	NumTurns       int
+	NumOfAgents    int
+	RNG            string
+	CompareRegimes bool
+}
+
+// currentParams returns this process's Params.
+func currentParams() Params {
+	return Params{NumRuns, NumTurns, NumOfAgents, RNG, CompareRegimes}
+}
+
+// apply makes p this process's Params.
+func (p Params) apply() {
+	NumRuns, NumTurns, NumOfAgents, RNG, CompareRegimes = p.NumRuns, p.NumTurns, p.NumOfAgents, p.RNG, p.CompareRegimes
+}
+
+type cellRequest struct {
+	Params      Params
+	Choices     string // choicesDigest of the coordinator
+	Activations []ActivationOrder
+	Act, Run    int
+	Seed        int64
+}
+
+// carried are the sweepable Choices that Params or a cellRequest carries,
+// rather than a worker's configuration.
+var carried = map[string]bool{"numofagents": true, "numturns": true, "numruns": true, "rng": true, "activations": true, "compareregimes": true}
+
+// choicesDigest returns a digest of the sweepable Choices but those carried,
+// and of the regimes, for a worker's to be checked against the
+// coordinator's.
+func choicesDigest() string {
+	choices := make(map[string]interface{}, len(sweepable)+1)
+	for name, choice := range sweepable {
+		if !carried[name] {
+			choices[name] = choice
+		}
+	}
+	var regimes []string
+	for act := uniform; act <= lastActivation(); act++ {
+		regimes = append(regimes, act.String())
+	}
+	choices["regimes"] = regimes
+	b, err := json.Marshal(choices) // in order of name
+	if err != nil {
+		panic(err)
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+type cellResponse struct {
+	SDs    []float64
+	Times  PhaseTimes
+	Output string // what the cell would have printed
+}
+
+// remoteExecutors returns RemoteSlots executors per remote worker, each
+// sending cells of the experiment over acts to its worker.
+func remoteExecutors(acts []ActivationOrder, seed int64) []executor {
+	client := &http.Client{Timeout: RemoteTimeout}
+	execs := make([]executor, 0)
+	digest := choicesDigest()
+	for _, addr := range RemoteWorkers {
+		for s := 0; s < RemoteSlots; s++ {
+			addr := addr
+			execs = append(execs, func(c cell) (cellResult, error) {
+				req := cellRequest{currentParams(), digest, acts, c.act, c.run, seed}
+				resp, err := requestCell(client, addr, req)
+				if err != nil {
+					return cellResult{}, fmt.Errorf("worker %s: %v", addr, err)
+				}
+				fmt.Print(resp.Output)
+				return cellResult{c.act, c.run, resp.SDs, resp.Times}, nil
+			})
+		}
+	}
+	return execs
+}
+
+// requestCell runs one cell on the worker at addr.
+func requestCell(client *http.Client, addr string, req cellRequest) (*cellResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	r, err := client.Post("http://"+addr+"/cell", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(r.Body)
+		return nil, fmt.Errorf("%s: %s", r.Status, bytes.TrimSpace(msg))
+	}
+	var resp cellResponse
+	if err := json.NewDecoder(r.Body).Decode(&resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// workerServer runs cells for a coordinator. Cells with the same Params run
+// concurrently; a request with different Params waits for them to finish
+// and then switches this process over.
+type workerServer struct {
+	mu sync.RWMutex // held for reading by running cells, for writing to change Params
+}
+
+// acquire returns once p is in force, holding s.mu for reading.
+func (s *workerServer) acquire(p Params) {
+	for {
+		s.mu.RLock()
+		if currentParams() == p {
+			return
+		}
+		s.mu.RUnlock()
+		s.mu.Lock()
+		p.apply()
+		s.mu.Unlock()
+	}
+}
+
+func (s *workerServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST a cell", http.StatusMethodNotAllowed)
+		return
+	}
+	var req cellRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := NewSource(req.Params.RNG, req.Seed); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Act < 0 || req.Act >= len(req.Activations) || req.Run < 0 || req.Run >= req.Params.NumRuns {
+		http.Error(w, fmt.Sprintf("no cell (%d, %d)", req.Act, req.Run), http.StatusBadRequest)
+		return
+	}
+	for _, act := range req.Activations {
+		if act < uniform || act > lastActivation() {
+			http.Error(w, fmt.Sprintf("no regime %d", act), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Choices != choicesDigest() {
+		http.Error(w, "the worker's settings differ from the coordinator's", http.StatusConflict)
+		return
+	}
+	s.acquire(req.Params)
+	defer s.mu.RUnlock()
+
+	c := cell{req.Act, req.Run}
+	var m *Model
+	if req.Params.CompareRegimes {
+		m = compareModel(initialModel(req.Activations, req.Seed, c.run), req.Activations, req.Seed, c)
+	} else {
+		m = experimentModel(req.Activations, req.Seed, c)
+	}
+	var out bytes.Buffer
+	sds := runCell(m, c.run, &out)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cellResponse{sds, m.Times, out.String()})
+}
+
+// runWorker serves cells to coordinators until the server fails.
+func runWorker(args []string) error {
+	fs := flag.NewFlagSet("worker", flag.ContinueOnError)
+	listen := fs.String("listen", ":7070", "address to serve cells on")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/cell", &workerServer{})
+	log.Printf("worker listening on %s", *listen)
+	server := &http.Server{Addr: *listen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	return server.ListenAndServe()
+}
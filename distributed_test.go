@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestRemoteMatchesLocal checks that cells run on a worker over HTTP give
+// the same results as running them in process.
+func TestRemoteMatchesLocal(t *testing.T) {
+	defer func(runs, turns int, workers []string) {
+		NumRuns, NumTurns, RemoteWorkers = runs, turns, workers
+	}(NumRuns, NumTurns, RemoteWorkers)
+	NumRuns, NumTurns = 2, 5
+	acts := []ActivationOrder{uniform, poisson}
+
+	local, collect := resultMatrices(acts)
+	if err := RunExperiment(acts, 42, collect); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(&workerServer{})
+	defer server.Close()
+	RemoteWorkers = []string{strings.TrimPrefix(server.URL, "http://")}
+	remote, collect := resultMatrices(acts)
+	if err := RunExperiment(acts, 42, collect); err != nil {
+		t.Fatal(err)
+	}
+	for a := range acts {
+		for r := 0; r < NumRuns; r++ {
+			for turn := 0; turn < NumTurns; turn++ {
+				if l, x := local[a].At(r, turn), remote[a].At(r, turn); l != x {
+					t.Fatalf("%v run %d turn %d: SD %v locally, %v remotely", acts[a], r, turn, l, x)
+				}
+			}
+		}
+	}
+}
+
+// TestRemoteWorkerDown checks that an experiment whose only worker is
+// unreachable fails rather than hanging.
+func TestRemoteWorkerDown(t *testing.T) {
+	defer func(workers []string) { RemoteWorkers = workers }(RemoteWorkers)
+	server := httptest.NewServer(&workerServer{})
+	RemoteWorkers = []string{strings.TrimPrefix(server.URL, "http://")}
+	server.Close()
+	_, collect := resultMatrices([]ActivationOrder{uniform})
+	if err := RunExperiment([]ActivationOrder{uniform}, 1, collect); err == nil {
+		t.Error("RunExperiment succeeded with no worker")
+	}
+}
+
+// TestRemoteMismatch checks that a worker turns down cells from a
+// coordinator whose settings differ from its own, or that name regimes it
+// doesn't know.
+func TestRemoteMismatch(t *testing.T) {
+	defer func(rule string) { RuleName = rule }(RuleName)
+	server := httptest.NewServer(&workerServer{})
+	defer server.Close()
+	addr := strings.TrimPrefix(server.URL, "http://")
+	client := server.Client()
+
+	RuleName = "yardsale"
+	req := cellRequest{currentParams(), choicesDigest(), []ActivationOrder{uniform}, 0, 0, 1}
+	RuleName = "leveler"
+	if _, err := requestCell(client, addr, req); err == nil || !strings.Contains(err.Error(), "differ") {
+		t.Errorf("cell with another rule: %v", err)
+	}
+	req.Choices = choicesDigest()
+	if _, err := requestCell(client, addr, req); err != nil {
+		t.Errorf("cell with the same settings: %v", err)
+	}
+	req.Activations = []ActivationOrder{uniform, lastActivation() + 1}
+	if _, err := requestCell(client, addr, req); err == nil {
+		t.Error("cell naming an unknown regime accepted")
+	}
+}
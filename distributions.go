@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/GaryBoone/GoStats/stats"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+/* Distributions over runs */
+
+/*
+ * With DistributionsFile set, the experiment also writes each regime's
+ * distribution over its runs of the final Gini, the final wealth SD and the
+ * gradient, ready to be drawn as violins or boxes:
+ *
+ *	regime,measure,kind,run,x,density
+ *
+ * The measures are final_gini, final_sd and gradient. A row of kind "run"
+ * is one run's value, x, with the density estimated there, for drawing the
+ * runs as points over the violin; a row of kind "kde" is a point of the
+ * violin's outline, the density at x of a Gaussian kernel density estimate
+ * with Silverman's bandwidth, on a grid of kdePoints from three bandwidths
+ * below the lowest run to three above the highest. A measure on which a
+ * regime's runs all agree has no "kde" rows, and densities of NaN, there
+ * being nothing to smooth.
+ */
+
+// kdePoints is the number of points a density is given at.
+const kdePoints = 64
+
+// distributionMeasures are the measures written, in order.
+var distributionMeasures = []string{"final_gini", "final_sd", "gradient"}
+
+// A distributionRecorder is an Observer that records each run's final
+// Gini, final SD and gradient, to write them out once the experiment is
+// done.
+type distributionRecorder struct {
+	acts []ActivationOrder
+
+	mu     sync.Mutex
+	sds    map[ActivationOrder][][]float64 // of runs in progress
+	values map[ActivationOrder][][]float64 // by measure, then run
+}
+
+func newDistributionRecorder(acts []ActivationOrder) *distributionRecorder {
+	r := &distributionRecorder{acts: acts, sds: make(map[ActivationOrder][][]float64),
+		values: make(map[ActivationOrder][][]float64)}
+	for _, act := range acts {
+		r.sds[act] = make([][]float64, NumRuns)
+		r.values[act] = make([][]float64, len(distributionMeasures))
+		for k := range r.values[act] {
+			r.values[act][k] = make([]float64, NumRuns)
+		}
+	}
+	return r
+}
+
+func (r *distributionRecorder) Turn(act ActivationOrder, ri, turn int, sd float64, wealth []float64) {
+	var g float64
+	if turn == NumTurns {
+		sorted := append([]float64(nil), wealth...)
+		sort.Float64s(sorted)
+		total := 0.0
+		for _, w := range sorted {
+			total += w
+		}
+		g = gini(sorted, total)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sds[act][ri] = append(r.sds[act][ri], sd)
+	if turn == NumTurns {
+		r.values[act][0][ri], r.values[act][1][ri] = g, sd
+	}
+}
+
+func (r *distributionRecorder) Done(act ActivationOrder, ri int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.values[act][2][ri] = gradient(r.sds[act][ri])
+	r.sds[act][ri] = nil
+}
+
+// save writes the distributions to the file name.
+func (r *distributionRecorder) save(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.Write([]string{"regime", "measure", "kind", "run", "x", "density"})
+	g := func(v float64) string { return strconv.FormatFloat(v, 'g', -1, 64) }
+	for _, act := range r.acts {
+		for k, measure := range distributionMeasures {
+			values := r.values[act][k]
+			h := bandwidth(values)
+			for ri, v := range values {
+				w.Write([]string{act.String(), measure, "run", strconv.Itoa(ri + 1), g(v), g(kde(values, h, v))})
+			}
+			if h == 0 {
+				continue
+			}
+			lo, hi := values[0], values[0]
+			for _, v := range values {
+				lo, hi = math.Min(lo, v), math.Max(hi, v)
+			}
+			lo, hi = lo-3*h, hi+3*h
+			for i := 0; i < kdePoints; i++ {
+				x := lo + (hi-lo)*float64(i)/(kdePoints-1)
+				w.Write([]string{act.String(), measure, "kde", "", g(x), g(kde(values, h, x))})
+			}
+		}
+	}
+	w.Flush()
+	err = w.Error()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("DistributionsFile: %v", err)
+	}
+	return nil
+}
+
+// bandwidth returns Silverman's rule-of-thumb bandwidth for a Gaussian
+// kernel density estimate from values, or 0 if they don't vary.
+func bandwidth(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	spread := stats.StatsSampleStandardDeviation(sorted)
+	if iqr := (quantile(sorted, 0.75) - quantile(sorted, 0.25)) / 1.34; iqr > 0 && iqr < spread {
+		spread = iqr
+	}
+	return 0.9 * spread * math.Pow(float64(len(values)), -0.2)
+}
+
+// kde returns the Gaussian kernel density estimate from values with
+// bandwidth h at x; with h 0, it's NaN.
+func kde(values []float64, h, x float64) float64 {
+	if h == 0 {
+		return math.NaN()
+	}
+	sum := 0.0
+	for _, v := range values {
+		z := (x - v) / h
+		sum += math.Exp(-z * z / 2)
+	}
+	return sum / (float64(len(values)) * h * math.Sqrt(2*math.Pi))
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/csv"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestKDE(t *testing.T) {
+	values := []float64{1, 2, 2.5, 4, 9}
+	h := bandwidth(values)
+	if want := 0.9 * 2 / 1.34 * math.Pow(5, -0.2); math.Abs(h-want) > 1e-12 {
+		t.Errorf("bandwidth %v, want %v", h, want)
+	}
+	area := 0.0
+	for x := -10.0; x < 20; x += 0.01 {
+		area += 0.01 * kde(values, h, x)
+	}
+	if math.Abs(area-1) > 1e-6 {
+		t.Errorf("density integrates to %v", area)
+	}
+	if h := bandwidth([]float64{3, 3, 3}); h != 0 || !math.IsNaN(kde([]float64{3, 3, 3}, h, 3)) {
+		t.Errorf("bandwidth %v of values that don't vary", h)
+	}
+}
+
+// TestDistributions checks that every run's final values are written, with
+// a density for each measure that varies.
+func TestDistributions(t *testing.T) {
+	defer func(runs, turns int) { NumRuns, NumTurns = runs, turns }(NumRuns, NumTurns)
+	NumRuns, NumTurns = 3, 2
+	r := newDistributionRecorder([]ActivationOrder{uniform})
+	for ri := 0; ri < NumRuns; ri++ {
+		for turn, sd := range []float64{4, 2, float64(ri + 1)} {
+			r.Turn(uniform, ri, turn, sd, []float64{0, 0, 0, float64(turn)})
+		}
+		r.Done(uniform, ri)
+	}
+	name := filepath.Join(t.TempDir(), "distributions.csv")
+	if err := r.save(name); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := make(map[string]int)
+	for _, row := range rows[1:] {
+		kinds[row[1]+" "+row[2]]++
+	}
+	if kinds["final_gini run"] != 3 || kinds["final_gini kde"] != 0 || kinds["final_sd kde"] != kdePoints || kinds["gradient kde"] != kdePoints {
+		t.Errorf("rows by measure and kind: %v", kinds)
+	}
+	if x, _ := strconv.ParseFloat(rows[1][4], 64); rows[1][1] != "final_gini" || x != 0.75 {
+		t.Errorf("first row %v, want run 1's final Gini of 0.75", rows[1])
+	}
+	if row := rows[5]; row[1] != "final_sd" || row[3] != "2" || row[4] != "2" {
+		t.Errorf("row %v, want run 2's final SD of 2", row)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"math"
+	"sync"
+)
+
+/* Event buffers */
+
+/*
+ * Each Model keeps its event list from turn to turn, but every cell of an
+ * experiment builds a fresh Model, which would otherwise grow a new list
+ * (and sort buffer) from nothing. Finished Models hand their buffers back to
+ * eventPool, and new ones start from a pooled buffer sized for a typical
+ * turn, so long experiments allocate event storage roughly once per worker.
+ */
+var eventPool = sync.Pool{New: func() interface{} { return new(events) }}
+
+// expectedEvents is a generous estimate of a Poisson turn's event count for n
+// agents: Normalize makes the lambdas sum to 1.1n, so over a turn the count
+// is Poisson with mean 1.1n times TurnLength, and four standard deviations
+// above it is rarely exceeded.
+func expectedEvents(n int) int {
+	mean := 1.1 * float64(n) * TurnLength
+	return int(mean + 4*math.Sqrt(mean))
+}
+
+// getEvents returns an empty event list with room for at least n events.
+func getEvents(n int) events {
+	e := *eventPool.Get().(*events)
+	if cap(e) < n {
+		e = make(events, 0, n)
+	}
+	return e[:0]
+}
+
+// putEvents hands an event list back for reuse.
+func putEvents(e events) {
+	if cap(e) > 0 {
+		eventPool.Put(&e)
+	}
+}
+
+// Release returns the Model's event buffers to the pool. The Model can still
+// be stepped afterwards; it will just fetch new buffers.
+func (m *Model) Release() {
+	putEvents(m.queue.h.events)
+	putEvents(m.aTimes)
+	putEvents(m.sortBuf)
+	m.queue, m.aTimes, m.sortBuf = EventQueue{}, nil, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestGetEvents checks that a pooled buffer always comes back empty and big
+// enough, whatever was handed back before it.
+func TestGetEvents(t *testing.T) {
+	putEvents(make(events, 5, 8))
+	putEvents(nil)
+	for _, n := range []int{0, 3, 100} {
+		e := getEvents(n)
+		if len(e) != 0 || cap(e) < n {
+			t.Errorf("getEvents(%d): length %d, capacity %d", n, len(e), cap(e))
+		}
+		putEvents(append(e, event{time: 1, agent: 2}))
+	}
+}
+
+// TestRelease checks that a Model that has handed back its buffers steps on
+// exactly as it would have kept them, and that the buffers it fetches are
+// fresh ones, not those another Model is using.
+func TestRelease(t *testing.T) {
+	defer func(agents int) { NumOfAgents = agents }(NumOfAgents)
+	NumOfAgents = 60
+	for _, act := range []ActivationOrder{poisson, inversePoisson} {
+		kept := NewModel(act, rand.New(rand.NewSource(3)))
+		released := NewModel(act, rand.New(rand.NewSource(3)))
+		other := NewModel(act, rand.New(rand.NewSource(4)))
+		for turn := 0; turn < 6; turn++ {
+			if turn == 3 {
+				released.Release()
+				other.Step() // takes the released buffers from the pool
+			}
+			kept.Step()
+			released.Step()
+		}
+		for i := range kept.Pop.Wealth {
+			if kept.Pop.Wealth[i] != released.Pop.Wealth[i] {
+				t.Fatalf("%v: agent %d has wealth %v after a Release, %v without", act, i, released.Pop.Wealth[i], kept.Pop.Wealth[i])
+			}
+		}
+		if &released.aTimes[:1][0] == &other.aTimes[:1][0] {
+			t.Errorf("%v: two Models share a buffer", act)
+		}
+	}
+}
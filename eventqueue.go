@@ -0,0 +1,83 @@
+package main
+
+import "container/heap"
+
+/* Event queue */
+
+/*
+ * An EventQueue hands out events in order of time, ties going by agent as
+ * in every sort of them. Poisact draws each turn's events into its Model's
+ * queue, kept from turn to turn, and takes them off in order for as long as
+ * the turn has room for them; the Poisson Scheduler (see scheduler.go) does
+ * the same with a queue it borrows from the event pool.
+ *
+ * A turn's events are all drawn before any is taken, so rather than pushing
+ * each through the heap they're added as a batch and put in order at once:
+ * by heap.Init, or, for a batch long enough to sort in parallel shards (see
+ * eventsort.go), by sorting them -- a sorted list being a heap already.
+ * Push is for events that arise as a simulation goes, as in continuous-time
+ * models where an exchange schedules its agents' next.
+ */
+
+// An EventQueue is a priority queue of agents' events by time. The zero
+// value is an empty queue.
+type EventQueue struct {
+	h eventHeap
+}
+
+// eventHeap is events as a container/heap.
+type eventHeap struct {
+	events
+}
+
+func (h *eventHeap) Push(x interface{}) {
+	h.events = append(h.events, x.(event))
+}
+
+// Pop drops the last event, leaving it just past the end for PopNext to
+// read: returning it would allocate.
+func (h *eventHeap) Pop() interface{} {
+	h.events = h.events[:len(h.events)-1]
+	return nil
+}
+
+// Push adds agent's event at time.
+func (q *EventQueue) Push(time float64, agent int) {
+	q.add(time, agent)
+	heap.Fix(&q.h, q.h.Len()-1) // as heap.Push would, without boxing the event
+}
+
+// PopNext removes the earliest event and returns its time and agent. The
+// queue mustn't be empty.
+func (q *EventQueue) PopNext() (time float64, agent int) {
+	heap.Pop(&q.h)
+	e := q.h.events[:len(q.h.events)+1][len(q.h.events)]
+	return e.time, int(e.agent)
+}
+
+// add adds agent's event at time to a batch to be put in order by
+// heapify.
+func (q *EventQueue) add(time float64, agent int) {
+	q.h.events = append(q.h.events, event{time: time, agent: int32(agent)})
+}
+
+// heapify puts the events added since the last Reset in order, sorting them
+// in parallel shards if there are enough, with buf as scratch space. It
+// returns the (possibly grown) buffer for reuse.
+func (q *EventQueue) heapify(buf events) events {
+	if len(q.h.events) >= ParallelSortThreshold && Workers >= 2 {
+		return sortEvents(q.h.events, buf)
+	}
+	heap.Init(&q.h)
+	return buf
+}
+
+// Len returns the number of events queued.
+func (q *EventQueue) Len() int {
+	return q.h.Len()
+}
+
+// Reset empties the queue, keeping its storage for reuse.
+func (q *EventQueue) Reset() {
+	q.h.events = q.h.events[:0]
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// TestEventQueueOrder checks that an EventQueue hands out events in the
+// order the event sort puts them, ties by agent included.
+func TestEventQueueOrder(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var want events
+	var q EventQueue
+	for i := 0; i < 500; i++ {
+		e := event{time: float64(rng.Intn(100)) / 10, agent: int32(rng.Intn(50))}
+		want = append(want, e)
+		q.Push(e.time, int(e.agent))
+	}
+	sort.Sort(want)
+	for k, e := range want {
+		if q.Len() != len(want)-k {
+			t.Fatalf("%d events queued after %d pops, want %d", q.Len(), k, len(want)-k)
+		}
+		if time, agent := q.PopNext(); time != e.time || agent != int(e.agent) {
+			t.Fatalf("event %d is agent %d at %v, want agent %d at %v", k, agent, time, e.agent, e.time)
+		}
+	}
+	q.Push(1, 2)
+	q.Reset()
+	if q.Len() != 0 {
+		t.Errorf("%d events queued after Reset", q.Len())
+	}
+}
+
+// TestEventQueueBatch checks that a batch of events, put in order at once,
+// comes out as pushed events would, whether it's heaped or sorted in
+// parallel, and that the queue is reused after Reset.
+func TestEventQueueBatch(t *testing.T) {
+	defer func(threshold, workers int) {
+		ParallelSortThreshold, Workers = threshold, workers
+	}(ParallelSortThreshold, Workers)
+	Workers = 3
+	rng := rand.New(rand.NewSource(2))
+	var q EventQueue
+	var buf events
+	for _, threshold := range []int{1 << 30, 1} {
+		ParallelSortThreshold = threshold
+		q.Reset()
+		var want events
+		for i := 0; i < 1000; i++ {
+			e := event{time: float64(rng.Intn(200)) / 10, agent: int32(rng.Intn(80))}
+			want = append(want, e)
+			q.add(e.time, int(e.agent))
+		}
+		buf = q.heapify(buf)
+		q.Push(-1, 7)
+		want = append(want, event{time: -1, agent: 7})
+		sort.Sort(want)
+		for k, e := range want {
+			if time, agent := q.PopNext(); time != e.time || agent != int(e.agent) {
+				t.Fatalf("threshold %d: event %d is agent %d at %v, want agent %d at %v", threshold, k, agent, time, e.agent, e.time)
+			}
+		}
+	}
+	if cap(q.h.events) < 1000 {
+		t.Errorf("queue holds %d events after Reset, not reusing its storage", cap(q.h.events))
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"sort"
+	"sync"
+)
+
+/* Sorting the event list */
+
+/*
+ * With millions of agents, putting a Poisson turn's events in order
+ * dominates the turn. Above ParallelSortThreshold the turn's EventQueue
+ * sorts them rather than heaping them (see eventqueue.go): the list is cut
+ * into one shard per worker, the shards are sorted concurrently, and sorted
+ * runs are then merged pairwise -- each round's merges also running
+ * concurrently -- through a scratch buffer that is kept from turn to turn.
+ */
+
+// sortEvents sorts e by time, using buf as scratch space for a parallel sort
+// if e is long enough. It returns the (possibly grown) buffer for reuse.
+func sortEvents(e, buf events) events {
+	if len(e) < ParallelSortThreshold || Workers < 2 {
+		sort.Sort(e)
+		return buf
+	}
+	if cap(buf) < len(e) {
+		putEvents(buf)
+		buf = getEvents(len(e))
+	}
+	buf = buf[:len(e)]
+
+	width := (len(e) + Workers - 1) / Workers
+	var wg sync.WaitGroup
+	for lo := 0; lo < len(e); lo += width {
+		wg.Add(1)
+		go func(shard events) {
+			defer wg.Done()
+			sort.Sort(shard)
+		}(e[lo:minInt(lo+width, len(e))])
+	}
+	wg.Wait()
+
+	src, dst := e, buf
+	for ; width < len(e); width *= 2 {
+		for lo := 0; lo < len(e); lo += 2 * width {
+			mid, hi := minInt(lo+width, len(e)), minInt(lo+2*width, len(e))
+			wg.Add(1)
+			go func(lo, mid, hi int) {
+				defer wg.Done()
+				mergeEvents(dst[lo:hi], src[lo:mid], src[mid:hi])
+			}(lo, mid, hi)
+		}
+		wg.Wait()
+		src, dst = dst, src
+	}
+	if &src[0] != &e[0] {
+		copy(e, src)
+	}
+	return buf
+}
+
+// mergeEvents merges the sorted lists a and b into dst, taking from a on ties.
+func mergeEvents(dst, a, b events) {
+	i, j, k := 0, 0, 0
+	for i < len(a) && j < len(b) {
+		if b[j].time < a[i].time || (b[j].time == a[i].time && b[j].agent < a[i].agent) {
+			dst[k] = b[j]
+			j++
+		} else {
+			dst[k] = a[i]
+			i++
+		}
+		k++
+	}
+	k += copy(dst[k:], a[i:])
+	copy(dst[k:], b[j:])
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
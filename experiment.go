@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/GaryBoone/GoStats/stats"
+	"github.com/gonum/matrix/mat64"
+	"io"
+	"os"
+)
+
+/* Experiments */
+
+/*
+ * An experiment is split into three parts that can be used and tested on
+ * their own: an Experiment does the runs, an Analyzer folds each run into
+ * the gradient analysis as it completes, and a Reporter prints the
+ * analysis. main wires them together with the observers the Choices ask
+ * for; the subcommands reuse whichever they need.
+ */
+
+// An Experiment is NumRuns runs of each of some regimes from one master
+// seed.
+type Experiment struct {
+	Acts []ActivationOrder
+	Seed int64
+}
+
+// Run does the experiment's runs, handing each run's wealth SDs to collect
+// as it completes. Under CompareRegimes every regime of a run starts from
+// the same population.
+func (e Experiment) Run(collect func(cellResult)) error {
+	if CompareRegimes {
+		return Compare(e.Acts, e.Seed, collect)
+	}
+	return RunExperiment(e.Acts, e.Seed, collect)
+}
+
+// A RegimeGradients is the mean and SD over a regime's runs of their
+// gradients, and the slopes of any QuantileSlopes fits to them.
+type RegimeGradients struct {
+	Act       ActivationOrder
+	Mean, SD  float64
+	Quantiles []float64 // at QuantileSlopes
+}
+
+// An Analyzer collects an experiment's runs and analyzes their gradients.
+type Analyzer interface {
+	Collect(res cellResult)
+	Analyze() ([]RegimeGradients, error) // once every run is collected
+}
+
+// newAnalyzer returns the Analyzer the Choices ask for: streaming, writing
+// raw rows to RawRowsFile, with StreamResults, or else keeping every run in
+// the results matrices.
+func newAnalyzer(acts []ActivationOrder) (Analyzer, error) {
+	if !StreamResults {
+		return newMatrixAnalyzer(acts), nil
+	}
+	return newStreamAnalyzer(acts, RawRowsFile)
+}
+
+// A matrixAnalyzer keeps every run's wealth SDs in the results matrices.
+type matrixAnalyzer struct {
+	acts     []ActivationOrder
+	matrices []*mat64.Dense
+	collect  func(cellResult)
+}
+
+func newMatrixAnalyzer(acts []ActivationOrder) *matrixAnalyzer {
+	matrices, collect := resultMatrices(acts)
+	return &matrixAnalyzer{acts: acts, matrices: matrices, collect: collect}
+}
+
+func (a *matrixAnalyzer) Collect(res cellResult) { a.collect(res) }
+
+func (a *matrixAnalyzer) Analyze() ([]RegimeGradients, error) {
+	results := make([]RegimeGradients, len(a.matrices))
+	for i, m := range a.matrices {
+		runs, turns := m.Dims()
+		gradients := make([]float64, 0, runs)
+		rows := make([][]float64, runs)
+		for j := 0; j < runs; j++ {
+			rows[j] = make([]float64, turns)
+			m.Row(rows[j], j)
+			gradients = append(gradients, gradient(rows[j]))
+		}
+		results[i] = RegimeGradients{a.acts[i], stats.StatsMean(gradients), stats.StatsSampleStandardDeviation(gradients), quantileSlopes(rows)}
+	}
+	return results, nil
+}
+
+// A streamAnalyzer folds each run into its regime's Summary, first writing
+// it to a raw rows file if it has one.
+type streamAnalyzer struct {
+	acts      []ActivationOrder
+	summaries []*Summary
+	collect   func(cellResult)
+	f         *os.File
+	raw       *csv.Writer
+}
+
+// newStreamAnalyzer returns a streamAnalyzer that writes raw rows to the
+// named file, unless name is empty.
+func newStreamAnalyzer(acts []ActivationOrder, name string) (*streamAnalyzer, error) {
+	a := &streamAnalyzer{acts: acts}
+	if name != "" {
+		f, err := os.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		a.f, a.raw = f, csv.NewWriter(f)
+	}
+	a.summaries, a.collect = streamSummaries(acts, a.raw)
+	return a, nil
+}
+
+func (a *streamAnalyzer) Collect(res cellResult) { a.collect(res) }
+
+func (a *streamAnalyzer) Analyze() ([]RegimeGradients, error) {
+	if a.raw != nil {
+		a.raw.Flush()
+		err := a.raw.Error()
+		if cerr := a.f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	results := make([]RegimeGradients, len(a.summaries))
+	for i, s := range a.summaries {
+		results[i] = RegimeGradients{a.acts[i], s.Gradients.Mean(), s.Gradients.SampleStandardDeviation(), nil}
+	}
+	return results, nil
+}
+
+// A Reporter prints an experiment's analysis.
+type Reporter struct {
+	W io.Writer
+}
+
+// Gradients prints the gradient analysis of an experiment of runs runs,
+// with the quantile slopes if it has them.
+func (r Reporter) Gradients(runs int, results []RegimeGradients) {
+	fmt.Fprintf(r.W, "\t\t\tGradient Analysis for %v runs\n", runs)
+	fmt.Fprintf(r.W, "\t\t\t   Mean\t\t\t    SD\n")
+	for _, g := range results {
+		fmt.Fprintf(r.W, "%-15s\t\t%f\t\t%f\n", g.Act, g.Mean, g.SD)
+	}
+	if len(results) == 0 || len(results[0].Quantiles) == 0 {
+		return
+	}
+	fmt.Fprintf(r.W, "\t\t\tQuantile slopes of log SD\n")
+	fmt.Fprintf(r.W, "%-15s", "")
+	for _, tau := range QuantileSlopes {
+		fmt.Fprintf(r.W, "\t\t   q%g", tau)
+	}
+	fmt.Fprintln(r.W)
+	for _, g := range results {
+		fmt.Fprintf(r.W, "%-15s", g.Act)
+		for _, slope := range g.Quantiles {
+			fmt.Fprintf(r.W, "\t\t%f", slope)
+		}
+		fmt.Fprintln(r.W)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExperimentRun(t *testing.T) {
+	defer func(runs, turns int, compare bool, out io.Writer) {
+		NumRuns, NumTurns, CompareRegimes, cellOutput = runs, turns, compare, out
+	}(NumRuns, NumTurns, CompareRegimes, cellOutput)
+	NumRuns, NumTurns, cellOutput = 3, 4, io.Discard
+	acts := []ActivationOrder{uniform, poisson}
+	for _, compare := range []bool{false, true} {
+		CompareRegimes = compare
+		seen := make(map[[2]int]bool)
+		err := Experiment{acts, 9}.Run(func(res cellResult) {
+			if len(res.sds) != NumTurns+1 {
+				t.Errorf("compare %v: %d SDs, want %d", compare, len(res.sds), NumTurns+1)
+			}
+			seen[[2]int{res.act, res.run}] = true
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(seen) != len(acts)*NumRuns {
+			t.Errorf("compare %v: %d runs, want %d", compare, len(seen), len(acts)*NumRuns)
+		}
+	}
+}
+
+// TestAnalyzersAgree checks that both Analyzers give the same gradients,
+// and that the streaming one writes every raw row.
+func TestAnalyzersAgree(t *testing.T) {
+	defer func(runs, turns int, out io.Writer) {
+		NumRuns, NumTurns, cellOutput = runs, turns, out
+	}(NumRuns, NumTurns, cellOutput)
+	NumRuns, NumTurns, cellOutput = 4, 6, io.Discard
+	acts := []ActivationOrder{random, inversePoisson}
+	raw := filepath.Join(t.TempDir(), "raw.csv")
+	stream, err := newStreamAnalyzer(acts, raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results [][]RegimeGradients
+	for _, a := range []Analyzer{newMatrixAnalyzer(acts), stream} {
+		if err := (Experiment{acts, 3}).Run(a.Collect); err != nil {
+			t.Fatal(err)
+		}
+		r, err := a.Analyze()
+		if err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, r)
+	}
+	for i := range acts {
+		m, s := results[0][i], results[1][i]
+		if m.Act != acts[i] || s.Act != acts[i] || math.Abs(m.Mean-s.Mean) > 1e-12 || math.Abs(m.SD-s.SD) > 1e-12 {
+			t.Errorf("%v: matrices give %+v, streaming %+v", acts[i], m, s)
+		}
+	}
+	data, err := os.ReadFile(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "\n"); n != len(acts)*NumRuns {
+		t.Errorf("%d raw rows, want %d", n, len(acts)*NumRuns)
+	}
+}
+
+func TestReporter(t *testing.T) {
+	var buf bytes.Buffer
+	Reporter{&buf}.Gradients(6, []RegimeGradients{{uniform, -0.5, 0.01, nil}, {inversePoisson, -0.25, 0.125, nil}})
+	want := "\t\t\tGradient Analysis for 6 runs\n" +
+		"\t\t\t   Mean\t\t\t    SD\n" +
+		"uniform        \t\t-0.500000\t\t0.010000\n" +
+		"inverse poisson\t\t-0.250000\t\t0.125000\n"
+	if buf.String() != want {
+		t.Errorf("report\n%q\nwant\n%q", buf.String(), want)
+	}
+}
@@ -0,0 +1,240 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/flight"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log"
+	"sync"
+)
+
+/* Arrow Flight */
+
+/*
+ * With FlightAddr set, the experiment serves its results as Arrow tables over
+ * Arrow Flight, so that clients such as pyarrow can pull them straight into
+ * data frames, while it runs and after, without files in between:
+ *
+ *	trajectories  regime, run, turn, sd, gini: every turn of every run so far
+ *	snapshots     regime, run, turn, agent, wealth: each run's agents after
+ *	              its latest turn
+ *
+ * Each table is a flight whose descriptor path and ticket are its name, and
+ * comes as a record batch per regime, or per run for snapshots. Once the
+ * experiment is over the server stays up until the process is interrupted,
+ * as the dashboard does.
+ *
+ *	client = pyarrow.flight.connect("grpc://localhost:8815")
+ *	df = client.do_get(pyarrow.flight.Ticket(b"trajectories")).read_pandas()
+ */
+
+var flightTables = []string{"trajectories", "snapshots"}
+
+var trajectorySchema = arrow.NewSchema([]arrow.Field{
+	{Name: "regime", Type: arrow.BinaryTypes.String},
+	{Name: "run", Type: arrow.PrimitiveTypes.Int32},
+	{Name: "turn", Type: arrow.PrimitiveTypes.Int32},
+	{Name: "sd", Type: arrow.PrimitiveTypes.Float64},
+	{Name: "gini", Type: arrow.PrimitiveTypes.Float64},
+}, nil)
+
+var snapshotSchema = arrow.NewSchema([]arrow.Field{
+	{Name: "regime", Type: arrow.BinaryTypes.String},
+	{Name: "run", Type: arrow.PrimitiveTypes.Int32},
+	{Name: "turn", Type: arrow.PrimitiveTypes.Int32},
+	{Name: "agent", Type: arrow.PrimitiveTypes.Int32},
+	{Name: "wealth", Type: arrow.PrimitiveTypes.Float64},
+}, nil)
+
+// A flightServer serves an experiment's tables. It is an Observer of the
+// snapshots; the trajectories come from a trajectoryRecorder.
+type flightServer struct {
+	flight.BaseFlightServer
+	trajectories *trajectoryRecorder
+	acts         []ActivationOrder
+
+	mu      sync.Mutex
+	snaps   map[ActivationOrder][]wealthSnapshot // by run
+	stopped chan struct{}                        // closed when the server stops
+}
+
+// A wealthSnapshot is a run's wealth after a turn.
+type wealthSnapshot struct {
+	turn   int
+	wealth []float64
+}
+
+// newFlightServer returns a server for an experiment over acts whose
+// trajectories are recorded by trajectories.
+func newFlightServer(acts []ActivationOrder, trajectories *trajectoryRecorder) *flightServer {
+	s := &flightServer{trajectories: trajectories, acts: acts,
+		snaps: make(map[ActivationOrder][]wealthSnapshot), stopped: make(chan struct{})}
+	for _, act := range acts {
+		s.snaps[act] = make([]wealthSnapshot, NumRuns)
+	}
+	return s
+}
+
+// Turn keeps run ri of act's wealth after the turn, in place of the last.
+func (s *flightServer) Turn(act ActivationOrder, ri, turn int, sd float64, wealth []float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snap := &s.snaps[act][ri]
+	snap.turn = turn
+	snap.wealth = append(snap.wealth[:0], wealth...)
+}
+
+func (s *flightServer) Done(act ActivationOrder, ri int) {}
+
+// records returns the record batches of the named table.
+func (s *flightServer) records(name string) (*arrow.Schema, []arrow.Record, error) {
+	if name == "trajectories" {
+		return trajectorySchema, s.trajectoryRecords(), nil
+	} else if name == "snapshots" {
+		return snapshotSchema, s.snapshotRecords(), nil
+	}
+	return nil, nil, status.Errorf(codes.NotFound, "no table %q (want trajectories or snapshots)", name)
+}
+
+func (s *flightServer) trajectoryRecords() []arrow.Record {
+	r := s.trajectories
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var recs []arrow.Record
+	for _, regime := range r.t.regimes {
+		b := array.NewRecordBuilder(memory.DefaultAllocator, trajectorySchema)
+		for ri, tr := range r.t.runs[regime] {
+			for turn := range tr.SD {
+				b.Field(0).(*array.StringBuilder).Append(regime)
+				b.Field(1).(*array.Int32Builder).Append(int32(ri + 1))
+				b.Field(2).(*array.Int32Builder).Append(int32(turn))
+				b.Field(3).(*array.Float64Builder).Append(tr.SD[turn])
+				b.Field(4).(*array.Float64Builder).Append(tr.Gini[turn])
+			}
+		}
+		recs = append(recs, b.NewRecord())
+		b.Release()
+	}
+	return recs
+}
+
+func (s *flightServer) snapshotRecords() []arrow.Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var recs []arrow.Record
+	for _, act := range s.acts {
+		for ri, snap := range s.snaps[act] {
+			if snap.wealth == nil {
+				continue // not started
+			}
+			b := array.NewRecordBuilder(memory.DefaultAllocator, snapshotSchema)
+			for i, w := range snap.wealth {
+				b.Field(0).(*array.StringBuilder).Append(act.String())
+				b.Field(1).(*array.Int32Builder).Append(int32(ri + 1))
+				b.Field(2).(*array.Int32Builder).Append(int32(snap.turn))
+				b.Field(3).(*array.Int32Builder).Append(int32(i))
+				b.Field(4).(*array.Float64Builder).Append(w)
+			}
+			recs = append(recs, b.NewRecord())
+			b.Release()
+		}
+	}
+	return recs
+}
+
+// info describes the named table as a flight.
+func (s *flightServer) info(name string) (*flight.FlightInfo, error) {
+	schema, recs, err := s.records(name)
+	if err != nil {
+		return nil, err
+	}
+	rows := int64(0)
+	for _, rec := range recs {
+		rows += rec.NumRows()
+		rec.Release()
+	}
+	return &flight.FlightInfo{
+		Schema:           flight.SerializeSchema(schema, memory.DefaultAllocator),
+		FlightDescriptor: &flight.FlightDescriptor{Type: flight.DescriptorPATH, Path: []string{name}},
+		Endpoint:         []*flight.FlightEndpoint{{Ticket: &flight.Ticket{Ticket: []byte(name)}}},
+		TotalRecords:     rows,
+		TotalBytes:       -1,
+	}, nil
+}
+
+func (s *flightServer) ListFlights(c *flight.Criteria, stream flight.FlightService_ListFlightsServer) error {
+	for _, name := range flightTables {
+		info, err := s.info(name)
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *flightServer) GetFlightInfo(ctx context.Context, desc *flight.FlightDescriptor) (*flight.FlightInfo, error) {
+	if desc.Type != flight.DescriptorPATH || len(desc.Path) != 1 {
+		return nil, status.Error(codes.InvalidArgument, "want a path of one table name")
+	}
+	return s.info(desc.Path[0])
+}
+
+func (s *flightServer) DoGet(tkt *flight.Ticket, stream flight.FlightService_DoGetServer) error {
+	schema, recs, err := s.records(string(tkt.Ticket))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, rec := range recs {
+			rec.Release()
+		}
+	}()
+	w := flight.NewRecordWriter(stream, ipc.WithSchema(schema))
+	defer w.Close()
+	for _, rec := range recs {
+		if err := w.Write(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newFlight returns a Flight server of s, listening at addr.
+func newFlight(s *flightServer, addr string) (flight.Server, error) {
+	server := flight.NewServerWithMiddleware(nil)
+	if err := server.Init(addr); err != nil {
+		return nil, err
+	}
+	server.RegisterFlightService(s)
+	return server, nil
+}
+
+// serveFlight serves s at addr until the server fails.
+func serveFlight(s *flightServer, addr string) {
+	defer close(s.stopped)
+	server, err := newFlight(s, addr)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	log.Printf("Arrow Flight at %s", server.Addr())
+	log.Print(server.Serve())
+}
+
+// hold keeps the server up once the experiment is over, until the process
+// is interrupted.
+func (s *flightServer) hold() {
+	fmt.Printf("Experiment finished; Arrow Flight is still serving (interrupt to quit)\n")
+	<-s.stopped
+}
@@ -0,0 +1,122 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"context"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/flight"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestFlightTables(t *testing.T) {
+	defer func(runs, turns int) { NumRuns, NumTurns = runs, turns }(NumRuns, NumTurns)
+	NumRuns, NumTurns = 2, 2
+	acts := []ActivationOrder{uniform, poisson}
+	trajectories := newTrajectoryRecorder(acts)
+	s := newFlightServer(acts, trajectories)
+	for turn := 0; turn <= NumTurns; turn++ {
+		wealth := []float64{1, 2, float64(3 + turn)}
+		trajectories.Turn(uniform, 1, turn, float64(turn), wealth)
+		s.Turn(uniform, 1, turn, float64(turn), wealth)
+	}
+
+	_, recs, err := s.records("trajectories")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 2 || recs[0].NumRows() != 3 || recs[1].NumRows() != 0 {
+		t.Fatalf("%d trajectory records", len(recs))
+	}
+	if run := recs[0].Column(1).(*array.Int32).Value(0); run != 2 {
+		t.Errorf("run %d, want 2", run)
+	}
+	if sd := recs[0].Column(3).(*array.Float64).Value(2); sd != 2 {
+		t.Errorf("sd after turn 2 is %g, want 2", sd)
+	}
+
+	_, recs, err = s.records("snapshots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 1 || recs[0].NumRows() != 3 {
+		t.Fatalf("%d snapshot records", len(recs))
+	}
+	if turn, w := recs[0].Column(2).(*array.Int32).Value(2), recs[0].Column(4).(*array.Float64).Value(2); turn != 2 || w != 5 {
+		t.Errorf("agent 2 has %g after turn %d, want 5 after 2", w, turn)
+	}
+
+	if _, _, err := s.records("runs"); status.Code(err) != codes.NotFound {
+		t.Errorf("unknown table: %v", err)
+	}
+}
+
+// TestFlightWire fetches a table over a socket, through a Flight client.
+func TestFlightWire(t *testing.T) {
+	defer func(runs, turns int) { NumRuns, NumTurns = runs, turns }(NumRuns, NumTurns)
+	NumRuns, NumTurns = 1, 2
+	acts := []ActivationOrder{uniform}
+	trajectories := newTrajectoryRecorder(acts)
+	s := newFlightServer(acts, trajectories)
+	for turn := 0; turn <= NumTurns; turn++ {
+		trajectories.Turn(uniform, 0, turn, float64(turn), []float64{1, 2, 3})
+	}
+	server, err := newFlight(s, "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve()
+	defer server.Shutdown()
+	client, err := flight.NewClientWithMiddleware(server.Addr().String(), nil, nil,
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	info, err := client.GetFlightInfo(ctx, &flight.FlightDescriptor{Type: flight.DescriptorPATH, Path: []string{"trajectories"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.TotalRecords != 3 {
+		t.Errorf("%d trajectory rows listed, want 3", info.TotalRecords)
+	}
+	stream, err := client.DoGet(ctx, info.Endpoint[0].Ticket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := flight.NewRecordReader(stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Release()
+	rows := 0
+	for r.Next() {
+		rec := r.Record()
+		for i := 0; i < int(rec.NumRows()); i++ {
+			if turn, sd := rec.Column(2).(*array.Int32).Value(i), rec.Column(3).(*array.Float64).Value(i); sd != float64(turn) {
+				t.Errorf("sd after turn %d is %g, want %d", turn, sd, turn)
+			}
+		}
+		rows += int(rec.NumRows())
+	}
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if rows != 3 {
+		t.Errorf("%d trajectory rows fetched, want 3", rows)
+	}
+
+	stream, err = client.DoGet(ctx, &flight.Ticket{Ticket: []byte("runs")})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("unknown table: %v", err)
+	}
+}
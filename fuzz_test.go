@@ -0,0 +1,77 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// fuzzWealth makes the initial wealth of n agents of the given kind.
+func fuzzWealth(kind uint8, n int, rng *rand.Rand) []float64 {
+	wealth := make([]float64, n)
+	for i := range wealth {
+		if k := kind % 6; k == 0 { // all equal
+			wealth[i] = 7
+		} else if k == 1 { // all broke
+			wealth[i] = 0
+		} else if k == 2 { // some broke
+			wealth[i] = float64(rng.Intn(2) * rng.Intn(100))
+		} else if k == 3 { // dust, whose reciprocals overflow
+			wealth[i] = 5e-324 * float64(1+rng.Intn(3))
+		} else if k == 4 { // fortunes, as large as their squares allow
+			wealth[i] = 1e150 * float64(1+rng.Intn(1000))
+		} else {
+			wealth[i] = math.Floor(1000 * rng.Float64())
+		}
+	}
+	return wealth
+}
+
+// FuzzSchedulers runs a few turns of every regime on tiny, degenerate and
+// extreme populations, optionally with a poisson rate function of the given
+// scale, and checks that they neither fail nor make wealth from nothing.
+func FuzzSchedulers(f *testing.F) {
+	for act := uint8(0); act <= uint8(localPoisson); act++ {
+		for kind := uint8(0); kind < 6; kind++ {
+			f.Add(uint8(1+kind%3), act, kind, int64(act), 0.0, kind%2 == 0)
+		}
+		f.Add(uint8(2), act, uint8(5), int64(1), 1e300, false)
+		f.Add(uint8(3), act, uint8(2), int64(2), math.Inf(1), true)
+		f.Add(uint8(4), act, uint8(0), int64(3), -1.0, false)
+	}
+	f.Fuzz(func(t *testing.T, agents, act, kind uint8, seed int64, lamScale float64, counts bool) {
+		defer func(n int, sampling string) { NumOfAgents, EventSampling = n, sampling }(NumOfAgents, EventSampling)
+		NumOfAgents = 1 + int(agents%8)
+		EventSampling = "waiting"
+		if counts {
+			EventSampling = "counts"
+		}
+		rng := rand.New(rand.NewSource(seed))
+		m := NewModel(ActivationOrder(act%uint8(localPoisson+1)), rng)
+		defer m.Release()
+		copy(m.Pop.Wealth, fuzzWealth(kind, NumOfAgents, rng))
+		if lamScale != 0 {
+			if !(lamScale >= 0) {
+				return // Poisact rejects negative rates
+			}
+			m.Lambda = func(wealth, mean, spread float64) float64 { return lamScale * (1 + math.Abs(wealth-mean)) }
+		}
+		total := totalWealth(m.Pop.Wealth)
+		for turn := 0; turn < 3; turn++ {
+			m.Step()
+			for i, w := range m.Pop.Wealth {
+				if w < 0 || math.IsNaN(w) || math.IsInf(w, 0) {
+					t.Fatalf("%s, turn %d: agent %d has wealth %v", m.Activation, turn+1, i, w)
+				}
+			}
+			for _, ev := range m.aTimes {
+				if ev.time < 0 || ev.time >= 1 || int(ev.agent) >= NumOfAgents {
+					t.Fatalf("%s, turn %d: event %+v", m.Activation, turn+1, ev)
+				}
+			}
+			if next := totalWealth(m.Pop.Wealth); next > total*(1+1e-12) { // up to rounding
+				t.Fatalf("%s, turn %d: total wealth rose from %v to %v", m.Activation, turn+1, total, next)
+			}
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+/* Geography */
+
+// A Site is an agent's location, with an optional region code.
+type Site struct {
+	X, Y   float64
+	Region string
+}
+
+/*
+ * With a Geography, the weight of a candidate partner decays with its
+ * distance from the activated agent, so most exchanges are local but long
+ * range ones still happen. DistanceDecay says how: "exponential", by
+ * exp(-d/DecayScale), or "power", by (1+d)^-DecayExponent as in gravity
+ * models, whose long range exchanges are more common.
+ */
+type Geography struct {
+	Decay func(d float64) float64
+}
+
+// ExponentialDecay weights partners by exp(-d/scale).
+func ExponentialDecay(scale float64) func(d float64) float64 {
+	return func(d float64) float64 {
+		return math.Exp(-d / scale)
+	}
+}
+
+// PowerDecay weights partners by (1+d)^-exponent, as in gravity models.
+func PowerDecay(exponent float64) func(d float64) float64 {
+	return func(d float64) float64 {
+		return math.Pow(1+d, -exponent)
+	}
+}
+
+// distanceDecay returns the decay DistanceDecay names.
+func distanceDecay() (func(d float64) float64, error) {
+	if DistanceDecay == "exponential" {
+		return ExponentialDecay(DecayScale), nil
+	} else if DistanceDecay == "power" {
+		return PowerDecay(DecayExponent), nil
+	}
+	return nil, fmt.Errorf("unknown DistanceDecay %q (want exponential or power)", DistanceDecay)
+}
+
+// checkGeography reports whether DistanceDecay names a decay.
+func checkGeography() error {
+	_, err := distanceDecay()
+	return err
+}
+
+// weight returns the distance-decay weight of a pairing between a and b.
+func (g *Geography) weight(a, b *Agent) float64 {
+	return g.Decay(math.Hypot(a.x-b.x, a.y-b.y))
+}
+
+// LoadSites reads one site per agent from CSV rows of x, y and an optional
+// region code. A first row whose x is not a number is taken to be a header.
+func LoadSites(r io.Reader, n int) ([]Site, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) > 0 && len(rows[0]) > 0 {
+		if _, err := strconv.ParseFloat(rows[0][0], 64); err != nil {
+			rows = rows[1:]
+		}
+	}
+	if len(rows) != n {
+		return nil, fmt.Errorf("coordinates: got %d sites for %d agents", len(rows), n)
+	}
+	sites := make([]Site, n)
+	for i, row := range rows {
+		if len(row) != 2 && len(row) != 3 {
+			return nil, fmt.Errorf("coordinates row %d: want 2 or 3 fields, got %d", i+1, len(row))
+		}
+		if sites[i].X, err = strconv.ParseFloat(row[0], 64); err != nil {
+			return nil, fmt.Errorf("coordinates row %d: %v", i+1, err)
+		}
+		if sites[i].Y, err = strconv.ParseFloat(row[1], 64); err != nil {
+			return nil, fmt.Errorf("coordinates row %d: %v", i+1, err)
+		}
+		if len(row) == 3 {
+			sites[i].Region = row[2]
+		}
+	}
+	return sites, nil
+}
+
+// PlaceAgents puts agent i at sites[i]. Region codes become the agents'
+// group tags, numbered in order of first appearance, so homophily by tag
+// means preferring partners from the same region.
+func PlaceAgents(Pop Population, sites []Site) {
+	codes := make(map[string]int)
+	for i := 0; i < Pop.Len(); i++ {
+		Pop.Agents[i].x, Pop.Agents[i].y = sites[i].X, sites[i].Y
+		if sites[i].Region == "" {
+			continue
+		}
+		if _, ok := codes[sites[i].Region]; !ok {
+			codes[sites[i].Region] = len(codes)
+		}
+		Pop.Agents[i].tag = codes[sites[i].Region]
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// TestLoadSites checks that sites are read with or without a header and
+// region codes, that regions become group tags, and that a file with the
+// wrong number of sites or a bad row is refused.
+func TestLoadSites(t *testing.T) {
+	sites, err := LoadSites(strings.NewReader("x,y,region\n0,0,north\n3,4,south\n1.5,2,north\n"), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sites[1] != (Site{3, 4, "south"}) {
+		t.Errorf("site 1 is %+v", sites[1])
+	}
+	Pop := NewPopulation(3)
+	PlaceAgents(Pop, sites)
+	if a := Pop.Agents[2]; a.x != 1.5 || a.y != 2 || a.tag != 0 || Pop.Agents[1].tag != 1 {
+		t.Errorf("agents placed at %+v, %+v", Pop.Agents[1], Pop.Agents[2])
+	}
+	if _, err := LoadSites(strings.NewReader("0,0\n1,1\n"), 2); err != nil {
+		t.Errorf("no header or regions: %v", err)
+	}
+	for _, bad := range []string{"0,0\n", "0,0\n1,1\n2,2\n", "0,0\n1\n", "0,0\n1,y\n"} {
+		if _, err := LoadSites(strings.NewReader(bad), 2); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
+
+// TestGeographicPairing checks that nearer agents are chosen as partners
+// more often, but that the far ones still are sometimes.
+func TestGeographicPairing(t *testing.T) {
+	defer func(agents int) { NumOfAgents = agents }(NumOfAgents)
+	NumOfAgents = 3
+	m := NewModel(random, rand.New(rand.NewSource(2)))
+	PlaceAgents(m.Pop, []Site{{X: 0}, {X: 1}, {X: 5}})
+	m.Geography = &Geography{Decay: ExponentialDecay(2)}
+	counts := make([]int, 3)
+	for k := 0; k < 3000; k++ {
+		counts[m.randomPartner(0)]++
+	}
+	if counts[0] != 0 || counts[1] <= 4*counts[2] || counts[2] == 0 {
+		t.Errorf("agent 0's partners %v, want mostly the nearer agent 1", counts)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Every regime's trajectories in a small fixed-seed experiment are checked
+// against testdata/golden/<regime>.golden, so that changes meant to leave
+// the model's behavior alone can be seen to. After a change that is meant
+// to alter it, rewrite the files with
+//
+//	go test -run TestGolden -update
+//
+// and review their diff with the change.
+var updateGolden = flag.Bool("update", false, "rewrite the golden files")
+
+// goldenTolerance is the relative difference allowed, for platforms that
+// fuse multiply-adds or libraries that sum in another order.
+const goldenTolerance = 1e-9
+
+func TestGolden(t *testing.T) {
+	defer func(runs, turns, agents int) {
+		NumRuns, NumTurns, NumOfAgents = runs, turns, agents
+	}(NumRuns, NumTurns, NumOfAgents)
+	NumRuns, NumTurns, NumOfAgents = 2, 12, 64
+	acts := []ActivationOrder{uniform, random, poisson, inversePoisson, naturalPoisson, localPoisson}
+	matrices, collect := resultMatrices(acts)
+	if err := RunExperiment(acts, 20170412, collect); err != nil {
+		t.Fatal(err)
+	}
+	for a, act := range acts {
+		var got strings.Builder
+		fmt.Fprintf(&got, "# %s: wealth SD by run and turn, %d agents, seed 20170412\n", act, NumOfAgents)
+		for r := 0; r < NumRuns; r++ {
+			for turn := 0; turn < NumTurns; turn++ {
+				fmt.Fprintf(&got, "%d %d %.17g\n", r+1, turn, matrices[a].At(r, turn))
+			}
+		}
+		name := filepath.Join("testdata", "golden", strings.Replace(act.String(), " ", "_", -1)+".golden")
+		if *updateGolden {
+			if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(name, []byte(got.String()), 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := compareGolden(got.String(), string(want)); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+// compareGolden reports the first line where got differs from want by more
+// than goldenTolerance.
+func compareGolden(got, want string) error {
+	gotLines := bufio.NewScanner(strings.NewReader(got))
+	wantLines := bufio.NewScanner(strings.NewReader(want))
+	for line := 1; ; line++ {
+		moreGot, moreWant := gotLines.Scan(), wantLines.Scan()
+		if !moreGot || !moreWant {
+			if moreGot != moreWant {
+				return fmt.Errorf("line %d: one trajectory is longer than the other", line)
+			}
+			return nil
+		}
+		g, w := gotLines.Text(), wantLines.Text()
+		if g == w || strings.HasPrefix(w, "#") {
+			continue
+		}
+		var gr, gt, wr, wt int
+		var gv, wv float64
+		_, err1 := fmt.Sscan(g, &gr, &gt, &gv)
+		_, err2 := fmt.Sscan(w, &wr, &wt, &wv)
+		if err1 != nil || err2 != nil || gr != wr || gt != wt {
+			return fmt.Errorf("line %d: got %q, want %q", line, g, w)
+		}
+		if math.Abs(gv-wv) > goldenTolerance*math.Max(math.Abs(wv), 1) {
+			return fmt.Errorf("run %d turn %d: SD %v, want %v", wr, wt, gv, wv)
+		}
+	}
+}
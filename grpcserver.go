@@ -0,0 +1,313 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/status"
+	"io"
+	"log"
+	"net"
+)
+
+/* gRPC service */
+
+/*
+ * "grpc -listen :9090" serves the Simulation service of simulation.proto,
+ * over the same job queue as "serve", so other systems can submit runs and
+ * follow their metrics turn by turn with typed messages. There is no
+ * generated code: the service is described to grpc-go by hand below, and
+ * messages go through protoCodec, which uses the hand-written encoding in
+ * protowire.go -- the same bytes generated code would produce, so clients
+ * generated from simulation.proto in any language can talk to it. The codec
+ * is registered under a name of its own and forced on this service's server
+ * and client alone: grpc-go's "proto" codec stays as it is for any other
+ * service in the process, such as Arrow Flight's (see flight.go).
+ *
+ * "grpc-run -addr host:9090 [-activation ...]" is a client: it submits a
+ * run, prints its metrics as they stream in, and then fetches the results.
+ */
+
+func init() {
+	encoding.RegisterCodec(protoCodec{})
+}
+
+// protoCodec is a codec for the protoMessages here, which grpc-go's own
+// "proto" codec, taking only generated messages, can't encode.
+type protoCodec struct{}
+
+func (protoCodec) Name() string {
+	return "leveler-proto"
+}
+
+func (protoCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(protoMessage)
+	if !ok {
+		return nil, fmt.Errorf("protoCodec: can't marshal %T", v)
+	}
+	return m.marshalProto(), nil
+}
+
+func (protoCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(protoMessage)
+	if !ok {
+		return fmt.Errorf("protoCodec: can't unmarshal %T", v)
+	}
+	return m.unmarshalProto(data)
+}
+
+// simulationService is the Simulation service.
+type simulationService interface {
+	SubmitRun(ctx context.Context, cfg *RunConfig) (*RunHandle, error)
+	StreamTurnMetrics(h *RunHandle, stream turnSender) error
+	GetResults(ctx context.Context, h *RunHandle) (*RunResult, error)
+}
+
+// turnSender is the server's end of a StreamTurnMetrics call.
+type turnSender interface {
+	Send(t *TurnMetrics) error
+	Context() context.Context
+}
+
+// simulationServer implements simulationService over a jobServer.
+type simulationServer struct {
+	jobs *jobServer
+}
+
+func (s *simulationServer) SubmitRun(ctx context.Context, cfg *RunConfig) (*RunHandle, error) {
+	j, err := s.jobs.enqueue(*cfg)
+	if err == errQueueFull {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	} else if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &RunHandle{j.ID}, nil
+}
+
+func (s *simulationServer) StreamTurnMetrics(h *RunHandle, stream turnSender) error {
+	sent := 0
+	for {
+		turns, over, changed, ok := s.jobs.progress(h.ID)
+		if !ok {
+			return status.Errorf(codes.NotFound, "no run %q", h.ID)
+		}
+		for ; sent < len(turns); sent++ {
+			if err := stream.Send(&turns[sent]); err != nil {
+				return err
+			}
+		}
+		if over {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+}
+
+func (s *simulationServer) GetResults(ctx context.Context, h *RunHandle) (*RunResult, error) {
+	st, res, msg, ok := s.jobs.lookup(h.ID)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "no run %q", h.ID)
+	} else if st == jobFailed {
+		return nil, status.Errorf(codes.Aborted, "run failed: %s", msg)
+	} else if st != jobDone {
+		return nil, status.Errorf(codes.FailedPrecondition, "run is %s", st)
+	}
+	return res, nil
+}
+
+const simulationServiceName = "redistribution.Simulation"
+
+// simulationServiceDesc is what protoc-gen-go-grpc would generate for the
+// Simulation service.
+var simulationServiceDesc = grpc.ServiceDesc{
+	ServiceName: simulationServiceName,
+	HandlerType: (*simulationService)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "SubmitRun", Handler: unaryHandler("SubmitRun", func() protoMessage { return new(RunConfig) },
+			func(s simulationService, ctx context.Context, req protoMessage) (interface{}, error) {
+				return s.SubmitRun(ctx, req.(*RunConfig))
+			})},
+		{MethodName: "GetResults", Handler: unaryHandler("GetResults", func() protoMessage { return new(RunHandle) },
+			func(s simulationService, ctx context.Context, req protoMessage) (interface{}, error) {
+				return s.GetResults(ctx, req.(*RunHandle))
+			})},
+	},
+	Streams: []grpc.StreamDesc{
+		{StreamName: "StreamTurnMetrics", ServerStreams: true, Handler: func(srv interface{}, stream grpc.ServerStream) error {
+			h := new(RunHandle)
+			if err := stream.RecvMsg(h); err != nil {
+				return err
+			}
+			return srv.(simulationService).StreamTurnMetrics(h, turnStream{stream})
+		}},
+	},
+	Metadata: "simulation.proto",
+}
+
+// unaryHandler returns the handler for a unary method, decoding its request
+// into newReq() and passing it through any interceptor to call.
+func unaryHandler(method string, newReq func() protoMessage,
+	call func(s simulationService, ctx context.Context, req protoMessage) (interface{}, error),
+) func(interface{}, context.Context, func(interface{}) error, grpc.UnaryServerInterceptor) (interface{}, error) {
+	return func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+		req := newReq()
+		if err := dec(req); err != nil {
+			return nil, err
+		}
+		if interceptor == nil {
+			return call(srv.(simulationService), ctx, req)
+		}
+		info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + simulationServiceName + "/" + method}
+		return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return call(srv.(simulationService), ctx, req.(protoMessage))
+		})
+	}
+}
+
+// turnStream is a turnSender over a gRPC server stream.
+type turnStream struct {
+	grpc.ServerStream
+}
+
+func (t turnStream) Send(m *TurnMetrics) error {
+	return t.ServerStream.SendMsg(m)
+}
+
+// simulationClient calls a Simulation service.
+type simulationClient struct {
+	cc *grpc.ClientConn
+}
+
+// dialSimulation connects, without TLS, to the service at addr.
+func dialSimulation(addr string) (*simulationClient, error) {
+	cc, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(protoCodec{})))
+	if err != nil {
+		return nil, err
+	}
+	return &simulationClient{cc}, nil
+}
+
+func (c *simulationClient) method(name string) string {
+	return "/" + simulationServiceName + "/" + name
+}
+
+// SubmitRun queues a run and returns its ID.
+func (c *simulationClient) SubmitRun(ctx context.Context, cfg RunConfig) (string, error) {
+	var h RunHandle
+	if err := c.cc.Invoke(ctx, c.method("SubmitRun"), &cfg, &h); err != nil {
+		return "", err
+	}
+	return h.ID, nil
+}
+
+// StreamTurnMetrics calls f with each turn's metrics of run id, returning
+// once the run is over.
+func (c *simulationClient) StreamTurnMetrics(ctx context.Context, id string, f func(TurnMetrics)) error {
+	stream, err := c.cc.NewStream(ctx, &simulationServiceDesc.Streams[0], c.method("StreamTurnMetrics"))
+	if err != nil {
+		return err
+	}
+	if err := stream.SendMsg(&RunHandle{id}); err != nil {
+		return err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return err
+	}
+	for {
+		var t TurnMetrics
+		if err := stream.RecvMsg(&t); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		f(t)
+	}
+}
+
+// GetResults returns the results of run id.
+func (c *simulationClient) GetResults(ctx context.Context, id string) (*RunResult, error) {
+	res := new(RunResult)
+	if err := c.cc.Invoke(ctx, c.method("GetResults"), &RunHandle{id}, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Close closes the connection.
+func (c *simulationClient) Close() error {
+	return c.cc.Close()
+}
+
+// newGRPCServer returns a server of the Simulation service, over a job queue
+// of its own.
+func newGRPCServer() *grpc.Server {
+	server := grpc.NewServer(grpc.ForceServerCodec(protoCodec{}))
+	server.RegisterService(&simulationServiceDesc, &simulationServer{newJobServer()})
+	return server
+}
+
+// runGRPC serves the Simulation service until the server fails.
+func runGRPC(args []string) error {
+	fs := flag.NewFlagSet("grpc", flag.ContinueOnError)
+	listen := fs.String("listen", ":9090", "address to serve on")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	lis, err := net.Listen("tcp", *listen)
+	if err != nil {
+		return err
+	}
+	server := newGRPCServer()
+	log.Printf("serving gRPC on %s", *listen)
+	return server.Serve(lis)
+}
+
+// runGRPCClient submits one run to a Simulation service and prints what
+// comes back.
+func runGRPCClient(args []string) error {
+	fs := flag.NewFlagSet("grpc-run", flag.ContinueOnError)
+	addr := fs.String("addr", "localhost:9090", "address of the service")
+	var cfg RunConfig
+	fs.StringVar(&cfg.Activation, "activation", "", "activation regime (default the server's)")
+	fs.IntVar(&cfg.Agents, "agents", 0, "number of agents (default the server's)")
+	fs.IntVar(&cfg.Turns, "turns", 0, "number of turns (default the server's)")
+	fs.Int64Var(&cfg.Seed, "seed", 0, "seed")
+	fs.StringVar(&cfg.RNG, "rng", "", "random number generator (default the server's)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	c, err := dialSimulation(*addr)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	ctx := context.Background()
+	id, err := c.SubmitRun(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Run %s submitted\nTurn\tSD\t\tGini\n", id)
+	err = c.StreamTurnMetrics(ctx, id, func(t TurnMetrics) {
+		fmt.Printf("%d\t%f\t%f\n", t.Turn, t.SD, t.Gini)
+	})
+	if err != nil {
+		return err
+	}
+	res, err := c.GetResults(ctx, id)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Run %s finished: %d agents, final SD %f\n", id, len(res.Wealth), res.SDs[len(res.SDs)-1])
+	return nil
+}
@@ -0,0 +1,113 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeTurnStream collects what StreamTurnMetrics sends.
+type fakeTurnStream struct {
+	sent []TurnMetrics
+}
+
+func (f *fakeTurnStream) Send(t *TurnMetrics) error {
+	f.sent = append(f.sent, *t)
+	return nil
+}
+
+func (f *fakeTurnStream) Context() context.Context {
+	return context.Background()
+}
+
+// TestSimulationServer drives the service's methods directly: a run is
+// submitted, its turns are streamed until it ends, and its results agree
+// with them.
+func TestSimulationServer(t *testing.T) {
+	defer currentParams().apply()
+	s := &simulationServer{newJobServer()}
+	ctx := context.Background()
+
+	if _, err := s.SubmitRun(ctx, &RunConfig{Activation: "no such regime"}); err == nil {
+		t.Error("bad activation accepted")
+	}
+	h, err := s.SubmitRun(ctx, &RunConfig{Activation: "uniform", Agents: 100, Turns: 6, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stream fakeTurnStream
+	if err := s.StreamTurnMetrics(h, &stream); err != nil {
+		t.Fatal(err)
+	}
+	res, err := s.GetResults(ctx, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stream.sent) != 7 || len(res.SDs) != 7 {
+		t.Fatalf("%d turns streamed and %d SDs, want 7 of each", len(stream.sent), len(res.SDs))
+	}
+	for i, turn := range stream.sent {
+		if turn.Turn != i || turn.SD != res.SDs[i] {
+			t.Errorf("turn %d streamed as %+v, SD %v in results", i, turn, res.SDs[i])
+		}
+	}
+	if _, err := s.GetResults(ctx, &RunHandle{"99"}); err == nil {
+		t.Error("results for an unknown run")
+	}
+}
+
+// TestGRPCWire runs the service over a socket, through the client, and
+// checks grpc-go's own proto codec is left for other services.
+func TestGRPCWire(t *testing.T) {
+	defer currentParams().apply()
+	if _, ok := encoding.GetCodec("leveler-proto").(protoCodec); !ok {
+		t.Error("protoCodec isn't registered")
+	}
+	if _, err := encoding.GetCodecV2("proto").Marshal(durationpb.New(time.Second)); err != nil {
+		t.Errorf("grpc-go's proto codec can't marshal generated messages: %v", err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newGRPCServer()
+	go server.Serve(lis)
+	defer server.Stop()
+	c, err := dialSimulation(lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx := context.Background()
+	id, err := c.SubmitRun(ctx, RunConfig{Activation: "uniform", Agents: 100, Turns: 4, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var turns []TurnMetrics
+	if err := c.StreamTurnMetrics(ctx, id, func(m TurnMetrics) { turns = append(turns, m) }); err != nil {
+		t.Fatal(err)
+	}
+	res, err := c.GetResults(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(turns) != 5 || len(res.SDs) != 5 || len(res.Wealth) != 100 {
+		t.Fatalf("%d turns streamed, %d SDs and %d agents, want 5, 5 and 100", len(turns), len(res.SDs), len(res.Wealth))
+	}
+	for i, turn := range turns {
+		if turn.SD != res.SDs[i] {
+			t.Errorf("turn %d streamed SD %v, %v in results", i, turn.SD, res.SDs[i])
+		}
+	}
+	if _, err := c.GetResults(ctx, "99"); status.Code(err) != codes.NotFound {
+		t.Errorf("results for an unknown run: %v", err)
+	}
+}
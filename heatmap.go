@@ -0,0 +1,192 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/palette"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+	vgdraw "gonum.org/v1/plot/vg/draw"
+	"image/color"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+/* Sweep heatmaps */
+
+/*
+ * "plot -sweep sweep.csv" draws the results of a sweep over two parameters
+ * as a heatmap, heatmap_<z>.png, of the mean of column z (-z; "gradient" by
+ * default, or "final_gini", say) at each point of the grid. The file has a
+ * header row; its first two columns are the parameters and every other
+ * column is a result, with a row per run, and runs at the same point are
+ * averaged; the sweep subcommand writes such files. With -contours n, n
+ * contour lines at evenly spaced levels are drawn over the heatmap. Points
+ * of the grid with no runs are left blank, and a grid with any can't be
+ * contoured.
+ */
+
+const heatmapColors = 32
+
+// A sweepGrid is the mean of one result over a two-parameter grid. It is a
+// plotter.GridXYZ.
+type sweepGrid struct {
+	xName, yName, zName string
+	xs, ys              []float64   // ascending
+	z                   [][]float64 // by x then y; NaN where there were no runs
+}
+
+func (g *sweepGrid) Dims() (c, r int)   { return len(g.xs), len(g.ys) }
+func (g *sweepGrid) Z(c, r int) float64 { return g.z[c][r] }
+func (g *sweepGrid) X(c int) float64    { return g.xs[c] }
+func (g *sweepGrid) Y(r int) float64    { return g.ys[r] }
+
+// Min and Max are the range of the grid's values, leaving out missing ones,
+// which plotter.NewHeatMap would otherwise take them from.
+func (g *sweepGrid) Min() float64 {
+	lo, _ := g.zRange()
+	return lo
+}
+
+func (g *sweepGrid) Max() float64 {
+	_, hi := g.zRange()
+	return hi
+}
+
+func (g *sweepGrid) zRange() (lo, hi float64) {
+	lo, hi = math.Inf(1), math.Inf(-1)
+	for _, col := range g.z {
+		for _, v := range col {
+			if !math.IsNaN(v) {
+				lo, hi = math.Min(lo, v), math.Max(hi, v)
+			}
+		}
+	}
+	return lo, hi
+}
+
+// complete reports whether every point of the grid had a run.
+func (g *sweepGrid) complete() bool {
+	for _, col := range g.z {
+		for _, v := range col {
+			if math.IsNaN(v) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// readSweep reads a sweep's results and returns the grid of the means of
+// column z.
+func readSweep(r io.Reader, z string) (*sweepGrid, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) < 2 || len(rows[0]) < 3 {
+		return nil, errors.New("a sweep needs a header, two parameters, a result and at least one run")
+	}
+	col := -1
+	for i, name := range rows[0][2:] {
+		if name == z {
+			col = i + 2
+		}
+	}
+	if col < 0 {
+		return nil, fmt.Errorf("sweep has no column %q", z)
+	}
+	type point struct{ x, y float64 }
+	sums, counts := make(map[point]float64), make(map[point]int)
+	xs, ys := make(map[float64]bool), make(map[float64]bool)
+	for i, row := range rows[1:] {
+		if len(row) != len(rows[0]) {
+			return nil, fmt.Errorf("sweep line %d: %d fields, want %d", i+2, len(row), len(rows[0]))
+		}
+		x, err1 := strconv.ParseFloat(row[0], 64)
+		y, err2 := strconv.ParseFloat(row[1], 64)
+		v, err3 := strconv.ParseFloat(row[col], 64)
+		if err := firstError(err1, err2, err3); err != nil {
+			return nil, fmt.Errorf("sweep line %d: %v", i+2, err)
+		}
+		sums[point{x, y}] += v
+		counts[point{x, y}]++
+		xs[x], ys[y] = true, true
+	}
+	g := &sweepGrid{xName: rows[0][0], yName: rows[0][1], zName: z, xs: sortedKeys(xs), ys: sortedKeys(ys)}
+	g.z = make([][]float64, len(g.xs))
+	for c, x := range g.xs {
+		g.z[c] = make([]float64, len(g.ys))
+		for r, y := range g.ys {
+			g.z[c][r] = math.NaN()
+			if n := counts[point{x, y}]; n > 0 {
+				g.z[c][r] = sums[point{x, y}] / float64(n)
+			}
+		}
+	}
+	return g, nil
+}
+
+func sortedKeys(set map[float64]bool) []float64 {
+	keys := make([]float64, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Float64s(keys)
+	return keys
+}
+
+// monochrome is a palette.Palette of one color, for contour lines.
+type monochrome []color.Color
+
+func (m monochrome) Colors() []color.Color {
+	return m
+}
+
+// drawSweep draws g as a heatmap, with contours contour lines over it, to
+// file.
+func drawSweep(g *sweepGrid, contours int, file string) error {
+	lo, hi := g.zRange()
+	p := plot.New()
+	p.Title.Text = fmt.Sprintf("Mean %s (%.3g to %.3g)", g.zName, lo, hi)
+	p.X.Label.Text = g.xName
+	p.Y.Label.Text = g.yName
+	heat := plotter.NewHeatMap(g, palette.Heat(heatmapColors, 1))
+	heat.NaN = color.Transparent
+	p.Add(heat)
+	if contours > 0 {
+		if !g.complete() {
+			return errors.New("can't draw contours over a sweep with missing points")
+		}
+		levels := make([]float64, contours)
+		for i := range levels {
+			levels[i] = lo + (hi-lo)*float64(i+1)/float64(contours+1)
+		}
+		c := plotter.NewContour(g, levels, monochrome{color.Black})
+		c.LineStyles = []vgdraw.LineStyle{{Color: color.Black, Width: vg.Points(0.75)}}
+		p.Add(c)
+	}
+	return p.Save(7*vg.Inch, 6*vg.Inch, file)
+}
+
+// plotSweepFile draws the heatmap of column z of the sweep in name, in dir.
+func plotSweepFile(name, z string, contours int, dir, format string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	g, err := readSweep(f, z)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	return drawSweep(g, contours, filepath.Join(dir, "heatmap_"+z+"."+format))
+}
@@ -0,0 +1,54 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"math"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testSweep = `Threshold,NumOfAgents,gradient,final_gini
+0.5,100,-0.2,0.1
+0.5,100,-0.4,0.3
+1,100,-0.1,0.2
+0.5,1000,-0.3,0.2
+`
+
+func TestReadSweep(t *testing.T) {
+	g, err := readSweep(strings.NewReader(testSweep), "gradient")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.xName != "Threshold" || g.yName != "NumOfAgents" {
+		t.Errorf("parameters %q and %q", g.xName, g.yName)
+	}
+	if !reflect.DeepEqual(g.xs, []float64{0.5, 1}) || !reflect.DeepEqual(g.ys, []float64{100, 1000}) {
+		t.Errorf("grid %v by %v", g.xs, g.ys)
+	}
+	if math.Abs(g.Z(0, 0)+0.3) > 1e-12 || g.Z(1, 0) != -0.1 || g.Z(0, 1) != -0.3 || !math.IsNaN(g.Z(1, 1)) {
+		t.Errorf("means %v", g.z)
+	}
+	if math.Abs(g.Min()+0.3) > 1e-12 || g.Max() != -0.1 || g.complete() {
+		t.Errorf("range %v to %v, complete %v", g.Min(), g.Max(), g.complete())
+	}
+	if _, err := readSweep(strings.NewReader(testSweep), "entropy"); err == nil {
+		t.Error("read a column the sweep doesn't have")
+	}
+}
+
+func TestDrawSweep(t *testing.T) {
+	g, err := readSweep(strings.NewReader(testSweep), "final_gini")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := drawSweep(g, 0, filepath.Join(dir, "heatmap.svg")); err != nil {
+		t.Fatal(err)
+	}
+	if err := drawSweep(g, 3, filepath.Join(dir, "heatmap.svg")); err == nil {
+		t.Error("contoured a grid with a missing point")
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import "math/rand"
+
+/* District hierarchy */
+
+/*
+ * A Hierarchy places every agent in a district and groups consecutive
+ * districts into regions. Leveling is local by default: each pairing stays
+ * within the activated agent's district unless, with probability
+ * CrossRegion, it may reach anyone, or, with probability CrossDistrict, any
+ * district of the same region.
+ */
+type Hierarchy struct {
+	Districts          int
+	DistrictsPerRegion int
+	CrossDistrict      float64
+	CrossRegion        float64
+}
+
+// pairing levels, from least to most restrictive
+const (
+	levelAll = iota
+	levelRegion
+	levelDistrict
+)
+
+// Assign spreads the Population evenly and at random over the districts.
+func (h *Hierarchy) Assign(Pop Population, rng *rand.Rand) {
+	order := rng.Perm(Pop.Len())
+	for k, i := range order {
+		Pop.Agents[i].district = k % h.Districts
+	}
+}
+
+// District returns the district a lives in.
+func (h *Hierarchy) District(a *Agent) int {
+	return a.district
+}
+
+// Region returns the region a lives in.
+func (h *Hierarchy) Region(a *Agent) int {
+	return a.district / h.DistrictsPerRegion
+}
+
+// level draws how far afield the next pairing may reach.
+func (h *Hierarchy) level(rng *rand.Rand) int {
+	u := rng.Float64()
+	if u < h.CrossRegion {
+		return levelAll
+	} else if u < h.CrossRegion+h.CrossDistrict {
+		return levelRegion
+	}
+	return levelDistrict
+}
+
+// within reports whether a and b may be paired at the given level. Without a
+// Hierarchy every pairing is allowed.
+func (h *Hierarchy) within(level int, a, b *Agent) bool {
+	if h == nil || level == levelAll {
+		return true
+	} else if level == levelRegion {
+		return h.Region(a) == h.Region(b)
+	}
+	return h.District(a) == h.District(b)
+}
+
+// Decompose splits the (population) variance of wealth into the variance
+// within groups and the variance between group means; the two sum to the
+// total.
+func Decompose(Pop Population, groupOf func(a *Agent) int) (within, between float64) {
+	sums := make(map[int]float64)
+	counts := make(map[int]float64)
+	total := 0.0
+	for i := 0; i < Pop.Len(); i++ {
+		g := groupOf(&Pop.Agents[i])
+		sums[g] += Pop.Wealth[i]
+		counts[g]++
+		total += Pop.Wealth[i]
+	}
+	n := float64(Pop.Len())
+	mean := total / n
+	for g := range sums {
+		d := sums[g]/counts[g] - mean
+		between += counts[g] * d * d / n
+	}
+	for i := 0; i < Pop.Len(); i++ {
+		g := groupOf(&Pop.Agents[i])
+		d := Pop.Wealth[i] - sums[g]/counts[g]
+		within += d * d / n
+	}
+	return within, between
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// TestDecompose checks the variance within and between groups against a
+// population small enough to work out by hand.
+func TestDecompose(t *testing.T) {
+	Pop := NewPopulation(4)
+	copy(Pop.Wealth, []float64{1, 3, 5, 7})
+	h := &Hierarchy{Districts: 2, DistrictsPerRegion: 2}
+	for i := range Pop.Agents {
+		Pop.Agents[i].district = i / 2 // {1, 3} and {5, 7}, in one region
+	}
+	within, between := Decompose(Pop, h.District)
+	if within != 1 || between != 4 {
+		t.Errorf("variance %v within districts and %v between, want 1 and 4", within, between)
+	}
+	if within, between := Decompose(Pop, h.Region); within != 5 || between != 0 {
+		t.Errorf("variance %v within the region and %v between, want 5 and 0", within, between)
+	}
+}
+
+// TestHierarchyPairing checks that pairings stay within the district unless
+// allowed to cross, and that Assign fills the districts evenly.
+func TestHierarchyPairing(t *testing.T) {
+	h := &Hierarchy{Districts: 4, DistrictsPerRegion: 2}
+	Pop := NewPopulation(40)
+	h.Assign(Pop, rand.New(rand.NewSource(1)))
+	counts := make([]int, h.Districts)
+	for i := range Pop.Agents {
+		counts[h.District(&Pop.Agents[i])]++
+	}
+	for d, c := range counts {
+		if c != 10 {
+			t.Errorf("district %d has %d agents, want 10", d, c)
+		}
+	}
+	a, b, c := &Agent{district: 0}, &Agent{district: 1}, &Agent{district: 2}
+	if h.within(levelDistrict, a, b) || !h.within(levelRegion, a, b) || h.within(levelRegion, a, c) || !h.within(levelAll, a, c) {
+		t.Error("pairing levels let the wrong agents meet")
+	}
+}
+
+// TestDistrictMetrics checks that a run with districts records the
+// decomposition every turn.
+func TestDistrictMetrics(t *testing.T) {
+	defer func(metrics []string, sample int) { Metrics, MetricSample = metrics, sample }(Metrics, MetricSample)
+	Metrics, MetricSample = []string{"gini"}, 0
+	Pop := NewPopulation(4)
+	copy(Pop.Wealth, []float64{1, 3, 5, 7})
+	for i := range Pop.Agents {
+		Pop.Agents[i].district = i / 2
+	}
+	r := newMetricRecorder(rand.New(rand.NewSource(1)), &Hierarchy{Districts: 2, DistrictsPerRegion: 1})
+	r.record(Pop, 0)
+	rows := strings.Split(strings.TrimSpace(r.rows.String()), "\n")
+	if want := "turn,gini,var_within_districts,var_between_districts,var_between_regions"; rows[0] != want {
+		t.Errorf("columns %q, want %q", rows[0], want)
+	}
+	if d := r.last[1]; d[0] != 1 || d[1] != 4 || d[2] != 4 {
+		t.Errorf("decomposition %v, want [1 4 4]", d)
+	}
+	if d := computeMetrics(r.metrics[1:], &snapshot{wealth: Pop.Wealth}, 1)[0]; !math.IsNaN(d[0]) {
+		t.Errorf("decomposition without districts %v, want NaN", d)
+	}
+}
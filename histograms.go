@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/* Per-turn wealth histograms */
+
+/*
+ * With HistogramsFile set, every turn's wealth distribution is also written
+ * there as a histogram of HistogramBins equal-width bins spanning that
+ * turn's wealth, to watch it collapse as the population levels. With
+ * HistogramRuns set only the first that many runs of each regime are
+ * written; runs are alike but for their seeds, so they're as good a sample
+ * as any. A file whose name ends in .json gets a JSON array of the turns,
+ *
+ *	[{"regime": "uniform", "run": 1, "turn": 0,
+ *	  "edges": [1, 100.9, ..., 1000], "counts": [100, 100, ..., 100]}, ...]
+ *
+ * with a bin's count between its edges; any other a CSV row per bin:
+ *
+ *	regime,run,turn,bin,lo,hi,count
+ *
+ * Runs count from 1 and turns from 0, before the first. Each run's
+ * histograms are written together when it completes, so runs appear in the
+ * order they finish.
+ */
+
+// A turnHistogram is one turn's histogram, as JSON.
+type turnHistogram struct {
+	Regime string    `json:"regime"`
+	Run    int       `json:"run"`
+	Turn   int       `json:"turn"`
+	Edges  []float64 `json:"edges"`
+	Counts []float64 `json:"counts"`
+}
+
+// A histogramWriter is an Observer that writes every turn's histogram.
+type histogramWriter struct {
+	mu     sync.Mutex
+	f      *os.File
+	w      *bufio.Writer
+	json   bool
+	turns  int                      // written so far, to separate JSON's
+	hists  map[cell][]turnHistogram // of runs in progress
+	failed error
+}
+
+// newHistogramWriter creates the file name and starts it.
+func newHistogramWriter(name string) (*histogramWriter, error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	h := &histogramWriter{f: f, w: bufio.NewWriter(f), json: strings.HasSuffix(name, ".json"),
+		hists: make(map[cell][]turnHistogram)}
+	if h.json {
+		h.w.WriteString("[")
+	} else {
+		h.w.WriteString("regime,run,turn,bin,lo,hi,count\n")
+	}
+	return h, nil
+}
+
+func (h *histogramWriter) Turn(act ActivationOrder, ri, turn int, sd float64, wealth []float64) {
+	if HistogramRuns > 0 && ri >= HistogramRuns || len(wealth) == 0 {
+		return
+	}
+	lo, hi := wealth[0], wealth[0]
+	for _, w := range wealth {
+		if w < lo {
+			lo = w
+		} else if w > hi {
+			hi = w
+		}
+	}
+	edges := make([]float64, HistogramBins+1)
+	for k := range edges {
+		edges[k] = lo + (hi-lo)*float64(k)/float64(HistogramBins)
+	}
+	edges[HistogramBins] = hi
+	hist := turnHistogram{act.String(), ri + 1, turn, edges, histogramOver(wealth, lo, hi, HistogramBins)}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	c := cell{int(act), ri}
+	h.hists[c] = append(h.hists[c], hist)
+}
+
+// Done writes the run's histograms.
+func (h *histogramWriter) Done(act ActivationOrder, ri int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	c := cell{int(act), ri}
+	for _, hist := range h.hists[c] {
+		if err := h.write(hist); err != nil && h.failed == nil {
+			h.failed = err
+		}
+	}
+	delete(h.hists, c)
+}
+
+// write writes one turn's histogram.
+func (h *histogramWriter) write(hist turnHistogram) error {
+	if h.json {
+		b, err := json.Marshal(hist)
+		if err != nil {
+			return err
+		}
+		if h.turns > 0 {
+			h.w.WriteString(",")
+		}
+		h.turns++
+		h.w.WriteString("\n")
+		_, err = h.w.Write(b)
+		return err
+	}
+	cw := csv.NewWriter(h.w)
+	g := func(v float64) string { return strconv.FormatFloat(v, 'g', -1, 64) }
+	for k, count := range hist.Counts {
+		cw.Write([]string{hist.Regime, strconv.Itoa(hist.Run), strconv.Itoa(hist.Turn), strconv.Itoa(k),
+			g(hist.Edges[k]), g(hist.Edges[k+1]), g(count)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// close finishes the file and closes it.
+func (h *histogramWriter) close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.json {
+		h.w.WriteString("\n]\n")
+	}
+	err := h.failed
+	if ferr := h.w.Flush(); err == nil {
+		err = ferr
+	}
+	if cerr := h.f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", h.f.Name(), err)
+	}
+	return nil
+}
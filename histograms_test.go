@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestHistogramWriter(t *testing.T) {
+	defer func(bins, runs int) { HistogramBins, HistogramRuns = bins, runs }(HistogramBins, HistogramRuns)
+	HistogramBins, HistogramRuns = 2, 1
+	write := func(name string) string {
+		h, err := newHistogramWriter(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.Turn(uniform, 0, 0, 1, []float64{1, 2, 3, 5})
+		h.Turn(uniform, 1, 0, 1, []float64{1, 2}) // past HistogramRuns
+		h.Turn(uniform, 0, 1, 0, []float64{3, 3, 3, 3})
+		h.Done(uniform, 0)
+		h.Done(uniform, 1)
+		if err := h.close(); err != nil {
+			t.Fatal(err)
+		}
+		b, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	dir := t.TempDir()
+	want := "regime,run,turn,bin,lo,hi,count\n" +
+		"uniform,1,0,0,1,3,2\n" +
+		"uniform,1,0,1,3,5,2\n" +
+		"uniform,1,1,0,3,3,4\n" +
+		"uniform,1,1,1,3,3,0\n"
+	if got := write(filepath.Join(dir, "h.csv")); got != want {
+		t.Errorf("wrote\n%s\nwant\n%s", got, want)
+	}
+	var hists []turnHistogram
+	if err := json.Unmarshal([]byte(write(filepath.Join(dir, "h.json"))), &hists); err != nil {
+		t.Fatal(err)
+	}
+	wantJSON := []turnHistogram{
+		{"uniform", 1, 0, []float64{1, 3, 5}, []float64{2, 2}},
+		{"uniform", 1, 1, []float64{3, 3, 3}, []float64{4, 0}},
+	}
+	if !reflect.DeepEqual(hists, wantJSON) {
+		t.Errorf("wrote %+v, want %+v", hists, wantJSON)
+	}
+}
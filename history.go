@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/* Activation histories */
+
+/*
+ * The regimes differ only in whom they activate and how often, and the
+ * poisson ones are meant to activate agents at rates set by their wealth --
+ * inverse poisson the rich faster. With ActivationHistory set, every run
+ * writes activations_<regime>_run<N>.csv to show whether it did, a row per
+ * agent and turn:
+ *
+ *	turn,id,wealth,activations,times
+ *
+ * giving the agent's wealth at the start of the turn, how many exchanges
+ * it took part in, and under a poisson regime the simulated times of its
+ * events that the turn paired off, separated by spaces. (A Schedule can
+ * skip some of those; see schedule.go.) An agent born during the turn has
+ * no wealth at its start, and one that exits has no more rows. The rows
+ * are kept until the run is over, so with many agents the file is large.
+ */
+
+// A firing is a poisson event, by agent ID rather than index.
+type firing struct {
+	time float64
+	id   int
+}
+
+// fire keeps the events a poisson turn pairs off, for its activation
+// history.
+func (m *Model) fire(events events) {
+	m.firings = m.firings[:0]
+	for _, e := range events {
+		m.firings = append(m.firings, firing{e.time, m.Pop.Agents[e.agent].id})
+	}
+}
+
+// An activationHistory records how often and when each agent is activated,
+// turn by turn, and keeps the rows until the run is over.
+type activationHistory struct {
+	wealth      map[int]float64 // at the start of the turn, by ID
+	activations map[int]int     // likewise
+	rows        bytes.Buffer
+}
+
+// newActivationHistory returns a history starting from the population Pop,
+// or nil without ActivationHistory.
+func newActivationHistory(Pop Population) *activationHistory {
+	if !ActivationHistory {
+		return nil
+	}
+	h := &activationHistory{wealth: make(map[int]float64), activations: make(map[int]int)}
+	h.start(Pop)
+	h.rows.WriteString("turn,id,wealth,activations,times\n")
+	return h
+}
+
+// start notes each agent's wealth and activations at the start of a turn.
+func (h *activationHistory) start(Pop Population) {
+	for id := range h.wealth {
+		delete(h.wealth, id)
+		delete(h.activations, id)
+	}
+	for i, a := range Pop.Agents {
+		h.wealth[a.id], h.activations[a.id] = Pop.Wealth[i], a.activations
+	}
+}
+
+// record adds a row for each agent of m after the given turn.
+func (h *activationHistory) record(m *Model, turn int) {
+	times := make(map[int][]string)
+	for _, f := range m.firings {
+		t := float64(turn-1)*TurnLength + f.time
+		times[f.id] = append(times[f.id], strconv.FormatFloat(t, 'g', -1, 64))
+	}
+	m.firings = m.firings[:0]
+	for _, a := range m.Pop.Agents {
+		wealth := ""
+		if w, ok := h.wealth[a.id]; ok {
+			wealth = strconv.FormatFloat(w, 'g', -1, 64)
+		}
+		fmt.Fprintf(&h.rows, "%d,%d,%s,%d,%s\n", turn, a.id, wealth, a.activations-h.activations[a.id], strings.Join(times[a.id], " "))
+	}
+	h.start(m.Pop)
+}
+
+// save writes the recorded rows for the given run of act.
+func (h *activationHistory) save(act ActivationOrder, run int) {
+	name := fmt.Sprintf("activations_%s_run%d.csv", strings.Replace(act.String(), " ", "_", -1), run+1)
+	if err := os.WriteFile(name, h.rows.Bytes(), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
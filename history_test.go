@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestActivationHistory checks that every agent's activations are written
+// each turn, with the times of its events under a poisson regime.
+func TestActivationHistory(t *testing.T) {
+	defer func(history bool, agents, turns int) {
+		ActivationHistory, NumOfAgents, NumTurns = history, agents, turns
+	}(ActivationHistory, NumOfAgents, NumTurns)
+	ActivationHistory, NumOfAgents, NumTurns = true, 20, 3
+	dir, _ := os.Getwd()
+	defer os.Chdir(dir)
+	os.Chdir(t.TempDir())
+
+	acts := []ActivationOrder{uniform, poisson}
+	for a, act := range acts {
+		runCell(experimentModel(acts, 11, cell{a, 0}), 0, io.Discard)
+		f, err := os.Open("activations_" + act.String() + "_run1.csv")
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows, err := csv.NewReader(f).ReadAll()
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 1+NumTurns*NumOfAgents || strings.Join(rows[0], ",") != "turn,id,wealth,activations,times" {
+			t.Fatalf("%s: %d rows, header %v", act, len(rows), rows[0])
+		}
+		total := 0
+		for _, row := range rows[1:] {
+			n, _ := strconv.Atoi(row[3])
+			total += n
+			turn, _ := strconv.Atoi(row[0])
+			if act == uniform && (n != 1 || row[4] != "") {
+				t.Errorf("%s: row %v, want one activation without times", act, row)
+			} else if act == poisson && len(strings.Fields(row[4])) != n {
+				t.Errorf("%s: row %v has %d activations, but not as many times", act, row, n)
+			} else if act == poisson {
+				for _, field := range strings.Fields(row[4]) {
+					if at, _ := strconv.ParseFloat(field, 64); at < float64(turn-1) || at >= float64(turn) {
+						t.Errorf("%s: row %v has a time outside turn %d", act, row, turn)
+					}
+				}
+			}
+		}
+		if act == poisson && (total == 0 || total%2 != 0) {
+			t.Errorf("%s: %d activations in all", act, total)
+		}
+		if rows[1][2] != "1" {
+			t.Errorf("%s: first agent starts with wealth %s, want 1", act, rows[1][2])
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import "sort"
+
+/* Homophily */
+
+/*
+ * With Homophily h > 0, each pairing is, with probability h, restricted to
+ * partners in the activated agent's own group. Groups are either the agents'
+ * fixed tags or, when Quantiles > 0, the wealth quantile each agent occupied
+ * at the start of the turn.
+ */
+
+// assignQuantiles records the wealth quantile each agent currently occupies.
+func (m *Model) assignQuantiles() {
+	order := make([]int, m.Pop.Len())
+	for i := 0; i < len(order); i++ {
+		order[i] = i
+	}
+	sort.Sort(byWealth{m.Pop.Wealth, order})
+	for rank, i := range order {
+		m.Pop.Agents[i].quantile = rank * m.Quantiles / m.Pop.Len()
+	}
+}
+
+// group returns the group a is paired within.
+func (m *Model) group(a *Agent) int {
+	if m.Quantiles > 0 {
+		return a.quantile
+	}
+	return a.tag
+}
+
+// homophilous decides whether the next pairing is restricted to one group.
+func (m *Model) homophilous() bool {
+	return m.Homophily > 0 && m.rng.Float64() < m.Homophily
+}
+
+// byWealth sorts agent indices by the wealth of the agents they refer to.
+type byWealth struct {
+	wealth []float64
+	order  []int
+}
+
+func (b byWealth) Len() int {
+	return len(b.order)
+}
+func (b byWealth) Less(i, j int) bool {
+	return b.wealth[b.order[i]] < b.wealth[b.order[j]]
+}
+func (b byWealth) Swap(i, j int) {
+	b.order[i], b.order[j] = b.order[j], b.order[i]
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestAssignQuantiles checks that agents are grouped by the wealth quantile
+// they occupy, each quantile holding an equal share of them.
+func TestAssignQuantiles(t *testing.T) {
+	defer func(agents int) { NumOfAgents = agents }(NumOfAgents)
+	NumOfAgents = 8
+	m := NewModel(random, rand.New(rand.NewSource(1)))
+	copy(m.Pop.Wealth, []float64{7, 0, 5, 2, 6, 1, 4, 3})
+	m.Quantiles = 4
+	m.assignQuantiles()
+	for i, want := range []int{3, 0, 2, 1, 3, 0, 2, 1} {
+		if got := m.group(&m.Pop.Agents[i]); got != want {
+			t.Errorf("agent with wealth %v is in quantile %d, want %d", m.Pop.Wealth[i], got, want)
+		}
+	}
+	m.Quantiles = 0
+	m.Pop.Agents[2].tag = 9
+	if got := m.group(&m.Pop.Agents[2]); got != 9 {
+		t.Errorf("without quantiles, agent is in group %d, want its tag 9", got)
+	}
+}
+
+// TestHomophily checks that full homophily keeps every pairing within a tag
+// group, and that partial homophily lets some cross.
+func TestHomophily(t *testing.T) {
+	defer func(agents int) { NumOfAgents = agents }(NumOfAgents)
+	NumOfAgents = 30
+	for _, h := range []float64{1, 0.5} {
+		m := NewModel(random, rand.New(rand.NewSource(2)))
+		m.Homophily, m.Quantiles = h, 0
+		for i := range m.Pop.Agents {
+			m.Pop.Agents[i].tag = i % 3
+		}
+		crossed := 0
+		for k := 0; k < 3000; k++ {
+			alpha := k % NumOfAgents
+			beta := m.randomPartner(alpha)
+			if m.Pop.Agents[alpha].tag != m.Pop.Agents[beta].tag {
+				crossed++
+			}
+		}
+		if h == 1 && crossed > 0 {
+			t.Errorf("homophily 1: %d pairings crossed groups", crossed)
+		}
+		if h < 1 && crossed == 0 {
+			t.Errorf("homophily %v: no pairing crossed groups", h)
+		}
+	}
+}
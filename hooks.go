@@ -0,0 +1,201 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/GaryBoone/GoStats/stats"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+/* Completion hooks */
+
+/*
+ * A long experiment can say when it's done: with WebhookURL set, it POSTs a
+ * JSON event there when its last run completes, and with HookCommand set it
+ * runs that command through the shell with the same JSON on its standard
+ * input. HookEveryRun sends an event as each run completes, too. An event
+ * carries the experiment's manifest -- what was run, with which seed, and
+ * since when -- and a summary of the runs it covers:
+ *
+ *	{"event": "experiment", "manifest": {...}, "elapsed_seconds": 812.4,
+ *	 "summary": [{"regime": "uniform", "runs": 10, "final_sd": 0.31,
+ *	              "gradient": -0.0042, "gradient_sd": 0.0003}, ...]}
+ *
+ * A "run" event has the run's number and a summary of just that run. A hook
+ * that fails is reported and otherwise ignored, so a notification can't cost
+ * the results; each gets hookTimeout to finish.
+ */
+
+const hookTimeout = 10 * time.Second
+
+// hookManifest describes the experiment an event is about.
+type hookManifest struct {
+	Regimes []string  `json:"regimes"`
+	Agents  int       `json:"agents"`
+	Turns   int       `json:"turns"`
+	Runs    int       `json:"runs"`
+	Rule    string    `json:"rule"`
+	RNG     string    `json:"rng"`
+	Seed    int64     `json:"seed"`
+	Started time.Time `json:"started"`
+
+	Interventions []string `json:"interventions,omitempty"`
+}
+
+// hookSummary sums up the runs of a regime an event covers.
+type hookSummary struct {
+	Regime     string  `json:"regime"`
+	Runs       int     `json:"runs"`
+	FinalSD    float64 `json:"final_sd"` // mean over the runs
+	Gradient   float64 `json:"gradient"` // mean over the runs
+	GradientSD float64 `json:"gradient_sd,omitempty"`
+}
+
+// hookEvent is what hooks are sent, as JSON.
+type hookEvent struct {
+	Event    string        `json:"event"` // "run" or "experiment"
+	Manifest hookManifest  `json:"manifest"`
+	Run      int           `json:"run,omitempty"`
+	Elapsed  float64       `json:"elapsed_seconds"`
+	Summary  []hookSummary `json:"summary"`
+}
+
+// completionHooks is an Observer that fires the hooks as runs and the
+// experiment complete.
+type completionHooks struct {
+	manifest hookManifest
+
+	mu        sync.Mutex
+	sds       map[ActivationOrder][][]float64 // of runs in progress
+	finalSDs  []stats.Stats                   // by regime, over the runs done
+	gradients []stats.Stats
+	done      int
+}
+
+// newCompletionHooks returns the hooks for an experiment over acts with the
+// given master seed.
+func newCompletionHooks(acts []ActivationOrder, seed int64) *completionHooks {
+	names := make([]string, len(acts))
+	sds := make(map[ActivationOrder][][]float64)
+	for i, act := range acts {
+		names[i] = act.String()
+		sds[act] = make([][]float64, NumRuns)
+	}
+	return &completionHooks{
+		manifest: hookManifest{Regimes: names, Agents: NumOfAgents, Turns: NumTurns, Runs: NumRuns,
+			Rule: RuleName, RNG: RNG, Seed: seed, Started: time.Now().UTC(), Interventions: Interventions},
+		sds: sds, finalSDs: make([]stats.Stats, len(acts)), gradients: make([]stats.Stats, len(acts)),
+	}
+}
+
+func (h *completionHooks) Turn(act ActivationOrder, ri, turn int, sd float64, wealth []float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sds[act][ri] = append(h.sds[act][ri], sd)
+}
+
+// Done folds the run into its regime's summary and fires the hooks for it,
+// and for the experiment if it was the last run.
+func (h *completionHooks) Done(act ActivationOrder, ri int) {
+	h.mu.Lock()
+	sds := h.sds[act][ri]
+	h.sds[act][ri] = nil
+	i := h.regime(act)
+	final, slope := sds[len(sds)-1], gradient(sds)
+	h.finalSDs[i].Update(final)
+	h.gradients[i].Update(slope)
+	h.done++
+	var last *hookEvent
+	if h.done == len(h.manifest.Regimes)*NumRuns {
+		last = h.event("experiment")
+		for i, name := range h.manifest.Regimes {
+			sum := hookSummary{Regime: name, Runs: h.gradients[i].Count(),
+				FinalSD: h.finalSDs[i].Mean(), Gradient: h.gradients[i].Mean()}
+			if sum.Runs > 1 { // JSON has no NaN
+				sum.GradientSD = h.gradients[i].SampleStandardDeviation()
+			}
+			last.Summary = append(last.Summary, sum)
+		}
+	}
+	h.mu.Unlock()
+
+	if HookEveryRun {
+		ev := h.event("run")
+		ev.Run = ri + 1
+		ev.Summary = []hookSummary{{Regime: act.String(), Runs: 1, FinalSD: final, Gradient: slope}}
+		fireHooks(ev)
+	}
+	if last != nil {
+		fireHooks(last)
+	}
+}
+
+// regime returns act's index in the manifest.
+func (h *completionHooks) regime(act ActivationOrder) int {
+	for i, name := range h.manifest.Regimes {
+		if name == act.String() {
+			return i
+		}
+	}
+	panic("regime " + act.String() + " isn't in the experiment")
+}
+
+func (h *completionHooks) event(kind string) *hookEvent {
+	return &hookEvent{Event: kind, Manifest: h.manifest, Elapsed: time.Since(h.manifest.Started).Seconds()}
+}
+
+// fireHooks sends ev to WebhookURL and HookCommand, whichever are set,
+// reporting any that fail.
+func fireHooks(ev *hookEvent) {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	if WebhookURL != "" {
+		if err := postWebhook(WebhookURL, body); err != nil {
+			log.Printf("webhook: %v", err)
+		}
+	}
+	if HookCommand != "" {
+		if err := runHookCommand(HookCommand, body); err != nil {
+			log.Printf("hook command: %v", err)
+		}
+	}
+}
+
+// postWebhook POSTs body to url as JSON.
+func postWebhook(url string, body []byte) error {
+	client := http.Client{Timeout: hookTimeout}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return nil
+}
+
+// runHookCommand runs command through the shell with body on its standard
+// input, passing its output through.
+func runHookCommand(command string, body []byte) error {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	timer := time.AfterFunc(hookTimeout, func() { cmd.Process.Kill() })
+	defer timer.Stop()
+	return cmd.Wait()
+}
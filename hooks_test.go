@@ -0,0 +1,54 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompletionHooks(t *testing.T) {
+	var events []hookEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev hookEvent
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &ev); err != nil {
+			t.Error(err)
+		}
+		events = append(events, ev)
+	}))
+	defer server.Close()
+	out := filepath.Join(t.TempDir(), "event.json")
+	defer func(runs int, url, command string, every bool) {
+		NumRuns, WebhookURL, HookCommand, HookEveryRun = runs, url, command, every
+	}(NumRuns, WebhookURL, HookCommand, HookEveryRun)
+	NumRuns, WebhookURL, HookCommand, HookEveryRun = 2, server.URL, "cat > "+out, true
+
+	h := newCompletionHooks([]ActivationOrder{uniform}, 42)
+	for ri := 0; ri < NumRuns; ri++ {
+		h.Turn(uniform, ri, 0, 4, nil)
+		h.Turn(uniform, ri, 1, float64(2+ri), nil)
+		h.Done(uniform, ri)
+	}
+
+	if len(events) != 3 || events[0].Event != "run" || events[1].Run != 2 || events[2].Event != "experiment" {
+		t.Fatalf("events %+v", events)
+	}
+	sum := events[2].Summary
+	if events[2].Manifest.Seed != 42 || len(sum) != 1 || sum[0].Runs != 2 || sum[0].FinalSD != 2.5 {
+		t.Errorf("experiment event %+v", events[2])
+	}
+	var ev hookEvent
+	body, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(body, &ev); err != nil || ev.Event != "experiment" {
+		t.Errorf("command got %s (%v)", body, err)
+	}
+}
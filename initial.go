@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+/* Initial wealth */
+
+/*
+ * Agents start with wealth 1, 2, ..., NumOfAgents, as in the original
+ * model. With InitialWealthFile set they instead start with the wealth in
+ * that CSV file, one agent per row, so empirical starting distributions can
+ * be used: a single column, or with a header row, the column headed
+ * "wealth" (or else the first). There must be a row for every agent, and
+ * no wealth may be negative.
+ *
+ * Otherwise InitialWealth says how wealth is drawn, afresh for each run:
+ *
+ *	linear     1..N (the default)
+ *	bootstrap  a sample, with replacement, of the values in WealthDataFile
+ *	           -- survey microdata, say -- which is read like
+ *	           InitialWealthFile but may have any number of rows
+ *
+ * or is drawn independently for each agent from a distribution whose
+ * parameters are WealthParams, in order, with defaults for any left out:
+ *
+ *	pareto       alpha (1.16, the "80/20" law) and minimum (1)
+ *	lognormal    mu (0) and sigma (1) of the wealth's log
+ *	uniform      low (1) and high (N)
+ *	exponential  mean ((N+1)/2)
+ *	equal        everyone's wealth ((N+1)/2)
+ *
+ * The defaults with a free scale match linear's mean.
+ *
+ * Draws come from the run's own random numbers, so a run's population
+ * depends only on its seed, and under CompareRegimes every regime of a run
+ * starts from the same one.
+ *
+ * However it was got, initial wealth can then be rescaled, in proportion,
+ * to total InitialTotal or to average InitialMean, so that gradients are
+ * comparable across population sizes and distributions.
+ */
+
+// LoadInitialWealth reads n agents' initial wealth from CSV.
+func LoadInitialWealth(r io.Reader, n int) ([]float64, error) {
+	wealth, err := readWealth(r, "initial wealth")
+	if err != nil {
+		return nil, err
+	}
+	if len(wealth) != n {
+		return nil, fmt.Errorf("initial wealth: got %d rows for %d agents", len(wealth), n)
+	}
+	return wealth, nil
+}
+
+// LoadWealthData reads the wealth values to bootstrap from, from CSV.
+func LoadWealthData(r io.Reader) ([]float64, error) {
+	data, err := readWealth(r, "wealth data")
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("wealth data: no values")
+	}
+	return data, nil
+}
+
+// readWealth reads a column of wealth from CSV, as described above; what
+// the wealth is for goes in its errors.
+func readWealth(r io.Reader, what string) ([]float64, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	col := 0
+	if len(rows) > 0 && len(rows[0]) > 0 {
+		if _, err := strconv.ParseFloat(rows[0][0], 64); err != nil {
+			for i, name := range rows[0] {
+				if strings.EqualFold(strings.TrimSpace(name), "wealth") {
+					col = i
+				}
+			}
+			rows = rows[1:]
+		}
+	}
+	wealth := make([]float64, len(rows))
+	for i, row := range rows {
+		if col >= len(row) {
+			return nil, fmt.Errorf("%s row %d: no column %d", what, i+1, col+1)
+		}
+		w, err := strconv.ParseFloat(strings.TrimSpace(row[col]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s row %d: %v", what, i+1, err)
+		} else if w < 0 || math.IsInf(w, 0) || math.IsNaN(w) {
+			return nil, fmt.Errorf("%s row %d: %v isn't a wealth", what, i+1, w)
+		}
+		wealth[i] = w
+	}
+	return wealth, nil
+}
+
+// checkInitialWealth reports whether InitialWealth can be drawn.
+func checkInitialWealth() error {
+	if InitialTotal < 0 || InitialMean < 0 {
+		return errors.New("InitialTotal and InitialMean can't be negative")
+	} else if InitialTotal > 0 && InitialMean > 0 {
+		return errors.New("set InitialTotal or InitialMean, not both")
+	}
+	if InitialWealth == "linear" {
+		return nil
+	}
+	_, err := wealthDistribution()
+	return err
+}
+
+// wealthDistribution returns a function that draws an agent's initial
+// wealth as InitialWealth says.
+func wealthDistribution() (func(rng *rand.Rand) float64, error) {
+	return namedDistribution("InitialWealth", InitialWealth, "WealthParams", WealthParams)
+}
+
+// namedDistribution returns a function that draws wealth from the
+// distribution called name, one of InitialWealth's, with the given
+// parameters. choice and paramsChoice name the Choices they came from.
+func namedDistribution(choice, name, paramsChoice string, params []float64) (func(rng *rand.Rand) float64, error) {
+	wealthParams := func(defaults ...float64) ([]float64, error) {
+		if len(params) > len(defaults) {
+			return nil, fmt.Errorf("%s %q takes %d %s, not %d", choice, name, len(defaults), paramsChoice, len(params))
+		}
+		return append(append([]float64(nil), params...), defaults[len(params):]...), nil
+	}
+	mean := float64(NumOfAgents+1) / 2 // that of linear
+	if name == "bootstrap" {
+		if wealthData == nil {
+			return nil, fmt.Errorf(`%s "bootstrap" needs WealthDataFile`, choice)
+		}
+		return func(rng *rand.Rand) float64 { return wealthData[rng.Intn(len(wealthData))] }, nil
+	} else if name == "pareto" {
+		p, err := wealthParams(1.16, 1)
+		if err != nil {
+			return nil, err
+		} else if p[0] <= 0 || p[1] <= 0 {
+			return nil, errors.New("pareto needs alpha > 0 and a minimum > 0")
+		}
+		alpha, xm := p[0], p[1]
+		return func(rng *rand.Rand) float64 { return xm / math.Pow(1-rng.Float64(), 1/alpha) }, nil
+	} else if name == "lognormal" {
+		p, err := wealthParams(0, 1)
+		if err != nil {
+			return nil, err
+		} else if p[1] < 0 {
+			return nil, errors.New("lognormal needs sigma >= 0")
+		}
+		mu, sigma := p[0], p[1]
+		return func(rng *rand.Rand) float64 { return math.Exp(mu + sigma*rng.NormFloat64()) }, nil
+	} else if name == "uniform" {
+		p, err := wealthParams(1, float64(NumOfAgents))
+		if err != nil {
+			return nil, err
+		} else if p[0] < 0 || p[1] < p[0] {
+			return nil, errors.New("uniform needs 0 <= low <= high")
+		}
+		lo, hi := p[0], p[1]
+		return func(rng *rand.Rand) float64 { return lo + (hi-lo)*rng.Float64() }, nil
+	} else if name == "exponential" {
+		p, err := wealthParams(mean)
+		if err != nil {
+			return nil, err
+		} else if p[0] <= 0 {
+			return nil, errors.New("exponential needs a mean > 0")
+		}
+		return func(rng *rand.Rand) float64 { return p[0] * rng.ExpFloat64() }, nil
+	} else if name == "equal" {
+		p, err := wealthParams(mean)
+		if err != nil {
+			return nil, err
+		} else if p[0] < 0 {
+			return nil, errors.New("equal needs wealth >= 0")
+		}
+		return func(rng *rand.Rand) float64 { return p[0] }, nil
+	}
+	return nil, fmt.Errorf("unknown %s %q", choice, name)
+}
+
+// endow gives Pop its initial wealth as InitialWealth says, drawing from
+// rng, unless Populate loaded it from InitialWealthFile, and rescales it to
+// InitialTotal or InitialMean. A warm start's wealth is left as it was.
+func endow(Pop Population, rng *rand.Rand) {
+	if warmState != nil {
+		return
+	}
+	if initialWealth == nil && InitialWealth != "linear" {
+		draw, err := wealthDistribution()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for i := range Pop.Wealth {
+			Pop.Wealth[i] = draw(rng)
+		}
+	}
+	if InitialTotal > 0 {
+		scaleWealth(Pop.Wealth, InitialTotal)
+	} else if InitialMean > 0 {
+		scaleWealth(Pop.Wealth, InitialMean*float64(len(Pop.Wealth)))
+	}
+}
+
+// scaleWealth rescales wealth, in proportion, so that it sums to total.
+// Wealth that is all zero stays so.
+func scaleWealth(wealth []float64, total float64) {
+	sum := 0.0
+	for _, w := range wealth {
+		sum += w
+	}
+	if sum == 0 {
+		return
+	}
+	for i := range wealth {
+		wealth[i] *= total / sum
+	}
+}
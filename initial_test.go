@@ -0,0 +1,132 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadInitialWealth(t *testing.T) {
+	got, err := LoadInitialWealth(strings.NewReader("id,wealth\n1,5\n2,0\n3,2.5\n"), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []float64{5, 0, 2.5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for _, bad := range []string{"1\n2\n", "1\n2\n3\n4\n", "1\n-2\n3\n", "1\nx\n3\n", "1\n+Inf\n3\n"} {
+		if _, err := LoadInitialWealth(strings.NewReader(bad), 3); err == nil {
+			t.Errorf("loaded %q", bad)
+		}
+	}
+}
+
+func TestPopulateInitialWealth(t *testing.T) {
+	defer func(n int, w []float64) { NumOfAgents, initialWealth = n, w }(NumOfAgents, initialWealth)
+	NumOfAgents, initialWealth = 3, []float64{7, 0, 1}
+	if got := Populate().Wealth; !reflect.DeepEqual(got, initialWealth) {
+		t.Errorf("populated %v", got)
+	}
+}
+
+func TestBootstrapWealth(t *testing.T) {
+	defer func(n int, mode string, data []float64, total float64) {
+		NumOfAgents, InitialWealth, wealthData, InitialTotal = n, mode, data, total
+	}(NumOfAgents, InitialWealth, wealthData, InitialTotal)
+	NumOfAgents, InitialWealth, wealthData = 1000, "bootstrap", []float64{1, 10}
+	m := NewModel(uniform, rand.New(rand.NewSource(1)))
+	ones := 0
+	for _, w := range m.Pop.Wealth {
+		if w == 1 {
+			ones++
+		} else if w != 10 {
+			t.Fatalf("drew %v", w)
+		}
+	}
+	if ones < 400 || ones > 600 {
+		t.Errorf("drew 1 %d times of 1000", ones)
+	}
+
+	InitialTotal = 500
+	m = NewModel(uniform, rand.New(rand.NewSource(1)))
+	total := 0.0
+	for _, w := range m.Pop.Wealth {
+		total += w
+	}
+	if math.Abs(total-500) > 1e-9 {
+		t.Errorf("total %v, want 500", total)
+	}
+	if err := checkInitialWealth(); err != nil {
+		t.Error(err)
+	}
+	wealthData = nil
+	if err := checkInitialWealth(); err == nil {
+		t.Error("bootstrap with no data")
+	}
+}
+
+func TestWealthDistributions(t *testing.T) {
+	defer func(n int, mode string, params []float64) {
+		NumOfAgents, InitialWealth, WealthParams = n, mode, params
+	}(NumOfAgents, InitialWealth, WealthParams)
+	NumOfAgents = 99
+	for _, c := range []struct {
+		mode   string
+		params []float64
+		mean   float64
+	}{
+		{"pareto", []float64{3}, 1.5},
+		{"pareto", []float64{2, 10}, 20},
+		{"lognormal", []float64{0, 0.5}, math.Exp(0.125)},
+		{"uniform", nil, 50},
+		{"uniform", []float64{2, 4}, 3},
+		{"exponential", nil, 50},
+		{"equal", []float64{7}, 7},
+	} {
+		InitialWealth, WealthParams = c.mode, c.params
+		draw, err := wealthDistribution()
+		if err != nil {
+			t.Fatal(err)
+		}
+		rng := rand.New(rand.NewSource(1))
+		sum, n := 0.0, 100000
+		for i := 0; i < n; i++ {
+			w := draw(rng)
+			if w < 0 {
+				t.Fatalf("%s drew %v", c.mode, w)
+			}
+			sum += w
+		}
+		if mean := sum / float64(n); math.Abs(mean-c.mean) > 0.05*c.mean {
+			t.Errorf("%s%v: mean %v, want %v", c.mode, c.params, mean, c.mean)
+		}
+	}
+	for _, bad := range []struct {
+		mode   string
+		params []float64
+	}{{"pareto", []float64{0}}, {"uniform", []float64{3, 2}}, {"equal", []float64{1, 2}}, {"gamma", nil}} {
+		InitialWealth, WealthParams = bad.mode, bad.params
+		if err := checkInitialWealth(); err == nil {
+			t.Errorf("accepted %s%v", bad.mode, bad.params)
+		}
+	}
+}
+
+func TestInitialMean(t *testing.T) {
+	defer func(n int, mean float64) { NumOfAgents, InitialMean = n, mean }(NumOfAgents, InitialMean)
+	NumOfAgents, InitialMean = 4, 1
+	m := NewModel(uniform, rand.New(rand.NewSource(1)))
+	want := []float64{0.4, 0.8, 1.2, 1.6}
+	for i := range want {
+		if math.Abs(m.Pop.Wealth[i]-want[i]) > 1e-12 {
+			t.Fatalf("rescaled 1..4 to %v, want %v", m.Pop.Wealth, want)
+		}
+	}
+	InitialTotal = 10
+	defer func() { InitialTotal = 0 }()
+	if err := checkInitialWealth(); err == nil {
+		t.Error("accepted both InitialTotal and InitialMean")
+	}
+}
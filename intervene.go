@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/* Interventions */
+
+/*
+ * A policy shock is an Intervention: a change made to a Model just before
+ * a given turn. Interventions lists them as "turn:kind:argument", e.g.
+ *
+ *	{"15:confiscate:0.01", "30:activation:natural poisson"}
+ *
+ * and every run of the experiment makes them. The kinds are
+ *
+ *	confiscate:p    take the wealth of the richest p of agents and share it
+ *	                out equally among everyone
+ *	tax:rate        take rate of everyone's wealth and share it out equally
+ *	activation:name switch to another regime, the runs still being reported
+ *	                under the one they started with
+ *	rule:name       switch to another exchange rule
+ *	set:name:v      change a live setting (see live.go), for this run only
+ *
+ * A Model won't skip turns as equalized while it has an intervention still
+ * to come, and the hooks' manifest (see hooks.go) lists them. Go code can
+ * give a Model Interventions of its own, with any Apply.
+ */
+
+// An Intervention is a change made to a Model before the given turn.
+type Intervention struct {
+	Turn  int
+	Spec  string // as Interventions gives it
+	Apply func(m *Model)
+}
+
+// interventionKinds make the change each kind of intervention makes, given
+// its argument.
+var interventionKinds = map[string]func(arg string) (func(m *Model), error){
+	"confiscate": func(arg string) (func(m *Model), error) {
+		p, err := strconv.ParseFloat(arg, 64)
+		if err != nil || p < 0 || p > 1 {
+			return nil, fmt.Errorf("share %q isn't from 0 to 1", arg)
+		}
+		return func(m *Model) { m.confiscate(p) }, nil
+	},
+	"tax": func(arg string) (func(m *Model), error) {
+		rate, err := strconv.ParseFloat(arg, 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("rate %q isn't from 0 to 1", arg)
+		}
+		return func(m *Model) { m.tax(rate) }, nil
+	},
+	"activation": func(arg string) (func(m *Model), error) {
+		act, err := ParseActivation(arg)
+		if err != nil {
+			return nil, err
+		}
+		return func(m *Model) {
+			m.Activation = act
+			if act == localPoisson && m.Net == nil {
+				m.SetNetwork(RingLattice(m.Pop.Len(), NeighborhoodRadius, m.rng))
+			}
+		}, nil
+	},
+	"rule": func(arg string) (func(m *Model), error) {
+		if _, err := lookupRule(arg); err != nil {
+			return nil, err
+		}
+		return func(m *Model) { m.Rule, _ = lookupRule(arg) }, nil
+	},
+	"set": func(arg string) (func(m *Model), error) {
+		parts := strings.Split(arg, ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%q isn't setting:value", arg)
+		}
+		v, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return nil, err
+		} else if err := checkSetting(parts[0], v); err != nil {
+			return nil, err
+		} else if parts[0] == "turns" {
+			return nil, fmt.Errorf("turns can't be set by an intervention")
+		}
+		set := liveSetters[parts[0]]
+		return func(m *Model) { set(m, v) }, nil
+	},
+}
+
+// parseIntervention parses an intervention as Interventions gives it.
+func parseIntervention(spec string) (Intervention, error) {
+	parts := strings.SplitN(spec, ":", 3)
+	if len(parts) != 3 {
+		return Intervention{}, fmt.Errorf("intervention %q isn't turn:kind:argument", spec)
+	}
+	turn, err := strconv.Atoi(parts[0])
+	if err != nil || turn < 1 {
+		return Intervention{}, fmt.Errorf("intervention %q: no turn %q", spec, parts[0])
+	}
+	kind, ok := interventionKinds[parts[1]]
+	if !ok {
+		return Intervention{}, fmt.Errorf("intervention %q: unknown kind %q", spec, parts[1])
+	}
+	apply, err := kind(parts[2])
+	if err != nil {
+		return Intervention{}, fmt.Errorf("intervention %q: %v", spec, err)
+	}
+	return Intervention{Turn: turn, Spec: spec, Apply: apply}, nil
+}
+
+// newInterventions parses specs, in order.
+func newInterventions(specs []string) ([]Intervention, error) {
+	var ivs []Intervention
+	for _, spec := range specs {
+		iv, err := parseIntervention(spec)
+		if err != nil {
+			return nil, err
+		}
+		ivs = append(ivs, iv)
+	}
+	return ivs, nil
+}
+
+// checkInterventions reports whether the Interventions parse.
+func checkInterventions() error {
+	_, err := newInterventions(Interventions)
+	return err
+}
+
+// intervene makes the interventions due before m's next turn.
+func (m *Model) intervene() {
+	for _, iv := range m.Interventions {
+		if iv.Turn == m.Turn+1 {
+			iv.Apply(m)
+		}
+	}
+}
+
+// intervening reports whether m has an intervention still to make.
+func (m *Model) intervening() bool {
+	for _, iv := range m.Interventions {
+		if iv.Turn > m.Turn {
+			return true
+		}
+	}
+	return false
+}
+
+// confiscate takes the wealth of the richest share p of m's agents and
+// shares it out equally among all of them.
+func (m *Model) confiscate(p float64) {
+	n := m.Pop.Len()
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return m.Pop.Wealth[order[a]] > m.Pop.Wealth[order[b]] })
+	total := 0.0
+	for _, i := range order[:int(p*float64(n)+0.5)] {
+		total += m.Pop.Wealth[i]
+		m.Pop.Wealth[i] = 0
+	}
+	for i := range m.Pop.Wealth {
+		m.Pop.Wealth[i] += total / float64(n)
+	}
+}
+
+// tax takes rate of every agent's wealth and shares it out equally among
+// them, returning the total collected.
+func (m *Model) tax(rate float64) float64 {
+	total := 0.0
+	for i, w := range m.Pop.Wealth {
+		total += rate * w
+		m.Pop.Wealth[i] -= rate * w
+	}
+	for i := range m.Pop.Wealth {
+		m.Pop.Wealth[i] += total / float64(m.Pop.Len())
+	}
+	return total
+}
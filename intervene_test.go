@@ -0,0 +1,67 @@
+package main
+
+import (
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func TestParseIntervention(t *testing.T) {
+	for _, good := range []string{"15:confiscate:0.01", "30:activation:natural poisson", "2:rule:yardsale", "5:tax:0.1", "1:set:fraction:0.2"} {
+		if _, err := parseIntervention(good); err != nil {
+			t.Errorf("%s: %v", good, err)
+		}
+	}
+	for _, bad := range []string{"15:confiscate", "0:tax:0.1", "x:tax:0.1", "3:expropriate:1", "3:tax:2",
+		"3:activation:sideways", "3:rule:robinhood", "3:set:turns:5", "3:set:fraction"} {
+		if _, err := parseIntervention(bad); err == nil {
+			t.Errorf("accepted %q", bad)
+		}
+	}
+}
+
+func TestConfiscate(t *testing.T) {
+	defer func(agents int) { NumOfAgents = agents }(NumOfAgents)
+	NumOfAgents = 100
+	m := NewModel(uniform, rand.New(rand.NewSource(1)))
+	for i := range m.Pop.Wealth {
+		m.Pop.Wealth[i] = float64(i + 1)
+	}
+	m.confiscate(0.01)
+	if m.Pop.Wealth[99] != 1 || m.Pop.Wealth[0] != 2 || m.Pop.Wealth[98] != 100 {
+		t.Errorf("after confiscating, wealth %v", m.Pop.Wealth)
+	}
+}
+
+// TestInterventions checks that a run makes its interventions before the
+// turns they're due, and isn't cut short as equalized before them.
+func TestInterventions(t *testing.T) {
+	defer func(ivs []string, agents, turns int, skip bool) {
+		Interventions, NumOfAgents, NumTurns, SkipEqualized = ivs, agents, turns, skip
+	}(Interventions, NumOfAgents, NumTurns, SkipEqualized)
+	Interventions, NumOfAgents, NumTurns, SkipEqualized = []string{"3:activation:local poisson", "6:rule:yardsale"}, 50, 8, true
+	m := NewModel(uniform, rand.New(rand.NewSource(2)))
+	var seen []ActivationOrder
+	for turn := 1; turn <= 6; turn++ {
+		m.Step()
+		seen = append(seen, m.Activation)
+	}
+	if seen[1] != uniform || seen[2] != localPoisson || m.Net == nil {
+		t.Errorf("regimes %v", seen)
+	}
+	if _, ok := m.Rule.(YardSale); !ok {
+		t.Errorf("rule %#v after turn 6", m.Rule)
+	}
+
+	m = NewModel(uniform, rand.New(rand.NewSource(2)))
+	for i := range m.Pop.Wealth {
+		m.Pop.Wealth[i] = 1
+	}
+	if m.Equalized(0) {
+		t.Error("equalized with interventions to come")
+	}
+	sds := runCell(m, 0, io.Discard)
+	if sds[NumTurns] == 0 {
+		t.Errorf("yard sales from turn 6 left SDs %v", sds)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+	"unsafe"
+)
+
+/* Million-agent runs */
+
+/*
+ * LargeScale is for populations in the millions. Nothing per-agent is kept
+ * beyond the Model itself -- each turn is reduced to its wealth SD as it
+ * goes, and network snapshots stay off unless asked for -- so memory is
+ * dominated by the live Models. To keep that to one Model at a time, cells
+ * run one after another, each using all the Workers for its own pairing and
+ * sorting. A memory estimate is printed before anything is allocated, and
+ * progress is reported on stderr after every turn.
+ */
+
+// modelBytes estimates the memory one Model of n agents running act needs at
+// its peak, including its reusable per-turn buffers.
+func modelBytes(n int, act ActivationOrder) int64 {
+	b := int64(n) * int64(16+unsafe.Sizeof(Agent{})) // Wealth, Lam and Agents
+	b += int64(n) * int64(unsafe.Sizeof(0))          // pair list
+	if n/2 >= ParallelThreshold && Workers > 1 {
+		b += 2 * int64(n) * int64(unsafe.Sizeof(0)) // pairing engine
+	}
+	if act != uniform && act != random {
+		ev := int64(expectedEvents(n)) * int64(unsafe.Sizeof(event{}))
+		b += ev
+		if expectedEvents(n) >= ParallelSortThreshold && Workers > 1 {
+			b += ev // sort buffer
+		}
+	}
+	if usesNetwork(act) {
+		entries := int64(n) * int64(2*NeighborhoodRadius)
+		if edgeList != nil {
+			entries = int64(edgeList.entries())
+		}
+		b += entries * int64(unsafe.Sizeof(0)+unsafe.Sizeof(0.0)+unsafe.Sizeof(Undirected))
+		b += int64(n) * (3*int64(unsafe.Sizeof([]int(nil))) + 25) // adjacency headers, local sums, the wealth summed and staleness
+	}
+	return b
+}
+
+// usesNetwork reports whether NewModel gives a Model running act a network.
+func usesNetwork(act ActivationOrder) bool {
+	return edgeList != nil || temporalEdges != nil || act == localPoisson || NetworkPairing ||
+		LambdaReference == "neighborhood" || NeighborhoodLeveling
+}
+
+// printMemoryEstimate reports the expected peak memory of an experiment over
+// acts, before any of it is allocated.
+func printMemoryEstimate(acts []ActivationOrder) {
+	var peak int64
+	for _, act := range acts {
+		if b := modelBytes(NumOfAgents, act); b > peak {
+			peak = b
+		}
+	}
+	total := peak
+	if CompareRegimes {
+		total += int64(NumRuns) * modelBytes(NumOfAgents, uniform) // the initial Models
+	}
+	fmt.Printf("Memory estimate for %d agents: %s per run, %s in all\n",
+		NumOfAgents, formatBytes(peak), formatBytes(total))
+}
+
+// formatBytes renders a byte count in binary units.
+func formatBytes(b int64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	f := float64(b)
+	u := 0
+	for f >= 1024 && u < len(units)-1 {
+		f /= 1024
+		u++
+	}
+	return fmt.Sprintf("%.1f %s", f, units[u])
+}
+
+// reportProgress tells stderr how far a run has got.
+func reportProgress(m *Model, ri int, sd float64, start time.Time) {
+	fmt.Fprintf(os.Stderr, "%s run %d: turn %d/%d, SD %f, %v elapsed\n",
+		m.Activation, ri+1, m.Turn, NumTurns, sd, time.Since(start).Round(time.Millisecond))
+}
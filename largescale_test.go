@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestFormatBytes(t *testing.T) {
+	for b, want := range map[int64]string{0: "0.0 B", 1023: "1023.0 B", 1536: "1.5 KiB", 3 << 30: "3.0 GiB", 5 << 50: "5120.0 TiB"} {
+		if got := formatBytes(b); got != want {
+			t.Errorf("formatBytes(%d) is %q, want %q", b, got, want)
+		}
+	}
+}
+
+// TestModelBytes checks that the memory estimate covers at least the
+// per-agent slices, grows with the population, and charges regimes with
+// events or a network for them.
+func TestModelBytes(t *testing.T) {
+	const n = 100000
+	agents := int64(n) * int64(16+unsafe.Sizeof(Agent{}))
+	if b := modelBytes(n, uniform); b < agents {
+		t.Errorf("uniform estimate %d is below the %d bytes of agents", b, agents)
+	}
+	if modelBytes(2*n, uniform) <= modelBytes(n, uniform) {
+		t.Error("estimate doesn't grow with the population")
+	}
+	if modelBytes(n, poisson) <= modelBytes(n, uniform) {
+		t.Error("poisson's events cost nothing")
+	}
+	if modelBytes(n, localPoisson) <= modelBytes(n, poisson) {
+		t.Error("local poisson's network costs nothing")
+	}
+}
+
+// TestRingLatticeCapacity checks that a ring lattice's adjacency lists are
+// allocated at their final size.
+func TestRingLatticeCapacity(t *testing.T) {
+	net := RingLattice(50, 3, rand.New(rand.NewSource(1)))
+	for i := 0; i < net.Size(); i++ {
+		if len(net.adj[i]) != 6 || cap(net.adj[i]) != 6 || cap(net.weight[i]) != 6 || cap(net.dir[i]) != 6 {
+			t.Fatalf("agent %d: %d neighbors in lists of capacity %d, %d, %d",
+				i, len(net.adj[i]), cap(net.adj[i]), cap(net.weight[i]), cap(net.dir[i]))
+		}
+	}
+}
+
+// TestLargeScaleRun checks that a large-scale run skips the network summary
+// and reports its progress on stderr every turn.
+func TestLargeScaleRun(t *testing.T) {
+	defer func(agents, turns int, large bool, stderr *os.File) {
+		NumOfAgents, NumTurns, LargeScale, os.Stderr = agents, turns, large, stderr
+	}(NumOfAgents, NumTurns, LargeScale, os.Stderr)
+	NumOfAgents, NumTurns = 50, 3
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stderr = w
+	for _, large := range []bool{false, true} {
+		LargeScale = large
+		var out bytes.Buffer
+		runCell(NewModel(localPoisson, rand.New(rand.NewSource(1))), 0, &out)
+		if summary := strings.Contains(out.String(), "Network (local poisson run 1)"); summary == large {
+			t.Errorf("large scale %v: network summary %v", large, summary)
+		}
+	}
+	w.Close()
+	progress, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(progress)), "\n"); len(lines) != NumTurns ||
+		!strings.HasPrefix(lines[2], "local poisson run 1: turn 3/3, SD ") {
+		t.Errorf("progress:\n%s", progress)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+/* Live settings */
+
+/*
+ * A few Choices can be changed while an experiment runs, for exploring it
+ * interactively: send the process SIGHUP to have it re-read ReloadFile, or
+ * write to the control socket at ControlAddr (see reload.go). A change
+ * takes effect at the start of the next turn of every run in progress, and
+ * of every run still to start; it's logged, and sent to every Observer that
+ * is a settingObserver, such as the dashboard, whose pages list it. The
+ * settings are
+ *
+ *	turns           NumTurns, though runs can only be cut short: a run past
+ *	                it repeats its SD, as one whose population equalized does
+ *	fraction        LevelingFraction, of the "partial" rule
+ *	directedrate    DirectedRate, the rate of tribute -- a tax paid along
+ *	                every directed edge -- or fraction of remittance
+ *	yardsale        YardSaleFraction
+ *	hoarderrefusal  HoarderRefusal
+ *	learningrate    LearningRate
+ *	birthrate       BirthRate, if the runs started with births or exits
+ *	exitrate        ExitRate, likewise
+ *
+ * The Choices themselves are left as they were, so that changing a setting
+ * doesn't race with the runs that read them.
+ */
+
+// liveSetters bring a Model up to date with a setting's new value. turns
+// is left to runCell.
+var liveSetters = map[string]func(m *Model, v float64){
+	"turns": nil,
+	"fraction": func(m *Model, v float64) {
+		if _, ok := m.Rule.(PartialLeveler); ok {
+			m.Rule = PartialLeveler{Fraction: v}
+		}
+	},
+	"directedrate": func(m *Model, v float64) {
+		if _, ok := m.DirectedRule.(Tribute); ok {
+			m.DirectedRule = Tribute{Rate: v}
+		} else if _, ok := m.DirectedRule.(Remittance); ok {
+			m.DirectedRule = Remittance{Fraction: v}
+		}
+	},
+	"yardsale": func(m *Model, v float64) {
+		if _, ok := m.Rule.(YardSale); ok {
+			m.Rule = YardSale{Fraction: v}
+		}
+	},
+	"hoarderrefusal": func(m *Model, v float64) {
+		m.setAgentType("hoarder", func(t *AgentType) { t.Refusal = v })
+	},
+	"learningrate": func(m *Model, v float64) {
+		m.setAgentType("learner", func(t *AgentType) { t.Rate = v })
+	},
+	"birthrate": func(m *Model, v float64) {
+		if m.Demography != nil {
+			m.Demography.BirthRate = v
+		}
+	},
+	"exitrate": func(m *Model, v float64) {
+		if m.Demography != nil {
+			m.Demography.ExitRate = v
+		}
+	},
+}
+
+// A settingObserver is an Observer that wants to know of changes to the
+// live settings.
+type settingObserver interface {
+	Setting(name string, value float64, source string)
+}
+
+// liveSettings are the settings changed so far.
+type liveSettings struct {
+	mu      sync.Mutex
+	changes int64 // how many so far, read atomically
+	values  map[string]float64
+}
+
+var live liveSettings
+
+// checkSetting reports whether v is a value the named setting can take.
+func checkSetting(name string, v float64) error {
+	if _, ok := liveSetters[name]; !ok {
+		return fmt.Errorf("%q can't be changed mid-run", name)
+	} else if name == "turns" && (v < 0 || v != math.Trunc(v)) {
+		return fmt.Errorf("turns %v isn't a number of turns", v)
+	} else if name != "turns" && !(v >= 0 && v <= 1) {
+		return fmt.Errorf("%s %v isn't from 0 to 1", name, v)
+	}
+	return nil
+}
+
+// Set changes the named setting to v, as source asked, for every run from
+// its next turn on.
+func (l *liveSettings) Set(name string, v float64, source string) error {
+	if err := checkSetting(name, v); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	if l.values == nil {
+		l.values = make(map[string]float64)
+	}
+	l.values[name] = v
+	atomic.AddInt64(&l.changes, 1)
+	l.mu.Unlock()
+	log.Printf("%s set %s to %v", source, name, v)
+	for _, o := range observers {
+		if so, ok := o.(settingObserver); ok {
+			so.Setting(name, v, source)
+		}
+	}
+	return nil
+}
+
+// Settings returns the settings changed so far, as "name value", in order.
+func (l *liveSettings) Settings() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var settings []string
+	for name, v := range l.values {
+		settings = append(settings, name+" "+strconv.FormatFloat(v, 'g', -1, 64))
+	}
+	sort.Strings(settings)
+	return settings
+}
+
+// apply brings m up to date with the settings if they've changed since
+// *seen changes, and returns the turns its run is now to last, given that
+// it was to last turns.
+func (l *liveSettings) apply(m *Model, seen *int64, turns int) int {
+	n := atomic.LoadInt64(&l.changes)
+	if n == *seen {
+		return turns
+	}
+	*seen = n
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for name, v := range l.values {
+		if name == "turns" {
+			turns = int(math.Min(v, float64(NumTurns)))
+		} else {
+			liveSetters[name](m, v)
+		}
+	}
+	return turns
+}
+
+// setAgentType changes the named type in m's mix, if it has one, without
+// touching the types of the Models it shares them with.
+func (m *Model) setAgentType(name string, change func(t *AgentType)) {
+	if m.Types == nil {
+		return
+	}
+	m.Types.Types = append([]AgentType(nil), m.Types.Types...)
+	for k := range m.Types.Types {
+		if m.Types.Types[k].Name == name {
+			change(&m.Types.Types[k])
+		}
+	}
+}
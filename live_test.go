@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// resetLive forgets the settings changed so far.
+func resetLive() {
+	live.mu.Lock()
+	live.values = nil
+	live.mu.Unlock()
+}
+
+func TestCheckSetting(t *testing.T) {
+	for _, bad := range []struct {
+		name string
+		v    float64
+	}{{"turns", -1}, {"turns", 2.5}, {"fraction", 1.5}, {"numofagents", 10}} {
+		if err := checkSetting(bad.name, bad.v); err == nil {
+			t.Errorf("accepted %s %v", bad.name, bad.v)
+		}
+	}
+}
+
+// TestLiveSettings checks that runs pick up changed settings from their
+// next turn on.
+func TestLiveSettings(t *testing.T) {
+	defer func(rule string, turns, agents int) { RuleName, NumTurns, NumOfAgents = rule, turns, agents }(RuleName, NumTurns, NumOfAgents)
+	defer resetLive()
+	RuleName, NumTurns, NumOfAgents = "partial", 10, 50
+	if err := live.Set("fraction", 0.2, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := live.Set("turns", 4, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(live.Settings(), ", "); got != "fraction 0.2, turns 4" {
+		t.Errorf("settings %q", got)
+	}
+	m := NewModel(random, rand.New(rand.NewSource(1)))
+	var out bytes.Buffer
+	sds := runCell(m, 0, &out)
+	if r, ok := m.Rule.(PartialLeveler); !ok || r.Fraction != 0.2 {
+		t.Errorf("rule %#v", m.Rule)
+	}
+	if len(sds) != NumTurns+1 || sds[4] == sds[3] || sds[10] != sds[4] {
+		t.Errorf("SDs %v", sds)
+	}
+	if !strings.Contains(out.String(), "Cut short (random run 1) after turn 4; skipping the remaining 6 turns") {
+		t.Errorf("output %q", out.String())
+	}
+}
@@ -0,0 +1,411 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+func main() {
+	seed := time.Now().UTC().UnixNano()
+	flag.Func("config", "JSON file of Choices, and the seed, describing the experiment; flags after it override it", func(name string) error {
+		return loadConfigFile(name, &seed)
+	})
+	flag.IntVar(&NumRuns, "runs", NumRuns, "runs of each regime")
+	flag.IntVar(&NumTurns, "turns", NumTurns, "turns of each run")
+	flag.IntVar(&NumOfAgents, "agents", NumOfAgents, "agents in each run")
+	var regimes []string
+	flag.Func("activation", "regime to run, by name; repeat it, or separate names with commas, for several (default: Activations)", func(names string) error {
+		for _, name := range strings.Split(names, ",") {
+			regimes = append(regimes, strings.TrimSpace(name))
+		}
+		Activations = regimes
+		return nil
+	})
+	flag.StringVar(&RuleName, "rule", RuleName, "exchange rule, by name: leveler, conserving, partial, proportional, yardsale, bargain or one registered")
+	flag.Int64Var(&seed, "seed", seed, "master seed, which decides every run (default: from the clock)")
+	flag.IntVar(&Workers, "j", Workers, "cells simulated concurrently (results don't depend on it)")
+	flag.Func("workers", "worker processes to run the experiment's cells on, as host:port separated by commas (see the worker subcommand)", func(addrs string) error {
+		for _, addr := range strings.Split(addrs, ",") {
+			RemoteWorkers = append(RemoteWorkers, strings.TrimSpace(addr))
+		}
+		return nil
+	})
+	flag.IntVar(&RemoteSlots, "remote-slots", RemoteSlots, "cells each worker is sent at once")
+	flag.DurationVar(&RemoteTimeout, "remote-timeout", RemoteTimeout, "longest a remote cell may take")
+	flag.BoolVar(&TUI, "tui", TUI, "draw live charts of the experiment in the terminal")
+	flag.StringVar(&PlotsDir, "plots", PlotsDir, "directory to save trajectories and plots of them in")
+	flag.Func("plot", "draw the SD and Gini figures as png or svg, in -plots or else plots", func(format string) error {
+		if err := checkPlotFormat(format); err != nil {
+			return err
+		}
+		PlotFormat = format
+		if PlotsDir == "" {
+			PlotsDir = "plots"
+		}
+		return nil
+	})
+	flag.StringVar(&SDFile, "csv", SDFile, "CSV file to write every turn's mean wealth and SD to")
+	flag.StringVar(&HistogramsFile, "histograms", HistogramsFile, "file to write every turn's wealth histogram to, as JSON if it ends in .json and CSV otherwise")
+	flag.IntVar(&HistogramBins, "bins", HistogramBins, "bins of each histogram")
+	flag.IntVar(&AnimateRun, "animate", AnimateRun, "run of each regime to animate as a GIF (0 for none)")
+	flag.StringVar(&DashboardAddr, "dashboard", DashboardAddr, "address to serve a live dashboard of the experiment at, e.g. localhost:8000")
+	flag.StringVar(&FlightAddr, "flight", FlightAddr, "address to serve the results at as Arrow Flight tables, e.g. localhost:8815")
+	flag.StringVar(&WebhookURL, "webhook", WebhookURL, "URL to POST a JSON event to when the experiment completes")
+	flag.StringVar(&HookCommand, "hook", HookCommand, "shell command to run, with the same event on its standard input, when the experiment completes")
+	flag.BoolVar(&HookEveryRun, "hook-every-run", HookEveryRun, "fire -webhook and -hook as each run completes, too")
+	flag.StringVar(&MesaFile, "mesa", MesaFile, "CSV file to also write every turn to as Mesa's batch_run would")
+	flag.StringVar(&ReloadFile, "reload", ReloadFile, "JSON file of live settings to re-read on SIGHUP")
+	flag.StringVar(&ControlAddr, "control", ControlAddr, "address to take live settings at over a control socket, e.g. localhost:7000")
+	flag.Parse()
+	if EdgeListFile != "" {
+		f, err := os.Open(EdgeListFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		edgeList, err = LoadEdgeList(f, NumOfAgents, DirectedEdges)
+		f.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if TemporalEdgeListFile != "" {
+		f, err := os.Open(TemporalEdgeListFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		temporalEdges, err = LoadTemporalEdgeList(f, NumOfAgents, DirectedEdges)
+		f.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if CoordinatesFile != "" {
+		f, err := os.Open(CoordinatesFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		sites, err = LoadSites(f, NumOfAgents)
+		f.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if InitialWealthFile != "" {
+		f, err := os.Open(InitialWealthFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		initialWealth, err = LoadInitialWealth(f, NumOfAgents)
+		f.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if WarmStartFile != "" {
+		f, err := os.Open(WarmStartFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		state, err := LoadState(f)
+		f.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		warmState = &state
+	}
+	if WealthDataFile != "" {
+		f, err := os.Open(WealthDataFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		wealthData, err = LoadWealthData(f)
+		f.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if CohortFile != "" {
+		f, err := os.Open(CohortFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cohortTags, err = LoadCohorts(f, NumOfAgents)
+		f.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := loadPlugins(Plugins); err != nil {
+		log.Fatal(err)
+	}
+	if err := loadScripts(); err != nil {
+		log.Fatal(err)
+	}
+	if flag.Arg(0) == "worker" {
+		if err := runWorker(flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	} else if flag.Arg(0) == "plot" {
+		if err := runPlot(flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	} else if flag.Arg(0) == "sweep" {
+		if err := runSweep(flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	} else if flag.Arg(0) == "pareto" {
+		if err := runPareto(flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	} else if flag.Arg(0) == "report" {
+		if err := runReport(flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	} else if flag.Arg(0) == "power" {
+		if err := runPower(flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	} else if flag.Arg(0) == "validate" {
+		if err := runValidate(flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	} else if flag.Arg(0) == "verify-determinism" {
+		if err := runVerifyDeterminism(flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	} else if flag.Arg(0) == "trace-diff" {
+		if err := runTraceDiff(flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	} else if flag.Arg(0) == "repl" {
+		if err := runREPL(flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	} else if flag.Arg(0) == "serve" {
+		if err := runServe(flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	} else if flag.Arg(0) == "grpc" {
+		if err := runGRPC(flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	} else if flag.Arg(0) == "grpc-run" {
+		if err := runGRPCClient(flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if err := checkChoices(seed); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Random numbers from %s, master seed %d\n", RNG, seed)
+	if len(RegionActivations) > 0 {
+		RunWorld(newRand(seed))
+		return
+	}
+	activationTypes, err := experimentActivations()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	applyMemoryLimit(activationTypes)
+	if LargeScale {
+		printMemoryEstimate(activationTypes)
+		debug.SetGCPercent(25) // trade some GC time for a smaller heap
+	}
+	finish, err := attachObservers(activationTypes, seed)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		if err := finish(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+	analyzer, err := newAnalyzer(activationTypes)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := (Experiment{activationTypes, seed}).Run(analyzer.Collect); err != nil {
+		log.Fatal(err)
+	}
+	results, err := analyzer.Analyze()
+	if err != nil {
+		log.Fatal(err)
+	}
+	Reporter{os.Stdout}.Gradients(NumRuns, results)
+}
+
+// checkChoices reports whether the Choices make a valid experiment with the
+// given master seed.
+func checkChoices(seed int64) error {
+	if _, err := NewSource(RNG, seed); err != nil {
+		return err
+	}
+	if NumRuns < 1 || NumTurns < 1 || NumOfAgents < 2 {
+		return fmt.Errorf("an experiment takes at least a run, a turn and two agents, not %d, %d and %d", NumRuns, NumTurns, NumOfAgents)
+	}
+	if _, err := lookupMetrics(Metrics); err != nil {
+		return err
+	}
+	if _, err := lookupRule(RuleName); err != nil {
+		return err
+	}
+	if err := checkPrecision(); err != nil {
+		return err
+	}
+	if err := checkTurnLength(); err != nil {
+		return err
+	}
+	if err := checkRemainder(); err != nil {
+		return err
+	}
+	if err := checkTransferFraction(); err != nil {
+		return err
+	}
+	if err := checkQuantileSlopes(); err != nil {
+		return err
+	}
+	if err := checkInitialWealth(); err != nil {
+		return err
+	}
+	if err := checkTrackAgents(); err != nil {
+		return err
+	}
+	if err := checkCohorts(); err != nil {
+		return err
+	}
+	if err := checkAgentTypes(); err != nil {
+		return err
+	}
+	if err := checkRiskAversion(); err != nil {
+		return err
+	}
+	if err := checkDemography(); err != nil {
+		return err
+	}
+	if err := checkSnapshots(); err != nil {
+		return err
+	}
+	if err := checkSchedule(); err != nil {
+		return err
+	}
+	if err := checkWorld(); err != nil {
+		return err
+	}
+	if err := checkMarket(); err != nil {
+		return err
+	}
+	if err := checkGeography(); err != nil {
+		return err
+	}
+	return checkInterventions()
+}
+
+// attachObservers adds the observers the Choices ask for, over acts, and
+// starts any servers they need. It returns the function to call once the
+// experiment is over, which saves what they recorded and then holds any
+// servers open, as the Choices say.
+func attachObservers(acts []ActivationOrder, seed int64) (finish func() error, err error) {
+	var finishers []func() error // run last first
+	finish = func() error {
+		for i := len(finishers) - 1; i >= 0; i-- {
+			if err := finishers[i](); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if DashboardAddr != "" {
+		dashboard := NewDashboard(acts)
+		observers = append(observers, dashboard)
+		go serveDashboard(dashboard, DashboardAddr)
+		finishers = append(finishers, func() error { dashboard.hold(); return nil })
+	}
+	if TUI {
+		tui := NewTerminalUI(acts, os.Stdout)
+		observers = append(observers, tui)
+		cellOutput = tui
+		go tui.Run()
+	}
+	var trajectories *trajectoryRecorder
+	if PlotsDir != "" || FlightAddr != "" {
+		trajectories = newTrajectoryRecorder(acts)
+		observers = append(observers, trajectories)
+	}
+	if FlightAddr != "" {
+		flights := newFlightServer(acts, trajectories)
+		observers = append(observers, flights)
+		go serveFlight(flights, FlightAddr)
+		finishers = append(finishers, func() error { flights.hold(); return nil })
+	}
+	if PlotsDir != "" {
+		if err := checkPlotFormat(PlotFormat); err != nil {
+			return nil, err
+		}
+		finishers = append(finishers, func() error { return trajectories.save(PlotsDir, PlotFormat) })
+	}
+	if MesaFile != "" {
+		mesa, err := newMesaWriter(MesaFile, acts)
+		if err != nil {
+			return nil, err
+		}
+		observers = append(observers, mesa)
+		finishers = append(finishers, mesa.close)
+	}
+	if SDFile != "" {
+		sds, err := newSDWriter(SDFile)
+		if err != nil {
+			return nil, err
+		}
+		observers = append(observers, sds)
+		finishers = append(finishers, sds.close)
+	}
+	if HistogramsFile != "" {
+		if HistogramBins < 1 {
+			return nil, fmt.Errorf("can't draw histograms of %d bins", HistogramBins)
+		}
+		histograms, err := newHistogramWriter(HistogramsFile)
+		if err != nil {
+			return nil, err
+		}
+		observers = append(observers, histograms)
+		finishers = append(finishers, histograms.close)
+	}
+	if DistributionsFile != "" {
+		distributions := newDistributionRecorder(acts)
+		observers = append(observers, distributions)
+		finishers = append(finishers, func() error { return distributions.save(DistributionsFile) })
+	}
+	if WebhookURL != "" || HookCommand != "" {
+		observers = append(observers, newCompletionHooks(acts, seed))
+	}
+	if AnimateRun > NumRuns {
+		return nil, fmt.Errorf("can't animate run %d of %d", AnimateRun, NumRuns)
+	} else if AnimateRun > 0 {
+		observers = append(observers, newAnimator(acts))
+	}
+	if err := watchReloads(); err != nil {
+		return nil, err
+	}
+	return finish, nil
+}
@@ -0,0 +1,57 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckChoices(t *testing.T) {
+	defer func(rng, rule string, agents int) { RNG, RuleName, NumOfAgents = rng, rule, agents }(RNG, RuleName, NumOfAgents)
+	if err := checkChoices(1); err != nil {
+		t.Errorf("default Choices: %v", err)
+	}
+	RNG = "dice"
+	if err := checkChoices(1); err == nil {
+		t.Error("accepted an unknown RNG")
+	}
+	RNG, RuleName = "math/rand", "robbery"
+	if err := checkChoices(1); err == nil {
+		t.Error("accepted an unknown rule")
+	}
+	RuleName, NumOfAgents = "leveler", 1
+	if err := checkChoices(1); err == nil {
+		t.Error("accepted a lone agent")
+	}
+}
+
+func TestAttachObservers(t *testing.T) {
+	defer func(obs []Observer, mesa string, animate, runs int) {
+		observers, MesaFile, AnimateRun, NumRuns = obs, mesa, animate, runs
+	}(observers, MesaFile, AnimateRun, NumRuns)
+	acts := []ActivationOrder{uniform}
+	NumRuns, AnimateRun = 2, 3
+	if _, err := attachObservers(acts, 1); err == nil {
+		t.Error("animating a run past NumRuns")
+	}
+
+	observers, AnimateRun = nil, 0
+	MesaFile = filepath.Join(t.TempDir(), "mesa.csv")
+	finish, err := attachObservers(acts, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(observers) != 1 {
+		t.Fatalf("%d observers, want the Mesa writer", len(observers))
+	}
+	observers[0].Turn(uniform, 0, 0, 1, []float64{1, 2})
+	observers[0].Done(uniform, 0)
+	if err := finish(); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(MesaFile); err != nil || len(data) == 0 {
+		t.Errorf("Mesa file %q, %v", data, err)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"github.com/GaryBoone/GoStats/stats"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+)
+
+/* Bipartite markets */
+
+// agent classes in a bipartite market
+const (
+	buyer = iota
+	seller
+)
+
+/*
+ * In a Market, agents are either buyers or sellers and only trade across the
+ * divide. The rule for an exchange is that of the initiating (activated)
+ * agent's class, so e.g. sellers can level fully while buyers only move part
+ * of the way: ClassRules names buyers' rule and then sellers', by name as
+ * RuleName does. An agent who can find no one of the other class stays
+ * unpaired.
+ */
+type Market struct {
+	SellerShare float64
+	Rules       [2]Rule // indexed by class; nil means the Model's Rule
+}
+
+// checkMarket reports whether ClassRules names known rules, no more than one
+// per class.
+func checkMarket() error {
+	if len(ClassRules) > 2 {
+		return fmt.Errorf("%d ClassRules for two classes, buyers and sellers", len(ClassRules))
+	}
+	for _, name := range ClassRules {
+		if name == "" {
+			continue
+		}
+		if _, err := lookupRule(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newMarket returns the Market the Choices ask for, with the rules
+// ClassRules gives each class.
+func newMarket() *Market {
+	if err := checkMarket(); err != nil {
+		log.Fatal(err)
+	}
+	mk := &Market{SellerShare: SellerShare}
+	for class, name := range ClassRules {
+		if name != "" {
+			mk.Rules[class], _ = lookupRule(name)
+		}
+	}
+	return mk
+}
+
+// Assign makes a random SellerShare of the Population sellers.
+func (mk *Market) Assign(Pop Population, rng *rand.Rand) {
+	sellers := int(math.Floor(mk.SellerShare*float64(Pop.Len()) + 0.5))
+	for k, i := range rng.Perm(Pop.Len()) {
+		if k < sellers {
+			Pop.Agents[i].class = seller
+		} else {
+			Pop.Agents[i].class = buyer
+		}
+	}
+}
+
+// ClassStats returns the number of agents, mean and standard deviation of
+// wealth within one class.
+func ClassStats(Pop Population, class int) (n int, mean, std float64) {
+	wealth := make([]float64, 0)
+	for i := 0; i < Pop.Len(); i++ {
+		if Pop.Agents[i].class == class {
+			wealth = append(wealth, Pop.Wealth[i])
+		}
+	}
+	return len(wealth), stats.StatsMean(wealth), stats.StatsSampleStandardDeviation(wealth)
+}
+
+// printMarket reports the final state of each class of a market run.
+func printMarket(w io.Writer, Pop Population) {
+	for class, name := range []string{"buyers", "sellers"} {
+		n, mean, std := ClassStats(Pop, class)
+		fmt.Fprintf(w, "Final %s: %d agents, mean wealth %f, SD %f\n", name, n, mean, std)
+	}
+	_, between := Decompose(Pop, func(a *Agent) int { return a.class })
+	fmt.Fprintf(w, "Variance between classes: %f\n", between)
+}
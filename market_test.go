@@ -0,0 +1,43 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestClassRules checks that each class of a market exchanges by the rule
+// ClassRules gives it, and the other by RuleName.
+func TestClassRules(t *testing.T) {
+	defer func(market bool, rules []string, rule string, fraction float64) {
+		BipartiteMarket, ClassRules, RuleName, TransferFraction = market, rules, rule, fraction
+	}(BipartiteMarket, ClassRules, RuleName, TransferFraction)
+	BipartiteMarket, ClassRules, RuleName, TransferFraction = true, []string{"", "proportional"}, "leveler", 0.25
+	m := NewModel(uniform, rand.New(rand.NewSource(1)))
+	if m.Market.Rules[buyer] != nil {
+		t.Errorf("buyers' rule %T, want RuleName's", m.Market.Rules[buyer])
+	}
+	var b, s int
+	for i, a := range m.Pop.Agents {
+		if a.class == buyer {
+			b = i
+		} else {
+			s = i
+		}
+	}
+	m.Pop.Wealth[b], m.Pop.Wealth[s] = 10, 30
+	m.exchange(s, b) // the seller's rule: a quarter of the richer's wealth
+	if m.Pop.Wealth[b] != 17.5 || m.Pop.Wealth[s] != 22.5 {
+		t.Errorf("seller's exchange left %v and %v, want 17.5 and 22.5", m.Pop.Wealth[s], m.Pop.Wealth[b])
+	}
+	m.exchange(b, s) // the buyer's: levelling
+	if m.Pop.Wealth[b] != 20 || m.Pop.Wealth[s] != 20 {
+		t.Errorf("buyer's exchange left %v and %v, want 20 each", m.Pop.Wealth[b], m.Pop.Wealth[s])
+	}
+
+	for _, bad := range [][]string{{"no such rule"}, {"leveler", "bargain", "yardsale"}} {
+		ClassRules = bad
+		if err := checkMarket(); err == nil {
+			t.Errorf("ClassRules %q accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime/debug"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+/* Memory usage and limits */
+
+/*
+ * With ReportMemory, each run's output ends with the most heap the process
+ * had in use while the run was going (cells running concurrently share the
+ * heap, so this is the process's figure, not the run's alone) and the
+ * process's peak RSS so far, where the OS reports it.
+ *
+ * MemoryLimit is a soft limit, in bytes. The Go runtime is told to collect
+ * harder as the heap nears it, and the experiment gives things up rather
+ * than be killed by the OOM killer on a batch node. Before it starts, if
+ * the memory estimate (see largescale.go) is over the limit, results are
+ * streamed rather than kept (StreamResults), and if that isn't enough,
+ * fewer cells run at once. If the heap still passes the limit during a run,
+ * network snapshots are turned off for the rest of the experiment.
+ */
+
+// snapshotsOff is set once the heap has passed MemoryLimit.
+var snapshotsOff atomic.Bool
+
+// applyMemoryLimit sets the runtime's soft memory limit and scales the
+// experiment over acts down to fit MemoryLimit, saying what it gave up.
+func applyMemoryLimit(acts []ActivationOrder) {
+	if MemoryLimit <= 0 {
+		return
+	}
+	debug.SetMemoryLimit(MemoryLimit)
+	var peak int64
+	for _, act := range acts {
+		if b := modelBytes(NumOfAgents, act); b > peak {
+			peak = b
+		}
+	}
+	fixed := int64(0)
+	if CompareRegimes {
+		fixed += int64(NumRuns) * modelBytes(NumOfAgents, uniform) // the initial Models
+	}
+	results := int64(len(acts)) * int64(NumRuns) * int64(NumTurns) * 8
+	cells := int64(Workers)
+	if LargeScale {
+		cells = 1
+	}
+	if fixed+results+cells*peak > MemoryLimit && !StreamResults {
+		StreamResults = true
+		fmt.Printf("Memory limit %s: streaming results rather than keeping every run\n", formatBytes(MemoryLimit))
+	}
+	if fixed+cells*peak > MemoryLimit && cells > 1 {
+		fit := (MemoryLimit - fixed) / peak
+		if fit < 1 {
+			fit = 1
+		}
+		Workers = int(fit)
+		fmt.Printf("Memory limit %s: running cells %d at a time rather than %d\n", formatBytes(MemoryLimit), Workers, cells)
+	}
+	if fixed+peak > MemoryLimit {
+		log.Printf("memory limit %s is below the %s a single run needs", formatBytes(MemoryLimit), formatBytes(fixed+peak))
+	}
+}
+
+// heapBytes returns the heap memory the process has in use, without
+// stopping the world as runtime.ReadMemStats would.
+func heapBytes() uint64 {
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return sample[0].Value.Uint64()
+}
+
+// peakRSS returns the process's peak resident set size so far, and false
+// if the OS doesn't say (it is read from /proc, so only Linux does).
+func peakRSS() (int64, bool) {
+	f, err := os.Open("/proc/self/status")
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 3 && fields[0] == "VmHWM:" && fields[2] == "kB" {
+			kb, err := strconv.ParseInt(fields[1], 10, 64)
+			return kb * 1024, err == nil
+		}
+	}
+	return 0, false
+}
+
+// A memoryWatch follows the heap over one run.
+type memoryWatch struct {
+	peak uint64
+}
+
+// newMemoryWatch starts watching, or returns nil if neither ReportMemory
+// nor MemoryLimit asks for it.
+func newMemoryWatch() *memoryWatch {
+	if !ReportMemory && MemoryLimit <= 0 {
+		return nil
+	}
+	w := &memoryWatch{}
+	w.check()
+	return w
+}
+
+// check records the heap in use, turning network snapshots off if it has
+// passed MemoryLimit.
+func (w *memoryWatch) check() {
+	heap := heapBytes()
+	if heap > w.peak {
+		w.peak = heap
+	}
+	if MemoryLimit > 0 && heap > uint64(MemoryLimit) && len(NetworkSnapshotTurns) > 0 && !snapshotsOff.Swap(true) {
+		log.Printf("heap %s is over the memory limit %s: network snapshots are off from here on",
+			formatBytes(int64(heap)), formatBytes(MemoryLimit))
+	}
+}
+
+// report writes the run's peak heap and the process's peak RSS.
+func (w *memoryWatch) report(out io.Writer, act ActivationOrder, run int) {
+	rss := "unknown"
+	if b, ok := peakRSS(); ok {
+		rss = formatBytes(b)
+	}
+	fmt.Fprintf(out, "Memory (%s run %d): peak heap %s, peak RSS %s\n", act, run+1, formatBytes(int64(w.peak)), rss)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+// TestApplyMemoryLimit checks that an experiment over the limit first
+// streams its results, then runs fewer cells at a time, and one under it
+// is left alone.
+func TestApplyMemoryLimit(t *testing.T) {
+	defer func(agents, runs, turns, workers int, limit int64, stream, compare, large bool) {
+		NumOfAgents, NumRuns, NumTurns, Workers, MemoryLimit = agents, runs, turns, workers, limit
+		StreamResults, CompareRegimes, LargeScale = stream, compare, large
+		debug.SetMemoryLimit(math.MaxInt64)
+	}(NumOfAgents, NumRuns, NumTurns, Workers, MemoryLimit, StreamResults, CompareRegimes, LargeScale)
+	NumOfAgents, NumRuns, NumTurns, CompareRegimes, LargeScale = 1000, 10, 100, false, false
+	acts := []ActivationOrder{uniform}
+	run := modelBytes(NumOfAgents, uniform)
+	results := int64(len(acts) * NumRuns * NumTurns * 8)
+	for _, c := range []struct {
+		limit   int64
+		stream  bool
+		workers int
+	}{
+		{4*run + results + 1, false, 4},
+		{4*run + 1, true, 4},
+		{2*run + 1, true, 2},
+		{run / 2, true, 1},
+	} {
+		Workers, MemoryLimit, StreamResults = 4, c.limit, false
+		applyMemoryLimit(acts)
+		if StreamResults != c.stream || Workers != c.workers {
+			t.Errorf("limit %d: streaming %v on %d workers, want %v on %d", c.limit, StreamResults, Workers, c.stream, c.workers)
+		}
+	}
+}
+
+// TestMemoryWatch checks that a watch records the heap, reports it, and
+// turns network snapshots off once the heap passes the limit.
+func TestMemoryWatch(t *testing.T) {
+	defer func(report bool, limit int64, turns []int) {
+		ReportMemory, MemoryLimit, NetworkSnapshotTurns = report, limit, turns
+		snapshotsOff.Store(false)
+	}(ReportMemory, MemoryLimit, NetworkSnapshotTurns)
+	ReportMemory, MemoryLimit = false, 0
+	if newMemoryWatch() != nil {
+		t.Error("watching with nothing to watch for")
+	}
+	ReportMemory = true
+	w := newMemoryWatch()
+	if w == nil || w.peak == 0 {
+		t.Fatalf("watch %+v", w)
+	}
+	var out bytes.Buffer
+	w.report(&out, poisson, 1)
+	if !strings.HasPrefix(out.String(), "Memory (poisson run 2): peak heap ") {
+		t.Errorf("report %q", out.String())
+	}
+	if snapshotsOff.Load() {
+		t.Fatal("snapshots off before the limit was passed")
+	}
+	MemoryLimit, NetworkSnapshotTurns = 1, []int{1}
+	w.check()
+	if !snapshotsOff.Load() {
+		t.Error("snapshots still on over the limit")
+	}
+}
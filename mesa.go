@@ -0,0 +1,96 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+)
+
+/* Mesa batch results */
+
+/*
+ * With MesaFile set, every turn of every run is also written to that CSV
+ * file in the layout of the DataFrame Mesa's batch_run returns with
+ * data_collection_period=1, so results can go through the analysis written
+ * for the Mesa version of this model unchanged:
+ *
+ *	RunId,iteration,Step,activation,N,SD,Gini
+ *
+ * RunId numbers the (regime, run) cells the way batch_run numbers its
+ * (parameters, iteration) pairs, regime by regime; iteration is the run
+ * within the regime, from 0; Step is the turn, from 0 before the first. The
+ * parameters are the regime and the number of agents, and SD and Gini are
+ * the model reporters. Each run's rows are written together when it
+ * completes, so runs appear in the order they finish rather than by RunId.
+ */
+
+var mesaHeader = []string{"RunId", "iteration", "Step", "activation", "N", "SD", "Gini"}
+
+// A mesaWriter is an Observer that writes every turn as a Mesa batch_run row.
+type mesaWriter struct {
+	mu      sync.Mutex
+	f       *os.File
+	w       *csv.Writer
+	acts    map[ActivationOrder]int // index of each regime, for RunId
+	rows    map[int][][]string      // of runs in progress, by RunId
+	sampler *wealthSampler
+}
+
+// newMesaWriter creates the file name for an experiment over acts and
+// writes its header.
+func newMesaWriter(name string, acts []ActivationOrder) (*mesaWriter, error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	m := &mesaWriter{f: f, w: csv.NewWriter(f), acts: make(map[ActivationOrder]int),
+		rows: make(map[int][][]string), sampler: newWealthSampler(plotSample)}
+	for i, act := range acts {
+		m.acts[act] = i
+	}
+	if err := m.w.Write(mesaHeader); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *mesaWriter) runID(act ActivationOrder, ri int) int {
+	return m.acts[act]*NumRuns + ri
+}
+
+func (m *mesaWriter) Turn(act ActivationOrder, ri, turn int, sd float64, wealth []float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := m.runID(act, ri)
+	sorted, total := m.sampler.sorted(wealth)
+	m.rows[id] = append(m.rows[id], []string{
+		strconv.Itoa(id), strconv.Itoa(ri), strconv.Itoa(turn), act.String(), strconv.Itoa(len(wealth)),
+		strconv.FormatFloat(sd, 'g', -1, 64), strconv.FormatFloat(gini(sorted, total), 'g', -1, 64),
+	})
+}
+
+// Done writes the run's rows.
+func (m *mesaWriter) Done(act ActivationOrder, ri int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := m.runID(act, ri)
+	m.w.WriteAll(m.rows[id]) // errors are sticky; close reports them
+	delete(m.rows, id)
+}
+
+// close flushes and closes the file.
+func (m *mesaWriter) close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.w.Flush()
+	if err := m.w.Error(); err != nil {
+		m.f.Close()
+		return fmt.Errorf("%s: %v", m.f.Name(), err)
+	}
+	return m.f.Close()
+}
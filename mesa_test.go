@@ -0,0 +1,35 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMesaWriter(t *testing.T) {
+	defer func(runs int) { NumRuns = runs }(NumRuns)
+	NumRuns = 3
+	name := filepath.Join(t.TempDir(), "mesa.csv")
+	m, err := newMesaWriter(name, []ActivationOrder{uniform, poisson})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Turn(poisson, 1, 0, 0.5, []float64{1, 1, 1, 1})
+	m.Turn(poisson, 1, 1, 0.25, []float64{0, 0, 0, 4})
+	m.Done(poisson, 1)
+	if err := m.close(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "RunId,iteration,Step,activation,N,SD,Gini\n" +
+		"4,1,0,poisson,4,0.5,0\n" +
+		"4,1,1,poisson,4,0.25,0.75\n"
+	if string(got) != want {
+		t.Errorf("wrote\n%s\nwant\n%s", got, want)
+	}
+}
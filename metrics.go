@@ -0,0 +1,417 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/* Per-turn metrics */
+
+/*
+ * Besides the wealth SD every run records, any of the metrics named in
+ * Metrics can be computed after each turn and written, one row per turn, to
+ * metrics_<regime>_run<N>.csv. Each turn's metrics are computed together
+ * over a single snapshot of wealth: the snapshot is sorted once, up front, if
+ * any of them needs it, and the metrics are then shared out among up to
+ * MetricWorkers goroutines.
+ *
+ * At 10M agents and up even one sort a turn is dear, so with MetricSample
+ * set the metrics are instead estimated from that many agents, drawn afresh
+ * every turn by reservoir sampling. Each value is then followed by a
+ * standard error, from the spread of the estimates over sampleBatches
+ * disjoint parts of the sample. Only metrics that a sample estimates
+ * without scaling -- Gini and quantiles -- can be sampled.
+ *
+ * A run with districts (see hierarchy.go) also gets the "districts" metric
+ * unless it samples: the variance of wealth within districts and between
+ * them, and between regions.
+ */
+
+const sampleBatches = 10
+
+// A Metric summarizes one turn's wealth as one or more values.
+type Metric struct {
+	Name    string
+	Columns []string                    // one per value Compute returns
+	Sorted  bool                        // whether Compute reads the snapshot's sorted wealth
+	Sampled bool                        // whether it can be estimated from a sample of agents
+	Compute func(s *snapshot) []float64 // must not modify the snapshot
+}
+
+// A snapshot is the wealth of every agent at the end of a turn.
+type snapshot struct {
+	wealth []float64
+	sorted []float64 // ascending; only filled in if a metric needs it
+	total  float64
+
+	pop       Population // the agents themselves, unless sampled
+	hierarchy *Hierarchy // their districts, if any
+}
+
+var quantileLevels = []float64{0.1, 0.25, 0.5, 0.75, 0.9}
+
+var metricTable = map[string]Metric{
+	"gini": {"gini", []string{"gini"}, true, true, func(s *snapshot) []float64 {
+		return []float64{gini(s.sorted, s.total)}
+	}},
+	"quantiles": {"quantiles", quantileColumns(), true, true, func(s *snapshot) []float64 {
+		q := make([]float64, len(quantileLevels))
+		for i, p := range quantileLevels {
+			q[i] = quantile(s.sorted, p)
+		}
+		return q
+	}},
+	"entropy": {"entropy", []string{"entropy"}, false, false, func(s *snapshot) []float64 {
+		return []float64{entropy(s.wealth, s.total)}
+	}},
+	"histogram": {"histogram", histogramColumns(), true, false, func(s *snapshot) []float64 {
+		return histogram(s.sorted, HistogramBins)
+	}},
+	"population": {"population", []string{"agents", "total_wealth"}, false, false, func(s *snapshot) []float64 {
+		return []float64{float64(len(s.wealth)), s.total}
+	}},
+	"districts": {"districts", []string{"var_within_districts", "var_between_districts", "var_between_regions"}, false, false, func(s *snapshot) []float64 {
+		if s.hierarchy == nil {
+			return []float64{math.NaN(), math.NaN(), math.NaN()}
+		}
+		within, between := Decompose(s.pop, s.hierarchy.District)
+		_, regions := Decompose(s.pop, s.hierarchy.Region)
+		return []float64{within, between, regions}
+	}},
+}
+
+// lookupMetrics returns the metrics with the given names.
+func lookupMetrics(names []string) ([]Metric, error) {
+	metrics := make([]Metric, 0, len(names))
+	for _, name := range names {
+		metric, ok := metricTable[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown metric %q", name)
+		}
+		if MetricSample > 0 && !metric.Sampled {
+			return nil, fmt.Errorf("metric %q can't be estimated from a sample", name)
+		}
+		metrics = append(metrics, metric)
+	}
+	return metrics, nil
+}
+
+// computeMetrics computes every metric over s, using up to workers
+// goroutines, and returns their values in the order of metrics.
+func computeMetrics(metrics []Metric, s *snapshot, workers int) [][]float64 {
+	s.total = 0
+	for _, w := range s.wealth {
+		s.total += w
+	}
+	for _, metric := range metrics {
+		if metric.Sorted {
+			s.sorted = append(s.sorted[:0], s.wealth...)
+			sort.Float64s(s.sorted)
+			break
+		}
+	}
+	values := make([][]float64, len(metrics))
+	if workers > len(metrics) {
+		workers = len(metrics)
+	}
+	if workers <= 1 {
+		for i, metric := range metrics {
+			values[i] = metric.Compute(s)
+		}
+		return values
+	}
+	next := make(chan int, len(metrics))
+	for i := range metrics {
+		next <- i
+	}
+	close(next)
+	var wg sync.WaitGroup
+	for k := 0; k < workers; k++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				values[i] = metrics[i].Compute(s)
+			}
+		}()
+	}
+	wg.Wait()
+	return values
+}
+
+// A metricRecorder computes the chosen metrics after every turn of a run
+// and keeps the rows until the run is over.
+type metricRecorder struct {
+	metrics []Metric
+	snap    snapshot
+	last    [][]float64 // the values of the last row recorded
+	lastSE  [][]float64 // and their standard errors, if sampling
+	rows    bytes.Buffer
+
+	rng    *rand.Rand // draws the samples
+	sample []float64
+}
+
+// newMetricRecorder returns a recorder for the metrics named in Metrics,
+// and "districts" if h is set, or nil if there are none. With MetricSample,
+// it draws the samples from rng.
+func newMetricRecorder(rng *rand.Rand, h *Hierarchy) *metricRecorder {
+	names := Metrics
+	if h != nil && MetricSample == 0 {
+		names = append(append([]string(nil), Metrics...), "districts")
+		for _, name := range Metrics {
+			if name == "districts" {
+				names = Metrics
+			}
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	metrics, err := lookupMetrics(names)
+	if err != nil {
+		log.Fatal(err)
+	}
+	r := &metricRecorder{metrics: metrics, rng: rng, snap: snapshot{hierarchy: h}}
+	r.rows.WriteString("turn")
+	for _, metric := range metrics {
+		for _, c := range metric.Columns {
+			r.rows.WriteString("," + c)
+			if MetricSample > 0 {
+				r.rows.WriteString("," + c + "_se")
+			}
+		}
+	}
+	r.rows.WriteString("\n")
+	return r
+}
+
+// record adds a row with the metrics of Pop after the given turn.
+func (r *metricRecorder) record(Pop Population, turn int) {
+	if MetricSample > 0 && MetricSample < Pop.Len() {
+		r.sample = sampleWealth(Pop.Wealth, MetricSample, r.rng, r.sample)
+		r.snap.wealth = r.sample
+		r.last = computeMetrics(r.metrics, &r.snap, MetricWorkers)
+		r.lastSE = r.standardErrors()
+	} else {
+		r.snap.wealth, r.snap.pop = Pop.Wealth, Pop
+		r.last = computeMetrics(r.metrics, &r.snap, MetricWorkers)
+		r.lastSE = nil // exact
+	}
+	r.snap.wealth, r.snap.pop = nil, Population{}
+	r.repeat(turn)
+}
+
+// standardErrors estimates the standard errors of the metrics of the
+// current sample from their spread over sampleBatches parts of it, scaled
+// down by the square root of their number.
+func (r *metricRecorder) standardErrors() [][]float64 {
+	var batches [sampleBatches][][]float64
+	k := len(r.sample)
+	for b := range batches {
+		part := r.sample[b*k/sampleBatches : (b+1)*k/sampleBatches]
+		batches[b] = computeMetrics(r.metrics, &snapshot{wealth: part}, MetricWorkers)
+	}
+	se := make([][]float64, len(r.last))
+	for i := range se {
+		se[i] = make([]float64, len(r.last[i]))
+		for j := range se[i] {
+			mean, ss := 0.0, 0.0
+			for b := range batches {
+				mean += batches[b][i][j] / sampleBatches
+			}
+			for b := range batches {
+				ss += (batches[b][i][j] - mean) * (batches[b][i][j] - mean)
+			}
+			se[i][j] = math.Sqrt(ss/(sampleBatches-1)) / math.Sqrt(sampleBatches)
+		}
+	}
+	return se
+}
+
+// repeat adds a row for the given turn with the values of the last one, for
+// turns skipped because they couldn't change anything.
+func (r *metricRecorder) repeat(turn int) {
+	r.rows.WriteString(strconv.Itoa(turn))
+	for i, v := range r.last {
+		for j, x := range v {
+			r.rows.WriteString("," + strconv.FormatFloat(x, 'g', -1, 64))
+			if MetricSample > 0 {
+				se := 0.0
+				if r.lastSE != nil {
+					se = r.lastSE[i][j]
+				}
+				r.rows.WriteString("," + strconv.FormatFloat(se, 'g', -1, 64))
+			}
+		}
+	}
+	r.rows.WriteString("\n")
+}
+
+// save writes the recorded rows for the given run of act.
+func (r *metricRecorder) save(act ActivationOrder, run int) {
+	name := fmt.Sprintf("metrics_%s_run%d.csv", strings.Replace(act.String(), " ", "_", -1), run+1)
+	if err := os.WriteFile(name, r.rows.Bytes(), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// sampleWealth fills buf with the wealth of k agents chosen uniformly at
+// random, in random order, and returns it. It uses Li's Algorithm L, which
+// skips over the agents that won't be chosen rather than drawing for each.
+func sampleWealth(wealth []float64, k int, rng *rand.Rand, buf []float64) []float64 {
+	buf = append(buf[:0], wealth[:k]...)
+	w := math.Exp(math.Log(1-rng.Float64()) / float64(k))
+	for i := k - 1; ; {
+		skip := math.Floor(math.Log(1-rng.Float64()) / math.Log(1-w))
+		if skip >= float64(len(wealth)-1-i) {
+			break
+		}
+		i += int(skip) + 1
+		buf[rng.Intn(k)] = wealth[i]
+		w *= math.Exp(math.Log(1-rng.Float64()) / float64(k))
+	}
+	rng.Shuffle(k, func(a, b int) { buf[a], buf[b] = buf[b], buf[a] })
+	return buf
+}
+
+// A wealthSampler sorts samples of at most k agents' wealth, for figures
+// and displays that only need an estimate.
+type wealthSampler struct {
+	k      int
+	rng    *rand.Rand
+	sample []float64
+	sort   []float64
+}
+
+func newWealthSampler(k int) *wealthSampler {
+	return &wealthSampler{k: k, rng: rand.New(rand.NewSource(1))}
+}
+
+// sorted returns a sample of wealth, ascending, and its total. It is only
+// valid until the next call.
+func (s *wealthSampler) sorted(wealth []float64) ([]float64, float64) {
+	sample := wealth
+	if len(wealth) > s.k {
+		s.sample = sampleWealth(wealth, s.k, s.rng, s.sample)
+		sample = s.sample
+	}
+	s.sort = append(s.sort[:0], sample...)
+	sort.Float64s(s.sort)
+	total := 0.0
+	for _, w := range s.sort {
+		total += w
+	}
+	return s.sort, total
+}
+
+// gini returns the Gini coefficient of the ascending wealths sorted, which
+// sum to total.
+func gini(sorted []float64, total float64) float64 {
+	n := float64(len(sorted))
+	if n == 0 || total == 0 {
+		return 0
+	}
+	weighted := 0.0
+	for i, w := range sorted {
+		weighted += float64(i+1) * w
+	}
+	return 2*weighted/(n*total) - (n+1)/n
+}
+
+// lorenz returns the Lorenz curve of the ascending wealths sorted, which sum
+// to total: the share of total held by the poorest i/points of agents, for
+// i from 0 to points.
+func lorenz(sorted []float64, total float64, points int) []float64 {
+	curve := make([]float64, points+1)
+	if total == 0 {
+		return curve
+	}
+	cum, k := 0.0, 0
+	for i := 1; i <= points; i++ {
+		for end := i * len(sorted) / points; k < end; k++ {
+			cum += sorted[k]
+		}
+		curve[i] = cum / total
+	}
+	return curve
+}
+
+// quantile returns the p-quantile of the ascending values sorted,
+// interpolating linearly between order statistics.
+func quantile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	h := p * float64(len(sorted)-1)
+	lo := int(h)
+	if lo+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[lo] + (h-float64(lo))*(sorted[lo+1]-sorted[lo])
+}
+
+// entropy returns the Shannon entropy, in nats, of the agents' shares of
+// total wealth; agents with no positive wealth hold no share.
+func entropy(wealth []float64, total float64) float64 {
+	h := 0.0
+	for _, w := range wealth {
+		if w > 0 && total > 0 {
+			p := w / total
+			h -= p * math.Log(p)
+		}
+	}
+	return h
+}
+
+// histogram counts the ascending values sorted into bins equal-width bins
+// spanning their range.
+func histogram(sorted []float64, bins int) []float64 {
+	if len(sorted) == 0 {
+		return make([]float64, bins)
+	}
+	return histogramOver(sorted, sorted[0], sorted[len(sorted)-1], bins)
+}
+
+// histogramOver counts values into bins equal-width bins from lo to hi,
+// putting any outside them in the end bins.
+func histogramOver(values []float64, lo, hi float64, bins int) []float64 {
+	counts := make([]float64, bins)
+	for _, w := range values {
+		b := 0
+		if hi > lo {
+			b = int(float64(bins) * (w - lo) / (hi - lo))
+		}
+		if b >= bins {
+			b = bins - 1
+		} else if b < 0 {
+			b = 0
+		}
+		counts[b]++
+	}
+	return counts
+}
+
+func quantileColumns() []string {
+	cols := make([]string, len(quantileLevels))
+	for i, p := range quantileLevels {
+		cols[i] = fmt.Sprintf("q%02.0f", 100*p)
+	}
+	return cols
+}
+
+func histogramColumns() []string {
+	cols := make([]string, HistogramBins)
+	for i := range cols {
+		cols[i] = fmt.Sprintf("bin%d", i)
+	}
+	return cols
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestGini(t *testing.T) {
+	if g := gini([]float64{5, 5, 5, 5}, 20); g != 0 {
+		t.Errorf("equal wealth: Gini %v, want 0", g)
+	}
+	if g := gini([]float64{0, 0, 0, 4}, 4); math.Abs(g-0.75) > 1e-12 {
+		t.Errorf("one holds everything: Gini %v, want 0.75", g)
+	}
+}
+
+// TestComputeMetricsParallel checks that sharing the metrics among workers
+// gives the same values as computing them one after another.
+func TestComputeMetricsParallel(t *testing.T) {
+	metrics, err := lookupMetrics([]string{"gini", "quantiles", "entropy", "histogram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rng := rand.New(rand.NewSource(1))
+	wealth := make([]float64, 1000)
+	for i := range wealth {
+		wealth[i] = rng.ExpFloat64()
+	}
+	serial := computeMetrics(metrics, &snapshot{wealth: wealth}, 1)
+	parallel := computeMetrics(metrics, &snapshot{wealth: wealth}, 4)
+	if !reflect.DeepEqual(serial, parallel) {
+		t.Errorf("parallel metrics %v differ from serial %v", parallel, serial)
+	}
+	if q := serial[1]; !(q[0] <= q[1] && q[1] <= q[2] && q[2] <= q[3] && q[3] <= q[4]) {
+		t.Errorf("quantiles out of order: %v", q)
+	}
+}
+
+func BenchmarkComputeMetrics(b *testing.B) {
+	metrics, _ := lookupMetrics([]string{"gini", "quantiles", "entropy", "histogram"})
+	rng := rand.New(rand.NewSource(1))
+	s := &snapshot{wealth: make([]float64, 100000)}
+	for i := range s.wealth {
+		s.wealth[i] = rng.ExpFloat64()
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		computeMetrics(metrics, s, MetricWorkers)
+	}
+}
+
+// TestSampleWealth checks that every agent is equally likely to be sampled.
+func TestSampleWealth(t *testing.T) {
+	n, k, trials := 1000, 10, 20000
+	wealth := make([]float64, n)
+	for i := range wealth {
+		wealth[i] = float64(i)
+	}
+	rng := rand.New(rand.NewSource(2))
+	var blocks [10]int // how often agents from each tenth of the population are drawn
+	var buf []float64
+	for trial := 0; trial < trials; trial++ {
+		buf = sampleWealth(wealth, k, rng, buf)
+		for _, w := range buf {
+			blocks[int(w)*len(blocks)/n]++
+		}
+	}
+	want := float64(trials*k) / float64(len(blocks))
+	for b, got := range blocks {
+		if math.Abs(float64(got)-want) > 0.05*want {
+			t.Errorf("tenth %d of the agents drawn %d times, want about %v", b, got, want)
+		}
+	}
+}
+
+// TestSampledGini checks that a sampled Gini lands within a few standard
+// errors of the exact one.
+func TestSampledGini(t *testing.T) {
+	defer func(sample int, metrics []string) { MetricSample, Metrics = sample, metrics }(MetricSample, Metrics)
+	MetricSample, Metrics = 20000, []string{"gini"}
+	rng := rand.New(rand.NewSource(4))
+	Pop := NewPopulation(200000)
+	for i := range Pop.Wealth {
+		Pop.Wealth[i] = rng.ExpFloat64()
+	}
+	r := newMetricRecorder(rng, nil)
+	r.record(Pop, 0)
+	exact := computeMetrics(r.metrics, &snapshot{wealth: Pop.Wealth}, 1)[0][0]
+	est, se := r.last[0][0], r.lastSE[0][0]
+	if se <= 0 || math.Abs(est-exact) > 4*se {
+		t.Errorf("sampled Gini %v ± %v, exact %v", est, se, exact)
+	}
+}
+
+func TestLorenz(t *testing.T) {
+	got := lorenz([]float64{1, 1, 2, 4}, 8, 4)
+	want := []float64{0, 0.125, 0.25, 0.5, 1}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("lorenz = %v, want %v", got, want)
+	}
+	if got := lorenz([]float64{0, 0}, 0, 2); !reflect.DeepEqual(got, []float64{0, 0, 0}) {
+		t.Errorf("lorenz of nothing = %v", got)
+	}
+}
+
+func TestGiniPopulation(t *testing.T) {
+	pop := NewPopulation(4)
+	copy(pop.Wealth, []float64{4, 0, 0, 0})
+	if g := Gini(pop); math.Abs(g-0.75) > 1e-12 {
+		t.Errorf("one holds everything: Gini %v, want 0.75", g)
+	}
+	if pop.Wealth[0] != 4 {
+		t.Error("Gini reordered the Population")
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import "math/rand"
+
+/* Mobility */
+
+/*
+ * With Mobility, agents live on the nodes of a location graph and only
+ * interact with agents at the same place. At the start of every turn each
+ * agent moves, with probability Rate, to a neighboring place chosen by edge
+ * weight; Rate is therefore a dial on how quickly wealth mixes spatially.
+ */
+type Mobility struct {
+	Places *Network
+	Rate   float64
+}
+
+// Scatter puts every agent at a uniformly random place.
+func (mob *Mobility) Scatter(Pop Population, rng *rand.Rand) {
+	for i := 0; i < Pop.Len(); i++ {
+		Pop.Agents[i].place = rng.Intn(mob.Places.Size())
+	}
+}
+
+// Move gives every agent its chance to move to a neighboring place.
+func (mob *Mobility) Move(Pop Population, rng *rand.Rand) {
+	for i := 0; i < Pop.Len(); i++ {
+		if rng.Float64() >= mob.Rate {
+			continue
+		}
+		here := Pop.Agents[i].place
+		if x := choose(mob.Places.Weights(here), rng); x >= 0 {
+			Pop.Agents[i].place = mob.Places.Neighbors(here)[x]
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestMove checks that at rate 1 every agent steps to a neighboring place,
+// and at rate 0 nobody moves.
+func TestMove(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	Pop := NewPopulation(50)
+	mob := &Mobility{Places: RingLattice(6, 1, rng), Rate: 1}
+	mob.Scatter(Pop, rng)
+	before := make([]int, Pop.Len())
+	for i := range before {
+		before[i] = Pop.Agents[i].place
+		if before[i] < 0 || before[i] >= 6 {
+			t.Fatalf("agent %d scattered to place %d", i, before[i])
+		}
+	}
+	mob.Move(Pop, rng)
+	for i, here := range before {
+		there, adjacent := Pop.Agents[i].place, false
+		for _, j := range mob.Places.Neighbors(here) {
+			adjacent = adjacent || j == there
+		}
+		if !adjacent {
+			t.Fatalf("agent %d moved from place %d to %d", i, here, there)
+		}
+	}
+	mob.Rate = 0
+	for i := range before {
+		before[i] = Pop.Agents[i].place
+	}
+	mob.Move(Pop, rng)
+	for i, here := range before {
+		if Pop.Agents[i].place != here {
+			t.Fatalf("agent %d moved at rate 0", i)
+		}
+	}
+}
+
+// TestMobileModel checks that MobilityPlaces gives a Model a ring of places
+// that its agents wander over the turns.
+func TestMobileModel(t *testing.T) {
+	defer func(agents, places int, rate float64) {
+		NumOfAgents, MobilityPlaces, MobilityRate = agents, places, rate
+	}(NumOfAgents, MobilityPlaces, MobilityRate)
+	NumOfAgents, MobilityPlaces, MobilityRate = 40, 5, 0.5
+	m := NewModel(random, rand.New(rand.NewSource(2)))
+	if m.Mobility == nil || m.Mobility.Places.Size() != 5 {
+		t.Fatal("no ring of 5 places")
+	}
+	start := make([]int, NumOfAgents)
+	for i := range start {
+		start[i] = m.Pop.Agents[i].place
+	}
+	for turn := 0; turn < 5; turn++ {
+		m.Step()
+	}
+	moved := 0
+	for i := range start {
+		if m.Pop.Agents[i].place != start[i] {
+			moved++
+		}
+	}
+	if moved == 0 {
+		t.Error("nobody moved in 5 turns at rate 0.5")
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+/* Network export */
+
+// WriteDOT writes the network as a Graphviz graph, with each agent's wealth
+// and activation count as node attributes.
+func (net *Network) WriteDOT(w io.Writer, Pop Population) error {
+	bw := bufio.NewWriter(w)
+	kind, link := "graph", "--"
+	if net.Directed() {
+		kind, link = "digraph", "->"
+	}
+	fmt.Fprintf(bw, "%s leveler {\n", kind)
+	for i := 0; i < net.Size(); i++ {
+		fmt.Fprintf(bw, "\t%d [wealth=%g, activations=%d, label=\"%g\"];\n",
+			i, Pop.Wealth[i], Pop.Agents[i].activations, Pop.Wealth[i])
+	}
+	net.edges(func(i, j int, wt float64, d Direction) {
+		if net.Directed() && d == Undirected {
+			fmt.Fprintf(bw, "\t%d %s %d [weight=%g, dir=none];\n", i, link, j, wt)
+		} else {
+			fmt.Fprintf(bw, "\t%d %s %d [weight=%g];\n", i, link, j, wt)
+		}
+	})
+	fmt.Fprintf(bw, "}\n")
+	return bw.Flush()
+}
+
+// WriteGEXF writes the network in Gephi's GEXF format, with each agent's
+// wealth and activation count as node attributes.
+func (net *Network) WriteGEXF(w io.Writer, Pop Population) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	fmt.Fprintf(bw, "<gexf xmlns=\"http://www.gexf.net/1.2draft\" version=\"1.2\">\n")
+	kind := "undirected"
+	if net.Directed() {
+		kind = "mixed"
+	}
+	fmt.Fprintf(bw, "  <graph mode=\"static\" defaultedgetype=\"%s\">\n", kind)
+	fmt.Fprintf(bw, "    <attributes class=\"node\">\n")
+	fmt.Fprintf(bw, "      <attribute id=\"wealth\" title=\"wealth\" type=\"double\"/>\n")
+	fmt.Fprintf(bw, "      <attribute id=\"activations\" title=\"activations\" type=\"integer\"/>\n")
+	fmt.Fprintf(bw, "    </attributes>\n")
+	fmt.Fprintf(bw, "    <nodes>\n")
+	for i := 0; i < net.Size(); i++ {
+		fmt.Fprintf(bw, "      <node id=\"%d\" label=\"%d\"><attvalues>", i, i)
+		fmt.Fprintf(bw, "<attvalue for=\"wealth\" value=\"%g\"/>", Pop.Wealth[i])
+		fmt.Fprintf(bw, "<attvalue for=\"activations\" value=\"%d\"/>", Pop.Agents[i].activations)
+		fmt.Fprintf(bw, "</attvalues></node>\n")
+	}
+	fmt.Fprintf(bw, "    </nodes>\n")
+	fmt.Fprintf(bw, "    <edges>\n")
+	e := 0
+	net.edges(func(i, j int, wt float64, d Direction) {
+		kind := "undirected"
+		if d != Undirected {
+			kind = "directed"
+		}
+		fmt.Fprintf(bw, "      <edge id=\"%d\" source=\"%d\" target=\"%d\" type=\"%s\" weight=\"%g\"/>\n",
+			e, i, j, kind, wt)
+		e++
+	})
+	fmt.Fprintf(bw, "    </edges>\n")
+	fmt.Fprintf(bw, "  </graph>\n")
+	fmt.Fprintf(bw, "</gexf>\n")
+	return bw.Flush()
+}
+
+// SnapshotNetwork writes the Model's network to the named file, as GEXF if
+// the name ends in .gexf and as DOT otherwise.
+func (m *Model) SnapshotNetwork(name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if strings.HasSuffix(name, ".gexf") {
+		err = m.Net.WriteGEXF(f, m.Pop)
+	} else {
+		err = m.Net.WriteDOT(f, m.Pop)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// exportTriangle returns a three-agent network with a weighted edge 0-1, and
+// an edge 1-2 pointing from 1 to 2 if directed, and the agents' Population.
+func exportTriangle(directed bool) (*Network, Population) {
+	net := newNetwork(3)
+	net.addWeightedEdge(0, 1, 2.5)
+	if directed {
+		net.addDirectedEdge(1, 2, 1, Forward)
+	} else {
+		net.addEdge(1, 2)
+	}
+	Pop := NewPopulation(3)
+	copy(Pop.Wealth, []float64{1, 20, 300})
+	Pop.Agents[1].activations = 4
+	return net, Pop
+}
+
+// TestWriteDOT checks the nodes, attributes and edges of a DOT export, and
+// that a directed edge points the right way while an undirected one in the
+// same graph shows no arrow.
+func TestWriteDOT(t *testing.T) {
+	for _, directed := range []bool{false, true} {
+		net, Pop := exportTriangle(directed)
+		var b bytes.Buffer
+		if err := net.WriteDOT(&b, Pop); err != nil {
+			t.Fatal(err)
+		}
+		want := []string{
+			"graph leveler {",
+			"\t1 [wealth=20, activations=4, label=\"20\"];",
+			"\t0 -- 1 [weight=2.5];",
+			"\t1 -- 2 [weight=1];",
+		}
+		if directed {
+			want = []string{
+				"digraph leveler {",
+				"\t0 -> 1 [weight=2.5, dir=none];",
+				"\t1 -> 2 [weight=1];",
+			}
+		}
+		for _, line := range want {
+			if !strings.Contains(b.String(), line+"\n") {
+				t.Errorf("directed %v: no line %q in\n%s", directed, line, b.String())
+			}
+		}
+	}
+}
+
+// TestWriteGEXF checks that a GEXF export is well-formed XML with every node,
+// its wealth and every edge, typed by its direction.
+func TestWriteGEXF(t *testing.T) {
+	net, Pop := exportTriangle(true)
+	var b bytes.Buffer
+	if err := net.WriteGEXF(&b, Pop); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Graph struct {
+			Mode  string `xml:"defaultedgetype,attr"`
+			Nodes []struct {
+				ID     string `xml:"id,attr"`
+				Values []struct {
+					For   string `xml:"for,attr"`
+					Value string `xml:"value,attr"`
+				} `xml:"attvalues>attvalue"`
+			} `xml:"nodes>node"`
+			Edges []struct {
+				Source string `xml:"source,attr"`
+				Target string `xml:"target,attr"`
+				Type   string `xml:"type,attr"`
+			} `xml:"edges>edge"`
+		} `xml:"graph"`
+	}
+	if err := xml.Unmarshal(b.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	g := doc.Graph
+	if g.Mode != "mixed" || len(g.Nodes) != 3 || len(g.Edges) != 2 {
+		t.Fatalf("%s graph of %d nodes and %d edges", g.Mode, len(g.Nodes), len(g.Edges))
+	}
+	if v := g.Nodes[2].Values[0]; v.For != "wealth" || v.Value != "300" {
+		t.Errorf("node 2 has %s %s", v.For, v.Value)
+	}
+	if e := g.Edges[0]; e.Source != "0" || e.Target != "1" || e.Type != "undirected" {
+		t.Errorf("first edge %+v", e)
+	}
+	if e := g.Edges[1]; e.Source != "1" || e.Target != "2" || e.Type != "directed" {
+		t.Errorf("second edge %+v", e)
+	}
+}
+
+// TestSnapshotNetwork checks that a snapshot's format follows its file name.
+func TestSnapshotNetwork(t *testing.T) {
+	net, Pop := exportTriangle(false)
+	m := &Model{Net: net, Pop: Pop}
+	dir := t.TempDir()
+	for name, prefix := range map[string]string{"net.dot": "graph ", "net.gexf": "<?xml"} {
+		path := filepath.Join(dir, name)
+		if err := m.SnapshotNetwork(path); err != nil {
+			t.Fatal(err)
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(b), prefix) {
+			t.Errorf("%s starts %q", name, b[:10])
+		}
+	}
+}
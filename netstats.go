@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+/* Network statistics */
+
+// NetworkStats describes the structure of a Network.
+type NetworkStats struct {
+	Nodes, Edges   int
+	MeanDegree     float64
+	Degrees        map[int]int // degree distribution: degree -> number of nodes
+	Clustering     float64     // mean local clustering coefficient
+	MeanPathLength float64     // mean shortest path from the sampled sources to nodes they reach
+	Sources        int         // number of BFS sources sampled for MeanPathLength
+}
+
+// Stats computes the degree distribution and clustering coefficient of the
+// whole network, and the average path length from a random sample of
+// sources (all nodes if sources >= Size()).
+func (net *Network) Stats(sources int, rng *rand.Rand) NetworkStats {
+	n := net.Size()
+	s := NetworkStats{Nodes: n, Degrees: make(map[int]int)}
+	for i := 0; i < n; i++ {
+		d := len(net.adj[i])
+		s.Degrees[d]++
+		s.Edges += d
+	}
+	s.Edges /= 2
+	if n > 0 {
+		s.MeanDegree = float64(2*s.Edges) / float64(n)
+	}
+
+	// clustering: the share of a node's neighbor pairs that are themselves linked
+	mark := make([]int, n)
+	for i := 0; i < n; i++ {
+		d := len(net.adj[i])
+		if d < 2 {
+			continue
+		}
+		for _, j := range net.adj[i] {
+			mark[j] = i + 1
+		}
+		links := 0
+		for _, j := range net.adj[i] {
+			for _, k := range net.adj[j] {
+				if mark[k] == i+1 {
+					links++
+				}
+			}
+		}
+		// each link between neighbors was seen from both ends
+		s.Clustering += float64(links) / float64(d*(d-1))
+	}
+	if n > 0 {
+		s.Clustering /= float64(n)
+	}
+
+	// path length by breadth-first search from sampled sources
+	order := rng.Perm(n)
+	if sources > n {
+		sources = n
+	}
+	s.Sources = sources
+	dist := make([]int, n)
+	queue := make([]int, 0, n)
+	total, reached := 0, 0
+	for _, src := range order[:sources] {
+		for i := range dist {
+			dist[i] = -1
+		}
+		dist[src] = 0
+		queue = append(queue[:0], src)
+		for len(queue) > 0 {
+			i := queue[0]
+			queue = queue[1:]
+			for _, j := range net.adj[i] {
+				if dist[j] < 0 {
+					dist[j] = dist[i] + 1
+					total += dist[j]
+					reached++
+					queue = append(queue, j)
+				}
+			}
+		}
+	}
+	if reached > 0 {
+		s.MeanPathLength = float64(total) / float64(reached)
+	}
+	return s
+}
+
+func (s NetworkStats) String() string {
+	degrees := make([]int, 0, len(s.Degrees))
+	for d := range s.Degrees {
+		degrees = append(degrees, d)
+	}
+	sort.Ints(degrees)
+	dist := make([]string, len(degrees))
+	for k, d := range degrees {
+		dist[k] = fmt.Sprintf("%d:%d", d, s.Degrees[d])
+	}
+	return fmt.Sprintf("%d nodes, %d edges, mean degree %.2f, clustering %.4f, mean path length %.3f (%d sources), degrees {%s}",
+		s.Nodes, s.Edges, s.MeanDegree, s.Clustering, s.MeanPathLength, s.Sources, strings.Join(dist, " "))
+}
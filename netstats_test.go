@@ -0,0 +1,49 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// TestNetworkStats checks the statistics of a triangle with a pendant
+// agent hanging off one corner, worked out by hand.
+func TestNetworkStats(t *testing.T) {
+	net := newNetwork(4)
+	net.addEdge(0, 1)
+	net.addEdge(1, 2)
+	net.addEdge(2, 0)
+	net.addEdge(2, 3)
+	s := net.Stats(10, rand.New(rand.NewSource(1)))
+	if s.Nodes != 4 || s.Edges != 4 || s.MeanDegree != 2 || s.Sources != 4 {
+		t.Errorf("%d nodes, %d edges, mean degree %v, %d sources", s.Nodes, s.Edges, s.MeanDegree, s.Sources)
+	}
+	if s.Degrees[1] != 1 || s.Degrees[2] != 2 || s.Degrees[3] != 1 {
+		t.Errorf("degrees %v", s.Degrees)
+	}
+	if math.Abs(s.Clustering-7.0/12) > 1e-12 {
+		t.Errorf("clustering %v, want 7/12", s.Clustering)
+	}
+	if math.Abs(s.MeanPathLength-4.0/3) > 1e-12 {
+		t.Errorf("mean path length %v, want 4/3", s.MeanPathLength)
+	}
+	if str := s.String(); !strings.Contains(str, "degrees {1:1 2:2 3:1}") {
+		t.Errorf("String() is %q", str)
+	}
+}
+
+// TestRingStats checks that a ring of nearest neighbors has no triangles,
+// and that sampling fewer sources still measures paths on a regular graph
+// correctly.
+func TestRingStats(t *testing.T) {
+	net := RingLattice(10, 1, rand.New(rand.NewSource(2)))
+	s := net.Stats(3, rand.New(rand.NewSource(3)))
+	if s.Clustering != 0 || s.Degrees[2] != 10 || s.Sources != 3 {
+		t.Errorf("clustering %v, degrees %v, %d sources", s.Clustering, s.Degrees, s.Sources)
+	}
+	// from any agent: two at each distance 1 to 4, one at 5
+	if math.Abs(s.MeanPathLength-25.0/9) > 1e-12 {
+		t.Errorf("mean path length %v, want 25/9", s.MeanPathLength)
+	}
+}
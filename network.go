@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+/* Networks */
+
+/*
+ * A Network gives each agent a neighborhood. Agents are identified by their
+ * index in the Population, so the adjacency lists hold indices into Pop
+ * rather than pointers. Local wealth aggregates (sum and sum of squares
+ * over the closed neighborhood) are kept in buffers that are reused from turn
+ * to turn, along with the wealth they were taken from, so refreshing them
+ * only sums again the neighborhoods of agents whose wealth has changed, and
+ * allocates nothing. Edges don't change once a network is built: a temporal
+ * network is a new one every turn, which sums every neighborhood once.
+ * Every edge carries a weight and a direction, parallel to adj; lattices
+ * use weight 1. Directed edges still appear in both endpoints' adjacency
+ * lists, since either end can be activated, but dir records which way they
+ * point.
+ */
+type Network struct {
+	adj    [][]int
+	weight [][]float64
+	dir    [][]Direction
+
+	directed bool // true if any edge has a direction
+
+	localSum   []float64
+	localSumSq []float64
+	summed     []float64 // each agent's wealth as of the last Aggregate; nil before the first
+	stale      []bool    // neighborhoods to sum again
+}
+
+// RingLattice places n agents on a ring in random order and connects each
+// agent to the k nearest agents on either side. The random placement keeps
+// neighborhoods from simply mirroring the 1..N initial wealth ordering.
+func RingLattice(n, k int, rng *rand.Rand) *Network {
+	if 2*k >= n {
+		k = (n - 1) / 2
+	}
+	net := newNetwork(n)
+	for i := 0; i < n; i++ { // every node ends up with exactly 2k edges
+		net.adj[i] = make([]int, 0, 2*k)
+		net.weight[i] = make([]float64, 0, 2*k)
+		net.dir[i] = make([]Direction, 0, 2*k)
+	}
+	order := rng.Perm(n)
+	for p := 0; p < n; p++ {
+		for d := 1; d <= k; d++ {
+			net.addEdge(order[p], order[(p+d)%n])
+		}
+	}
+	return net
+}
+
+// Direction is the orientation of an edge as seen from the first of the two
+// agents asked about.
+type Direction int8
+
+const (
+	Undirected Direction = iota // also used for agents that are not adjacent
+	Forward                     // the edge points from the first agent to the second
+	Backward                    // the edge points from the second agent to the first
+)
+
+// Reverse returns the direction as seen from the other end of the edge.
+func (d Direction) Reverse() Direction {
+	if d == Forward {
+		return Backward
+	} else if d == Backward {
+		return Forward
+	}
+	return d
+}
+
+func newNetwork(n int) *Network {
+	return &Network{
+		adj:        make([][]int, n),
+		weight:     make([][]float64, n),
+		dir:        make([][]Direction, n),
+		localSum:   make([]float64, n),
+		localSumSq: make([]float64, n),
+	}
+}
+
+func (net *Network) addEdge(i, j int) {
+	net.addWeightedEdge(i, j, 1)
+}
+
+func (net *Network) addWeightedEdge(i, j int, w float64) {
+	net.addDirectedEdge(i, j, w, Undirected)
+}
+
+// addDirectedEdge adds an edge with direction d as seen from i.
+func (net *Network) addDirectedEdge(i, j int, w float64, d Direction) {
+	net.adj[i] = append(net.adj[i], j)
+	net.adj[j] = append(net.adj[j], i)
+	net.weight[i] = append(net.weight[i], w)
+	net.weight[j] = append(net.weight[j], w)
+	net.dir[i] = append(net.dir[i], d)
+	net.dir[j] = append(net.dir[j], d.Reverse())
+	if d != Undirected {
+		net.directed = true
+	}
+}
+
+// LoadEdgeList reads an optionally weighted network over n agents. Each
+// line holds two agent indices and an optional non-negative weight (default
+// 1), separated by spaces, tabs or commas; blank lines and lines starting
+// with # are skipped. If directed is true, each edge points from its first
+// agent to its second.
+func LoadEdgeList(r io.Reader, n int, directed bool) (*Network, error) {
+	net := newNetwork(n)
+	err := scanEdges(r, n, 0, func(i, j int, w float64, extra []string) error {
+		if directed {
+			net.addDirectedEdge(i, j, w, Forward)
+		} else {
+			net.addWeightedEdge(i, j, w)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return net, nil
+}
+
+// scanEdges reads edge list lines of the form "i j <extra fields> [weight]",
+// validating the agent indices and weight and handing the extra fields to f.
+func scanEdges(r io.Reader, n, extra int, f func(i, j int, w float64, extra []string) error) error {
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.FieldsFunc(text, func(c rune) bool {
+			return c == ' ' || c == '\t' || c == ','
+		})
+		if len(fields) != 2+extra && len(fields) != 3+extra {
+			return fmt.Errorf("edge list line %d: want %d or %d fields, got %d", line, 2+extra, 3+extra, len(fields))
+		}
+		i, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return fmt.Errorf("edge list line %d: %v", line, err)
+		}
+		j, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return fmt.Errorf("edge list line %d: %v", line, err)
+		}
+		if i < 0 || i >= n || j < 0 || j >= n || i == j {
+			return fmt.Errorf("edge list line %d: bad edge %d-%d for %d agents", line, i, j, n)
+		}
+		w := 1.0
+		if len(fields) == 3+extra {
+			if w, err = strconv.ParseFloat(fields[2+extra], 64); err != nil {
+				return fmt.Errorf("edge list line %d: %v", line, err)
+			}
+			if w < 0 {
+				return fmt.Errorf("edge list line %d: negative weight %v", line, w)
+			}
+		}
+		if err := f(i, j, w, fields[2:2+extra]); err != nil {
+			return fmt.Errorf("edge list line %d: %v", line, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// Clone returns a copy of the network with its own aggregate buffers.
+func (net *Network) Clone() *Network {
+	c := newNetwork(net.Size())
+	for i := 0; i < net.Size(); i++ {
+		c.adj[i] = append([]int(nil), net.adj[i]...)
+		c.weight[i] = append([]float64(nil), net.weight[i]...)
+		c.dir[i] = append([]Direction(nil), net.dir[i]...)
+	}
+	c.directed = net.directed
+	return c
+}
+
+// entries returns the total length of the adjacency lists, twice the number
+// of edges.
+func (net *Network) entries() int {
+	e := 0
+	for _, a := range net.adj {
+		e += len(a)
+	}
+	return e
+}
+
+// Size returns the number of nodes in the network.
+func (net *Network) Size() int {
+	return len(net.adj)
+}
+
+// Neighbors returns the indices of the agents adjacent to agent i.
+func (net *Network) Neighbors(i int) []int {
+	return net.adj[i]
+}
+
+// Weights returns the weights of agent i's edges, in the order of Neighbors(i).
+func (net *Network) Weights(i int) []float64 {
+	return net.weight[i]
+}
+
+// Direction returns the orientation of the edge between agents i and j, as
+// seen from i; Undirected if the edge has none or there is no edge.
+func (net *Network) Direction(i, j int) Direction {
+	for k, n := range net.adj[i] {
+		if n == j {
+			return net.dir[i][k]
+		}
+	}
+	return Undirected
+}
+
+// Directed reports whether any edge of the network has a direction.
+func (net *Network) Directed() bool {
+	return net.directed
+}
+
+// edges calls f once for every edge, oriented along its direction if it has one.
+func (net *Network) edges(f func(i, j int, w float64, d Direction)) {
+	for i := 0; i < len(net.adj); i++ {
+		for k, j := range net.adj[i] {
+			if d := net.dir[i][k]; d == Forward || (d == Undirected && i < j) {
+				f(i, j, net.weight[i][k], d)
+			}
+		}
+	}
+}
+
+// Aggregate refreshes the local wealth sums for every neighborhood. Those
+// with no agent whose wealth changed since the last call are left as they
+// are; the rest are summed from scratch, so the sums come out the same as if
+// all were.
+func (net *Network) Aggregate(Pop Population) {
+	n := len(net.adj)
+	if net.summed == nil {
+		net.summed = make([]float64, n)
+		net.stale = make([]bool, n)
+		for i := range net.stale {
+			net.stale[i] = true
+		}
+	} else {
+		for i, w := range Pop.Wealth[:n] {
+			if w != net.summed[i] {
+				net.stale[i] = true
+				for _, j := range net.adj[i] {
+					net.stale[j] = true
+				}
+			}
+		}
+	}
+	copy(net.summed, Pop.Wealth[:n])
+	for i := 0; i < n; i++ {
+		if !net.stale[i] {
+			continue
+		}
+		net.stale[i] = false
+		w := Pop.Wealth[i]
+		net.localSum[i] = w
+		net.localSumSq[i] = w * w
+		for _, j := range net.adj[i] {
+			w = Pop.Wealth[j]
+			net.localSum[i] += w
+			net.localSumSq[i] += w * w
+		}
+	}
+}
+
+// LocalMean returns the mean wealth of agent i and its neighbors as of the
+// last call to Aggregate.
+func (net *Network) LocalMean(i int) float64 {
+	return net.localSum[i] / float64(len(net.adj[i])+1)
+}
+
+// LocalSD returns the (population) standard deviation of wealth in agent i's
+// closed neighborhood as of the last call to Aggregate.
+func (net *Network) LocalSD(i int) float64 {
+	n := float64(len(net.adj[i]) + 1)
+	mean := net.localSum[i] / n
+	v := net.localSumSq[i]/n - mean*mean
+	if v < 0 { // rounding
+		v = 0
+	}
+	return math.Sqrt(v)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// TestAggregateIncremental checks that refreshing the local sums after a few
+// agents' wealth changes gives exactly the sums of a fresh network.
+func TestAggregateIncremental(t *testing.T) {
+	defer func(agents int) { NumOfAgents = agents }(NumOfAgents)
+	NumOfAgents = 50
+	rng := rand.New(rand.NewSource(8))
+	m := NewModel(uniform, rng)
+	net := RingLattice(NumOfAgents, 3, rng)
+	net.Aggregate(m.Pop)
+	for turn := 0; turn < 3; turn++ {
+		for k := 0; k < 5; k++ {
+			m.Pop.Wealth[rng.Intn(NumOfAgents)] *= 1 + rng.Float64()
+		}
+		if turn == 2 { // nothing changed since the last turn
+			net.Aggregate(m.Pop)
+		}
+		net.Aggregate(m.Pop)
+		fresh := net.Clone()
+		fresh.Aggregate(m.Pop)
+		for i := 0; i < NumOfAgents; i++ {
+			if net.localSum[i] != fresh.localSum[i] || net.localSumSq[i] != fresh.localSumSq[i] {
+				t.Fatalf("turn %d: agent %d's sums are %v, %v, want %v, %v", turn, i,
+					net.localSum[i], net.localSumSq[i], fresh.localSum[i], fresh.localSumSq[i])
+			}
+		}
+	}
+}
+
+// TestDirectedEdgeList checks that a directed edge list keeps each edge's
+// orientation from both ends, and an undirected one has none.
+func TestDirectedEdgeList(t *testing.T) {
+	for _, directed := range []bool{false, true} {
+		net, err := LoadEdgeList(strings.NewReader("0 1\n2,1 0.5\n"), 3, directed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if net.Directed() != directed {
+			t.Errorf("directed %v: Directed() is %v", directed, net.Directed())
+		}
+		want := []Direction{Undirected, Undirected, Undirected}
+		if directed {
+			want = []Direction{Forward, Backward, Undirected}
+		}
+		for k, got := range []Direction{net.Direction(0, 1), net.Direction(1, 2), net.Direction(0, 2)} {
+			if got != want[k] {
+				t.Errorf("directed %v: direction %d is %v, want %v", directed, k, got, want[k])
+			}
+		}
+		if len(net.Neighbors(1)) != 2 {
+			t.Errorf("directed %v: agent 1 has neighbors %v", directed, net.Neighbors(1))
+		}
+	}
+}
+
+// TestDirectedExchange checks that a pair joined by a directed edge pays
+// tribute along it, whichever of them was activated first, while an
+// undirected pair uses the Model's Rule.
+func TestDirectedExchange(t *testing.T) {
+	defer func(agents int, exchange string, rate float64) {
+		NumOfAgents, DirectedExchange, DirectedRate = agents, exchange, rate
+	}(NumOfAgents, DirectedExchange, DirectedRate)
+	NumOfAgents, DirectedExchange, DirectedRate = 3, "tribute", 0.5
+	m := NewModel(random, rand.New(rand.NewSource(1)))
+	net := newNetwork(3)
+	net.addDirectedEdge(0, 1, 1, Forward)
+	net.addEdge(1, 2)
+	m.SetNetwork(net)
+	copy(m.Pop.Wealth, []float64{10, 100, 4})
+	m.exchange(1, 0)
+	if m.Pop.Wealth[0] != 5 || m.Pop.Wealth[1] != 105 {
+		t.Errorf("after tribute from 0 to 1, wealth %v", m.Pop.Wealth)
+	}
+	m.exchange(1, 2)
+	if m.Pop.Wealth[1] != 54 || m.Pop.Wealth[2] != 54 {
+		t.Errorf("after leveling 1 and 2, wealth %v", m.Pop.Wealth)
+	}
+}
+
+// TestLoadEdgeList checks the weights an edge list gives, and that bad
+// lines are reported with their line number.
+func TestLoadEdgeList(t *testing.T) {
+	net, err := LoadEdgeList(strings.NewReader("# a weighted triangle\n0 1 2\n\n1\t2\n2, 0, 0.25\n"), 3, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range [][]float64{{2, 0.25}, {2, 1}, {1, 0.25}} {
+		got := net.Weights(i)
+		if len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+			t.Errorf("agent %d's weights are %v, want %v", i, got, want)
+		}
+	}
+	for _, bad := range []string{"0 1\n0\n", "0 3\n", "1 1\n", "0 1 -1\n", "0 x\n", "0 1 2 3\n"} {
+		if _, err := LoadEdgeList(strings.NewReader(bad), 3, false); err == nil {
+			t.Errorf("%q accepted", bad)
+		} else if !strings.HasPrefix(err.Error(), "edge list line ") {
+			t.Errorf("%q: error %q doesn't give the line", bad, err)
+		}
+	}
+}
+
+// TestWeightedNetworkPairing checks that network pairing chooses among an
+// agent's neighbors in proportion to edge weight.
+func TestWeightedNetworkPairing(t *testing.T) {
+	defer func(agents int) { NumOfAgents = agents }(NumOfAgents)
+	NumOfAgents = 4
+	m := NewModel(random, rand.New(rand.NewSource(7)))
+	net, err := LoadEdgeList(strings.NewReader("0 1 1\n0 2 3\n2 3\n"), 4, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.SetNetwork(net)
+	m.NetworkPairing = true
+	counts := make([]int, 4)
+	for k := 0; k < 4000; k++ {
+		counts[m.randomPartner(0)]++
+	}
+	if counts[0] != 0 || counts[3] != 0 || counts[1] < 850 || counts[1] > 1150 {
+		t.Errorf("agent 0's partners %v, want about 1000 of agent 1 to 3000 of agent 2", counts)
+	}
+}
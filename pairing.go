@@ -0,0 +1,166 @@
+package main
+
+import "sync"
+
+/* Pairing engine */
+
+/*
+ * A PairingEngine applies an exchange to a list of pairs of agent indices,
+ * in parallel when the list is long enough to pay for it, with exactly the
+ * result of applying them one after another.
+ *
+ * Pairs are assigned to waves: a pair's wave is one more than the latest
+ * wave either of its agents already appears in. Pairs in the same wave are
+ * therefore disjoint, and every agent meets its partners in the original
+ * order, so running the waves one after another -- each split into chunks
+ * across the workers -- never lets two goroutines touch the same agent and
+ * reproduces the serial result bit for bit. A shuffled population (Unifact)
+ * is a single wave; Randmact and Poisact, where agents can appear more than
+ * once, take a few.
+ */
+type PairingEngine struct {
+	Workers   int // goroutines per wave
+	Threshold int // pairs below which everything is done inline
+
+	last   []int // per agent: latest wave it appears in
+	wave   []int // per pair
+	start  []int // per wave: offset of its first pair in byWave
+	byWave []int
+}
+
+// Run applies apply to each pair (flat[2k], flat[2k+1]) of agents drawn
+// from a population of n.
+func (e *PairingEngine) Run(flat []int, n int, apply func(a, b int)) {
+	pairs := len(flat) / 2
+	if pairs < e.Threshold || e.Workers < 2 {
+		for k := 0; k < pairs; k++ {
+			apply(flat[2*k], flat[2*k+1])
+		}
+		return
+	}
+
+	// assign waves
+	e.last = resize(e.last, n)
+	for i := range e.last {
+		e.last[i] = 0
+	}
+	e.wave = resize(e.wave, pairs)
+	waves := 0
+	for k := 0; k < pairs; k++ {
+		a, b := flat[2*k], flat[2*k+1]
+		w := e.last[a]
+		if e.last[b] > w {
+			w = e.last[b]
+		}
+		w++
+		e.last[a], e.last[b] = w, w
+		e.wave[k] = w
+		if w > waves {
+			waves = w
+		}
+	}
+
+	// bucket the pairs by wave, keeping their order within each wave
+	e.start = resize(e.start, waves+2)
+	for w := range e.start {
+		e.start[w] = 0
+	}
+	for k := 0; k < pairs; k++ {
+		e.start[e.wave[k]+1]++
+	}
+	for w := 1; w < len(e.start); w++ {
+		e.start[w] += e.start[w-1]
+	}
+	e.byWave = resize(e.byWave, pairs)
+	next := append([]int(nil), e.start...)
+	for k := 0; k < pairs; k++ {
+		e.byWave[next[e.wave[k]]] = k
+		next[e.wave[k]]++
+	}
+
+	for w := 1; w <= waves; w++ {
+		e.runWave(flat, e.byWave[e.start[w]:e.start[w+1]], apply)
+	}
+}
+
+// runWave applies the disjoint pairs of one wave, split into chunks.
+func (e *PairingEngine) runWave(flat, wave []int, apply func(a, b int)) {
+	if len(wave) < e.Threshold {
+		for _, k := range wave {
+			apply(flat[2*k], flat[2*k+1])
+		}
+		return
+	}
+	chunk := (len(wave) + e.Workers - 1) / e.Workers
+	var wg sync.WaitGroup
+	for start := 0; start < len(wave); start += chunk {
+		end := start + chunk
+		if end > len(wave) {
+			end = len(wave)
+		}
+		wg.Add(1)
+		go func(ks []int) {
+			defer wg.Done()
+			for _, k := range ks {
+				apply(flat[2*k], flat[2*k+1])
+			}
+		}(wave[start:end])
+	}
+	wg.Wait()
+}
+
+// resize returns buf with length n, reallocating only if it is too small.
+func resize(buf []int, n int) []int {
+	if cap(buf) < n {
+		return make([]int, n)
+	}
+	return buf[:n]
+}
+
+// exchangePairs applies exchange to consecutive pairs of agent indices in
+// flat, through the Model's PairingEngine. Group rules reach beyond the
+// pair, so they are always applied inline.
+func (m *Model) exchangePairs(flat []int) {
+	apply := func(a, b int) {
+		m.exchange(a, b)
+	}
+	if m.GroupRule != nil {
+		for k := 0; k+1 < len(flat); k += 2 {
+			apply(flat[k], flat[k+1])
+		}
+		return
+	}
+	m.pairing.Run(flat, m.Pop.Len(), apply)
+}
+
+// batchRule returns the Model's Rule as a BatchRule if BatchExchange is on
+// and every exchange reduces to that Rule applied to wealth alone; otherwise nil.
+func (m *Model) batchRule() BatchRule {
+	if !BatchExchange || m.GroupRule != nil || m.DirectedRule != nil || m.Market != nil || m.Types != nil {
+		return nil
+	}
+	r, _ := m.Rule.(BatchRule)
+	return r
+}
+
+// exchangeBatch levels the disjoint pairs in flat with one call to r: the
+// pairs' wealth is gathered into two slices, leveled as array operations and
+// scattered back.
+func (m *Model) exchangeBatch(r BatchRule, flat []int) {
+	n := len(flat) / 2
+	if cap(m.batchA) < n {
+		m.batchA, m.batchB = make([]float64, n), make([]float64, n)
+	}
+	a, b := m.batchA[:n], m.batchB[:n]
+	wealth := m.Pop.Wealth
+	for k := 0; k < n; k++ {
+		a[k], b[k] = wealth[flat[2*k]], wealth[flat[2*k+1]]
+	}
+	r.ApplyBatch(a, b)
+	for k := 0; k < n; k++ {
+		i, j := flat[2*k], flat[2*k+1]
+		wealth[i], wealth[j] = a[k], b[k]
+		m.Pop.Agents[i].activations++
+		m.Pop.Agents[j].activations++
+	}
+}
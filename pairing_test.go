@@ -0,0 +1,99 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestPairingEngineDeterministic checks that splitting a turn's exchanges
+// across workers gives bit-identical wealth to applying them serially.
+func TestPairingEngineDeterministic(t *testing.T) {
+	for _, act := range []ActivationOrder{uniform, random, poisson, inversePoisson} {
+		serial := NewModel(act, rand.New(rand.NewSource(1)))
+		parallel := NewModel(act, rand.New(rand.NewSource(1)))
+		serial.pairing = PairingEngine{Workers: 1}
+		parallel.pairing = PairingEngine{Workers: 4, Threshold: 1}
+		for turn := 0; turn < NumTurns; turn++ {
+			serial.Step()
+			parallel.Step()
+		}
+		for i := range serial.Pop.Wealth {
+			if serial.Pop.Wealth[i] != parallel.Pop.Wealth[i] {
+				t.Fatalf("%v: agent %d has wealth %v serially, %v in parallel",
+					act, i, serial.Pop.Wealth[i], parallel.Pop.Wealth[i])
+			}
+		}
+	}
+}
+
+// TestPairingEngineRules checks the same under rules that draw coins for
+// each exchange as well as ones that don't. Run with -race, it also checks
+// that no two workers touch the same agent.
+func TestPairingEngineRules(t *testing.T) {
+	rules := []Rule{ConservingLeveler{Remainder: "random"}, ProportionalTransfer{Fraction: 0.1}, YardSale{Fraction: 0.1}, Bargain{}}
+	for _, act := range []ActivationOrder{uniform, random, poisson, inversePoisson} {
+		for _, rule := range rules {
+			serial := NewModel(act, rand.New(rand.NewSource(1)))
+			parallel := NewModel(act, rand.New(rand.NewSource(1)))
+			serial.Rule, parallel.Rule = rule, rule
+			serial.pairing = PairingEngine{Workers: 1}
+			parallel.pairing = PairingEngine{Workers: 4, Threshold: 1}
+			for turn := 0; turn < NumTurns; turn++ {
+				serial.Step()
+				parallel.Step()
+			}
+			for i := range serial.Pop.Wealth {
+				if serial.Pop.Wealth[i] != parallel.Pop.Wealth[i] {
+					t.Fatalf("%v, %T: agent %d has wealth %v serially, %v in parallel",
+						act, rule, i, serial.Pop.Wealth[i], parallel.Pop.Wealth[i])
+				}
+			}
+		}
+	}
+}
+
+// benchmarkPairingEngine times a Randmact-like turn of overlapping pairs over
+// a million agents, on the given number of workers.
+func benchmarkPairingEngine(b *testing.B, workers int) {
+	const n = 1000000
+	rng := rand.New(rand.NewSource(1))
+	flat := make([]int, n)
+	for i := range flat {
+		flat[i] = rng.Intn(n)
+	}
+	wealth := make([]float64, n)
+	e := PairingEngine{Workers: workers, Threshold: 1 << 10}
+	apply := func(a, b int) { Proc(&wealth[a], &wealth[b]) }
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		e.Run(flat, n, apply)
+	}
+}
+
+func BenchmarkPairingEngineSerial(b *testing.B)   { benchmarkPairingEngine(b, 1) }
+func BenchmarkPairingEngineParallel(b *testing.B) { benchmarkPairingEngine(b, 4) }
+
+// TestBatchExchangeMatchesPairs checks that leveling a uniform turn's pairs
+// as array operations gives exactly the per-pair result.
+func TestBatchExchangeMatchesPairs(t *testing.T) {
+	defer func(saved bool) { BatchExchange = saved }(BatchExchange)
+	for _, rule := range []Rule{Leveler{}, PartialLeveler{Fraction: 0.3}} {
+		var final [2]Population
+		for k, batch := range []bool{false, true} {
+			BatchExchange = batch
+			m := NewModel(uniform, rand.New(rand.NewSource(1)))
+			m.Rule = rule
+			for turn := 0; turn < NumTurns; turn++ {
+				m.Step()
+			}
+			final[k] = m.Pop
+		}
+		for i := range final[0].Wealth {
+			if final[0].Wealth[i] != final[1].Wealth[i] || final[0].Agents[i] != final[1].Agents[i] {
+				t.Fatalf("%T: agent %d has wealth %v per pair, %v batched",
+					rule, i, final[0].Wealth[i], final[1].Wealth[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,267 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/* Parameter files */
+
+/*
+ * Sweep designs can be read from the experiment files of other modeling
+ * tools:
+ *
+ * - NetLogo BehaviorSpace XML (as exported from the BehaviorSpace dialog or
+ *   saved with a model): each steppedValueSet and enumeratedValueSet is a
+ *   parameter, repetitions is runs per point and timeLimit is NumTurns.
+ *   setup, go, metrics and stop conditions are NetLogo code and are ignored,
+ *   as are subexperiments. A file with several experiments needs one picked
+ *   by name.
+ *
+ * - Repast Simphony batch parameter files: each number, list or constant
+ *   parameter is a parameter, nested ones varying faster than those they
+ *   are nested in, and runs is runs per point. randomSeed is ignored, since
+ *   a sweep uses one master seed throughout.
+ */
+
+// readParamFile reads the sweep design in the named file, which is in
+// either format; experiment picks a BehaviorSpace experiment.
+func readParamFile(name, experiment string) (*sweepDesign, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	d, err := parseParamFile(data, experiment)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return d, nil
+}
+
+// parseParamFile parses a sweep design in either format, telling them apart
+// by their root elements.
+func parseParamFile(data []byte, experiment string) (*sweepDesign, error) {
+	dec := newXMLDecoder(data)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil, fmt.Errorf("no experiment in the file")
+		} else if err != nil {
+			return nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "experiments", "experiment":
+			return parseBehaviorSpace(data, experiment)
+		case "sweep":
+			return parseRepast(data)
+		}
+		return nil, fmt.Errorf("<%s> is neither a BehaviorSpace experiment nor a Repast sweep", start.Name.Local)
+	}
+}
+
+// newXMLDecoder returns a decoder for data that also reads the us-ascii
+// NetLogo declares, which is UTF-8 as far as it goes.
+func newXMLDecoder(data []byte) *xml.Decoder {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.CharsetReader = func(charset string, r io.Reader) (io.Reader, error) {
+		if strings.EqualFold(charset, "us-ascii") || strings.EqualFold(charset, "ascii") {
+			return r, nil
+		}
+		return nil, fmt.Errorf("can't read %s; save the file as UTF-8", charset)
+	}
+	return dec
+}
+
+// A bsElement is any element of a BehaviorSpace experiment.
+type bsElement struct {
+	XMLName  xml.Name
+	Name     string      `xml:"name,attr"`
+	Reps     int         `xml:"repetitions,attr"`
+	Variable string      `xml:"variable,attr"`
+	First    string      `xml:"first,attr"`
+	Step     string      `xml:"step,attr"`
+	Last     string      `xml:"last,attr"`
+	Steps    int         `xml:"steps,attr"`
+	Values   []bsValue   `xml:"value"`
+	Children []bsElement `xml:",any"`
+}
+
+type bsValue struct {
+	Value string `xml:"value,attr"`
+}
+
+func parseBehaviorSpace(data []byte, experiment string) (*sweepDesign, error) {
+	var root bsElement
+	if err := newXMLDecoder(data).Decode(&root); err != nil {
+		return nil, err
+	}
+	candidates := []bsElement{root}
+	if root.XMLName.Local == "experiments" {
+		candidates = root.Children
+	}
+	var experiments []bsElement
+	var names []string
+	for _, e := range candidates {
+		if e.XMLName.Local == "experiment" {
+			experiments = append(experiments, e)
+			names = append(names, strconv.Quote(e.Name))
+		}
+	}
+	if experiment == "" {
+		if len(experiments) == 1 {
+			return behaviorSpaceDesign(experiments[0])
+		} else if len(experiments) == 0 {
+			return nil, fmt.Errorf("no experiments")
+		}
+		return nil, fmt.Errorf("pick one of experiments %s with -experiment", strings.Join(names, ", "))
+	}
+	for _, e := range experiments {
+		if e.Name == experiment {
+			return behaviorSpaceDesign(e)
+		}
+	}
+	return nil, fmt.Errorf("no experiment %q (have %s)", experiment, strings.Join(names, ", "))
+}
+
+// behaviorSpaceDesign returns the design of experiment e.
+func behaviorSpaceDesign(e bsElement) (*sweepDesign, error) {
+	d := &sweepDesign{Repetitions: e.Reps}
+	var add func(els []bsElement) error
+	add = func(els []bsElement) error {
+		for _, el := range els {
+			switch el.XMLName.Local {
+			case "timeLimit":
+				d.Params = append(d.Params, sweepParam{"NumTurns", []string{strconv.Itoa(el.Steps)}})
+			case "steppedValueSet":
+				values, err := steppedValues(el.First, el.Step, el.Last)
+				if err != nil {
+					return fmt.Errorf("%s: %v", el.Variable, err)
+				}
+				d.Params = append(d.Params, sweepParam{el.Variable, values})
+			case "enumeratedValueSet":
+				p := sweepParam{Name: el.Variable}
+				for _, v := range el.Values {
+					p.Values = append(p.Values, unquoteNetLogo(v.Value))
+				}
+				d.Params = append(d.Params, p)
+			case "constants": // NetLogo 6.4 groups the fixed sets
+				if err := add(el.Children); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := add(e.Children); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// unquoteNetLogo returns a NetLogo string literal's contents, or any other
+// value as it is.
+func unquoteNetLogo(v string) string {
+	if s, err := strconv.Unquote(v); err == nil && strings.HasPrefix(v, `"`) {
+		return s
+	}
+	return v
+}
+
+// steppedValues returns first, first+step, ..., up to last, written to as
+// many decimal places as first and step are.
+func steppedValues(first, step, last string) ([]string, error) {
+	f, err1 := strconv.ParseFloat(first, 64)
+	s, err2 := strconv.ParseFloat(step, 64)
+	l, err3 := strconv.ParseFloat(last, 64)
+	if err := firstError(err1, err2, err3); err != nil {
+		return nil, err
+	}
+	if s == 0 || (l-f)/s < 0 {
+		return nil, fmt.Errorf("step %s never gets from %s to %s", step, first, last)
+	}
+	places := decimalPlaces(first)
+	if p := decimalPlaces(step); p > places {
+		places = p
+	}
+	var values []string
+	n := int(math.Floor((l-f)/s + 1e-9))
+	for i := 0; i <= n; i++ {
+		values = append(values, strconv.FormatFloat(f+float64(i)*s, 'f', places, 64))
+	}
+	return values, nil
+}
+
+func decimalPlaces(number string) int {
+	if i := strings.IndexByte(number, '.'); i >= 0 {
+		return len(strings.TrimRight(number[i+1:], "0"))
+	}
+	return 0
+}
+
+// A repastParam is a parameter of a Repast sweep, with those nested in it.
+type repastParam struct {
+	Name     string        `xml:"name,attr"`
+	Type     string        `xml:"type,attr"`
+	Value    string        `xml:"value,attr"`
+	Start    string        `xml:"start,attr"`
+	End      string        `xml:"end,attr"`
+	Step     string        `xml:"step,attr"`
+	Values   string        `xml:"values,attr"`
+	Children []repastParam `xml:"parameter"`
+}
+
+type repastSweep struct {
+	Runs   int           `xml:"runs,attr"`
+	Params []repastParam `xml:"parameter"`
+}
+
+func parseRepast(data []byte) (*sweepDesign, error) {
+	var s repastSweep
+	if err := newXMLDecoder(data).Decode(&s); err != nil {
+		return nil, err
+	}
+	d := &sweepDesign{Repetitions: s.Runs}
+	var add func(ps []repastParam) error
+	add = func(ps []repastParam) error {
+		for _, p := range ps {
+			if p.Name != "randomSeed" {
+				var values []string
+				var err error
+				switch p.Type {
+				case "constant":
+					values = []string{p.Value}
+				case "number":
+					values, err = steppedValues(p.Start, p.Step, p.End)
+				case "list":
+					values = strings.Fields(p.Values)
+				default:
+					err = fmt.Errorf("unknown parameter type %q", p.Type)
+				}
+				if err != nil {
+					return fmt.Errorf("%s: %v", p.Name, err)
+				}
+				d.Params = append(d.Params, sweepParam{p.Name, values})
+			}
+			if err := add(p.Children); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := add(s.Params); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
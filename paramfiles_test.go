@@ -0,0 +1,75 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+const behaviorSpaceFile = `<?xml version="1.0" encoding="us-ascii"?>
+<!DOCTYPE experiments SYSTEM "behaviorspace.dtd">
+<experiments>
+  <experiment name="agents" repetitions="4" runMetricsEveryStep="true">
+    <setup>setup</setup>
+    <go>go</go>
+    <timeLimit steps="50"/>
+    <metric>gini</metric>
+    <steppedValueSet variable="num-agents" first="100" step="100" last="300"/>
+    <enumeratedValueSet variable="activation">
+      <value value="&quot;uniform&quot;"/>
+      <value value="&quot;poisson&quot;"/>
+    </enumeratedValueSet>
+  </experiment>
+  <experiment name="homophily" repetitions="2">
+    <steppedValueSet variable="homophily" first="0" step="0.1" last="0.3"/>
+  </experiment>
+</experiments>`
+
+func TestParseBehaviorSpace(t *testing.T) {
+	d, err := parseParamFile([]byte(behaviorSpaceFile), "agents")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &sweepDesign{Repetitions: 4, Params: []sweepParam{
+		{"NumTurns", []string{"50"}},
+		{"num-agents", []string{"100", "200", "300"}},
+		{"activation", []string{"uniform", "poisson"}},
+	}}
+	if !reflect.DeepEqual(d, want) {
+		t.Errorf("got %+v, want %+v", d, want)
+	}
+	d, err = parseParamFile([]byte(behaviorSpaceFile), "homophily")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := d.Params[0].Values; !reflect.DeepEqual(got, []string{"0.0", "0.1", "0.2", "0.3"}) {
+		t.Errorf("homophily values %v", got)
+	}
+	if _, err := parseParamFile([]byte(behaviorSpaceFile), ""); err == nil {
+		t.Error("no error without an experiment picked from two")
+	}
+}
+
+const repastFile = `<?xml version="1.0" encoding="UTF-8"?>
+<sweep runs="3">
+  <parameter name="randomSeed" type="constant" constant_type="number" value="1">
+    <parameter name="numAgents" type="number" start="10" end="30" step="10">
+      <parameter name="rule" type="list" value_type="string" values="leveler mirror"/>
+    </parameter>
+  </parameter>
+</sweep>`
+
+func TestParseRepast(t *testing.T) {
+	d, err := parseParamFile([]byte(repastFile), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &sweepDesign{Repetitions: 3, Params: []sweepParam{
+		{"numAgents", []string{"10", "20", "30"}},
+		{"rule", []string{"leveler", "mirror"}},
+	}}
+	if !reflect.DeepEqual(d, want) {
+		t.Errorf("got %+v, want %+v", d, want)
+	}
+}
@@ -0,0 +1,224 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/* Pareto frontiers */
+
+/*
+ * A sweep over policy parameters usually trades one outcome off against
+ * another -- a higher tax levels faster but takes more -- so there's no
+ * best point, only the points no other beats on every count.
+ * "pareto -objectives final_sd:min,taxrate:min [-o frontier.csv] sweep.csv"
+ * finds them: it averages each objective over the runs of every
+ * configuration (a point of the sweep and a regime), and writes a row per
+ * configuration, the parameters and regime, its runs, its mean objectives,
+ * and whether it's dominated:
+ *
+ *	taxrate,regime,runs,final_sd,dominated
+ *
+ * A configuration is dominated if another is at least as good on every
+ * objective and better on one; the rest are the Pareto frontier. Objectives
+ * are any numeric columns of the sweep, results or parameters alike, each
+ * to be minimized or maximized (":max").
+ */
+
+// An objective is a column of a sweep to minimize, or maximize.
+type objective struct {
+	name string
+	max  bool
+}
+
+// parseObjectives parses a comma-separated list of column[:min|:max].
+func parseObjectives(list string) ([]objective, error) {
+	var objs []objective
+	for _, field := range strings.Split(list, ",") {
+		parts := strings.SplitN(strings.TrimSpace(field), ":", 2)
+		o := objective{name: parts[0]}
+		if len(parts) == 2 && parts[1] == "max" {
+			o.max = true
+		} else if len(parts) == 2 && parts[1] != "min" {
+			return nil, fmt.Errorf("objective %q: %q isn't min or max", field, parts[1])
+		}
+		if o.name == "" {
+			return nil, fmt.Errorf("objective %q has no column", field)
+		}
+		objs = append(objs, o)
+	}
+	if len(objs) < 2 {
+		return nil, errors.New("a Pareto frontier takes at least two objectives")
+	}
+	return objs, nil
+}
+
+// A configuration is a point of a sweep and a regime, with its mean
+// objectives over its runs.
+type configuration struct {
+	key       []string // the parameters' values and the regime
+	runs      int
+	means     []float64
+	dominated bool
+}
+
+// dominates reports whether c is at least as good as d on every objective
+// and better on one.
+func (c *configuration) dominates(d *configuration, objs []objective) bool {
+	better := false
+	for k, o := range objs {
+		a, b := c.means[k], d.means[k]
+		if o.max {
+			a, b = -a, -b
+		}
+		if a > b {
+			return false
+		} else if a < b {
+			better = true
+		}
+	}
+	return better
+}
+
+// paretoFrontier reads a sweep's runs and returns the header of its
+// configurations' keys and the configurations, in the order they first
+// appear, with the dominated ones flagged.
+func paretoFrontier(r io.Reader, objs []objective) ([]string, []*configuration, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(rows) < 2 {
+		return nil, nil, errors.New("a sweep needs a header and at least one run")
+	}
+	header := rows[0]
+	regime, cols := -1, make([]int, len(objs))
+	for k := range cols {
+		cols[k] = -1
+	}
+	for i, name := range header {
+		if name == "regime" {
+			regime = i
+		}
+		for k, o := range objs {
+			if name == o.name {
+				cols[k] = i
+			}
+		}
+	}
+	if regime < 0 {
+		return nil, nil, errors.New(`sweep has no "regime" column`)
+	}
+	for k, col := range cols {
+		if col < 0 {
+			return nil, nil, fmt.Errorf("sweep has no column %q", objs[k].name)
+		}
+	}
+	byKey := make(map[string]*configuration)
+	var configs []*configuration
+	for i, row := range rows[1:] {
+		if len(row) != len(header) {
+			return nil, nil, fmt.Errorf("sweep line %d: %d fields, want %d", i+2, len(row), len(header))
+		}
+		key := row[:regime+1]
+		c, ok := byKey[strings.Join(key, "\x00")]
+		if !ok {
+			c = &configuration{key: key, means: make([]float64, len(objs))}
+			byKey[strings.Join(key, "\x00")] = c
+			configs = append(configs, c)
+		}
+		for k, col := range cols {
+			v, err := strconv.ParseFloat(row[col], 64)
+			if err != nil {
+				return nil, nil, fmt.Errorf("sweep line %d: %s: %v", i+2, objs[k].name, err)
+			}
+			c.means[k] += v
+		}
+		c.runs++
+	}
+	for _, c := range configs {
+		for k := range c.means {
+			c.means[k] /= float64(c.runs)
+		}
+	}
+	for _, c := range configs {
+		for _, d := range configs {
+			if d.dominates(c, objs) {
+				c.dominated = true
+				break
+			}
+		}
+	}
+	return header[:regime+1], configs, nil
+}
+
+// writeFrontier writes the configurations as CSV.
+func writeFrontier(w io.Writer, keys []string, objs []objective, configs []*configuration) error {
+	cw := csv.NewWriter(w)
+	header := append(append([]string(nil), keys...), "runs")
+	for _, o := range objs {
+		header = append(header, o.name)
+	}
+	cw.Write(append(header, "dominated"))
+	for _, c := range configs {
+		row := append(append([]string(nil), c.key...), strconv.Itoa(c.runs))
+		for _, v := range c.means {
+			row = append(row, strconv.FormatFloat(v, 'g', -1, 64))
+		}
+		cw.Write(append(row, strconv.FormatBool(c.dominated)))
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// runPareto is the pareto subcommand.
+func runPareto(args []string) error {
+	fs := flag.NewFlagSet("pareto", flag.ContinueOnError)
+	out := fs.String("o", "frontier.csv", "file to write the configurations to")
+	list := fs.String("objectives", "", "columns to optimize, e.g. final_sd:min,taxrate:min")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || *list == "" {
+		return errors.New("usage: pareto -objectives column[:min|:max],... [-o frontier.csv] sweep.csv")
+	}
+	objs, err := parseObjectives(*list)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	keys, configs, err := paretoFrontier(f, objs)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	if f, err = os.Create(*out); err != nil {
+		return err
+	}
+	err = writeFrontier(f, keys, objs, configs)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	frontier := 0
+	for _, c := range configs {
+		if !c.dominated {
+			frontier++
+		}
+	}
+	fmt.Printf("%d of %d configurations are on the Pareto frontier; written to %s\n", frontier, len(configs), *out)
+	return nil
+}
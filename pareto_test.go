@@ -0,0 +1,54 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParetoFrontier(t *testing.T) {
+	sweep := "taxrate,regime,run,gradient,final_sd\n" +
+		"0,uniform,1,-0.1,8\n" +
+		"0,uniform,2,-0.1,10\n" + // mean 9: the cheapest, so on the frontier
+		"0.1,uniform,1,-0.2,4\n" + // on the frontier
+		"0.2,uniform,1,-0.2,5\n" + // dominated by 0.1
+		"0.3,uniform,1,-0.4,1\n" // on the frontier
+	objs, err := parseObjectives("final_sd:min,taxrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, configs, err := paretoFrontier(strings.NewReader(sweep), objs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := writeFrontier(&buf, keys, objs, configs); err != nil {
+		t.Fatal(err)
+	}
+	want := "taxrate,regime,runs,final_sd,taxrate,dominated\n" +
+		"0,uniform,2,9,0,false\n" +
+		"0.1,uniform,1,4,0.1,false\n" +
+		"0.2,uniform,1,5,0.2,true\n" +
+		"0.3,uniform,1,1,0.3,false\n"
+	if buf.String() != want {
+		t.Errorf("frontier\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	objs, _ = parseObjectives("gradient:max,final_sd:max")
+	if _, configs, _ = paretoFrontier(strings.NewReader(sweep), objs); !configs[2].dominated || configs[0].dominated || !configs[3].dominated {
+		t.Errorf("maximizing, dominated: %v %v %v %v", configs[0].dominated, configs[1].dominated, configs[2].dominated, configs[3].dominated)
+	}
+}
+
+func TestParseObjectives(t *testing.T) {
+	for _, bad := range []string{"final_sd", "final_sd:min,gradient:most", "final_sd,:max"} {
+		if _, err := parseObjectives(bad); err == nil {
+			t.Errorf("parsed %q", bad)
+		}
+	}
+	if _, _, err := paretoFrontier(strings.NewReader("regime,run,gradient\nuniform,1,x\n"), []objective{{"gradient", false}, {"run", false}}); err == nil {
+		t.Error("read a gradient of x")
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"math/rand"
+	"sort"
+)
+
+/* Constrained partner selection */
+
+/*
+ * Homophily, the district hierarchy, network pairing, market classes,
+ * geography and mobility all narrow down who an activated agent may be
+ * paired with. Each pairing asks affinity() for a
+ * weight function over candidate partners (zero meaning not allowed); the
+ * schedulers then draw from the candidates in proportion to their weights.
+ * When nobody qualifies, homophily and the hierarchy fall back on the
+ * regime's usual partner, while the hard constraints (network pairing,
+ * markets and co-location) leave the agent unpaired.
+ *
+ * Drawing from the whole Population weighs every agent, so the random
+ * regime's constrained turns take time in the square of its size; under
+ * network pairing only the activated agent's neighbors are weighed.
+ */
+
+// constrained reports whether any partner restriction is configured.
+func (m *Model) constrained() bool {
+	return m.Homophily > 0 || m.Hierarchy != nil || m.Geography != nil || m.strict()
+}
+
+// strict reports whether some partner restriction must never be relaxed.
+func (m *Model) strict() bool {
+	return m.NetworkPairing || m.Market != nil || m.Mobility != nil
+}
+
+// affinity returns the weight of each candidate partner of alpha for this
+// pairing, or nil if anyone will do equally well.
+func (m *Model) affinity(alpha *Agent) func(b *Agent) float64 {
+	sameGroup := m.homophilous()
+	level := levelAll
+	if m.Hierarchy != nil {
+		level = m.Hierarchy.level(m.rng)
+	}
+	var edges map[int]float64
+	if m.NetworkPairing {
+		edges = make(map[int]float64)
+		ws := m.Net.Weights(alpha.node)
+		for k, j := range m.Net.Neighbors(alpha.node) {
+			edges[j] += ws[k]
+		}
+	}
+	if !sameGroup && level == levelAll && edges == nil && m.Market == nil &&
+		m.Geography == nil && m.Mobility == nil {
+		return nil
+	}
+	return func(b *Agent) float64 {
+		if m.Market != nil && b.class == alpha.class {
+			return 0
+		}
+		if m.Mobility != nil && b.place != alpha.place {
+			return 0
+		}
+		if sameGroup && m.group(b) != m.group(alpha) {
+			return 0
+		}
+		if !m.Hierarchy.within(level, alpha, b) {
+			return 0
+		}
+		w := 1.0
+		if edges != nil {
+			w = edges[b.node]
+		}
+		if m.Geography != nil && w > 0 {
+			w *= m.Geography.weight(alpha, b)
+		}
+		return w
+	}
+}
+
+// choose draws an index in proportion to weights, or returns -1 if they are
+// all zero.
+func choose(weights []float64, rng *rand.Rand) int {
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+	if total <= 0 {
+		return -1
+	}
+	u := rng.Float64() * total
+	for x, w := range weights {
+		u -= w
+		if u < 0 && w > 0 {
+			return x
+		}
+	}
+	for x := len(weights) - 1; ; x-- { // rounding
+		if weights[x] > 0 {
+			return x
+		}
+	}
+}
+
+// randomPartner picks a partner for agent alpha from the rest of the
+// Population. It returns -1 if alpha has nobody to pair with.
+func (m *Model) randomPartner(alpha int) int {
+	m.drawing("partner")
+	if weight := m.affinity(&m.Pop.Agents[alpha]); weight != nil {
+		if m.NetworkPairing { // only neighbors can have any weight
+			nbrs := m.neighbors(alpha)
+			weights := make([]float64, len(nbrs))
+			for x, b := range nbrs {
+				weights[x] = weight(&m.Pop.Agents[b])
+			}
+			if x := choose(weights, m.rng); x >= 0 {
+				return nbrs[x]
+			}
+			return -1
+		}
+		weights := make([]float64, m.Pop.Len())
+		for i := 0; i < m.Pop.Len(); i++ {
+			weights[i] = weight(&m.Pop.Agents[i])
+		}
+		weights[alpha] = 0 // alpha would always be its own likeliest partner
+		if x := choose(weights, m.rng); x >= 0 || m.strict() {
+			return x
+		}
+	}
+	if m.Pop.Len() < 2 {
+		return -1
+	}
+	beta := m.rng.Intn(m.Pop.Len() - 1) // anyone else
+	if beta >= alpha {
+		beta++
+	}
+	return beta
+}
+
+// neighbors returns agent alpha's network neighbors, each once, in order of
+// index: the candidates with any weight under NetworkPairing, in the order
+// a search of the whole Population would meet them.
+func (m *Model) neighbors(alpha int) []int {
+	nbrs := append([]int(nil), m.Net.Neighbors(m.Pop.Agents[alpha].node)...)
+	sort.Ints(nbrs)
+	k := 0
+	for x, b := range nbrs {
+		if x == 0 || b != nbrs[k-1] {
+			nbrs[k] = b
+			k++
+		}
+	}
+	return nbrs[:k]
+}
+
+// partnerIndex picks agent alpha's partner from the agents still waiting for
+// a turn, returning its position in turnList, or -1 if alpha has nobody to
+// pair with.
+func (m *Model) partnerIndex(alpha int, turnList []int) int {
+	m.drawing("partner")
+	if weight := m.affinity(&m.Pop.Agents[alpha]); weight != nil {
+		weights := make([]float64, len(turnList))
+		for x, a := range turnList {
+			weights[x] = weight(&m.Pop.Agents[a])
+		}
+		if x := choose(weights, m.rng); x >= 0 || m.strict() {
+			return x
+		}
+	}
+	return m.rng.Intn(len(turnList))
+}
+
+// pairEvents pairs a sorted event list like Poisact does, except that a
+// constrained pairing looks further down the list for a partner: each later
+// event is accepted with probability proportional to its weight.
+func (m *Model) pairEvents(aTimes events) {
+	pending := append(events(nil), aTimes...)
+	for len(pending) >= 2 {
+		alpha := pending[0]
+		x := 1
+		m.drawing("partner")
+		if weight := m.affinity(&m.Pop.Agents[int(alpha.agent)]); weight != nil {
+			x = m.nextEligible(pending, weight)
+		}
+		if x < 0 {
+			pending = pending[1:]
+			continue
+		}
+		beta := pending[x]
+		pending = append(pending[1:x], pending[x+1:]...)
+		m.exchange(int(alpha.agent), int(beta.agent))
+	}
+}
+
+// nextEligible scans pending (after its first event) for alpha's partner,
+// passing over alpha's own later events. It returns -1 if alpha has nobody
+// to pair with.
+func (m *Model) nextEligible(pending events, weight func(b *Agent) float64) int {
+	alpha := pending[0].agent
+	wmax := 0.0
+	for y := 1; y < len(pending); y++ {
+		if pending[y].agent == alpha {
+			continue
+		}
+		if w := weight(&m.Pop.Agents[int(pending[y].agent)]); w > wmax {
+			wmax = w
+		}
+	}
+	if wmax == 0 {
+		if m.strict() {
+			return -1
+		}
+		for y := 1; y < len(pending); y++ { // the next event but alpha's own
+			if pending[y].agent != alpha {
+				return y
+			}
+		}
+		return -1
+	}
+	for {
+		for y := 1; y < len(pending); y++ {
+			if pending[y].agent == alpha {
+				continue
+			}
+			w := weight(&m.Pop.Agents[int(pending[y].agent)])
+			if w >= wmax || (w > 0 && m.rng.Float64() < w/wmax) {
+				return y
+			}
+		}
+	}
+}
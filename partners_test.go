@@ -0,0 +1,137 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// TestNoSelfPartner checks that a constrained pairing never pairs an agent
+// with itself, though under a Geography it's the nearest candidate of all.
+func TestNoSelfPartner(t *testing.T) {
+	defer func(agents int) { NumOfAgents = agents }(NumOfAgents)
+	NumOfAgents = 20
+	m := NewModel(random, rand.New(rand.NewSource(4)))
+	places := make([]Site, NumOfAgents)
+	for i := range places {
+		places[i] = Site{X: float64(i), Y: 0}
+	}
+	PlaceAgents(m.Pop, places)
+	m.Geography = &Geography{Decay: ExponentialDecay(0.5)}
+	for k := 0; k < 1000; k++ {
+		alpha := k % NumOfAgents
+		if beta := m.randomPartner(alpha); beta == alpha {
+			t.Fatalf("agent %d paired with itself", alpha)
+		}
+	}
+	pending := events{{0.1, 3}, {0.2, 3}, {0.3, 7}}
+	if x := m.nextEligible(pending, m.affinity(&m.Pop.Agents[3])); x != 2 {
+		t.Errorf("agent 3's partner is event %d, want 2, not its own", x)
+	}
+}
+
+// TestNoSelfFallback checks that when a pairing that can be relaxed finds
+// nobody with any weight, the partner it falls back on is still someone
+// else.
+func TestNoSelfFallback(t *testing.T) {
+	defer func(agents int) { NumOfAgents = agents }(NumOfAgents)
+	NumOfAgents = 5
+	m := NewModel(random, rand.New(rand.NewSource(5)))
+	m.Homophily, m.Quantiles = 1, 0
+	for i := range m.Pop.Agents {
+		m.Pop.Agents[i].tag = i // everyone in a group of their own
+	}
+	for k := 0; k < 1000; k++ {
+		alpha := k % NumOfAgents
+		if beta := m.randomPartner(alpha); beta == alpha || beta < 0 {
+			t.Fatalf("agent %d fell back on %d", alpha, beta)
+		}
+	}
+	pending := events{{0.1, 3}, {0.2, 3}, {0.3, 2}}
+	if x := m.nextEligible(pending, m.affinity(&m.Pop.Agents[3])); x != 2 {
+		t.Errorf("agent 3 fell back on event %d, want 2, not its own", x)
+	}
+	if x := m.nextEligible(pending[:2], m.affinity(&m.Pop.Agents[3])); x != -1 {
+		t.Errorf("agent 3 fell back on event %d with nobody else left", x)
+	}
+}
+
+// TestDistanceDecay checks the decays DistanceDecay chooses between.
+func TestDistanceDecay(t *testing.T) {
+	defer func(decay string, scale, exponent float64) {
+		DistanceDecay, DecayScale, DecayExponent = decay, scale, exponent
+	}(DistanceDecay, DecayScale, DecayExponent)
+	DecayScale, DecayExponent = 10, 2
+	for _, c := range []struct {
+		decay string
+		want  float64 // at distance 9
+	}{{"exponential", math.Exp(-0.9)}, {"power", 0.01}} {
+		DistanceDecay = c.decay
+		decay, err := distanceDecay()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w := decay(9); math.Abs(w-c.want) > 1e-12 {
+			t.Errorf("%s decay at 9 is %v, want %v", c.decay, w, c.want)
+		}
+	}
+	DistanceDecay = "linear"
+	if err := checkGeography(); err == nil {
+		t.Error("unknown DistanceDecay accepted")
+	}
+}
+
+// TestPartnerConstraints checks that each hard or certain restriction only
+// ever pairs an agent with someone who meets it.
+func TestPartnerConstraints(t *testing.T) {
+	defer func(agents int) { NumOfAgents = agents }(NumOfAgents)
+	NumOfAgents = 40
+	for _, c := range []struct {
+		name  string
+		setup func(m *Model)
+		ok    func(m *Model, a, b *Agent) bool
+	}{
+		{"network", func(m *Model) {
+			m.Net = RingLattice(NumOfAgents, 2, m.rng)
+			m.NetworkPairing = true
+		}, func(m *Model, a, b *Agent) bool {
+			for _, j := range m.Net.Neighbors(a.node) {
+				if j == b.node {
+					return true
+				}
+			}
+			return false
+		}},
+		{"market", func(m *Model) {
+			m.Market = &Market{SellerShare: 0.5}
+			m.Market.Assign(m.Pop, m.rng)
+		}, func(m *Model, a, b *Agent) bool { return a.class != b.class }},
+		{"mobility", func(m *Model) {
+			m.Mobility = &Mobility{Places: RingLattice(5, 1, m.rng)}
+			m.Mobility.Scatter(m.Pop, m.rng)
+		}, func(m *Model, a, b *Agent) bool { return a.place == b.place }},
+		{"homophily", func(m *Model) {
+			m.Homophily, m.Quantiles = 1, 4
+			m.assignQuantiles()
+		}, func(m *Model, a, b *Agent) bool { return m.group(a) == m.group(b) }},
+		{"hierarchy", func(m *Model) {
+			m.Hierarchy = &Hierarchy{Districts: 4, DistrictsPerRegion: 2}
+			m.Hierarchy.Assign(m.Pop, m.rng)
+		}, func(m *Model, a, b *Agent) bool {
+			return m.Hierarchy.District(a) == m.Hierarchy.District(b)
+		}},
+	} {
+		m := NewModel(random, rand.New(rand.NewSource(6)))
+		c.setup(m)
+		for k := 0; k < 2000; k++ {
+			alpha := k % NumOfAgents
+			beta := m.randomPartner(alpha)
+			if beta < 0 {
+				continue
+			}
+			if beta == alpha || !c.ok(m, &m.Pop.Agents[alpha], &m.Pop.Agents[beta]) {
+				t.Fatalf("%s: agent %d paired with %d", c.name, alpha, beta)
+			}
+		}
+	}
+}
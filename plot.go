@@ -0,0 +1,706 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/GaryBoone/GoStats/stats"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+	"gonum.org/v1/plot/vg"
+	"image/color"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+/* Plots */
+
+/*
+ * With PlotsDir set (-plots dir), the experiment records every run's wealth
+ * SD and Gini before the first turn and after each, and once it is over
+ * writes them to trajectories.csv in that directory and draws them, as
+ * sd.png and gini.png (or .svg, with PlotFormat). Each figure has every run
+ * as a faint line in its regime's color, and each regime's mean over its
+ * runs in bold, within a band of one SD either side -- of log SD, for the
+ * wealth SD, which is drawn on a log scale. The SD figure also has each
+ * regime's regression line of log SD on time, dashed, averaged over its
+ * runs, so its slope is the gradient the experiment reports. "-plot png"
+ * (or svg) sets PlotFormat and, without -plots, draws into plots.
+ *
+ * Each run's Lorenz curve is also recorded at lorenzTurns -- the first,
+ * middle and last turns, or every turn with LorenzEveryTurn -- and written
+ * to lorenz.csv. The first, middle and last of those turns each get a
+ * figure of the regimes' mean Lorenz curves, lorenz_turn<N>.png, and
+ * gini_final.png is a bar chart of each regime's mean final Gini, with error
+ * bars of one SD over its runs. Gini and the Lorenz curves are estimated from
+ * a sample of at most plotSample agents. The runs' Bands (see bands.go) are
+ * written to bands.csv.
+ *
+ * "plot [-o dir] [-format svg] trajectories.csv" draws the figures again
+ * from a saved file, and from the lorenz.csv beside it if there is one,
+ * without rerunning anything.
+ */
+
+const (
+	plotSample   = 10000
+	lorenzPoints = 100
+)
+
+// sdFloor stands in for a wealth SD of 0 on the log scale, as in gradient.
+const sdFloor = 0.00000000001
+
+// A trajectory is one run's wealth SD and Gini, before the first turn and
+// after each, and its Lorenz curves at some turns.
+type trajectory struct {
+	SD, Gini []float64
+	Lorenz   map[int][]float64 // by turn
+}
+
+// lorenzTurns returns the turns whose Lorenz curves are recorded: every
+// turn with LorenzEveryTurn, or else the first, middle and last.
+func lorenzTurns() []int {
+	if !LorenzEveryTurn {
+		return []int{0, NumTurns / 2, NumTurns}
+	}
+	turns := make([]int, NumTurns+1)
+	for i := range turns {
+		turns[i] = i
+	}
+	return turns
+}
+
+// trajectories holds every run's trajectory, by regime.
+type trajectories struct {
+	regimes []string
+	runs    map[string][]trajectory
+	length  float64 // of a turn, in simulated time
+}
+
+func newTrajectories() *trajectories {
+	return &trajectories{runs: make(map[string][]trajectory), length: TurnLength}
+}
+
+// set records the SD and Gini of run ri of regime after the given turn.
+func (t *trajectories) set(regime string, ri, turn int, sd, g float64) {
+	runs, ok := t.runs[regime]
+	if !ok {
+		t.regimes = append(t.regimes, regime)
+	}
+	for len(runs) <= ri {
+		runs = append(runs, trajectory{})
+	}
+	tr := &runs[ri]
+	for len(tr.SD) <= turn {
+		tr.SD = append(tr.SD, math.NaN())
+		tr.Gini = append(tr.Gini, math.NaN())
+	}
+	tr.SD[turn], tr.Gini[turn] = sd, g
+	t.runs[regime] = runs
+}
+
+// setLorenz records the Lorenz curve of run ri of regime after the given
+// turn.
+func (t *trajectories) setLorenz(regime string, ri, turn int, curve []float64) {
+	runs, ok := t.runs[regime]
+	if !ok {
+		t.regimes = append(t.regimes, regime)
+	}
+	for len(runs) <= ri {
+		runs = append(runs, trajectory{})
+	}
+	if runs[ri].Lorenz == nil {
+		runs[ri].Lorenz = make(map[int][]float64)
+	}
+	runs[ri].Lorenz[turn] = curve
+	t.runs[regime] = runs
+}
+
+// lorenzTurns returns every turn any run has a Lorenz curve for, in order.
+func (t *trajectories) lorenzTurns() []int {
+	seen := make(map[int]bool)
+	var turns []int
+	for _, runs := range t.runs {
+		for _, tr := range runs {
+			for turn := range tr.Lorenz {
+				if !seen[turn] {
+					seen[turn] = true
+					turns = append(turns, turn)
+				}
+			}
+		}
+	}
+	sort.Ints(turns)
+	return turns
+}
+
+// writeTrajectories writes t as CSV, a row per regime, run and turn.
+func writeTrajectories(w io.Writer, t *trajectories) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"regime", "run", "turn", "sd", "gini", "time"})
+	for _, regime := range t.regimes {
+		for ri, tr := range t.runs[regime] {
+			for turn := range tr.SD {
+				cw.Write([]string{regime, strconv.Itoa(ri + 1), strconv.Itoa(turn),
+					strconv.FormatFloat(tr.SD[turn], 'g', -1, 64), strconv.FormatFloat(tr.Gini[turn], 'g', -1, 64),
+					strconv.FormatFloat(float64(turn)*t.length, 'g', -1, 64)})
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// readTrajectories reads what writeTrajectories wrote. Files without a
+// time column have turns of length 1.
+func readTrajectories(r io.Reader) (*trajectories, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("no trajectories")
+	}
+	t := newTrajectories()
+	t.length = 1
+	for i, row := range rows[1:] {
+		if len(row) != len(rows[0]) || len(row) < 5 || len(row) > 6 {
+			return nil, fmt.Errorf("trajectories line %d: %d fields, want 5 or 6", i+2, len(row))
+		}
+		run, err1 := strconv.Atoi(row[1])
+		turn, err2 := strconv.Atoi(row[2])
+		sd, err3 := strconv.ParseFloat(row[3], 64)
+		g, err4 := strconv.ParseFloat(row[4], 64)
+		if err := firstError(err1, err2, err3, err4); err != nil {
+			return nil, fmt.Errorf("trajectories line %d: %v", i+2, err)
+		} else if run < 1 || turn < 0 {
+			return nil, fmt.Errorf("trajectories line %d: no run %d, turn %d", i+2, run, turn)
+		}
+		if len(row) == 6 && turn > 0 {
+			at, err := strconv.ParseFloat(row[5], 64)
+			if err != nil || !(at > 0) {
+				return nil, fmt.Errorf("trajectories line %d: no time %q", i+2, row[5])
+			}
+			t.length = at / float64(turn)
+		}
+		t.set(row[0], run-1, turn, sd, g)
+	}
+	return t, nil
+}
+
+// writeLorenz writes the Lorenz curves of t as CSV, a row per regime, run,
+// turn and point of the curve.
+func writeLorenz(w io.Writer, t *trajectories) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"regime", "run", "turn", "population", "wealth"})
+	for _, regime := range t.regimes {
+		for ri, tr := range t.runs[regime] {
+			for _, turn := range t.lorenzTurns() {
+				curve := tr.Lorenz[turn]
+				for i, share := range curve {
+					cw.Write([]string{regime, strconv.Itoa(ri + 1), strconv.Itoa(turn),
+						strconv.FormatFloat(float64(i)/float64(len(curve)-1), 'g', -1, 64),
+						strconv.FormatFloat(share, 'g', -1, 64)})
+				}
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// readLorenz adds the Lorenz curves writeLorenz wrote to t.
+func readLorenz(r io.Reader, t *trajectories) error {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return err
+	}
+	for i, row := range rows[minInt(1, len(rows)):] {
+		if len(row) != 5 {
+			return fmt.Errorf("lorenz line %d: %d fields, want 5", i+2, len(row))
+		}
+		run, err1 := strconv.Atoi(row[1])
+		turn, err2 := strconv.Atoi(row[2])
+		share, err3 := strconv.ParseFloat(row[4], 64)
+		if err := firstError(err1, err2, err3); err != nil {
+			return fmt.Errorf("lorenz line %d: %v", i+2, err)
+		} else if run < 1 {
+			return fmt.Errorf("lorenz line %d: no run %d", i+2, run)
+		}
+		var curve []float64
+		if runs := t.runs[row[0]]; run <= len(runs) {
+			curve = runs[run-1].Lorenz[turn]
+		}
+		t.setLorenz(row[0], run-1, turn, append(curve, share))
+	}
+	return nil
+}
+
+// writeBands writes the Bands of each regime's wealth SD and Gini in t as
+// CSV, a row per regime, measure and turn.
+func writeBands(w io.Writer, t *trajectories) error {
+	cw := csv.NewWriter(w)
+	cw.Write(append([]string{"regime", "measure", "turn", "time", "runs", "mean", "se"}, bandColumns()...))
+	g := func(v float64) string { return strconv.FormatFloat(v, 'g', -1, 64) }
+	for _, regime := range t.regimes {
+		var sds, ginis [][]float64
+		for _, tr := range t.runs[regime] {
+			sds, ginis = append(sds, tr.SD), append(ginis, tr.Gini)
+		}
+		for _, measure := range []struct {
+			name string
+			runs [][]float64
+		}{{"sd", sds}, {"gini", ginis}} {
+			for _, b := range NewBands(measure.runs, t.length) {
+				row := []string{regime, measure.name, strconv.Itoa(b.Turn), g(b.Time), strconv.Itoa(b.Runs), g(b.Mean), g(b.SE)}
+				for _, q := range b.Quantiles {
+					row = append(row, g(q))
+				}
+				cw.Write(row)
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A trajectoryRecorder is an Observer that records every run's trajectory.
+type trajectoryRecorder struct {
+	mu      sync.Mutex
+	t       *trajectories
+	sampler *wealthSampler
+	lorenz  []int // the turns to record Lorenz curves at
+}
+
+// newTrajectoryRecorder returns a recorder for an experiment over acts.
+func newTrajectoryRecorder(acts []ActivationOrder) *trajectoryRecorder {
+	t := newTrajectories()
+	for _, act := range acts {
+		t.regimes = append(t.regimes, act.String())
+		t.runs[act.String()] = make([]trajectory, NumRuns)
+	}
+	return &trajectoryRecorder{t: t, sampler: newWealthSampler(plotSample), lorenz: lorenzTurns()}
+}
+
+func (r *trajectoryRecorder) Turn(act ActivationOrder, ri, turn int, sd float64, wealth []float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sorted, total := r.sampler.sorted(wealth)
+	r.t.set(act.String(), ri, turn, sd, gini(sorted, total))
+	for _, t := range r.lorenz {
+		if t == turn {
+			r.t.setLorenz(act.String(), ri, turn, lorenz(sorted, total, lorenzPoints))
+		}
+	}
+}
+
+func (r *trajectoryRecorder) Done(act ActivationOrder, ri int) {}
+
+// save writes the trajectories to dir and draws them there.
+func (r *trajectoryRecorder) save(dir, format string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(filepath.Join(dir, "trajectories.csv"))
+	if err != nil {
+		return err
+	}
+	if err := writeTrajectories(f, r.t); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if f, err = os.Create(filepath.Join(dir, "lorenz.csv")); err != nil {
+		return err
+	}
+	if err := writeLorenz(f, r.t); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if f, err = os.Create(filepath.Join(dir, "bands.csv")); err != nil {
+		return err
+	}
+	if err := writeBands(f, r.t); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return drawTrajectories(r.t, dir, format)
+}
+
+// checkPlotFormat reports whether plots can be drawn in format.
+func checkPlotFormat(format string) error {
+	if format != "png" && format != "svg" {
+		return fmt.Errorf("unknown plot format %q (want png or svg)", format)
+	}
+	return nil
+}
+
+// drawTrajectories draws the SD and Gini figures of t in dir.
+func drawTrajectories(t *trajectories, dir, format string) error {
+	if err := checkPlotFormat(format); err != nil {
+		return err
+	}
+	figures := []struct {
+		name, label string
+		logScale    bool
+		series      func(trajectory) []float64
+	}{
+		{"sd", "Wealth SD", true, func(tr trajectory) []float64 { return tr.SD }},
+		{"gini", "Gini", false, func(tr trajectory) []float64 { return tr.Gini }},
+	}
+	for _, fig := range figures {
+		p := plot.New()
+		p.Title.Text = fig.label + " by turn"
+		p.X.Label.Text = timeLabel(t.length)
+		p.Y.Label.Text = fig.label
+		if fig.logScale {
+			p.Y.Scale = plot.LogScale{}
+			p.Y.Tick.Marker = plot.LogTicks{Prec: -1}
+		}
+		for i, regime := range t.regimes {
+			c := plotutil.Color(i)
+			var runs [][]float64
+			for _, tr := range t.runs[regime] {
+				runs = append(runs, fig.series(tr))
+			}
+			for _, run := range runs {
+				line, err := plotter.NewLine(turnXYs(run, t.length, fig.logScale))
+				if err != nil {
+					return err
+				}
+				line.LineStyle.Color = fade(c, 0x40)
+				line.LineStyle.Width = vg.Points(0.5)
+				p.Add(line)
+			}
+			mean, lo, hi := meanBand(runs, fig.logScale)
+			band, err := plotter.NewPolygon(append(turnXYs(lo, t.length, fig.logScale), reverseXYs(turnXYs(hi, t.length, fig.logScale))...))
+			if err != nil {
+				return err
+			}
+			band.Color = fade(c, 0x30)
+			band.LineStyle.Width = 0
+			line, err := plotter.NewLine(turnXYs(mean, t.length, fig.logScale))
+			if err != nil {
+				return err
+			}
+			line.LineStyle.Color = c
+			line.LineStyle.Width = vg.Points(2)
+			p.Add(band, line)
+			p.Legend.Add(regime, line)
+			if fig.logScale {
+				xys, slope, ok := gradientFit(runs, t.length)
+				if !ok {
+					continue
+				}
+				fit, err := plotter.NewLine(xys)
+				if err != nil {
+					return err
+				}
+				fit.LineStyle.Color = c
+				fit.LineStyle.Width = vg.Points(1)
+				fit.LineStyle.Dashes = []vg.Length{vg.Points(6), vg.Points(3)}
+				p.Add(fit)
+				p.Legend.Add(fmt.Sprintf("%s fit, gradient %.4g", regime, slope), fit)
+			}
+		}
+		if fig.logScale {
+			logRange(p)
+		}
+		if err := p.Save(8*vg.Inch, 5*vg.Inch, filepath.Join(dir, fig.name+"."+format)); err != nil {
+			return err
+		}
+	}
+	for _, turn := range figureTurns(t.lorenzTurns()) {
+		if err := drawLorenz(t, turn, filepath.Join(dir, fmt.Sprintf("lorenz_turn%d.%s", turn, format))); err != nil {
+			return err
+		}
+	}
+	return drawFinalGini(t, filepath.Join(dir, "gini_final."+format))
+}
+
+// figureTurns returns the turns of the Lorenz figures, the first, middle and
+// last of turns, so recording every turn's curves doesn't draw them all.
+func figureTurns(turns []int) []int {
+	if len(turns) <= 3 {
+		return turns
+	}
+	return []int{turns[0], turns[(len(turns)-1)/2], turns[len(turns)-1]}
+}
+
+// drawLorenz draws each regime's mean Lorenz curve after the given turn, over
+// its runs, to file.
+func drawLorenz(t *trajectories, turn int, file string) error {
+	p := plot.New()
+	p.Title.Text = fmt.Sprintf("Lorenz curves after turn %d", turn)
+	p.X.Label.Text = "Share of agents, poorest first"
+	p.Y.Label.Text = "Share of wealth"
+	p.Legend.Top, p.Legend.Left = true, true
+	equality, err := plotter.NewLine(plotter.XYs{{X: 0, Y: 0}, {X: 1, Y: 1}})
+	if err != nil {
+		return err
+	}
+	equality.LineStyle.Color = color.Gray{0x99}
+	equality.LineStyle.Dashes = []vg.Length{vg.Points(4), vg.Points(4)}
+	p.Add(equality)
+	for i, regime := range t.regimes {
+		var mean []float64
+		n := 0
+		for _, tr := range t.runs[regime] {
+			curve, ok := tr.Lorenz[turn]
+			if !ok {
+				continue
+			}
+			for len(mean) < len(curve) {
+				mean = append(mean, 0)
+			}
+			for k, share := range curve {
+				mean[k] += share
+			}
+			n++
+		}
+		if n == 0 {
+			continue
+		}
+		xys := make(plotter.XYs, len(mean))
+		for k := range mean {
+			xys[k] = plotter.XY{X: float64(k) / float64(len(mean)-1), Y: mean[k] / float64(n)}
+		}
+		line, err := plotter.NewLine(xys)
+		if err != nil {
+			return err
+		}
+		line.LineStyle.Color = plotutil.Color(i)
+		line.LineStyle.Width = vg.Points(1.5)
+		p.Add(line)
+		p.Legend.Add(regime, line)
+	}
+	return p.Save(6*vg.Inch, 6*vg.Inch, file)
+}
+
+// errorBars are the points and extents of a plotter.YErrorBars.
+type errorBars struct {
+	plotter.XYs
+	plotter.YErrors
+}
+
+// drawFinalGini draws a bar chart of each regime's mean Gini after the last
+// turn, with error bars of one SD over its runs, to file.
+func drawFinalGini(t *trajectories, file string) error {
+	p := plot.New()
+	p.Title.Text = "Final Gini"
+	p.Y.Label.Text = "Gini"
+	var bars errorBars
+	for i, regime := range t.regimes {
+		var s stats.Stats
+		for _, tr := range t.runs[regime] {
+			if n := len(tr.Gini); n > 0 && !math.IsNaN(tr.Gini[n-1]) {
+				s.Update(tr.Gini[n-1])
+			}
+		}
+		mean, sd := 0.0, 0.0
+		if s.Count() > 0 {
+			mean = s.Mean()
+		}
+		if s.Count() > 1 {
+			sd = s.SampleStandardDeviation()
+		}
+		bar, err := plotter.NewBarChart(plotter.Values{mean}, vg.Points(30))
+		if err != nil {
+			return err
+		}
+		bar.XMin = float64(i)
+		bar.Color = plotutil.Color(i)
+		bar.LineStyle.Width = 0
+		p.Add(bar)
+		bars.XYs = append(bars.XYs, plotter.XY{X: float64(i), Y: mean})
+		bars.YErrors = append(bars.YErrors, struct{ Low, High float64 }{sd, sd})
+	}
+	errs, err := plotter.NewYErrorBars(bars)
+	if err != nil {
+		return err
+	}
+	p.Add(errs)
+	p.NominalX(t.regimes...)
+	return p.Save(8*vg.Inch, 5*vg.Inch, file)
+}
+
+// meanBand returns, for each turn, the mean of runs and that mean less and
+// plus one SD, skipping turns a run lacks. With logScale, it is the mean and
+// SD of the logs, with values of 0 taken as sdFloor, and the results are
+// exponentiated back.
+func meanBand(runs [][]float64, logScale bool) (mean, lo, hi []float64) {
+	for turn := 0; ; turn++ {
+		var s stats.Stats
+		for _, run := range runs {
+			if turn < len(run) && !math.IsNaN(run[turn]) {
+				v := run[turn]
+				if logScale {
+					v = math.Log(math.Max(v, sdFloor))
+				}
+				s.Update(v)
+			}
+		}
+		if s.Count() == 0 {
+			return mean, lo, hi
+		}
+		m, sd := s.Mean(), 0.0
+		if s.Count() > 1 {
+			sd = s.SampleStandardDeviation()
+		}
+		if logScale {
+			mean, lo, hi = append(mean, math.Exp(m)), append(lo, math.Exp(m-sd)), append(hi, math.Exp(m+sd))
+		} else {
+			mean, lo, hi = append(mean, m), append(lo, m-sd), append(hi, m+sd)
+		}
+	}
+}
+
+// logRange widens the y axis of a log-scale plot whose values are all the
+// same, which plot would otherwise pad below zero.
+func logRange(p *plot.Plot) {
+	if p.Y.Min == p.Y.Max {
+		p.Y.Min, p.Y.Max = p.Y.Min/10, p.Y.Max*10
+	}
+}
+
+// turnXYs pairs each value with its turn's time, for turns of the given
+// length, dropping missing ones; with logScale, values of 0 are taken as
+// sdFloor.
+func turnXYs(values []float64, length float64, logScale bool) plotter.XYs {
+	xys := make(plotter.XYs, 0, len(values))
+	for turn, v := range values {
+		if math.IsNaN(v) {
+			continue
+		}
+		if logScale {
+			v = math.Max(v, sdFloor)
+		}
+		xys = append(xys, plotter.XY{X: float64(turn) * length, Y: v})
+	}
+	return xys
+}
+
+// gradientFit returns the line of the mean regression over runs of log SD
+// on time, whose slope, returned too, is the gradient reported for them: it
+// fits the turns the results keep, all but the last. ok is false if there
+// aren't turns enough to fit.
+func gradientFit(runs [][]float64, length float64) (xys plotter.XYs, slope float64, ok bool) {
+	var intercept float64
+	turns := 0
+	for _, run := range runs {
+		if len(run) < 3 {
+			return nil, 0, false
+		}
+		a, b := fitOver(run[:len(run)-1], length)
+		intercept, slope = intercept+a/float64(len(runs)), slope+b/float64(len(runs))
+		if len(run)-1 > turns {
+			turns = len(run) - 1
+		}
+	}
+	if len(runs) == 0 {
+		return nil, 0, false
+	}
+	xys = make(plotter.XYs, turns)
+	for turn := range xys {
+		x := float64(turn) * length
+		xys[turn] = plotter.XY{X: x, Y: math.Max(math.Exp(intercept+slope*x), sdFloor)}
+	}
+	return xys, slope, true
+}
+
+func reverseXYs(xys plotter.XYs) plotter.XYs {
+	for i, j := 0, len(xys)-1; i < j; i, j = i+1, j-1 {
+		xys[i], xys[j] = xys[j], xys[i]
+	}
+	return xys
+}
+
+// fade returns c with the given alpha.
+func fade(c color.Color, alpha uint8) color.Color {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	n.A = alpha
+	return n
+}
+
+// runPlot draws the figures of a saved trajectories.csv, or the heatmap of
+// a sweep, or both.
+func runPlot(args []string) error {
+	fs := flag.NewFlagSet("plot", flag.ContinueOnError)
+	out := fs.String("o", ".", "directory to write the figures to")
+	format := fs.String("format", PlotFormat, `"png" or "svg"`)
+	sweep := fs.String("sweep", "", "results of a two-parameter sweep to draw as a heatmap")
+	z := fs.String("z", "gradient", "the sweep's result to draw")
+	contours := fs.Int("contours", 0, "contour lines to draw over the heatmap")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 || (fs.NArg() == 0 && *sweep == "") {
+		return errors.New("usage: plot [-o dir] [-format png|svg] [-sweep sweep.csv [-z gradient] [-contours n]] [trajectories.csv]")
+	}
+	if err := checkPlotFormat(*format); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		return err
+	}
+	if fs.NArg() == 1 {
+		if err := plotTrajectoriesFile(fs.Arg(0), *out, *format); err != nil {
+			return err
+		}
+	}
+	if *sweep != "" {
+		return plotSweepFile(*sweep, *z, *contours, *out, *format)
+	}
+	return nil
+}
+
+// plotTrajectoriesFile draws the figures of the trajectories in name, and
+// of the lorenz.csv beside it if there is one, in dir.
+func plotTrajectoriesFile(name, dir, format string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	t, err := readTrajectories(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	if f, err := os.Open(filepath.Join(filepath.Dir(name), "lorenz.csv")); err == nil {
+		err = readLorenz(f, t)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	return drawTrajectories(t, dir, format)
+}
@@ -0,0 +1,127 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testTrajectories() *trajectories {
+	t := newTrajectories()
+	for ri := 0; ri < 3; ri++ {
+		for turn := 0; turn <= 4; turn++ {
+			t.set("uniform", ri, turn, 10/float64(turn+ri+1), 0.5/float64(turn+1))
+			t.set("random", ri, turn, 0, 0.1)
+		}
+		t.setLorenz("uniform", ri, 4, []float64{0, 0.2, 1})
+		t.setLorenz("random", ri, 0, []float64{0, 0.5, 1})
+	}
+	return t
+}
+
+func TestTrajectoriesRoundTrip(t *testing.T) {
+	want := testTrajectories()
+	var buf bytes.Buffer
+	if err := writeTrajectories(&buf, want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readTrajectories(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := writeLorenz(&buf, want); err != nil {
+		t.Fatal(err)
+	}
+	if err := readLorenz(&buf, got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("read back %+v, want %+v", got, want)
+	}
+}
+
+func TestMeanBand(t *testing.T) {
+	mean, lo, hi := meanBand([][]float64{{1, 2}, {3, 4, 5}}, false)
+	if !reflect.DeepEqual(mean, []float64{2, 3, 5}) || lo[2] != 5 || hi[2] != 5 {
+		t.Errorf("mean %v, lo %v, hi %v", mean, lo, hi)
+	}
+	if math.Abs(hi[0]-2-math.Sqrt2) > 1e-12 || math.Abs(lo[0]-2+math.Sqrt2) > 1e-12 {
+		t.Errorf("band at turn 0 is %v to %v, want 2 ± √2", lo[0], hi[0])
+	}
+	mean, _, _ = meanBand([][]float64{{0, 10}, {0, 1000}}, true)
+	if math.Abs(mean[0]-sdFloor) > 1e-20 || math.Abs(mean[1]-100) > 1e-9 {
+		t.Errorf("log mean %v, want [%g 100]", mean, sdFloor)
+	}
+}
+
+func TestDrawTrajectories(t *testing.T) {
+	dir := t.TempDir()
+	if err := drawTrajectories(testTrajectories(), dir, "svg"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"sd.svg", "gini.svg", "lorenz_turn0.svg", "lorenz_turn4.svg", "gini_final.svg"} {
+		if fi, err := os.Stat(filepath.Join(dir, name)); err != nil || fi.Size() == 0 {
+			t.Errorf("%s wasn't drawn: %v", name, err)
+		}
+	}
+	if err := drawTrajectories(testTrajectories(), dir, "gif"); err == nil {
+		t.Error("drew a gif")
+	}
+}
+
+func TestGradientFit(t *testing.T) {
+	runs := [][]float64{{1, math.Exp(-1), math.Exp(-2), 5}, {math.E, 1, math.Exp(-1), 5}}
+	xys, slope, ok := gradientFit(runs, 0.5)
+	if !ok || math.Abs(slope+2) > 1e-12 || slope != gradientOver(runs[0][:3], 0.5) {
+		t.Fatalf("slope %v, %v; want -2, the runs' gradient", slope, ok)
+	}
+	want := []float64{math.Exp(0.5), math.Exp(-0.5), math.Exp(-1.5)}
+	if len(xys) != 3 {
+		t.Fatalf("%d points, want 3", len(xys))
+	}
+	for i, xy := range xys {
+		if xy.X != float64(i)*0.5 || math.Abs(xy.Y-want[i]) > 1e-12 {
+			t.Errorf("point %d is %v, want (%v, %v)", i, xy, float64(i)*0.5, want[i])
+		}
+	}
+	if _, _, ok := gradientFit([][]float64{{1, 2}}, 1); ok {
+		t.Error("fit a single turn")
+	}
+}
+
+func TestLorenzEveryTurn(t *testing.T) {
+	defer func(turns int, every bool) { NumTurns, LorenzEveryTurn = turns, every }(NumTurns, LorenzEveryTurn)
+	NumTurns = 6
+	if got := lorenzTurns(); !reflect.DeepEqual(got, []int{0, 3, 6}) {
+		t.Errorf("recorded turns %v, want [0 3 6]", got)
+	}
+	LorenzEveryTurn = true
+	turns := lorenzTurns()
+	if !reflect.DeepEqual(turns, []int{0, 1, 2, 3, 4, 5, 6}) {
+		t.Errorf("recorded turns %v, want all of 0 to 6", turns)
+	}
+	if got := figureTurns(turns); !reflect.DeepEqual(got, []int{0, 3, 6}) {
+		t.Errorf("drew turns %v, want [0 3 6]", got)
+	}
+}
+
+func TestWriteBands(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeBands(&buf, testTrajectories()); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1+2*2*5 || lines[0] != "regime,measure,turn,time,runs,mean,se,q05,q25,q50,q75,q95" {
+		t.Fatalf("bands:\n%s", buf.String())
+	}
+	if want := "random,gini,4,4,3,0.1,0,0.1,0.1,0.1,0.1,0.1"; lines[len(lines)-1] != want {
+		t.Errorf("last band %q, want %q", lines[len(lines)-1], want)
+	}
+}
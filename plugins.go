@@ -0,0 +1,68 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"fmt"
+	"plugin"
+)
+
+/* Plugins */
+
+/*
+ * Each of the Plugins is a Go plugin (go build -buildmode=plugin) opened at
+ * startup. A plugin can't import this package, which is main, so instead of
+ * registering Rules and Metrics it exports plain functions, in either or
+ * both of
+ *
+ *	var Rules = map[string]func(a, b *float64){...}
+ *	var Metrics = map[string]func(sorted []float64) float64{...}
+ *
+ * which are registered under their keys -- a rule as with RegisterRule, a
+ * metric as a one-column Metric of the ascending wealth. Activation regimes
+ * need the Model, so they can only be compiled in. Plugins need cgo and a
+ * plugin built with the same toolchain and dependencies as the model.
+ */
+
+// loadPlugins opens every plugin at paths and registers what it exports.
+func loadPlugins(paths []string) error {
+	for _, path := range paths {
+		p, err := plugin.Open(path)
+		if err != nil {
+			return err
+		}
+		found := false
+		if sym, err := p.Lookup("Rules"); err == nil {
+			rules, ok := sym.(*map[string]func(a, b *float64))
+			if !ok {
+				return fmt.Errorf("plugin %s: Rules is %T, not map[string]func(a, b *float64)", path, sym)
+			}
+			for name, f := range *rules {
+				rule := funcRule(f)
+				RegisterRule(name, func() Rule { return rule })
+			}
+			found = true
+		}
+		if sym, err := p.Lookup("Metrics"); err == nil {
+			metrics, ok := sym.(*map[string]func(sorted []float64) float64)
+			if !ok {
+				return fmt.Errorf("plugin %s: Metrics is %T, not map[string]func(sorted []float64) float64", path, sym)
+			}
+			for name, f := range *metrics {
+				RegisterMetric(pluginMetric(name, f))
+			}
+			found = true
+		}
+		if !found {
+			return fmt.Errorf("plugin %s exports neither Rules nor Metrics", path)
+		}
+	}
+	return nil
+}
+
+// pluginMetric returns the Metric for a plugin's function of sorted wealth.
+func pluginMetric(name string, f func(sorted []float64) float64) Metric {
+	return Metric{name, []string{name}, true, false, func(s *snapshot) []float64 {
+		return []float64{f(s.sorted)}
+	}}
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/gonum/matrix/mat64"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+/* Parallel experiment */
+
+/*
+ * Every (regime, run) cell of the experiment is independent: it builds its
+ * own Model and draws from its own RNG. RunExperiment hands the cells to a
+ * pool of executors -- Workers goroutines, or the RemoteWorkers -- and files
+ * each result under its regime and run, so the matrices come out the same
+ * whatever order the cells finish in.
+ */
+
+type cell struct {
+	act, run int
+}
+
+type cellResult struct {
+	act, run int
+	sds      []float64
+	times    PhaseTimes
+}
+
+// RunExperiment runs NumRuns runs of each regime, handing each run's wealth
+// SDs to collect as it completes.
+func RunExperiment(acts []ActivationOrder, seed int64, collect func(cellResult)) error {
+	if len(RemoteWorkers) > 0 {
+		return runCells(acts, remoteExecutors(acts, seed), collect)
+	}
+	return runCells(acts, localExecutors(func(c cell) *Model {
+		return experimentModel(acts, seed, c)
+	}), collect)
+}
+
+// resultMatrices returns, per regime, a matrix of wealth SDs with a row per
+// run, and the collect function that fills them in.
+func resultMatrices(acts []ActivationOrder) ([]*mat64.Dense, func(cellResult)) {
+	totalResults := make([]*mat64.Dense, len(acts)) // approximating a 3D matrix with a slice of 2D matrices
+	for a := 0; a < len(acts); a++ {
+		totalResults[a] = mat64.NewDense(NumRuns, NumTurns, nil)
+	}
+	return totalResults, func(res cellResult) {
+		totalResults[res.act].SetRow(res.run, res.sds)
+	}
+}
+
+// experimentModel builds the Model for cell c of RunExperiment.
+func experimentModel(acts []ActivationOrder, seed int64, c cell) *Model {
+	s := cellSeed(seed, c.act, c.run)
+	if AuditDraws {
+		rng, audit := auditedRand(s, acts[c.act], c.run)
+		m := NewModel(acts[c.act], rng)
+		m.audit = audit
+		m.statsRng = newRand(streamSeed(s, purposeStats))
+		return m
+	}
+	m := NewModel(acts[c.act], newRand(s))
+	m.statsRng = newRand(streamSeed(s, purposeStats))
+	return m
+}
+
+// Compare is RunExperiment for a controlled comparison: within each run,
+// every regime starts from a clone of the same initial Model (population,
+// network, districts and so on) and draws from an identically seeded RNG,
+// so the regime is the only thing that differs between them.
+func Compare(acts []ActivationOrder, seed int64, collect func(cellResult)) error {
+	if len(RemoteWorkers) > 0 {
+		return runCells(acts, remoteExecutors(acts, seed), collect)
+	}
+	initial := make([]*Model, NumRuns)
+	for r := 0; r < NumRuns; r++ {
+		initial[r] = initialModel(acts, seed, r)
+	}
+	return runCells(acts, localExecutors(func(c cell) *Model {
+		return compareModel(initial[c.run], acts, seed, c)
+	}), collect)
+}
+
+// initialModel builds the Model every regime of run r of Compare starts from.
+func initialModel(acts []ActivationOrder, seed int64, r int) *Model {
+	needNet := false
+	for _, act := range acts {
+		needNet = needNet || act == localPoisson
+	}
+	rng := newRand(cellSeed(seed, -1, r))
+	m := NewModel(acts[0], rng)
+	if needNet && m.Net == nil {
+		m.SetNetwork(RingLattice(m.Pop.Len(), NeighborhoodRadius, rng))
+	}
+	return m
+}
+
+// compareModel builds the Model for cell c of Compare from its run's initial Model.
+func compareModel(initial *Model, acts []ActivationOrder, seed int64, c cell) *Model {
+	m := initial.Clone()
+	m.Activation = acts[c.act]
+	s := cellSeed(seed, len(acts), c.run)
+	m.rng = newRand(s)
+	if AuditDraws {
+		m.rng, m.audit = auditedRand(s, acts[c.act], c.run)
+	}
+	m.statsRng = newRand(streamSeed(s, purposeStats))
+	return m
+}
+
+// An Observer follows the runs of an experiment as they go. runCell calls
+// every one of the observers, from whichever goroutine is running the cell,
+// with the wealth before the first turn and after each, and when the run is
+// over. wealth is only valid for the duration of the call.
+type Observer interface {
+	Turn(act ActivationOrder, ri, turn int, sd float64, wealth []float64)
+	Done(act ActivationOrder, ri int)
+}
+
+var observers []Observer
+
+// cellOutput is where cells run in this process report.
+var cellOutput io.Writer = os.Stdout
+
+// An executor simulates one cell, locally or elsewhere.
+type executor func(c cell) (cellResult, error)
+
+// localExecutors returns Workers executors that run cells in this process,
+// building each cell's Model with newModel.
+func localExecutors(newModel func(c cell) *Model) []executor {
+	workers := Workers
+	if LargeScale { // one Model at a time; its turns use the Workers instead
+		workers = 1
+	}
+	execs := make([]executor, workers)
+	for w := range execs {
+		execs[w] = func(c cell) (cellResult, error) {
+			m := newModel(c)
+			sds := runCell(m, c.run, cellOutput)
+			return cellResult{c.act, c.run, sds, m.Times}, nil
+		}
+	}
+	return execs
+}
+
+// runCells simulates every cell, each executor taking cells from a shared
+// queue in its own goroutine, and passes the results to collect one at a
+// time. An executor that fails puts its cell back for the others and
+// retires; the experiment only fails if they all do.
+func runCells(acts []ActivationOrder, execs []executor, collect func(cellResult)) error {
+	total := len(acts) * NumRuns
+	jobs := make(chan cell, total) // room for every cell, so requeueing never blocks
+	for a := 0; a < len(acts); a++ {
+		for r := 0; r < NumRuns; r++ {
+			jobs <- cell{a, r}
+		}
+	}
+	results := make(chan cellResult)
+	failures := make(chan error)
+	for _, exec := range execs {
+		go func(exec executor) {
+			for c := range jobs {
+				res, err := exec(c)
+				if err != nil {
+					jobs <- c
+					failures <- err
+					return
+				}
+				results <- res
+			}
+		}(exec)
+	}
+
+	times := make([]PhaseTimes, len(acts))
+	live := len(execs)
+	for done := 0; done < total; {
+		select {
+		case res := <-results:
+			collect(res)
+			times[res.act].Add(res.times)
+			done++
+		case err := <-failures:
+			live--
+			if live == 0 {
+				return err
+			}
+			log.Print(err)
+		}
+	}
+	close(jobs)
+	if ReportTimings {
+		fmt.Printf("Time spent per phase, over all %d runs:\n", NumRuns)
+		for a := 0; a < len(acts); a++ {
+			fmt.Printf("%-15s\t%v\n", acts[a], times[a])
+		}
+	}
+	return nil
+}
+
+// runCell performs one run, returning the wealth SD before the first turn
+// and after every turn. Anything it reports to w beyond the start of the run
+// is written in one piece at the end, so workers' output doesn't interleave.
+func runCell(m *Model, ri int, w io.Writer) []float64 {
+	act := m.Activation
+	start := time.Now()
+	fmt.Fprintf(w, "Starting run %d with %d turns, %s activation. Time is now %v, Num Agents = %d\n",
+		ri+1, NumTurns, act, time.Now(), m.Pop.Len())
+	var out bytes.Buffer
+
+	if m.Net != nil && !LargeScale { // path lengths cost a BFS per source
+		fmt.Fprintf(&out, "Network (%s run %d): %v\n", act, ri+1, m.Net.Stats(NetworkStatsSources, m.statsStream()))
+	}
+	metrics := newMetricRecorder(m.statsStream(), m.Hierarchy)
+	tracker := newAgentTracker(m.Pop)
+	history := newActivationHistory(m.Pop)
+	trace := newReplicationTrace(m)
+	cohorts := newCohortRecorder()
+	audit := newPrecisionAudit(m.Pop)
+	watch := newMemoryWatch()
+	m.mark()
+	_, sdw := Asdw(m.Pop)
+	if metrics != nil {
+		metrics.record(m.Pop, 0)
+	}
+	if tracker != nil {
+		tracker.record(m.Pop, 0)
+	}
+	if cohorts != nil {
+		cohorts.record(m.Pop, 0)
+	}
+	for _, o := range observers {
+		o.Turn(act, ri, 0, sdw, m.Pop.Wealth)
+	}
+	m.lap(phaseStats)
+
+	sds := make([]float64, 0)
+	sds = append(sds, sdw)
+	snapshotNetwork(m, ri, 0)
+	turns, changes := NumTurns, int64(0)
+	for i := 0; i < NumTurns; i++ {
+		turns = live.apply(m, &changes, turns)
+		if sd := sds[len(sds)-1]; m.Equalized(sd) || i >= turns {
+			stopped := "Equalized"
+			if i >= turns {
+				stopped = "Cut short"
+			}
+			fmt.Fprintf(&out, "%s (%s run %d) after turn %d; skipping the remaining %d turns\n",
+				stopped, act, ri+1, i, NumTurns-i)
+			for ; i < NumTurns; i++ {
+				sds = append(sds, sd)
+				if metrics != nil {
+					metrics.repeat(i + 1)
+				}
+				if tracker != nil {
+					tracker.record(m.Pop, i+1)
+				}
+				if history != nil {
+					history.record(m, i+1)
+				}
+				if cohorts != nil {
+					cohorts.record(m.Pop, i+1)
+				}
+				for _, o := range observers {
+					o.Turn(act, ri, i+1, sd, m.Pop.Wealth)
+				}
+				snapshotNetwork(m, ri, i+1)
+			}
+			break
+		}
+		m.Step()
+		m.mark()
+		_, sd := Asdw(m.Pop)
+		if metrics != nil {
+			metrics.record(m.Pop, i+1)
+		}
+		if tracker != nil {
+			tracker.record(m.Pop, i+1)
+		}
+		if trace != nil { // before history, which clears the turn's events
+			trace.record(m, i+1)
+		}
+		if history != nil {
+			history.record(m, i+1)
+		}
+		if cohorts != nil {
+			cohorts.record(m.Pop, i+1)
+		}
+		if audit != nil {
+			audit.check(m.Pop, i+1)
+		}
+		if watch != nil {
+			watch.check()
+		}
+		for _, o := range observers {
+			o.Turn(act, ri, i+1, sd, m.Pop.Wealth)
+		}
+		m.lap(phaseStats)
+		sds = append(sds, sd)
+		if LargeScale {
+			reportProgress(m, ri, sd, start)
+		}
+		snapshotNetwork(m, ri, i+1)
+	}
+	if metrics != nil {
+		metrics.save(act, ri)
+	}
+	if tracker != nil {
+		tracker.save(act, ri)
+	}
+	if history != nil {
+		history.save(act, ri)
+	}
+	if trace != nil {
+		trace.save(act, ri)
+	}
+	if cohorts != nil {
+		cohorts.save(act, ri)
+	}
+	if SaveFinalState {
+		saveState(m.Pop, act, ri)
+	}
+	for _, o := range observers {
+		o.Done(act, ri)
+	}
+	if audit != nil {
+		audit.report(&out, act, ri)
+	}
+	if ReportMemory {
+		watch.report(&out, act, ri)
+	}
+	if m.Hierarchy != nil {
+		within, between := Decompose(m.Pop, m.Hierarchy.District)
+		_, betweenRegions := Decompose(m.Pop, m.Hierarchy.Region)
+		fmt.Fprintf(&out, "Final variance (%s run %d): %f within districts, %f between districts (%f between regions)\n",
+			act, ri+1, within, between, betweenRegions)
+	}
+	if m.Market != nil {
+		printMarket(&out, m.Pop)
+	}
+	if m.Types != nil {
+		printTypes(&out, m, act, ri)
+	}
+	if m.Demography != nil {
+		printDemography(&out, m, act, ri)
+	}
+	if ReportTimings {
+		fmt.Fprintf(&out, "Timing (%s run %d): %v\n", act, ri+1, m.Times)
+	}
+	w.Write(out.Bytes())
+	if m.audit != nil {
+		m.audit.close()
+	}
+	m.Release()
+	return sds
+}
+
+// cellSeed derives a cell's seed from the master seed, so that its random
+// stream doesn't depend on which worker runs it or when.
+func cellSeed(master int64, act, run int) int64 {
+	return int64(splitmix64(uint64(master) ^ splitmix64(uint64(act)<<32|uint64(run))))
+}
+
+// splitmix64 is the SplitMix64 finalizer, a cheap and well-mixed hash.
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+)
+
+// TestCellSeed checks that every cell of an experiment gets a seed of its
+// own, and the same one every time.
+func TestCellSeed(t *testing.T) {
+	seen := make(map[int64]cell)
+	for a := -1; a < 8; a++ {
+		for r := 0; r < 100; r++ {
+			s := cellSeed(5, a, r)
+			if c, dup := seen[s]; dup {
+				t.Fatalf("cells %v and %v share seed %d", c, cell{a, r}, s)
+			}
+			seen[s] = cell{a, r}
+			if cellSeed(5, a, r) != s {
+				t.Fatalf("cell %v's seed changed", cell{a, r})
+			}
+		}
+	}
+	if cellSeed(5, 0, 0) == cellSeed(6, 0, 0) {
+		t.Error("master seed doesn't reach the cells")
+	}
+}
+
+// TestRunCells checks that every cell is run and collected exactly once,
+// that a failing executor hands its cell on to the others, and that the
+// experiment only fails when every executor has.
+func TestRunCells(t *testing.T) {
+	defer func(runs int) { NumRuns = runs }(NumRuns)
+	NumRuns = 7
+	acts := []ActivationOrder{uniform, random, poisson}
+	ok := func(c cell) (cellResult, error) { return cellResult{act: c.act, run: c.run}, nil }
+	var failures int32
+	flaky := func(c cell) (cellResult, error) {
+		atomic.AddInt32(&failures, 1)
+		return cellResult{}, errors.New("unreachable")
+	}
+	collected := make(map[cell]int)
+	if err := runCells(acts, []executor{flaky, ok, flaky, ok}, func(res cellResult) {
+		collected[cell{res.act, res.run}]++
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(collected) != len(acts)*NumRuns {
+		t.Errorf("collected %d cells, want %d", len(collected), len(acts)*NumRuns)
+	}
+	for c, n := range collected {
+		if n != 1 {
+			t.Errorf("cell %v collected %d times", c, n)
+		}
+	}
+	if failures > 2 {
+		t.Errorf("%d failures, want each flaky executor to retire after one", failures)
+	}
+	if err := runCells(acts, []executor{flaky, flaky}, func(cellResult) {}); err == nil {
+		t.Error("no error with every executor failing")
+	}
+}
+
+// TestLocalExecutors checks that a local pool has Workers executors, one
+// under LargeScale, and that each runs the Model it's given for its cell.
+func TestLocalExecutors(t *testing.T) {
+	defer func(workers, turns int, large bool, out io.Writer) {
+		Workers, NumTurns, LargeScale, cellOutput = workers, turns, large, out
+	}(Workers, NumTurns, LargeScale, cellOutput)
+	Workers, NumTurns, cellOutput = 3, 4, io.Discard
+	acts := []ActivationOrder{uniform, random}
+	newModel := func(c cell) *Model { return experimentModel(acts, 1, c) }
+	for _, large := range []bool{false, true} {
+		LargeScale = large
+		execs := localExecutors(newModel)
+		if want := map[bool]int{false: 3, true: 1}[large]; len(execs) != want {
+			t.Errorf("large scale %v: %d executors, want %d", large, len(execs), want)
+		}
+	}
+	LargeScale = false
+	res, err := localExecutors(newModel)[0](cell{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.act != 1 || res.run != 2 || len(res.sds) != NumTurns+1 {
+		t.Errorf("cell {1 2} gave act %d, run %d and %d SDs", res.act, res.run, len(res.sds))
+	}
+}
+
+// TestCompare checks that in a controlled comparison every regime of a run
+// starts from the same population, so that two copies of one regime agree
+// turn for turn, while different runs go their own ways.
+func TestCompare(t *testing.T) {
+	defer func(runs, turns int, out io.Writer) {
+		NumRuns, NumTurns, cellOutput = runs, turns, out
+	}(NumRuns, NumTurns, cellOutput)
+	NumRuns, NumTurns, cellOutput = 3, 5, io.Discard
+	acts := []ActivationOrder{random, poisson, random, localPoisson}
+	matrices, collect := resultMatrices(acts)
+	if err := Compare(acts, 4, collect); err != nil {
+		t.Fatal(err)
+	}
+	for r := 0; r < NumRuns; r++ {
+		for a := range acts {
+			if start := matrices[a].At(r, 0); start != matrices[0].At(r, 0) {
+				t.Errorf("run %d: %v starts at SD %v, %v at %v", r, acts[a], start, acts[0], matrices[0].At(r, 0))
+			}
+		}
+		for turn := 0; turn < NumTurns; turn++ {
+			if x, y := matrices[0].At(r, turn), matrices[2].At(r, turn); x != y {
+				t.Fatalf("run %d, turn %d: copies of random at SD %v and %v", r, turn, x, y)
+			}
+		}
+	}
+	if last := NumTurns - 1; matrices[0].At(0, last) == matrices[0].At(1, last) {
+		t.Error("runs 1 and 2 came out the same")
+	}
+}
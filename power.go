@@ -0,0 +1,128 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/GaryBoone/GoStats/stats"
+	"io"
+	"math"
+	"os"
+)
+
+/* Power analysis */
+
+/*
+ * "power -diff d [-alpha 0.05] [-power 0.8] (-sd s | trajectories.csv)"
+ * recommends how many runs of each regime an experiment needs to tell
+ * their mean gradients apart by d: the smallest NumRuns for which Welch's
+ * t-test at level alpha detects a true difference of d with the given
+ * probability. The spread of the gradient over runs comes from -sd, or
+ * from a pilot experiment's trajectories.csv (see plot.go), in which case
+ * every pair of its regimes gets a recommendation from their own spreads.
+ *
+ * For n runs each, the test has about 2(n-1) degrees of freedom when the
+ * spreads are alike, fewer when not, and n is enough when
+ *
+ *	n >= (t(1-alpha/2) + t(power))^2 (sa^2 + sb^2) / d^2
+ *
+ * for t's quantiles at those degrees of freedom; the search starts from
+ * the normal approximation, which is never more than enough.
+ */
+
+// runPower prints the runs needed to detect a difference in gradients.
+func runPower(args []string) error {
+	fs := flag.NewFlagSet("power", flag.ContinueOnError)
+	diff := fs.Float64("diff", 0, "difference in mean gradients to detect")
+	alpha := fs.Float64("alpha", 0.05, "significance level of the two-sided test")
+	power := fs.Float64("power", 0.8, "probability of detecting the difference")
+	sd := fs.Float64("sd", 0, "SD of the gradient over runs, if there's no pilot")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if (*sd > 0) == (fs.NArg() == 1) || fs.NArg() > 1 {
+		return errors.New("usage: power -diff d [-alpha 0.05] [-power 0.8] (-sd s | trajectories.csv)")
+	} else if !(*diff > 0) {
+		return fmt.Errorf("difference %v isn't positive", *diff)
+	} else if !(*alpha > 0 && *alpha < 1) {
+		return fmt.Errorf("alpha %v isn't strictly between 0 and 1", *alpha)
+	} else if !(*power > 0.5 && *power < 1) {
+		return fmt.Errorf("power %v isn't strictly between 0.5 and 1", *power)
+	}
+	fmt.Printf("Runs per regime to detect a difference in gradients of %g at alpha %g with power %g:\n", *diff, *alpha, *power)
+	if *sd > 0 {
+		fmt.Printf("SD %g: %d runs\n", *sd, runsNeeded(*sd, *sd, *diff, *alpha, *power))
+		return nil
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	t, err := readTrajectories(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	return recommendRuns(os.Stdout, t, *diff, *alpha, *power)
+}
+
+// recommendRuns prints the runs needed for each pair of the regimes in the
+// pilot t, from the SDs of their gradients.
+func recommendRuns(w io.Writer, t *trajectories, diff, alpha, power float64) error {
+	sds := make([]float64, len(t.regimes))
+	for i, regime := range t.regimes {
+		var s stats.Stats
+		for _, tr := range t.runs[regime] {
+			if len(tr.SD) > 0 {
+				s.Update(gradientOver(tr.SD, t.length))
+			}
+		}
+		if s.Count() < 2 {
+			return fmt.Errorf("pilot has %d runs of %s; the SD of its gradient takes two", s.Count(), regime)
+		}
+		sds[i] = s.SampleStandardDeviation()
+		fmt.Fprintf(w, "%-15s\tpilot SD %.6g over %d runs\n", regime, sds[i], s.Count())
+	}
+	most := 0
+	for i := range t.regimes {
+		for j := i + 1; j < len(t.regimes); j++ {
+			n := runsNeeded(sds[i], sds[j], diff, alpha, power)
+			if n > most {
+				most = n
+			}
+			fmt.Fprintf(w, "%-15s vs %-15s\t%d runs\n", t.regimes[i], t.regimes[j], n)
+		}
+	}
+	if len(t.regimes) == 1 {
+		most = runsNeeded(sds[0], sds[0], diff, alpha, power)
+	}
+	fmt.Fprintf(w, "NumRuns of at least %d tells every pair apart\n", most)
+	return nil
+}
+
+// runsNeeded returns the smallest number of runs of each of two regimes,
+// whose gradients have SDs sa and sb, for Welch's test at level alpha to
+// detect a difference of diff with probability power.
+func runsNeeded(sa, sb, diff, alpha, power float64) int {
+	va, vb := sa*sa, sb*sb
+	if va+vb == 0 {
+		return 2
+	}
+	need := func(qa, qb float64) int {
+		return int(math.Ceil((qa + qb) * (qa + qb) * (va + vb) / (diff * diff)))
+	}
+	z := func(p float64) float64 { return math.Sqrt2 * math.Erfinv(2*p-1) }
+	n := need(z(1-alpha/2), z(power))
+	if n < 2 {
+		n = 2
+	}
+	for {
+		df := (va + vb) * (va + vb) * float64(n-1) / (va*va + vb*vb)
+		if need(tQuantile(1-alpha/2, df), tQuantile(power, df)) <= n {
+			return n
+		}
+		n++
+	}
+}
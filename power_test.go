@@ -0,0 +1,45 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestRunsNeeded(t *testing.T) {
+	// as R's power.t.test(delta = diff, sd = 1, power = 0.8) has it, rounded up
+	for _, c := range []struct {
+		diff float64
+		want int
+	}{{1, 17}, {0.5, 64}, {2, 6}} {
+		if n := runsNeeded(1, 1, c.diff, 0.05, 0.8); n != c.want {
+			t.Errorf("difference %v: %d runs, want %d", c.diff, n, c.want)
+		}
+	}
+	if a, b := runsNeeded(1, 3, 1, 0.05, 0.8), runsNeeded(math.Sqrt(5), math.Sqrt(5), 1, 0.05, 0.8); a < b {
+		t.Errorf("unequal SDs need %d runs, fewer than equal ones' %d", a, b)
+	}
+}
+
+func TestRecommendRuns(t *testing.T) {
+	tr := newTrajectories()
+	for ri, slopes := range [][]float64{{-1, -0.5}, {-1.2, -0.5}, {-0.8, -0.5}} {
+		for k := 0; k <= 2; k++ {
+			tr.set("uniform", ri, k, math.Exp(slopes[0]*float64(k)), 0)
+			tr.set("random", ri, k, math.Exp(slopes[1]*float64(k)), 0)
+		}
+	}
+	var buf bytes.Buffer
+	if err := recommendRuns(&buf, tr, 0.2, 0.05, 0.8); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "uniform        \tpilot SD 0.2 over 3 runs") ||
+		!strings.HasPrefix(lines[1], "random         \tpilot SD 0 over 3 runs") ||
+		lines[2] != "uniform         vs random         \t10 runs" || lines[3] != "NumRuns of at least 10 tells every pair apart" {
+		t.Errorf("recommendation:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+)
+
+/* Precision */
+
+/*
+ * Wealth is held as float64 throughout, so over very long runs rounding can
+ * creep into both the exchanges and the statistics taken of them. With
+ * Precision set to "compensated", the wealth mean and SD are computed with
+ * Neumaier summation and two passes rather than by naive accumulation. With
+ * AuditPrecision, total wealth is also summed exactly (with math/big) after
+ * every turn and compared to the total the run started with, and to what
+ * float64 summation makes of it. Under Leveler the integer floor discards
+ * wealth by design, so there the drift measures that as well as rounding.
+ */
+
+// checkPrecision returns an error if Precision isn't a known mode.
+func checkPrecision() error {
+	if Precision != "float64" && Precision != "compensated" {
+		return fmt.Errorf("unknown precision %q", Precision)
+	}
+	return nil
+}
+
+// neumaierSum returns the sum of x with Neumaier's compensated summation,
+// whose error doesn't grow with len(x).
+func neumaierSum(x []float64) float64 {
+	sum, c := 0.0, 0.0
+	for _, v := range x {
+		t := sum + v
+		if math.Abs(sum) >= math.Abs(v) {
+			c += (sum - t) + v
+		} else {
+			c += (v - t) + sum
+		}
+		sum = t
+	}
+	return sum + c
+}
+
+// compensatedMeanSD returns the mean and sample standard deviation of x,
+// summing with neumaierSum and taking deviations from the mean in a second
+// pass.
+func compensatedMeanSD(x []float64) (mean, sd float64) {
+	n := float64(len(x))
+	mean = neumaierSum(x) / n
+	ss, c := 0.0, 0.0 // Neumaier again, inline so as not to allocate
+	for _, v := range x {
+		d := (v - mean) * (v - mean)
+		t := ss + d
+		if ss >= d {
+			c += (ss - t) + d
+		} else {
+			c += (d - t) + ss
+		}
+		ss = t
+	}
+	return mean, math.Sqrt((ss + c) / (n - 1))
+}
+
+// exactSum returns the sum of x without rounding. Any float64 fits in 2098
+// bits of mantissa, and the extra 64 leave room for the carries.
+func exactSum(x []float64) *big.Float {
+	sum := new(big.Float).SetPrec(2098 + 64)
+	v := new(big.Float)
+	for _, w := range x {
+		sum.Add(sum, v.SetFloat64(w))
+	}
+	return sum
+}
+
+// A precisionAudit follows a run's total wealth.
+type precisionAudit struct {
+	initial  *big.Float
+	drift    float64 // the largest relative change in exact total wealth so far
+	driftAt  int     // and the turn it was seen
+	sumError float64 // the largest relative error of float64 summation so far
+}
+
+// newPrecisionAudit starts auditing from the wealth in Pop, or returns nil
+// if AuditPrecision isn't set.
+func newPrecisionAudit(Pop Population) *precisionAudit {
+	if !AuditPrecision {
+		return nil
+	}
+	return &precisionAudit{initial: exactSum(Pop.Wealth)}
+}
+
+// check compares the total wealth in Pop after the given turn with the
+// initial total.
+func (p *precisionAudit) check(Pop Population, turn int) {
+	total := exactSum(Pop.Wealth)
+	exact, _ := total.Float64()
+	if drift := p.relative(new(big.Float).Sub(total, p.initial), p.initial); math.Abs(drift) > math.Abs(p.drift) {
+		p.drift, p.driftAt = drift, turn
+	}
+	naive := 0.0
+	for _, w := range Pop.Wealth {
+		naive += w
+	}
+	e := math.Abs(naive - exact)
+	if exact != 0 {
+		e /= math.Abs(exact)
+	}
+	if e > p.sumError {
+		p.sumError = e
+	}
+}
+
+// relative returns d/x, or d itself if x is 0.
+func (p *precisionAudit) relative(d, x *big.Float) float64 {
+	if x.Sign() == 0 {
+		f, _ := d.Float64()
+		return f
+	}
+	f, _ := new(big.Float).Quo(d, x).Float64()
+	return f
+}
+
+// report writes what the audit found over the given run of act.
+func (p *precisionAudit) report(w io.Writer, act ActivationOrder, run int) {
+	initial, _ := p.initial.Float64()
+	fmt.Fprintf(w, "Precision (%s run %d): total wealth %v at the start, largest drift %.3g (relative) at turn %d, largest float64 summation error %.3g (relative)\n",
+		act, run+1, initial, p.drift, p.driftAt, p.sumError)
+}
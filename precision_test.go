@@ -0,0 +1,30 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestExactSum(t *testing.T) {
+	if s, _ := exactSum([]float64{1e16, 1, -1e16}).Float64(); s != 1 {
+		t.Errorf("exact sum %v, want 1", s)
+	}
+}
+
+// TestCompensatedMeanSD checks the compensated statistics on values whose
+// spread is tiny next to their size, where naive accumulation goes wrong.
+func TestCompensatedMeanSD(t *testing.T) {
+	x := make([]float64, 100001)
+	for i := range x {
+		x[i] = 1e9 + float64(i%2)*0.1
+	}
+	mean, sd := compensatedMeanSD(x)
+	wantMean := 1e9 + 0.1*50000/100001
+	wantSD := math.Sqrt(100001 * (50000.0 / 100001) * (50001.0 / 100001) * 0.01 / 100000)
+	if math.Abs(mean-wantMean) > 1e-6 {
+		t.Errorf("mean %v, want %v", mean, wantMean)
+	}
+	if math.Abs(sd-wantSD)/wantSD > 1e-6 {
+		t.Errorf("SD %v, want %v", sd, wantSD)
+	}
+}
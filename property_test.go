@@ -0,0 +1,177 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+	"testing/quick"
+)
+
+// A propertyConfig is a random small experiment for the invariant tests.
+type propertyConfig struct {
+	Agents   int
+	Turns    int
+	Act      ActivationOrder
+	Seed     int64
+	Wealth   []float64 // initial
+	Counts   bool      // EventSampling "counts" rather than "waiting"
+	Parallel bool      // pair and sort in parallel
+}
+
+// Generate makes propertyConfig a quick.Generator.
+func (propertyConfig) Generate(rng *rand.Rand, size int) reflect.Value {
+	c := propertyConfig{
+		Agents:   2 + rng.Intn(200),
+		Turns:    1 + rng.Intn(10),
+		Act:      ActivationOrder(rng.Intn(int(localPoisson) + 1)),
+		Seed:     rng.Int63(),
+		Counts:   rng.Intn(2) == 0,
+		Parallel: rng.Intn(2) == 0,
+	}
+	c.Wealth = make([]float64, c.Agents)
+	scale := math.Pow(10, float64(rng.Intn(7)))
+	for i := range c.Wealth {
+		c.Wealth[i] = math.Floor(scale * rng.Float64())
+	}
+	return reflect.ValueOf(c)
+}
+
+// step advances m a turn and reports true, unless its wealth is already
+// equal, when the experiment would skip the rest of the run.
+func step(m *Model) bool {
+	if _, sd := Asdw(m.Pop); sd == 0 {
+		return false
+	}
+	m.Step()
+	return true
+}
+
+// model sets the Choices for c and returns its Model, with wealth per c
+// and rule if it isn't nil.
+func (c propertyConfig) model(rule Rule) *Model {
+	NumOfAgents = c.Agents
+	EventSampling = "waiting"
+	if c.Counts {
+		EventSampling = "counts"
+	}
+	Workers, ParallelThreshold, ParallelSortThreshold = 1, 1<<16, 1<<18
+	if c.Parallel {
+		Workers, ParallelThreshold, ParallelSortThreshold = 4, 1, 1
+	}
+	m := NewModel(c.Act, rand.New(rand.NewSource(c.Seed)))
+	copy(m.Pop.Wealth, c.Wealth)
+	if rule != nil {
+		m.Rule = rule
+	}
+	return m
+}
+
+// checkProperty checks that property holds for random configurations,
+// restoring the Choices they set.
+func checkProperty(t *testing.T, property func(c propertyConfig) bool) {
+	defer func(agents, workers, pairs, sorts int, sampling string) {
+		NumOfAgents, Workers, ParallelThreshold, ParallelSortThreshold, EventSampling = agents, workers, pairs, sorts, sampling
+	}(NumOfAgents, Workers, ParallelThreshold, ParallelSortThreshold, EventSampling)
+	cfg := &quick.Config{MaxCount: 200, Rand: rand.New(rand.NewSource(1))}
+	if err := quick.Check(property, cfg); err != nil {
+		t.Error(err)
+	}
+}
+
+func totalWealth(wealth []float64) float64 {
+	total := 0.0
+	for _, w := range wealth {
+		total += w
+	}
+	return total
+}
+
+// TestTotalWealthNeverIncreases: leveling with the integer floor can only
+// lose wealth.
+func TestTotalWealthNeverIncreases(t *testing.T) {
+	checkProperty(t, func(c propertyConfig) bool {
+		m := c.model(nil)
+		defer m.Release()
+		total := totalWealth(m.Pop.Wealth)
+		for turn := 0; turn < c.Turns && step(m); turn++ {
+			next := totalWealth(m.Pop.Wealth)
+			if next > total {
+				t.Logf("%s: total rose from %v to %v in turn %d", c.Act, total, next, turn+1)
+				return false
+			}
+			total = next
+		}
+		return true
+	})
+}
+
+// TestSDNeverIncreases: averaging pairs without the floor keeps the mean
+// and can only shrink the spread.
+func TestSDNeverIncreases(t *testing.T) {
+	checkProperty(t, func(c propertyConfig) bool {
+		m := c.model(PartialLeveler{Fraction: 1})
+		defer m.Release()
+		_, sd := Asdw(m.Pop)
+		for turn := 0; turn < c.Turns && step(m); turn++ {
+			_, next := Asdw(m.Pop)
+			if next > sd*(1+1e-9)+1e-9 {
+				t.Logf("%s: SD rose from %v to %v in turn %d", c.Act, sd, next, turn+1)
+				return false
+			}
+			sd = next
+		}
+		return true
+	})
+}
+
+// TestPairsInRange: every pair a turn makes is of agents in the
+// population, and uniform activation pairs each agent at most once.
+func TestPairsInRange(t *testing.T) {
+	checkProperty(t, func(c propertyConfig) bool {
+		m := c.model(nil)
+		defer m.Release()
+		for turn := 0; turn < c.Turns && step(m); turn++ {
+			if len(m.order) > c.Agents { // Unifact keeps an odd agent out at the end
+				t.Logf("%s: %d agents paired of %d", c.Act, len(m.order), c.Agents)
+				return false
+			}
+			seen := make(map[int]bool)
+			for _, i := range m.order {
+				if i < 0 || i >= c.Agents || (c.Act == uniform && seen[i]) {
+					t.Logf("%s: bad pairing of agent %d of %d in %v", c.Act, i, c.Agents, m.order)
+					return false
+				}
+				seen[i] = true
+			}
+		}
+		return true
+	})
+}
+
+// TestEventTimes: a Poisson turn's events fall within the turn, in order,
+// and are of agents in the population.
+func TestEventTimes(t *testing.T) {
+	checkProperty(t, func(c propertyConfig) bool {
+		if c.Act == uniform || c.Act == random {
+			c.Act = poisson
+		}
+		m := c.model(nil)
+		defer m.Release()
+		for turn := 0; turn < c.Turns && step(m); turn++ {
+			e := m.aTimes
+			if !sort.IsSorted(e) {
+				t.Logf("%s: events out of order", c.Act)
+				return false
+			}
+			for _, ev := range e {
+				if ev.time < 0 || ev.time >= 1 || ev.agent < 0 || int(ev.agent) >= c.Agents {
+					t.Logf("%s: event %+v in a turn of %d agents", c.Act, ev, c.Agents)
+					return false
+				}
+			}
+		}
+		return true
+	})
+}
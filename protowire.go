@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+/* Protocol buffer encoding */
+
+/*
+ * The messages of simulation.proto, encoded and decoded by hand so that the
+ * gRPC service needs no generated code. Only the wire types those messages
+ * use are written: varints for integers, fixed 64-bit for doubles, and
+ * length-delimited for strings and packed repeated doubles. Decoding skips
+ * fields it doesn't know and also accepts unpacked repeated doubles, as the
+ * protobuf spec requires.
+ */
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// A protoMessage can be written and read in the protobuf wire format.
+type protoMessage interface {
+	marshalProto() []byte
+	unmarshalProto(b []byte) error
+}
+
+var errProtoTruncated = errors.New("protobuf: truncated message")
+
+func appendTag(b []byte, field, wire int) []byte {
+	return binary.AppendUvarint(b, uint64(field)<<3|uint64(wire))
+}
+
+// appendInt appends an int64 field, unless it is zero.
+func appendInt(b []byte, field int, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	return binary.AppendUvarint(appendTag(b, field, wireVarint), uint64(v))
+}
+
+// appendDouble appends a double field, unless it is zero.
+func appendDouble(b []byte, field int, v float64) []byte {
+	if v == 0 {
+		return b
+	}
+	return binary.LittleEndian.AppendUint64(appendTag(b, field, wireFixed64), math.Float64bits(v))
+}
+
+// appendString appends a string field, unless it is empty.
+func appendString(b []byte, field int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = binary.AppendUvarint(appendTag(b, field, wireBytes), uint64(len(s)))
+	return append(b, s...)
+}
+
+// appendDoubles appends a packed repeated double field, unless it is empty.
+func appendDoubles(b []byte, field int, v []float64) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = binary.AppendUvarint(appendTag(b, field, wireBytes), uint64(8*len(v)))
+	for _, x := range v {
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(x))
+	}
+	return b
+}
+
+// parseProto calls f for every field of the message in b. For varint and
+// fixed fields, v is the value; for length-delimited ones, data is.
+func parseProto(b []byte, f func(field, wire int, v uint64, data []byte) error) error {
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errProtoTruncated
+		}
+		b = b[n:]
+		field, wire := int(tag>>3), int(tag&7)
+		var v uint64
+		var data []byte
+		if wire == wireVarint {
+			if v, n = binary.Uvarint(b); n <= 0 {
+				return errProtoTruncated
+			}
+			b = b[n:]
+		} else if wire == wireFixed64 {
+			if len(b) < 8 {
+				return errProtoTruncated
+			}
+			v, b = binary.LittleEndian.Uint64(b), b[8:]
+		} else if wire == wireBytes {
+			l, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < l {
+				return errProtoTruncated
+			}
+			data, b = b[n:n+int(l)], b[n+int(l):]
+		} else if wire == wireFixed32 {
+			if len(b) < 4 {
+				return errProtoTruncated
+			}
+			v, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+		} else {
+			return fmt.Errorf("protobuf: unsupported wire type %d", wire)
+		}
+		if err := f(field, wire, v, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseDoubles appends the doubles of a repeated field to dst, packed or not.
+func parseDoubles(dst []float64, wire int, v uint64, data []byte) ([]float64, error) {
+	if wire == wireFixed64 {
+		return append(dst, math.Float64frombits(v)), nil
+	} else if wire != wireBytes || len(data)%8 != 0 {
+		return dst, errors.New("protobuf: malformed repeated double")
+	}
+	for ; len(data) > 0; data = data[8:] {
+		dst = append(dst, math.Float64frombits(binary.LittleEndian.Uint64(data)))
+	}
+	return dst, nil
+}
+
+// RunConfig is the RunRequest message.
+func (c *RunConfig) marshalProto() []byte {
+	var b []byte
+	b = appendString(b, 1, c.Activation)
+	b = appendInt(b, 2, int64(c.Agents))
+	b = appendInt(b, 3, int64(c.Turns))
+	b = appendInt(b, 4, c.Seed)
+	return appendString(b, 5, c.RNG)
+}
+
+func (c *RunConfig) unmarshalProto(b []byte) error {
+	*c = RunConfig{}
+	return parseProto(b, func(field, wire int, v uint64, data []byte) error {
+		if field == 1 {
+			c.Activation = string(data)
+		} else if field == 2 {
+			c.Agents = int(int64(v))
+		} else if field == 3 {
+			c.Turns = int(int64(v))
+		} else if field == 4 {
+			c.Seed = int64(v)
+		} else if field == 5 {
+			c.RNG = string(data)
+		}
+		return nil
+	})
+}
+
+// RunHandle names a submitted run.
+type RunHandle struct {
+	ID string
+}
+
+func (h *RunHandle) marshalProto() []byte {
+	return appendString(nil, 1, h.ID)
+}
+
+func (h *RunHandle) unmarshalProto(b []byte) error {
+	*h = RunHandle{}
+	return parseProto(b, func(field, wire int, v uint64, data []byte) error {
+		if field == 1 {
+			h.ID = string(data)
+		}
+		return nil
+	})
+}
+
+func (t *TurnMetrics) marshalProto() []byte {
+	b := appendInt(nil, 1, int64(t.Turn))
+	b = appendDouble(b, 2, t.SD)
+	return appendDouble(b, 3, t.Gini)
+}
+
+func (t *TurnMetrics) unmarshalProto(b []byte) error {
+	*t = TurnMetrics{}
+	return parseProto(b, func(field, wire int, v uint64, data []byte) error {
+		if field == 1 {
+			t.Turn = int(int64(v))
+		} else if field == 2 {
+			t.SD = math.Float64frombits(v)
+		} else if field == 3 {
+			t.Gini = math.Float64frombits(v)
+		}
+		return nil
+	})
+}
+
+// RunResult is the Results message.
+func (r *RunResult) marshalProto() []byte {
+	b := appendDoubles(nil, 1, r.SDs)
+	return appendDoubles(b, 2, r.Wealth)
+}
+
+func (r *RunResult) unmarshalProto(b []byte) error {
+	*r = RunResult{}
+	return parseProto(b, func(field, wire int, v uint64, data []byte) error {
+		var err error
+		if field == 1 {
+			r.SDs, err = parseDoubles(r.SDs, wire, v, data)
+		} else if field == 2 {
+			r.Wealth, err = parseDoubles(r.Wealth, wire, v, data)
+		}
+		return err
+	})
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// TestProtoRoundTrip checks that every message survives encoding.
+func TestProtoRoundTrip(t *testing.T) {
+	msgs := []protoMessage{
+		&RunConfig{Activation: "inverse poisson", Agents: 1000, Turns: 20, Seed: -7, RNG: "pcg"},
+		&RunHandle{ID: "42"},
+		&TurnMetrics{Turn: 3, SD: 12.5, Gini: 0.25},
+		&RunResult{SDs: []float64{3, 2, 1}, Wealth: []float64{-1.5, 0, 1e300}},
+	}
+	for _, m := range msgs {
+		got := reflect.New(reflect.TypeOf(m).Elem()).Interface().(protoMessage)
+		if err := got.unmarshalProto(m.marshalProto()); err != nil {
+			t.Fatalf("%T: %v", m, err)
+		}
+		if !reflect.DeepEqual(got, m) {
+			t.Errorf("%T: got %+v back, want %+v", m, got, m)
+		}
+	}
+}
+
+// TestProtoWireFormat checks encodings against bytes worked out from the
+// protobuf spec, and that unknown fields and unpacked doubles are read.
+func TestProtoWireFormat(t *testing.T) {
+	if b := (&RunHandle{ID: "1"}).marshalProto(); !bytes.Equal(b, []byte{0x0a, 0x01, '1'}) {
+		t.Errorf("RunHandle encodes as % x", b)
+	}
+	if b := (&RunConfig{Agents: 300}).marshalProto(); !bytes.Equal(b, []byte{0x10, 0xac, 0x02}) {
+		t.Errorf("RunConfig encodes as % x", b)
+	}
+	in := []byte{
+		0x09, 0, 0, 0, 0, 0, 0, 0xf0, 0x3f, // sds: 1.0, unpacked
+		0x30, 0x05, // field 6, unknown
+		0x09, 0, 0, 0, 0, 0, 0, 0, 0x40, // sds: 2.0
+	}
+	var r RunResult
+	if err := r.unmarshalProto(in); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r.SDs, []float64{1, 2}) {
+		t.Errorf("read SDs %v, want [1 2]", r.SDs)
+	}
+	if err := r.unmarshalProto([]byte{0x0a, 0x10, 0}); err == nil {
+		t.Error("truncated message read without error")
+	}
+}
@@ -0,0 +1,111 @@
+"""Python bindings for the leveler model, built as a C shared library.
+
+Build the library from the repository root with
+
+    go build -tags cshared -buildmode=c-shared -o libleveler.so .
+
+and then
+
+    from leveler import Leveler
+
+    model = Leveler("./libleveler.so")
+    with model.run(activation="inverse poisson", agents=1000, turns=50, seed=7) as run:
+        sds = run.sds()        # wealth SD before the first turn and after each
+        wealth = run.wealth()  # every agent's final wealth
+
+Results come back as lists of floats, or NumPy arrays if NumPy is installed.
+Only the standard library's ctypes is needed.
+"""
+
+import ctypes
+import json
+import os
+
+try:
+    import numpy
+except ImportError:
+    numpy = None
+
+__all__ = ["Leveler", "Run", "LevelerError"]
+
+
+class LevelerError(Exception):
+    """A run the library refused or couldn't do."""
+
+
+class Leveler:
+    """The model, loaded from the shared library at path."""
+
+    def __init__(self, path=None):
+        if path is None:
+            path = os.environ.get("LEVELER_LIB", "libleveler.so")
+        lib = ctypes.CDLL(path)
+        lib.LevelerRun.argtypes = [ctypes.c_char_p, ctypes.POINTER(ctypes.c_void_p)]
+        lib.LevelerRun.restype = ctypes.c_longlong
+        for name in ("LevelerSDs", "LevelerWealth"):
+            fn = getattr(lib, name)
+            fn.argtypes = [ctypes.c_longlong, ctypes.POINTER(ctypes.c_double), ctypes.c_int]
+            fn.restype = ctypes.c_int
+        lib.LevelerRelease.argtypes = [ctypes.c_longlong]
+        lib.LevelerRelease.restype = None
+        lib.LevelerFree.argtypes = [ctypes.c_void_p]
+        lib.LevelerFree.restype = None
+        self._lib = lib
+
+    def run(self, activation="", agents=0, turns=0, seed=0, rng=""):
+        """Does one run and returns it. Zero or empty arguments take the
+        library's defaults, as in RunConfig."""
+        config = json.dumps({"activation": activation, "agents": agents,
+                             "turns": turns, "seed": seed, "rng": rng})
+        err = ctypes.c_void_p()
+        handle = self._lib.LevelerRun(config.encode(), ctypes.byref(err))
+        if handle < 0:
+            msg = ctypes.string_at(err.value).decode()
+            self._lib.LevelerFree(err)
+            raise LevelerError(msg)
+        return Run(self._lib, handle)
+
+
+class Run:
+    """The results of one run, held by the library until released."""
+
+    def __init__(self, lib, handle):
+        self._lib = lib
+        self._handle = handle
+
+    def sds(self):
+        """The wealth SD before the first turn and after each."""
+        return self._fetch(self._lib.LevelerSDs)
+
+    def wealth(self):
+        """Every agent's wealth after the last turn."""
+        return self._fetch(self._lib.LevelerWealth)
+
+    def _fetch(self, fn):
+        if self._handle is None:
+            raise LevelerError("run already released")
+        n = fn(self._handle, None, 0)
+        if n < 0:
+            raise LevelerError("no such run")
+        if numpy is not None:
+            out = numpy.empty(n, dtype=numpy.float64)
+            fn(self._handle, out.ctypes.data_as(ctypes.POINTER(ctypes.c_double)), n)
+            return out
+        buf = (ctypes.c_double * n)()
+        fn(self._handle, buf, n)
+        return list(buf)
+
+    def release(self):
+        """Frees the results in the library."""
+        if self._handle is not None:
+            self._lib.LevelerRelease(self._handle)
+            self._handle = None
+
+    def __enter__(self):
+        return self
+
+    def __exit__(self, *exc):
+        self.release()
+
+    def __del__(self):
+        self.release()
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+/* Quantile slopes */
+
+/*
+ * The mean gradient averages away how much runs differ, and the original
+ * model's runs occasionally level out far faster or slower than the rest.
+ * With QuantileSlopes set, e.g. to {0.1, 0.5, 0.9}, the gradient analysis
+ * also fits a quantile regression of log wealth SD on simulated time to
+ * every turn of every run of each regime, pooled, and reports the slope at
+ * each quantile: how fast the lower, middle and upper runs of the ensemble
+ * level out, however heavy its tails. The fits need every run's
+ * trajectory, so they can't be made with StreamResults.
+ *
+ * A fit minimizes the check loss, sum over points of tau times the residual
+ * if it's positive and tau-1 times it if not. For a given slope the best
+ * intercept is the tau-quantile of the residuals, and the loss that leaves
+ * is convex in the slope, so the slope is found by a ternary search.
+ */
+
+// checkQuantileSlopes returns an error unless the QuantileSlopes are
+// strictly between 0 and 1 and can be fitted.
+func checkQuantileSlopes() error {
+	for _, tau := range QuantileSlopes {
+		if !(tau > 0 && tau < 1) {
+			return fmt.Errorf("QuantileSlopes: %v isn't strictly between 0 and 1", tau)
+		}
+	}
+	if len(QuantileSlopes) > 0 && StreamResults {
+		return errors.New("QuantileSlopes need every run's trajectory, so can't be fitted with StreamResults")
+	}
+	return nil
+}
+
+// quantileSlopes returns the slopes of the QuantileSlopes regressions of
+// log wealth SD on time over every turn of runs, each a run's SDs.
+func quantileSlopes(runs [][]float64) []float64 {
+	if len(QuantileSlopes) == 0 {
+		return nil
+	}
+	var x, y []float64
+	for _, sds := range runs {
+		for k, sd := range sds {
+			if sd == 0 {
+				sd = 0.00000000001 // as gradient has it
+			}
+			x, y = append(x, float64(k)*TurnLength), append(y, math.Log(sd))
+		}
+	}
+	slopes := make([]float64, len(QuantileSlopes))
+	for i, tau := range QuantileSlopes {
+		_, slopes[i] = quantileFit(x, y, tau)
+	}
+	return slopes
+}
+
+// quantileFit returns the intercept and slope of the tau-quantile
+// regression of y on x.
+func quantileFit(x, y []float64, tau float64) (intercept, slope float64) {
+	if len(x) == 0 {
+		return math.NaN(), math.NaN()
+	}
+	residuals := make([]float64, len(x))
+	// fit returns the best intercept for slope b, and the loss with it.
+	fit := func(b float64) (float64, float64) {
+		for i := range x {
+			residuals[i] = y[i] - b*x[i]
+		}
+		sort.Float64s(residuals)
+		a := residuals[int(math.Ceil(tau*float64(len(residuals))))-1]
+		loss := 0.0
+		for _, r := range residuals {
+			if r -= a; r > 0 {
+				loss += tau * r
+			} else {
+				loss += (tau - 1) * r
+			}
+		}
+		return a, loss
+	}
+	// the best slope is one through two of the points, so it lies within
+	// the steepest slope any pair could have
+	xlo, xhi, ylo, yhi := x[0], x[0], y[0], y[0]
+	gap := math.Inf(1)
+	xs := append([]float64(nil), x...)
+	sort.Float64s(xs)
+	for i, v := range xs {
+		xlo, xhi = math.Min(xlo, v), math.Max(xhi, v)
+		if i > 0 && v > xs[i-1] {
+			gap = math.Min(gap, v-xs[i-1])
+		}
+		ylo, yhi = math.Min(ylo, y[i]), math.Max(yhi, y[i])
+	}
+	if xhi == xlo {
+		a, _ := fit(0)
+		return a, 0
+	}
+	lo, hi := -(yhi-ylo)/gap, (yhi-ylo)/gap
+	for k := 0; k < 200 && hi-lo > 1e-12*math.Max(1, math.Abs(lo)); k++ {
+		m1, m2 := lo+(hi-lo)/3, hi-(hi-lo)/3
+		_, l1 := fit(m1)
+		_, l2 := fit(m2)
+		if l1 > l2 {
+			lo = m1
+		} else {
+			hi = m2
+		}
+	}
+	slope = (lo + hi) / 2
+	intercept, _ = fit(slope)
+	return intercept, slope
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestQuantileFit(t *testing.T) {
+	var x, y []float64
+	for k := 0; k < 5; k++ {
+		for _, off := range []float64{-1, 0, 1} {
+			x, y = append(x, float64(k)), append(y, 2*float64(k)+off)
+		}
+	}
+	for _, c := range []struct{ tau, intercept float64 }{{0.1, -1}, {0.5, 0}, {0.9, 1}} {
+		a, b := quantileFit(x, y, c.tau)
+		if math.Abs(a-c.intercept) > 1e-9 || math.Abs(b-2) > 1e-9 {
+			t.Errorf("tau %v: intercept %v, slope %v; want %v, 2", c.tau, a, b, c.intercept)
+		}
+	}
+}
+
+// TestQuantileSlopes checks that the fits pick out the slowest, middle and
+// fastest of runs leveling out at different rates, and are reported.
+func TestQuantileSlopes(t *testing.T) {
+	defer func(taus []float64) { QuantileSlopes = taus }(QuantileSlopes)
+	QuantileSlopes = []float64{0.1, 0.5, 0.9}
+	var runs [][]float64
+	for _, rate := range []float64{-1, -0.5, -0.1} {
+		var sds []float64
+		for k := 0; k <= 10; k++ {
+			sds = append(sds, math.Exp(rate*float64(k)))
+		}
+		runs = append(runs, sds)
+	}
+	slopes := quantileSlopes(runs)
+	for i, want := range []float64{-1, -0.5, -0.1} {
+		if math.Abs(slopes[i]-want) > 1e-9 {
+			t.Errorf("slopes %v, want [-1 -0.5 -0.1]", slopes)
+			break
+		}
+	}
+	var buf bytes.Buffer
+	Reporter{&buf}.Gradients(3, []RegimeGradients{{uniform, -0.5, 0.45, slopes}})
+	if !strings.Contains(buf.String(), "\t\t\tQuantile slopes of log SD\n               \t\t   q0.1\t\t   q0.5\t\t   q0.9\n"+
+		"uniform        \t\t-1.000000\t\t-0.500000\t\t-0.100000\n") {
+		t.Errorf("report:\n%s", buf.String())
+	}
+}
+
+func TestCheckQuantileSlopes(t *testing.T) {
+	defer func(taus []float64, stream bool) { QuantileSlopes, StreamResults = taus, stream }(QuantileSlopes, StreamResults)
+	for _, bad := range []struct {
+		taus   []float64
+		stream bool
+	}{{[]float64{0}, false}, {[]float64{0.5, 1}, false}, {[]float64{math.NaN()}, false}, {[]float64{0.5}, true}} {
+		QuantileSlopes, StreamResults = bad.taus, bad.stream
+		if checkQuantileSlopes() == nil {
+			t.Errorf("accepted %+v", bad)
+		}
+	}
+}
@@ -17,20 +17,145 @@ package main
 import (
 	"fmt"
 	"github.com/GaryBoone/GoStats/stats"
-	"github.com/gonum/matrix/mat64"
-	"github.com/oleiade/lane"
+	"log"
 	"math"
 	"math/rand"
+	"runtime"
 	"sort"
+	"strings"
 	"time"
 )
 
 /* Choices */
 var NumRuns = 6
 var NumTurns = 20
-var activationType = inversePoisson
-var Pop Population
 var NumOfAgents = 1000
+var Workers = runtime.NumCPU()              // (regime, run) cells simulated concurrently
+var ParallelThreshold = 1 << 16             // pairs per turn above which their exchanges are split across Workers
+var BatchExchange = true                    // if true, uniform turns level all pairs at once with array operations when the rule allows
+var EventSampling = "waiting"               // how Poisact generates events: "waiting" times, or Poisson "counts" then uniform times
+var TurnLength = 1.0                        // simulated time a turn covers, over which poisson regimes draw events (see timescale.go)
+var ParallelSortThreshold = 1 << 18         // events per turn above which Poisact sorts them in parallel shards
+var RemoteWorkers = []string{}              // "host:port" of worker processes; if set, experiment cells run there
+var RemoteSlots = 2                         // cells each remote worker is sent at once
+var RemoteTimeout = 30 * time.Minute        // longest a remote cell may take
+var RNG = "math/rand"                       // random number generator: "math/rand", "pcg" or "xoshiro"
+var CompareRegimes = false                  // if true, all regimes in a run start from the same population and seed
+var NeighborhoodRadius = 5                  // neighbors on each side of the ring, for local poisson
+var RegionActivations = []ActivationOrder{} // if non-empty, run one World with a region per entry instead
+var MigrationRate = 0.01                    // per-agent, per-turn probability of leaving a region
+var RegionRules = []string{}                // if set, each region's exchange rule, by name, in the order of RegionActivations
+var CouplingRate = 0.0                      // share of its wealth each region sends the others every turn, shared equally among their agents
+var Homophily = 0.0                         // probability a pairing is restricted to the same wealth quantile
+var HomophilyQuantiles = 5
+var Districts = 0 // if > 0, agents live in districts and mostly level locally
+var DistrictsPerRegion = 5
+var CrossDistrict = 0.1       // probability a pairing may leave the district, but not the region
+var CrossRegion = 0.01        // probability a pairing may go anywhere
+var EdgeListFile = ""         // if set, every regime runs on this (optionally weighted) network
+var TemporalEdgeListFile = "" // if set, like EdgeListFile but each edge is "i j first-turn last-turn [weight]"
+var DirectedEdges = false     // if true, EdgeListFile edges point from the first agent to the second
+var DirectedExchange = ""     // rule along directed edges: "tribute" or "remittance"; undirected pairs use Rule
+var DirectedRate = 0.1        // tribute rate, or remittance fraction
+var NetworkPairing = false    // if true, agents only pair with network neighbors, weighted by edge
+var BipartiteMarket = false   // if true, agents are buyers or sellers and only trade across classes
+var SellerShare = 0.5
+var ClassRules = []string{}        // with BipartiteMarket, buyers' then sellers' exchange rules, by name; a class with none or "" uses RuleName
+var CoordinatesFile = ""           // if set, agents are placed at the sites in this CSV (x, y[, region])
+var DistanceDecay = "exponential"  // how pairing weight falls with distance: "exponential" or "power" (see geo.go)
+var DecayScale = 100.0             // distance at which exponential pairing weight falls by a factor of e
+var DecayExponent = 2.0            // exponent of power-law pairing weight
+var MobilityPlaces = 0             // if > 0, agents wander a ring of this many places and only meet co-located agents
+var MobilityRate = 0.1             // per-agent, per-turn probability of moving to a neighboring place
+var LambdaReference = "global"     // mean poisson lambdas are measured against: "global", "neighborhood" or "group"
+var NeighborhoodLeveling = false   // if true, activated agents level their whole network neighborhood at once
+var NetworkStatsSources = 50       // BFS sources sampled for the per-run average path length
+var NetworkSnapshotTurns = []int{} // turns at which to export the network, if the regime uses one
+var NetworkSnapshotFormat = "dot"  // or "gexf"
+var LargeScale = false             // if true, run in million-agent mode: one cell at a time, with a memory estimate and progress
+var ReportTimings = false          // if true, report how long each phase of the turns took
+var StreamResults = false          // if true, fold each run into running summaries instead of keeping every trajectory
+var RawRowsFile = ""               // with StreamResults, also write each run's SDs to this CSV file
+var DistributionsFile = ""         // if set, write each regime's final Gini, final SD and gradient over its runs there, with densities for violin plots (see distributions.go)
+var QuantileSlopes = []float64{}   // if set, also fit these quantiles of log wealth SD against time over each regime's runs, e.g. {0.1, 0.5, 0.9} (see quantreg.go)
+var Metrics = []string{}           // per-turn metrics to write out: "gini", "quantiles", "entropy", "histogram", "population", "districts"
+var MetricWorkers = 4              // goroutines sharing each turn's metrics
+var HistogramBins = 10             // equal-width bins of the "histogram" metric, and of HistogramsFile's histograms
+var HistogramsFile = ""            // if set, write every turn's wealth histogram there, as JSON if it ends in .json and CSV otherwise (-histograms, see histograms.go)
+var HistogramRuns = 0              // if > 0, only the first this many runs of each regime get histograms in HistogramsFile
+var MetricSample = 0               // if > 0, estimate metrics from a sample of this many agents each turn
+var SkipEqualized = true           // if true, stop simulating a run once its wealth SD is within EqualizedTolerance
+var EqualizedTolerance = 0.0       // above 0, skipped turns only approximately repeat the last turn
+var Precision = "float64"          // or "compensated": Neumaier summation for the wealth mean and SD
+var AuditPrecision = false         // if true, report how far each run's total wealth drifted, summed exactly
+var ReportMemory = false           // if true, report the peak heap and RSS after each run
+var MemoryLimit int64 = 0          // soft limit in bytes; if > 0, the experiment scales itself down to stay under it
+var DashboardAddr = ""             // if set, e.g. "localhost:8000", serve a live dashboard of the experiment there (-dashboard)
+var TUI = false                    // if true, draw the experiment's progress in the terminal as it runs (-tui)
+var PlotsDir = ""                  // if set, save every run's trajectory there and plot them (-plots)
+var PlotFormat = "png"             // or "svg" (-plot)
+var LorenzEveryTurn = false        // if true, record every turn's Lorenz curves in lorenz.csv, not just the first, middle and last's
+var AnimateRun = 0                 // if > 0, write that run of each regime as an animated GIF of its wealth histogram (-animate)
+var AnimationBins = 40             // bins of the animated histogram
+var RuleName = "leveler"           // exchange rule, built in or registered (see registry.go)
+var Activations = []string{}       // regimes to run, built in or registered; if empty, the six built in
+var Plugins = []string{}           // Go plugins to load rules and metrics from
+var RuleScript = ""                // if set, Starlark defining exchange(a, b), as the rule "script" (see script.go)
+var LambdaScript = ""              // if set, Starlark defining lam(wealth, mean, spread), the poisson regime's rate
+var FlightAddr = ""                // if set, e.g. "localhost:8815", serve the results as Arrow tables over Arrow Flight there (-flight)
+var WebhookURL = ""                // if set, POST a JSON event there when the experiment completes (-webhook, see hooks.go)
+var HookCommand = ""               // if set, a shell command run with the same event on its standard input (-hook)
+var HookEveryRun = false           // if true, fire the hooks as each run completes, too
+var MesaFile = ""                  // if set, also write every turn to this CSV file as Mesa's batch_run would (-mesa, see mesa.go)
+var SDFile = ""                    // if set, also write every turn's mean wealth and SD to this CSV file, one row per run and turn (-csv, see sdcsv.go)
+var InitialWealthFile = ""         // if set, agents start with the wealth in this CSV file rather than 1..N (see initial.go)
+var InitialWealth = "linear"       // how initial wealth is drawn when there's no InitialWealthFile: "linear" (1..N), "bootstrap", "pareto", "lognormal", "uniform", "exponential" or "equal"
+var WealthParams = []float64{}     // parameters of InitialWealth's distribution (see initial.go)
+var WealthDataFile = ""            // empirical wealth values, in CSV, for "bootstrap" to sample from
+var InitialTotal = 0.0             // if > 0, rescale initial wealth to total this
+var InitialMean = 0.0              // if > 0, rescale initial wealth to average this
+var ActivationHistory = false      // if true, write how often and when each agent is activated every turn to activations_<regime>_run<N>.csv (see history.go)
+var ReplicationTrace = false       // if true, write each poisson run's rates, activations and event order every turn to trace_<regime>_run<N>.csv (see trace.go)
+var TrackAgents = []string{}       // agents whose wealth to write out every turn: IDs, "richest", "poorest" or "median" (see tracking.go)
+var Cohorts = 0                    // if > 0, tag agents by their quantile of initial wealth, into this many cohorts (see cohorts.go)
+var CohortFile = ""                // if set, tag agents with the cohorts in this CSV file instead
+var CohortMetrics = false          // if true, write every turn's inequality by cohort, and decomposed within and between them
+var AgentTypes = []string{}        // if set, the population's mix of agent types, e.g. {"hoarder:0.2", "leveler:0.8"} (see types.go)
+var HoarderRefusal = 0.5           // probability a hoarder refuses an exchange that would leave it poorer
+var LearnerRefusal = 0.5           // probability a learner starts out refusing an exchange that would leave it poorer
+var LearningRate = 0.1             // how far a learner's refusal probability moves after a turn it gains or loses wealth
+var YardSaleFraction = 0.1         // share of the poorer agent's wealth at stake in a "yardsale" exchange
+var RiskAversion = ""              // if set, how agents' risk aversion is drawn: "equal", "uniform" or "beta" (see risk.go)
+var RiskParams = []float64{}       // parameters of RiskAversion's distribution
+var BirthRate = 0.0                // per-turn probability an agent has a child (see demography.go)
+var ExitRate = 0.0                 // per-turn probability an agent exits
+var BirthWealth = "mean"           // a child's wealth: "mean" for the population's, or drawn as InitialWealth is
+var BirthParams = []float64{}      // parameters of BirthWealth's distribution
+var ExitWealth = "remove"          // what becomes of an exiting agent's wealth: "remove" or "redistribute"
+var RemainderTo = "poorer"         // who gets the unit left over when a pair's total is odd, under the "conserving" rule: "poorer", "richer" or "random"
+var LevelingFraction = 0.5         // how far each agent moves toward the pair's average under the "partial" rule
+var TransferFraction = 0.1         // share of the richer agent's wealth it hands the poorer under the "proportional" rule
+var ReloadFile = ""                // if set, a JSON object of live settings to re-read on SIGHUP (-reload, see live.go)
+var ControlAddr = ""               // if set, e.g. "localhost:7000", take live settings over a control socket there (-control)
+var SnapshotEvery = 0              // if > 0, the repl's Models keep a copy of themselves every this many turns, to rewind to (see snapshot.go)
+var SnapshotKeep = 10              // how many of those copies to keep, the latest
+var EventCondition = ""            // if "unchanged", a poisson event is skipped if its agent's wealth changed since it was scheduled (see schedule.go)
+var DeferAfterLoss = 0.0           // if > 0, an agent losing more than this share of its wealth in an exchange puts off the rest of its turn's events
+var DeferDelay = 0.25              // how much of a turn those events are put off by; any past its end are cancelled
+var Interventions = []string{}     // changes every run makes before given turns, e.g. {"15:confiscate:0.01"} (see intervene.go)
+var SaveFinalState = false         // if true, write each run's final population to state_<regime>_run<N>.csv (see warmstart.go)
+var WarmStartFile = ""             // if set, every run starts from the population saved in this file instead
+var AuditDraws = false             // if true, log every random draw of each run, and what for, to draws_<regime>_run<N>.txt (see audit.go)
+
+var edgeList *Network // loaded from EdgeListFile
+var temporalEdges *TemporalNetwork
+var sites []Site            // loaded from CoordinatesFile
+var initialWealth []float64 // loaded from InitialWealthFile
+var warmState *Population   // loaded from WarmStartFile
+var wealthData []float64    // loaded from WealthDataFile
+var cohortTags []int        // loaded from CohortFile
+
+var scriptedLambda func(wealth, mean, spread float64) float64 // compiled from LambdaScript
 
 /* activation types */
 type ActivationOrder int
@@ -41,6 +166,7 @@ const (
 	poisson
 	inversePoisson
 	naturalPoisson
+	localPoisson
 )
 
 func (act ActivationOrder) String() string {
@@ -55,37 +181,183 @@ func (act ActivationOrder) String() string {
 		s = "inverse poisson"
 	} else if act == naturalPoisson {
 		s = "natural poisson"
+	} else if act == localPoisson {
+		s = "local poisson"
+	} else if c := act.custom(); c != nil {
+		s = c.name
 	}
 	return s
 }
 
+// ParseActivation returns the activation type with the given name, as
+// printed by String.
+func ParseActivation(name string) (ActivationOrder, error) {
+	for act := uniform; act <= lastActivation(); act++ {
+		if act.String() == name {
+			return act, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown activation %q", name)
+}
+
 /* "Classes" */
 
 /*
  * The Python code declares an Agent class, with constructor that sets the
  * wealth parameter. It then initializes Pop as an array of Agents. I instead
- * make Agent a struct and Population a slice of Agents, then create the Populate()
- * function to initialize the agents and set their wealths unequally.
+ * make Agent a struct and Population a set of parallel slices (see below), then
+ * create the Populate() function to initialize the agents and set their
+ * wealths unequally.
  */
 type Agent struct {
-	wealth float64
-	lam    float64
+	id       int // stable identity, kept wherever the agent goes (see tracking.go)
+	tag      int // fixed group membership
+	quantile int // wealth quantile at the start of the turn, if the Model tracks them
+	district int
+	node     int     // position in the Model's network, if it has one
+	class    int     // buyer or seller, in a Market
+	kind     int     // agent type, an index into the Model's Types
+	refusal  float64 // own probability of refusing an exchange, if its type learns
+	seen     float64 // wealth when it last learned
+	risk     float64 // share of its wealth it won't stake in a StakeRule's exchanges
+	x, y     float64
+	place    int // current location, under Mobility
+
+	activations int // exchanges taken part in since the run started
+}
+
+/*
+ * A Population is laid out as a structure of arrays: the wealth and lambda
+ * that every turn's hot loops sweep over sit in contiguous slices, and the
+ * rest of each agent's state lives in Agents. Agent i is Wealth[i], Lam[i]
+ * and Agents[i]; anything that needs to name an agent uses its index.
+ */
+type Population struct {
+	Wealth []float64
+	Lam    []float64
+	Agents []Agent
+}
+
+// NewPopulation returns a Population of n agents with no wealth.
+func NewPopulation(n int) Population {
+	return Population{
+		Wealth: make([]float64, n),
+		Lam:    make([]float64, n),
+		Agents: make([]Agent, n),
+	}
+}
+
+// Len returns the number of agents in the Population.
+func (p Population) Len() int {
+	return len(p.Wealth)
+}
+
+// Add appends agent i of q to p.
+func (p *Population) Add(q Population, i int) {
+	p.Wealth = append(p.Wealth, q.Wealth[i])
+	p.Lam = append(p.Lam, q.Lam[i])
+	p.Agents = append(p.Agents, q.Agents[i])
+}
+
+// Append appends all of q's agents to p.
+func (p *Population) Append(q Population) {
+	p.Wealth = append(p.Wealth, q.Wealth...)
+	p.Lam = append(p.Lam, q.Lam...)
+	p.Agents = append(p.Agents, q.Agents...)
+}
+
+// Find returns the index of the agent with the given ID, or -1 if there is
+// none.
+func (p Population) Find(id int) int {
+	for i := range p.Agents {
+		if p.Agents[i].id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Copy returns a copy of p that shares no storage with it.
+func (p Population) Copy() Population {
+	var c Population
+	c.Append(p)
+	return c
 }
 
-type Population []Agent
+/*
+ * A Model bundles a Population with the activation regime and exchange rule
+ * that act on it. Everything a turn touches hangs off the Model, so several
+ * of them (e.g. the regions of a World) can coexist in one process.
+ */
+type Model struct {
+	Pop        Population
+	Activation ActivationOrder
+	Rule       Rule
+	GroupRule  GroupRule // if set, activated agents apply it to their neighborhoods instead of Rule
+
+	DirectedRule DirectedRule // if set, pairs joined by a directed edge use it instead of Rule
+
+	Net       *Network // needed by local poisson, network pairing and neighborhood references
+	Reference ReferenceStat
+	Lambda    func(wealth, mean, spread float64) float64 // if set, gives the poisson regime's rates
+
+	NetworkPairing bool             // if true, partners are network neighbors chosen by edge weight
+	Temporal       *TemporalNetwork // if set, Net is replaced by the turn's snapshot every Step
+
+	Homophily float64    // probability that a pairing stays within the activated agent's group
+	Quantiles int        // if > 0, group agents by wealth quantile rather than by tag
+	Hierarchy *Hierarchy // if set, pairings mostly stay within districts
+	Market    *Market    // if set, pairings only cross between buyers and sellers
+	Geography *Geography // if set, partners are weighted by distance
+	Mobility  *Mobility  // if set, agents move between places and only meet co-located agents
+	Types     *TypeMix   // if set, agents of different types apply their own rules and may refuse
+
+	Demography *Demography // if set, agents are born and exit as the run goes on
+	Snapshots  *Snapshots  // if set, copies of the Model kept to rewind to
+	Schedule   *Schedule   // if set, poisson turns' events are dispatched conditionally
+
+	Interventions []Intervention // changes to make before given turns
+
+	Turn     int        // turns completed
+	Times    PhaseTimes // time spent in each phase of those turns
+	rng      *rand.Rand // all of the Model's random draws come from here,
+	statsRng *rand.Rand // except those that only feed statistics, if set
+	coinSeed uint64     // decides the outcomes of StakeRules' exchanges, if they're used
+	audit    *drawAudit // logs rng's draws, with AuditDraws
+
+	lastLap time.Time
+
+	queue   EventQueue // Poisact's events as they're drawn, reused from turn to turn
+	aTimes  events     // those the turn keeps, in order of time, likewise
+	order   []int      // the turn's pairs of agent indices, likewise
+	sortBuf events     // scratch space for putting the queue in order in parallel
+	batchA  []float64
+	batchB  []float64 // the wealth of each side of the turn's pairs, for batch exchange
+	pairing PairingEngine
+	firings []firing // the last poisson turn's events, with ActivationHistory or ReplicationTrace
+}
 
+// An event is one activation of one agent. Agents are named by their index,
+// so events don't depend on the Population's layout and can be written out
+// as they are.
 type event struct {
 	time  float64
-	agent *Agent
+	agent int32 // index into Pop
 }
 type events []event
 
-//implement sort.Interface
+// before reports whether e comes before f. Ties go by agent, so every sort
+// agrees.
+func (e event) before(f event) bool {
+	return e.time < f.time || (e.time == f.time && e.agent < f.agent)
+}
+
+// implement sort.Interface
 func (e events) Len() int {
 	return len(e)
 }
 func (e events) Less(i, j int) bool {
-	return e[i].time < e[j].time
+	return e[i].before(e[j])
 }
 func (e events) Swap(i, j int) {
 	e[i], e[j] = e[j], e[i]
@@ -93,50 +365,281 @@ func (e events) Swap(i, j int) {
 
 /* Model Creation */
 
-// Populate initializes the agent population.
+// Populate initializes the agent population, with wealth 1..N or from
+// InitialWealthFile, or else as WarmStartFile left it.
 func Populate() Population {
-	Pop := make(Population, NumOfAgents)
+	if warmState != nil {
+		return warmState.Copy()
+	}
+	Pop := NewPopulation(NumOfAgents)
+	for i := range Pop.Agents {
+		Pop.Agents[i].id = i
+	}
+	if initialWealth != nil {
+		if len(initialWealth) != NumOfAgents {
+			log.Fatalf("InitialWealthFile has %d agents, not %d", len(initialWealth), NumOfAgents)
+		}
+		copy(Pop.Wealth, initialWealth)
+		return Pop
+	}
 	for i := 0; i < NumOfAgents; i++ {
-		Pop[i].wealth = float64(i + 1)
+		Pop.Wealth[i] = float64(i + 1)
 	}
 	return Pop
 }
 
+// NewModel returns a freshly populated Model using the rule named by
+// RuleName and drawing its random numbers from rng.
+func NewModel(act ActivationOrder, rng *rand.Rand) *Model {
+	m := &Model{Pop: Populate(), Activation: act, Rule: newRule(),
+		Homophily: Homophily, Quantiles: HomophilyQuantiles, Lambda: scriptedLambda, rng: rng,
+		pairing: PairingEngine{Workers: Workers, Threshold: ParallelThreshold}}
+	endow(m.Pop, rng)
+	if Districts > 0 {
+		m.Hierarchy = &Hierarchy{Districts: Districts, DistrictsPerRegion: DistrictsPerRegion,
+			CrossDistrict: CrossDistrict, CrossRegion: CrossRegion}
+		m.Hierarchy.Assign(m.Pop, rng)
+	}
+	if temporalEdges != nil {
+		m.Temporal = temporalEdges
+		m.SetNetwork(temporalEdges.At(1))
+	} else if edgeList != nil {
+		m.SetNetwork(edgeList.Clone())
+	} else if act == localPoisson || NetworkPairing || LambdaReference == "neighborhood" || NeighborhoodLeveling {
+		m.SetNetwork(RingLattice(m.Pop.Len(), NeighborhoodRadius, rng))
+	}
+	m.NetworkPairing = NetworkPairing
+	if NeighborhoodLeveling {
+		m.GroupRule = NeighborhoodLeveler{}
+	}
+	if DirectedExchange == "tribute" {
+		m.DirectedRule = Tribute{Rate: DirectedRate}
+	} else if DirectedExchange == "remittance" {
+		m.DirectedRule = Remittance{Fraction: DirectedRate}
+	}
+	if LambdaReference == "neighborhood" {
+		m.Reference = &NeighborhoodMean{Net: m.Net}
+	} else if LambdaReference == "group" {
+		m.Reference = &GroupMean{}
+	} else {
+		m.Reference = &GlobalMean{}
+	}
+	if sites != nil {
+		PlaceAgents(m.Pop, sites)
+		decay, err := distanceDecay()
+		if err != nil {
+			log.Fatal(err)
+		}
+		m.Geography = &Geography{Decay: decay}
+	}
+	tagCohorts(m.Pop)
+	if MobilityPlaces > 0 {
+		m.Mobility = &Mobility{Places: RingLattice(MobilityPlaces, 1, rng), Rate: MobilityRate}
+		m.Mobility.Scatter(m.Pop, rng)
+	}
+	if BipartiteMarket {
+		m.Market = newMarket()
+		m.Market.Assign(m.Pop, rng)
+	}
+	if len(AgentTypes) > 0 {
+		types, err := newAgentTypes(AgentTypes)
+		if err != nil {
+			log.Fatal(err)
+		}
+		m.Types = types
+		m.Types.Assign(m.Pop, rng)
+	}
+	m.setStakes(rng)
+	if warmState != nil {
+		m.restoreState()
+	}
+	m.Demography = newDemography(m.Pop)
+	m.Schedule = newSchedule()
+	if len(Interventions) > 0 {
+		ivs, err := newInterventions(Interventions)
+		if err != nil {
+			log.Fatal(err)
+		}
+		m.Interventions = ivs
+	}
+	return m
+}
+
+// SetNetwork places the Model's agents on the nodes of net, agent i at node i.
+func (m *Model) SetNetwork(net *Network) {
+	m.Net = net
+	if ref, ok := m.Reference.(*NeighborhoodMean); ok {
+		ref.Net = net
+	}
+	for i := 0; i < m.Pop.Len(); i++ {
+		m.Pop.Agents[i].node = i
+	}
+}
+
+// Clone returns a copy of the Model that can be stepped independently: the
+// population, network and reference statistic are copied, while read-only
+// configuration (rules, hierarchy, market, geography, mobility graph and
+// temporal edges) is shared. The clone shares the RNG until it is given its own.
+func (m *Model) Clone() *Model {
+	c := *m
+	c.Pop = m.Pop.Copy()
+	c.queue, c.aTimes, c.order, c.sortBuf = EventQueue{}, nil, nil, nil
+	c.batchA, c.batchB, c.firings = nil, nil, nil
+	c.pairing = PairingEngine{Workers: m.pairing.Workers, Threshold: m.pairing.Threshold}
+	if m.Net != nil {
+		c.Net = m.Net.Clone()
+	}
+	if m.Types != nil {
+		c.Types = m.Types.clone()
+	}
+	if m.Demography != nil {
+		c.Demography = m.Demography.clone()
+	}
+	if m.Schedule != nil {
+		c.Schedule = m.Schedule.clone()
+	}
+	switch m.Reference.(type) {
+	case *NeighborhoodMean:
+		c.Reference = &NeighborhoodMean{Net: c.Net}
+	case *GroupMean:
+		c.Reference = &GroupMean{}
+	case *GlobalMean:
+		c.Reference = &GlobalMean{}
+	}
+	return &c
+}
+
 /* Model Methods */
 
-// Asdw returns the mean and standard deviation of Population wealth.
+// Asdw returns the mean and standard deviation of Population wealth. It reads
+// the Wealth slice in place and allocates nothing. With Precision
+// "compensated", it sums with compensation (see precision.go).
 func Asdw(Pop Population) (mean, std float64) {
-	bals := make([]float64, 0)
-	for i := 0; i < len(Pop); i++ {
-		bals = append(bals, Pop[i].wealth)
+	if Precision == "compensated" {
+		return compensatedMeanSD(Pop.Wealth)
+	}
+	return stats.StatsMean(Pop.Wealth), stats.StatsSampleStandardDeviation(Pop.Wealth)
+}
+
+// Gini returns the Gini coefficient of Population wealth, from 0 when every
+// agent holds the same to nearly 1 when one holds it all. Unlike the plots,
+// it uses every agent rather than a sample.
+func Gini(Pop Population) float64 {
+	sorted := append([]float64(nil), Pop.Wealth...)
+	sort.Float64s(sorted)
+	total := 0.0
+	for _, w := range sorted {
+		total += w
 	}
-	return stats.StatsMean(bals), stats.StatsSampleStandardDeviation(bals)
+	return gini(sorted, total)
 }
 
 // Proc conducts a pairwise reset of wealth.
-func Proc(a, b *Agent) { //should be pointers here, yes?
-	averg := math.Floor((a.wealth + b.wealth) / 2) // simulate integer divsion
-	b.wealth = averg
-	a.wealth = averg
+func Proc(a, b *float64) { //should be pointers here, yes?
+	averg := math.Floor((*a + *b) / 2) // simulate integer divsion
+	*b = averg
+	*a = averg
+}
+
+// exchange applies the Model's rule to the activated pair of agents a and b
+// or, with a GroupRule, to each of their neighborhoods.
+func (m *Model) exchange(a, b int) {
+	if m.GroupRule != nil {
+		m.levelNeighborhood(a)
+		m.levelNeighborhood(b)
+		return
+	}
+	alpha, beta := &m.Pop.Agents[a], &m.Pop.Agents[b]
+	alpha.activations++
+	beta.activations++
+	wa, wb := &m.Pop.Wealth[a], &m.Pop.Wealth[b]
+	if m.DirectedRule != nil && m.Net != nil {
+		if d := m.Net.Direction(alpha.node, beta.node); d != Undirected {
+			m.DirectedRule.ApplyDirected(wa, wb, d)
+			return
+		}
+	}
+	rule := m.Rule
+	if m.Market != nil && m.Market.Rules[alpha.class] != nil {
+		rule = m.Market.Rules[alpha.class]
+	}
+	if m.Types != nil {
+		m.Types.exchange(m, rule, alpha, beta, wa, wb)
+		return
+	}
+	m.apply(rule, alpha, beta, wa, wb)
+}
+
+// levelNeighborhood applies the GroupRule to agent a and its network neighbors.
+func (m *Model) levelNeighborhood(a int) {
+	group := []*float64{&m.Pop.Wealth[a]}
+	m.Pop.Agents[a].activations++
+	for _, j := range m.Net.Neighbors(m.Pop.Agents[a].node) {
+		group = append(group, &m.Pop.Wealth[j])
+		m.Pop.Agents[j].activations++
+	}
+	m.GroupRule.ApplyAll(group)
 }
 
 // Randmact randomly selects a Population's worth in pairs and levels.
-func Randmact() {
-	for i := 0; i < NumOfAgents/2; i++ {
-		Proc(&Pop[rand.Intn(NumOfAgents)], &Pop[rand.Intn(NumOfAgents)])
+func (m *Model) Randmact() {
+	m.mark()
+	if !m.constrained() {
+		m.drawing("partner")
+		pairs := randomPairs(m.order[:0], m.Pop.Len(), m.rng)
+		m.order = pairs
+		m.lap(phasePairing)
+		m.exchangePairs(pairs)
+		m.lap(phaseExchange)
+		return
+	}
+	defer m.lap(phaseExchange)
+	for i := 0; i < m.Pop.Len()/2; i++ {
+		m.drawing("activation")
+		alpha := m.rng.Intn(m.Pop.Len())
+		if beta := m.randomPartner(alpha); beta >= 0 {
+			m.exchange(alpha, beta)
+		}
 	}
 }
 
 // Unifact randomly selects a Population's worth in pairs and levels.
-func Unifact() {
-	turnList := make([]*Agent, len(Pop))
+func (m *Model) Unifact() {
+	m.mark()
+	if m.constrained() {
+		m.unifactConstrained()
+		m.lap(phaseExchange)
+		return
+	}
+	order := m.order[:0]
+	for i := 0; i < m.Pop.Len(); i++ {
+		order = append(order, i)
+	}
+	m.order = order
+	n := len(order)
+	m.drawing("permutation")
+	shufflePairs(order, m.rng)
+	m.lap(phasePairing)
+	if r := m.batchRule(); r != nil {
+		m.exchangeBatch(r, order[:n-n%2])
+	} else {
+		m.exchangePairs(order[:n-n%2])
+	}
+	m.lap(phaseExchange)
+}
+
+// unifactConstrained is Unifact for Models with partner restrictions, where
+// each partner has to be searched for among the agents still waiting.
+func (m *Model) unifactConstrained() {
+	turnList := make([]int, m.Pop.Len())
 	//	copy(turnList, Pop)
 	for i := 0; i < len(turnList); i++ {
-		turnList[i] = &Pop[i]
+		turnList[i] = i
 	}
-	for i := 0; i < NumOfAgents/2; i++ {
+	for i := 0; i < m.Pop.Len()/2; i++ {
 
-		x := rand.Intn(len(turnList))
+		m.drawing("activation")
+		x := m.rng.Intn(len(turnList))
 		alpha := turnList[x]
 
 		if x < len(turnList)-1 {
@@ -145,7 +648,13 @@ func Unifact() {
 			turnList = turnList[:x]
 		}
 
-		x = rand.Intn(len(turnList))
+		x = m.partnerIndex(alpha, turnList)
+		if x < 0 { // nobody left that alpha can pair with
+			if len(turnList) < 2 {
+				break
+			}
+			continue
+		}
 		beta := turnList[x]
 
 		if x < len(turnList)-1 {
@@ -153,7 +662,7 @@ func Unifact() {
 		} else {
 			turnList = turnList[:x]
 		}
-		Proc(alpha, beta)
+		m.exchange(alpha, beta)
 
 		if len(turnList) < 2 {
 			break
@@ -162,176 +671,225 @@ func Unifact() {
 	}
 }
 
-// Poisact activates a Pop's worth in pairs chosen based on Poisson activation probabilities.
-func Poisact() {
-	// make activation rate inversely proportional to distance from mean
-	mnw, _ := Asdw(Pop) //mean wealth, sd of wealth
-	totd := 0.0         // total distance from mean
-	var denom float64
+// setRates sets each agent's poisson rate, lam[i], to rate of its wealth,
+// the mean ref gives it and the agents' total distance from theirs.
+func setRates(lam, wealth []float64, ref ReferenceStat, rate func(wealth, mean, spread float64) float64) {
+	totd := 0.0 // total distance from mean
+	for i := range wealth {
+		totd += math.Abs(wealth[i] - ref.Mean(i))
+	}
+	for i := range wealth {
+		lam[i] = rate(wealth[i], ref.Mean(i), totd)
+	}
+}
 
-	// first calculate total distance from mean of all agents
-	for i := 0; i < len(Pop); i++ {
-		dist := math.Abs(Pop[i].wealth - mnw)
-		totd += dist
+// PoissonRate is the poisson regime's rate: the nearer the mean, the
+// slower. If everyone is at the mean, Normalize evens the rates out.
+func PoissonRate(wealth, mean, spread float64) float64 {
+	if spread == 0 {
+		return 0
 	}
+	return math.Abs(wealth-mean) / spread
+}
 
-	// then set lambdas based on distance
-	for i := 0; i < len(Pop); i++ {
-		if activationType == inversePoisson { //rich activate faster
-			denom = math.Abs(Pop[i].wealth - mnw)
-			if denom == 0 {
-				denom = 0.0001
-			}
-			Pop[i].lam = totd / denom
-		} else if activationType == naturalPoisson { // poor activate faster
-			denom = Pop[i].wealth
-			if denom == 0 {
-				denom = 0.0001
-			}
-			Pop[i].lam = 1 / denom
-		} else {
-			//lambda is proportional to dist from mean;
-			// those closer are activated slower
-			Pop[i].lam = math.Abs(Pop[i].wealth-mnw) / totd
-			//fmt.Println(Pop[i].lam)
-		}
+// InversePoissonRate is the inverse poisson regime's rate: the nearer the
+// mean, the faster.
+func InversePoissonRate(wealth, mean, spread float64) float64 {
+	denom := math.Abs(wealth - mean)
+	if denom == 0 {
+		denom = 0.0001
+	}
+	return spread / denom
+}
+
+// NaturalPoissonRate is the natural poisson regime's rate: the poorer, the
+// faster.
+func NaturalPoissonRate(wealth, mean, spread float64) float64 {
+	denom := wealth
+	if denom == 0 {
+		denom = 0.0001
 	}
+	return 1 / denom
+}
+
+// Poisact activates a Pop's worth in pairs chosen based on Poisson
+// activation probabilities, at the rates its regime's Scheduler sets.
+func (m *Model) Poisact() {
+	m.mark()
+	n := m.Pop.Len()
+	lam := m.Pop.Lam
+	builtinSchedulers[m.Activation].(rater).rates(m, lam)
 
 	// make average lambda = 1
-	Normalize()
+	m.Normalize()
+	m.lap(phaseLambda)
 
 	// KC: Based on lambda rates, create a list of activations for this turn,
-	// an array that will contain time, agent tuples. I will eventually sort this on times
+	// time, agent pairs, queued in order of time
 
-	aTimes := make(events, 0) // trying an array of structs instead of an array of tuples
-
-	for i := 0; i < len(Pop); i++ {
-		// find the agent's first activation time
-		nextT := -1 * math.Log(rand.Float64()) / Pop[i].lam
-		for nextT < 1.0 {
-			// will only put the even on the scheduler if it's less than 1
-			aTimes = append(aTimes, event{time: nextT, agent: &Pop[i]})
-			nextT += -1 * math.Log(rand.Float64()) / Pop[i].lam
-		}
+	q := &m.queue
+	if q.h.events == nil {
+		q.h.events = getEvents(expectedEvents(n))
 	}
-
-	sort.Sort(aTimes)
-	if len(aTimes)%2 > 0 { // make sure list is even
-		aTimes = aTimes[:len(aTimes)-1] // Pop
+	q.Reset()
+	m.drawing("activation")
+	drawEvents(q, lam, m.rng)
+	m.lap(phaseEvents)
+	m.sortBuf = q.heapify(m.sortBuf)
+	kept := keptEvents(q, n)
+	if m.aTimes == nil {
+		m.aTimes = getEvents(turnEvents(n))
 	}
-	if len(aTimes) > len(Pop) {
-		// truncate list to Population size
-		aTimes = aTimes[:len(Pop)] // -1?
+	aTimes := m.aTimes[:0]
+	for len(aTimes) < kept {
+		t, i := q.PopNext()
+		aTimes = append(aTimes, event{time: t, agent: int32(i)})
 	}
-
-	arr0 := lane.NewDeque()
-	for i := 0; i < len(aTimes); i++ {
-		arr0.Append(aTimes[i])
+	m.aTimes = aTimes // keep the (possibly grown) buffer for next turn
+	m.lap(phaseSort)
+	if ActivationHistory || ReplicationTrace {
+		m.fire(aTimes)
 	}
 
-	half := int(len(aTimes) / 2) // iterate pairwise
-	for j := 0; j < half; j++ {
-		if arr0.Size() < 2 {
-			break
-		}
-		alpha := arr0.Shift().(event)
-		beta := arr0.Shift().(event)
+	if m.Schedule != nil {
+		m.Schedule.dispatch(m, aTimes)
+		m.lap(phaseExchange)
+		return
+	} else if m.constrained() {
+		m.pairEvents(aTimes)
+		m.lap(phaseExchange)
+		return
+	}
 
-		Proc(alpha.agent, beta.agent)
+	// pair off consecutive events
+	pairs := m.order[:0]
+	for j := 0; j+1 < len(aTimes); j += 2 {
+		pairs = append(pairs, int(aTimes[j].agent), int(aTimes[j+1].agent))
 	}
+	m.order = pairs
+	m.lap(phasePairing)
+	m.exchangePairs(pairs)
+	m.lap(phaseExchange)
 }
 
 // Normalize sets one turn's worth of lambda rates.
-func Normalize() {
+func (m *Model) Normalize() {
+	normalizeRates(m.Pop.Lam)
+}
+
+// normalizeRates scales rates lam to one turn's worth, as Normalize does.
+func normalizeRates(lam []float64) {
+	n := len(lam)
 	totlam := 0.0
-	for i := 0; i < len(Pop); i++ { // first determine the total lambda
-		totlam += Pop[i].lam
+	for i := 0; i < n; i++ { // first determine the total lambda
+		totlam += lam[i]
+	}
+	if totlam == 0 || totlam > math.MaxFloat64/(float64(n)*1.1) {
+		totlam = tameRates(lam)
 	}
-	for i := 0; i < len(Pop); i++ {
+	for i := 0; i < n; i++ {
 		// the following increases the total activations to reasonable number
-		Pop[i].lam = Pop[i].lam * float64(NumOfAgents) * 1.1 / totlam
+		lam[i] = lam[i] * float64(n) * 1.1 / totlam
 		// reject lambda = 0
-		if Pop[i].lam == 0 {
-			Pop[i].lam = float64(1) / float64(NumOfAgents)
+		if lam[i] == 0 {
+			lam[i] = float64(1) / float64(n)
 		}
 	}
 }
 
-func main() {
-	rand.Seed(time.Now().UTC().UnixNano())
-	activationTypes := []ActivationOrder{uniform, random, poisson, inversePoisson, naturalPoisson}
-
-	totalResults := make([]*mat64.Dense, 0) // approximating a 3D matrix with a slice of 2D matrices
-	for _, act := range activationTypes {
-		actResults := mat64.NewDense(NumRuns, NumTurns, nil) //using NumRuns instead of len(activationTypes) because I can't make a 3D Matrix
-		activationType = act
-
-		for ri := 0; ri < NumRuns; ri++ {
-			//results := make([]float64, 0)
-			fmt.Printf("Starting run %d with %d turns, %s activation.\n",
-				ri+1, NumTurns, act)
-			timenow := time.Now()
-			fmt.Printf("Time is now %v, Num Agents = %d\n", timenow, NumOfAgents)
-
-			Pop = Populate()
-			_, sdw := Asdw(Pop)
-
-			sds := make([]float64, 0)
-			sds = append(sds, sdw)
-			for i := 0; i < NumTurns; i++ {
-				if activationType == uniform {
-					Unifact()
-				} else if activationType == random {
-					Randmact()
-				} else {
-					Poisact()
-					// fmt.Println("Skipping Poisson")
-				}
-				_, sd := Asdw(Pop)
-				sds = append(sds, sd)
-			}
-			results := make([]float64, 0)
-			results = append(results, sds...)
-			actResults.SetRow(ri, results)
+// tameRates rescales rates that Normalize can't -- all zero, or so large
+// that their total or its multiples overflow -- keeping their proportions,
+// and returns their new total. All-zero rates become equal, and infinite
+// ones, from agents right at the mean under inverse poisson with wealth
+// far from it, say, share all the activity equally.
+func tameRates(lam []float64) float64 {
+	top := 0.0
+	for _, l := range lam {
+		if l > top {
+			top = l
 		}
-
-		totalResults = append(totalResults, actResults)
-
-	}
-	fmt.Printf("\t\t\tGradient Analysis for %v runs\n", NumRuns)
-	fmt.Printf("\t\t\t   Mean\t\t\t    SD\n")
-	for i := 0; i < len(totalResults); i++ {
-		gradients := make([]float64, 0)
-		for j := 0; j < NumRuns; j++ {
-
-			_, row := totalResults[i].Caps()
-			runArray := make([]float64, row) // why is this 5?
-			totalResults[i].Row(runArray, j)
-			//fmt.Printf("Output: %v\n", runArray)
-			//fmt.Printf("Should be: %v\n", actResults.RowView(i))
-			seq_along := make([]float64, len(runArray))
-			for k := 0; k < len(runArray); k++ {
-				if runArray[k] == 0 {
-					runArray[k] = 0.00000000001
-				}
-				runArray[k] = math.Log(runArray[k])
-				seq_along[k] = float64(k) // +1?
+	}
+	totlam := 0.0
+	for i, l := range lam {
+		if top == 0 {
+			lam[i] = 1
+		} else if math.IsInf(top, 1) {
+			lam[i] = 0
+			if math.IsInf(l, 1) {
+				lam[i] = 1
 			}
-			var r stats.Regression
-			r.UpdateArray(seq_along, runArray)
-			gradient := r.Slope()
-			gradients = append(gradients, gradient)
+		} else {
+			lam[i] = l / top
 		}
+		totlam += lam[i]
+	}
+	return totlam
+}
 
-		fmt.Printf("%-15s\t\t%f\t\t%f\n", activationTypes[i], stats.StatsMean(gradients), stats.StatsSampleStandardDeviation(gradients))
+// Step advances the Model by one turn of its activation regime.
+func (m *Model) Step() {
+	if m.Interventions != nil {
+		m.drawing("intervention")
+		m.intervene()
+	}
+	if m.Homophily > 0 && m.Quantiles > 0 {
+		m.assignQuantiles()
 	}
-	/*
-		fmt.Println("\nDumping results matrices:")
-		for i := 0; i < len(totalResults); i++ {
-			fmt.Println(activationTypes[i])
-			printMatrix := mat64.Formatted(totalResults[i].T(), mat64.Prefix(""))
-			fmt.Println(printMatrix)
-			fmt.Println()
+	if m.Mobility != nil {
+		m.drawing("mobility")
+		m.Mobility.Move(m.Pop, m.rng)
+	}
+	m.Turn++
+	if m.Temporal != nil {
+		m.SetNetwork(m.Temporal.At(m.Turn))
+	}
+	m.Activation.step(m)
+	if m.Types != nil {
+		m.Types.Learn(m.Pop)
+	}
+	if m.Demography != nil {
+		m.drawing("demography")
+		m.Demography.Turn(m)
+	}
+	if m.Snapshots != nil {
+		m.Snapshots.Take(m)
+	}
+}
+
+// Equalized reports whether a population with wealth SD sd has levelled out
+// for good, so that further turns can be skipped: the SD is within
+// EqualizedTolerance and every exchange m can make preserves equal wealth.
+// Directed exchanges don't, a temporal network is left to run so its
+// snapshots stay true to the turn, and so is a Model with interventions to
+// come or with births, which can leave it unequal again.
+func (m *Model) Equalized(sd float64) bool {
+	if !SkipEqualized || sd > EqualizedTolerance || m.DirectedRule != nil || m.Temporal != nil || m.intervening() ||
+		m.Demography != nil {
+		return false
+	}
+	if m.GroupRule != nil {
+		_, ok := m.GroupRule.(NeighborhoodLeveler)
+		return ok
+	}
+	switch m.Rule.(type) {
+	case Leveler, PartialLeveler:
+		return true
+	}
+	return false
+}
+
+// snapshotNetwork exports m's network if turn is one of NetworkSnapshotTurns.
+func snapshotNetwork(m *Model, run, turn int) {
+	if m.Net == nil || snapshotsOff.Load() {
+		return
+	}
+	for _, t := range NetworkSnapshotTurns {
+		if t == turn {
+			name := fmt.Sprintf("network_%s_run%d_turn%d.%s",
+				strings.Replace(m.Activation.String(), " ", "_", -1), run+1, turn, NetworkSnapshotFormat)
+			if err := m.SnapshotNetwork(name); err != nil {
+				log.Fatal(err)
+			}
 		}
-	*/
+	}
 }
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"io"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func benchmarkPoisact(b *testing.B, act ActivationOrder) {
+	m := NewModel(act, rand.New(rand.NewSource(1)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if i%NumTurns == 0 { // start over before the population levels out
+			b.StopTimer()
+			m.Pop = Populate()
+			b.StartTimer()
+		}
+		m.Poisact()
+	}
+}
+
+func BenchmarkPoisactPoisson(b *testing.B)        { benchmarkPoisact(b, poisson) }
+func BenchmarkPoisactInversePoisson(b *testing.B) { benchmarkPoisact(b, inversePoisson) }
+func BenchmarkPoisactNaturalPoisson(b *testing.B) { benchmarkPoisact(b, naturalPoisson) }
+
+// benchmarkEventCounts is benchmarkPoisact with events drawn as Poisson
+// counts rather than waiting times.
+func benchmarkEventCounts(b *testing.B, act ActivationOrder) {
+	defer func(saved string) { EventSampling = saved }(EventSampling)
+	EventSampling = "counts"
+	benchmarkPoisact(b, act)
+}
+
+func BenchmarkPoisactPoissonCounts(b *testing.B)        { benchmarkEventCounts(b, poisson) }
+func BenchmarkPoisactInversePoissonCounts(b *testing.B) { benchmarkEventCounts(b, inversePoisson) }
+func BenchmarkPoisactNaturalPoissonCounts(b *testing.B) { benchmarkEventCounts(b, naturalPoisson) }
+
+func benchmarkUnifact(b *testing.B, n int, batch bool) {
+	defer func(agents int, batch bool) {
+		NumOfAgents, BatchExchange = agents, batch
+	}(NumOfAgents, BatchExchange)
+	NumOfAgents, BatchExchange = n, batch
+	m := NewModel(uniform, rand.New(rand.NewSource(1)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Unifact()
+	}
+}
+
+func BenchmarkUnifact1k(b *testing.B)      { benchmarkUnifact(b, 1000, false) }
+func BenchmarkUnifact1M(b *testing.B)      { benchmarkUnifact(b, 1000000, false) }
+func BenchmarkUnifact1kBatch(b *testing.B) { benchmarkUnifact(b, 1000, true) }
+func BenchmarkUnifact1MBatch(b *testing.B) { benchmarkUnifact(b, 1000000, true) }
+
+// benchmarkStep times whole turns of one activation regime over n agents.
+func benchmarkStep(b *testing.B, act ActivationOrder, n int) {
+	defer func(saved int) { NumOfAgents = saved }(NumOfAgents)
+	NumOfAgents = n
+	m := NewModel(act, rand.New(rand.NewSource(1)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if i%NumTurns == 0 { // start over before the population levels out
+			b.StopTimer()
+			m.Pop = Populate()
+			b.StartTimer()
+		}
+		m.Step()
+	}
+}
+
+func BenchmarkStepUniform1k(b *testing.B)          { benchmarkStep(b, uniform, 1000) }
+func BenchmarkStepUniform100k(b *testing.B)        { benchmarkStep(b, uniform, 100000) }
+func BenchmarkStepRandom1k(b *testing.B)           { benchmarkStep(b, random, 1000) }
+func BenchmarkStepRandom100k(b *testing.B)         { benchmarkStep(b, random, 100000) }
+func BenchmarkStepPoisson1k(b *testing.B)          { benchmarkStep(b, poisson, 1000) }
+func BenchmarkStepPoisson100k(b *testing.B)        { benchmarkStep(b, poisson, 100000) }
+func BenchmarkStepInversePoisson1k(b *testing.B)   { benchmarkStep(b, inversePoisson, 1000) }
+func BenchmarkStepInversePoisson100k(b *testing.B) { benchmarkStep(b, inversePoisson, 100000) }
+func BenchmarkStepNaturalPoisson1k(b *testing.B)   { benchmarkStep(b, naturalPoisson, 1000) }
+func BenchmarkStepNaturalPoisson100k(b *testing.B) { benchmarkStep(b, naturalPoisson, 100000) }
+func BenchmarkStepLocalPoisson1k(b *testing.B)     { benchmarkStep(b, localPoisson, 1000) }
+func BenchmarkStepLocalPoisson100k(b *testing.B)   { benchmarkStep(b, localPoisson, 100000) }
+
+// benchmarkRule times one exchange rule over a fixed set of pairs.
+func benchmarkRule(b *testing.B, r Rule) {
+	wealth := make([]float64, 1000)
+	for i := 0; i < b.N; i++ {
+		if i%len(wealth) == 0 {
+			for j := range wealth {
+				wealth[j] = float64(j + 1)
+			}
+		}
+		j := i % (len(wealth) / 2)
+		r.Apply(&wealth[2*j], &wealth[2*j+1])
+	}
+}
+
+func BenchmarkLeveler(b *testing.B)        { benchmarkRule(b, Leveler{}) }
+func BenchmarkPartialLeveler(b *testing.B) { benchmarkRule(b, PartialLeveler{Fraction: 0.5}) }
+
+func benchmarkAsdw(b *testing.B, n int) {
+	defer func(saved int) { NumOfAgents = saved }(NumOfAgents)
+	NumOfAgents = n
+	Pop := Populate()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Asdw(Pop)
+	}
+}
+
+func BenchmarkAsdw1k(b *testing.B) { benchmarkAsdw(b, 1000) }
+func BenchmarkAsdw1M(b *testing.B) { benchmarkAsdw(b, 1000000) }
+
+func BenchmarkNormalize(b *testing.B) {
+	m := NewModel(poisson, rand.New(rand.NewSource(1)))
+	for i := 0; i < m.Pop.Len(); i++ {
+		m.Pop.Lam[i] = m.Pop.Wealth[i]
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Normalize()
+	}
+}
+
+func BenchmarkDecompose(b *testing.B) {
+	Pop := Populate()
+	for i := 0; i < Pop.Len(); i++ {
+		Pop.Agents[i].tag = i % 10
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Decompose(Pop, func(a *Agent) int { return a.tag })
+	}
+}
+
+// TestSortEvents checks the parallel sort against sort.Sort.
+func TestSortEvents(t *testing.T) {
+	defer func(threshold, workers int) {
+		ParallelSortThreshold, Workers = threshold, workers
+	}(ParallelSortThreshold, Workers)
+	ParallelSortThreshold, Workers = 1, 3
+	rng := rand.New(rand.NewSource(1))
+	for _, n := range []int{1, 2, 7, 1000, 12345} {
+		e := make(events, n)
+		for i := range e {
+			e[i] = event{time: rng.Float64(), agent: int32(i)}
+		}
+		want := append(events(nil), e...)
+		sort.Sort(want)
+		sortEvents(e, nil)
+		for i := range e {
+			if e[i] != want[i] {
+				t.Fatalf("n = %d: event %d is %v, want %v", n, i, e[i], want[i])
+			}
+		}
+	}
+}
+
+func TestAsdwDoesNotAllocate(t *testing.T) {
+	Pop := Populate()
+	if allocs := testing.AllocsPerRun(10, func() { Asdw(Pop) }); allocs != 0 {
+		t.Errorf("Asdw allocates %v times per call", allocs)
+	}
+}
+
+// TestSkipEqualized checks that skipping the turns after a population has
+// levelled out records the same SDs as simulating them.
+func TestSkipEqualized(t *testing.T) {
+	defer func(skip bool) { SkipEqualized = skip }(SkipEqualized)
+	for _, act := range []ActivationOrder{uniform, random, poisson} {
+		var sds [2][]float64
+		for k, skip := range []bool{false, true} {
+			SkipEqualized = skip
+			m := NewModel(act, rand.New(rand.NewSource(3)))
+			for i := 0; i < m.Pop.Len(); i++ {
+				m.Pop.Wealth[i] = float64(i % 2) // levels to 0 under the integer floor
+			}
+			sds[k] = runCell(m, 0, io.Discard)
+		}
+		if len(sds[1]) != NumTurns+1 {
+			t.Fatalf("%v: %d SDs with skipping, want %d", act, len(sds[1]), NumTurns+1)
+		}
+		for i := range sds[0] {
+			if sds[0][i] != sds[1][i] {
+				t.Errorf("%v turn %d: SD %v simulated, %v with skipping", act, i, sds[0][i], sds[1][i])
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import "github.com/GaryBoone/GoStats/stats"
+
+/* Reference statistics for Poisson lambdas */
+
+/*
+ * The poisson and inverse poisson regimes set each agent's lambda from its
+ * distance to a reference mean. Ken's model uses the global mean; a
+ * ReferenceStat lets the reference be local instead. Prepare is called once
+ * per turn, before any Mean.
+ */
+type ReferenceStat interface {
+	Prepare(Pop Population)
+	Mean(i int) float64
+}
+
+// GlobalMean is the mean wealth of the whole Population.
+type GlobalMean struct {
+	mean float64
+}
+
+func (g *GlobalMean) Prepare(Pop Population) {
+	g.mean = stats.StatsMean(Pop.Wealth)
+}
+
+func (g *GlobalMean) Mean(i int) float64 {
+	return g.mean
+}
+
+// NeighborhoodMean is the mean wealth of agent i and its network neighbors.
+type NeighborhoodMean struct {
+	Net *Network
+}
+
+func (n *NeighborhoodMean) Prepare(Pop Population) {
+	n.Net.Aggregate(Pop)
+}
+
+func (n *NeighborhoodMean) Mean(i int) float64 {
+	return n.Net.LocalMean(i)
+}
+
+// GroupMean is the mean wealth of the agents sharing agent i's tag.
+type GroupMean struct {
+	tags  []int
+	means map[int]float64
+}
+
+func (g *GroupMean) Prepare(Pop Population) {
+	sums := make(map[int]float64)
+	counts := make(map[int]float64)
+	g.tags = g.tags[:0]
+	for i := 0; i < Pop.Len(); i++ {
+		tag := Pop.Agents[i].tag
+		g.tags = append(g.tags, tag)
+		sums[tag] += Pop.Wealth[i]
+		counts[tag]++
+	}
+	g.means = make(map[int]float64)
+	for t := range sums {
+		g.means[t] = sums[t] / counts[t]
+	}
+}
+
+func (g *GroupMean) Mean(i int) float64 {
+	return g.means[g.tags[i]]
+}
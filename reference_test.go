@@ -0,0 +1,73 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestReferenceStats checks each reference mean on four agents in a line,
+// 0-1-2-3, tagged by parity.
+func TestReferenceStats(t *testing.T) {
+	Pop := NewPopulation(4)
+	copy(Pop.Wealth, []float64{1, 2, 3, 10})
+	for i := range Pop.Agents {
+		Pop.Agents[i].tag = i % 2
+	}
+	net := newNetwork(4)
+	net.addEdge(0, 1)
+	net.addEdge(1, 2)
+	net.addEdge(2, 3)
+	for _, c := range []struct {
+		ref  ReferenceStat
+		want []float64
+	}{
+		{&GlobalMean{}, []float64{4, 4, 4, 4}},
+		{&NeighborhoodMean{Net: net}, []float64{1.5, 2, 5, 6.5}},
+		{&GroupMean{}, []float64{2, 6, 2, 6}},
+	} {
+		c.ref.Prepare(Pop)
+		for i, want := range c.want {
+			if got := c.ref.Mean(i); got != want {
+				t.Errorf("%T: agent %d's reference is %v, want %v", c.ref, i, got, want)
+			}
+		}
+	}
+}
+
+// TestLambdaReference checks that LambdaReference picks the Model's
+// reference, that a neighborhood reference gets a network to work over, and
+// that the lambdas follow the reference: under poisson, agents right at the
+// reference mean get only the floor rate, 1/N.
+func TestLambdaReference(t *testing.T) {
+	defer func(agents int, ref string) { NumOfAgents, LambdaReference = agents, ref }(NumOfAgents, LambdaReference)
+	NumOfAgents = 4
+	for _, c := range []struct {
+		name string
+		ok   func(ReferenceStat) bool
+		at   []bool // which agents are at their reference mean
+	}{
+		{"global", func(r ReferenceStat) bool { _, ok := r.(*GlobalMean); return ok }, []bool{false, false, true, false}},
+		{"neighborhood", func(r ReferenceStat) bool { n, ok := r.(*NeighborhoodMean); return ok && n.Net != nil }, nil},
+		{"group", func(r ReferenceStat) bool { _, ok := r.(*GroupMean); return ok }, []bool{false, true, false, true}},
+	} {
+		LambdaReference = c.name
+		m := NewModel(poisson, rand.New(rand.NewSource(1)))
+		if !c.ok(m.Reference) {
+			t.Errorf("%s: reference %#v", c.name, m.Reference)
+		}
+		if c.at == nil {
+			continue
+		}
+		copy(m.Pop.Wealth, []float64{1, 4, 3, 4}) // global mean 3, group means 2 and 4
+		for i := range m.Pop.Agents {
+			m.Pop.Agents[i].tag = i % 2
+		}
+		m.Step()
+		for i, at := range c.at {
+			if (m.Pop.Lam[i] == 0.25) != at {
+				t.Errorf("%s: lambdas %v, want 1/4 just for agents at the mean %v", c.name, m.Pop.Lam, c.at)
+				break
+			}
+		}
+	}
+}
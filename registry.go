@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+/* Extensions */
+
+/*
+ * Exchange rules, activation regimes and metrics can be added without
+ * touching the rest of the model: put them in a file of their own and
+ * register them by name from its init function,
+ *
+ *	func init() {
+ *		RegisterRule("halfway", func() Rule { return PartialLeveler{Fraction: 0.5} })
+ *	}
+ *
+ * and then choose them by name: RuleName for the rule, Activations for the
+ * regimes an experiment runs, Metrics for the metrics. Registered regimes
+ * follow the six built in, in the order they were registered, so remote
+ * workers agree on them as long as they are the same build.
+ *
+ * A regime that only decides who meets whom can be a Scheduler instead,
+ * registered with RegisterScheduler; see scheduler.go.
+ *
+ * Rules and metrics can also come from Go plugins; see plugins.go.
+ */
+
+// ruleTable holds the rules RuleName can name.
+var ruleTable = map[string]func() Rule{
+	"leveler":      func() Rule { return Leveler{} },
+	"conserving":   func() Rule { return ConservingLeveler{Remainder: RemainderTo} },
+	"partial":      func() Rule { return PartialLeveler{Fraction: LevelingFraction} },
+	"proportional": func() Rule { return ProportionalTransfer{Fraction: TransferFraction} },
+	"yardsale":     func() Rule { return YardSale{Fraction: YardSaleFraction} },
+	"bargain":      func() Rule { return Bargain{} },
+}
+
+// RegisterRule makes the rule newRule returns available as name.
+func RegisterRule(name string, newRule func() Rule) {
+	if _, ok := ruleTable[name]; ok {
+		panic("rule " + name + " registered twice")
+	}
+	ruleTable[name] = newRule
+}
+
+// lookupRule returns a new rule by the given name.
+func lookupRule(name string) (Rule, error) {
+	newRule, ok := ruleTable[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown rule %q", name)
+	}
+	return newRule(), nil
+}
+
+// A funcRule is a Rule that is just a function.
+type funcRule func(a, b *float64)
+
+// Apply calls the function.
+func (f funcRule) Apply(a, b *float64) {
+	f(a, b)
+}
+
+// A customActivation is a registered activation regime: step runs one turn
+// of it on m.
+type customActivation struct {
+	name string
+	step func(m *Model)
+}
+
+// customActivations follow localPoisson, in the order they were registered.
+var customActivations []customActivation
+
+// RegisterActivation makes step, which runs one turn of a regime on a Model,
+// available as the regime name, and returns the regime.
+func RegisterActivation(name string, step func(m *Model)) ActivationOrder {
+	if _, err := ParseActivation(name); err == nil {
+		panic("activation " + name + " registered twice")
+	}
+	customActivations = append(customActivations, customActivation{name, step})
+	return lastActivation()
+}
+
+// lastActivation returns the last regime, built in or registered.
+func lastActivation() ActivationOrder {
+	return localPoisson + ActivationOrder(len(customActivations))
+}
+
+// custom returns the registration of a registered regime, or nil for one
+// built in.
+func (act ActivationOrder) custom() *customActivation {
+	if act > localPoisson && act <= lastActivation() {
+		return &customActivations[act-localPoisson-1]
+	}
+	return nil
+}
+
+// RegisterMetric makes the metric available by its name.
+func RegisterMetric(metric Metric) {
+	if _, ok := metricTable[metric.Name]; ok {
+		panic("metric " + metric.Name + " registered twice")
+	}
+	metricTable[metric.Name] = metric
+}
+
+// experimentActivations returns the regimes named in Activations, or the
+// six built in if there are none.
+func experimentActivations() ([]ActivationOrder, error) {
+	if len(Activations) == 0 {
+		return []ActivationOrder{uniform, random, poisson, inversePoisson, naturalPoisson, localPoisson}, nil
+	}
+	return parseActivations(Activations)
+}
+
+// parseActivations returns the regimes names name.
+func parseActivations(names []string) ([]ActivationOrder, error) {
+	acts := make([]ActivationOrder, len(names))
+	for i, name := range names {
+		act, err := ParseActivation(name)
+		if err != nil {
+			return nil, err
+		}
+		acts[i] = act
+	}
+	return acts, nil
+}
+
+// newRule returns the rule named by RuleName for a new Model.
+func newRule() Rule {
+	rule, err := lookupRule(RuleName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return rule
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+var mirror ActivationOrder
+
+func init() {
+	// a regime that levels agents with their mirror images, poorest with richest
+	mirror = RegisterActivation("mirror", func(m *Model) {
+		for i, j := 0, m.Pop.Len()-1; i < j; i, j = i+1, j-1 {
+			m.exchange(i, j)
+		}
+	})
+	RegisterRule("halfway", func() Rule { return PartialLeveler{Fraction: 0.5} })
+	RegisterMetric(Metric{"max", []string{"max"}, true, true, func(s *snapshot) []float64 {
+		return []float64{s.sorted[len(s.sorted)-1]}
+	}})
+}
+
+func TestRegisteredActivation(t *testing.T) {
+	if mirror.String() != "mirror" {
+		t.Errorf("registered regime is called %q", mirror)
+	}
+	if act, err := ParseActivation("mirror"); err != nil || act != mirror {
+		t.Errorf("ParseActivation(mirror) = %v, %v", act, err)
+	}
+	defer func(agents int, acts []string) { NumOfAgents, Activations = agents, acts }(NumOfAgents, Activations)
+	NumOfAgents, Activations = 4, []string{"uniform", "mirror"}
+	acts, err := experimentActivations()
+	if err != nil || len(acts) != 2 || acts[1] != mirror {
+		t.Fatalf("experimentActivations() = %v, %v", acts, err)
+	}
+	m := NewModel(mirror, rand.New(rand.NewSource(1)))
+	m.Step()
+	for i, w := range m.Pop.Wealth {
+		if w != 2 {
+			t.Errorf("agent %d has %v after a mirrored turn, want 2", i, w)
+		}
+	}
+}
+
+func TestRegisteredRuleAndMetric(t *testing.T) {
+	defer func(name string) { RuleName = name }(RuleName)
+	RuleName = "halfway"
+	m := NewModel(uniform, rand.New(rand.NewSource(1)))
+	if r, ok := m.Rule.(PartialLeveler); !ok || r.Fraction != 0.5 {
+		t.Errorf("rule %#v, want the registered halfway rule", m.Rule)
+	}
+	if _, err := lookupRule("nonesuch"); err == nil {
+		t.Error("found a rule that wasn't registered")
+	}
+	metrics, err := lookupMetrics([]string{"max"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := computeMetrics(metrics, &snapshot{wealth: []float64{3, 9, 1}, total: 13}, 1); v[0][0] != 9 {
+		t.Errorf("max = %v", v)
+	}
+}
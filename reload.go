@@ -0,0 +1,154 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+/* Reloading settings */
+
+/*
+ * The live settings (see live.go) reach a running experiment two ways.
+ * With ReloadFile set, SIGHUP has it re-read the file, a JSON object of
+ * settings,
+ *
+ *	{"turns": 200, "fraction": 0.25}
+ *
+ * and apply any that changed since it was last read. With ControlAddr set,
+ * it listens there for connections, each a series of lines:
+ *
+ *	set fraction 0.25   change a setting; answers "ok" or "error: ..."
+ *	get                 list the settings changed so far, then "ok"
+ *
+ * Anything that can reach the socket can change the runs, so it should
+ * listen on localhost.
+ */
+
+// watchReloads starts re-reading ReloadFile on SIGHUP and serving
+// ControlAddr, as the Choices ask.
+func watchReloads() error {
+	if ReloadFile != "" {
+		if _, err := readSettings(ReloadFile); err != nil {
+			return err
+		}
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go func() {
+			last := make(map[string]float64)
+			for range hup {
+				if err := reloadSettings(ReloadFile, last); err != nil {
+					log.Printf("reloading %s: %v", ReloadFile, err)
+				}
+			}
+		}()
+	}
+	if ControlAddr != "" {
+		l, err := net.Listen("tcp", ControlAddr)
+		if err != nil {
+			return err
+		}
+		go serveControl(l)
+	}
+	return nil
+}
+
+// readSettings reads the settings in the named file.
+func readSettings(name string) (map[string]float64, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var settings map[string]float64
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	for setting, v := range settings {
+		if err := checkSetting(setting, v); err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return settings, nil
+}
+
+// reloadSettings applies the settings in the named file that differ from
+// last, which it updates.
+func reloadSettings(name string, last map[string]float64) error {
+	settings, err := readSettings(name)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(settings))
+	for setting := range settings {
+		names = append(names, setting)
+	}
+	sort.Strings(names)
+	for _, setting := range names {
+		if v, ok := last[setting]; ok && v == settings[setting] {
+			continue
+		}
+		if err := live.Set(setting, settings[setting], "SIGHUP"); err != nil {
+			return err
+		}
+		last[setting] = settings[setting]
+	}
+	return nil
+}
+
+// serveControl takes settings over connections to l.
+func serveControl(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			log.Print(err)
+			return
+		}
+		go func() {
+			defer conn.Close()
+			control(conn, conn, "control "+conn.RemoteAddr().String())
+		}()
+	}
+}
+
+// control carries out the commands read from r, a line each, answering to
+// w, on behalf of source.
+func control(r io.Reader, w io.Writer, source string) {
+	lines := bufio.NewScanner(r)
+	for lines.Scan() {
+		if cmd := strings.Fields(lines.Text()); len(cmd) > 0 {
+			fmt.Fprintln(w, answer(cmd, source))
+		}
+	}
+}
+
+// answer is the reply to a control command.
+func answer(cmd []string, source string) string {
+	if len(cmd) == 3 && cmd[0] == "set" {
+		v, err := strconv.ParseFloat(cmd[2], 64)
+		if err == nil {
+			err = live.Set(cmd[1], v, source)
+		}
+		if err != nil {
+			return "error: " + err.Error()
+		}
+		return "ok"
+	} else if len(cmd) == 1 && cmd[0] == "get" {
+		var b strings.Builder
+		for _, s := range live.Settings() {
+			b.WriteString(s + "\n")
+		}
+		return b.String() + "ok"
+	}
+	return `error: want "set name value" or "get"`
+}
@@ -0,0 +1,43 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestControl(t *testing.T) {
+	defer resetLive()
+	var out bytes.Buffer
+	control(strings.NewReader("set yardsale 0.3\nset yardsale 3\n\nget\nhello\n"), &out, "test")
+	want := "ok\nerror: yardsale 3 isn't from 0 to 1\nyardsale 0.3\nok\nerror: want \"set name value\" or \"get\"\n"
+	if out.String() != want {
+		t.Errorf("answered %q, want %q", out.String(), want)
+	}
+}
+
+func TestReloadSettings(t *testing.T) {
+	defer resetLive()
+	name := filepath.Join(t.TempDir(), "settings.json")
+	os.WriteFile(name, []byte(`{"exitrate": 0.1, "turns": 20}`), 0644)
+	last := make(map[string]float64)
+	if err := reloadSettings(name, last); err != nil {
+		t.Fatal(err)
+	}
+	before := live.changes
+	os.WriteFile(name, []byte(`{"exitrate": 0.1, "turns": 30}`), 0644)
+	if err := reloadSettings(name, last); err != nil {
+		t.Fatal(err)
+	}
+	if live.changes != before+1 || strings.Join(live.Settings(), ", ") != "exitrate 0.1, turns 30" {
+		t.Errorf("%d changes; settings %v", live.changes-before, live.Settings())
+	}
+	os.WriteFile(name, []byte(`{"numofagents": 10}`), 0644)
+	if err := reloadSettings(name, last); err == nil {
+		t.Error("reloaded numofagents")
+	}
+}
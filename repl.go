@@ -0,0 +1,270 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/* Interactive stepping */
+
+/*
+ * "repl" builds a single Model, as the Choices say, and reads commands to
+ * drive it, one a line, from standard input -- for showing a class how the
+ * regimes differ, a turn at a time. It can step the Model, look at any
+ * agent and compute any metric, change the live settings (see live.go) and
+ * intervene, with a flat tax shared out equally or a gift to one agent;
+ * "help" lists the commands. Settings changed with "set" last until the
+ * next "new", which builds the Model afresh from the Choices, drawing its
+ * random numbers from the master seed, -seed, so a session can be replayed
+ * command for command. With SnapshotEvery set, "rewind" takes the Model
+ * back to a turn it kept a snapshot of (see snapshot.go), to try something
+ * else from there.
+ */
+
+const replHelp = `commands:
+  new [regime]          start again, with another regime if given
+  step [n]              run n turns (1)
+  agent <who>           show an agent: an ID, or richest, poorest or median
+  top [k]               list the k richest agents (10)
+  metrics [name ...]    compute metrics (gini and quantiles)
+  set <setting> <v>     change a live setting: turns isn't one here
+  tax <rate>            take rate of everyone's wealth and share it equally
+  give <id> <amount>    add amount to an agent's wealth, or take it away
+  rewind <turn>         go back to a turn there's a snapshot of
+  snapshots             list the turns there are snapshots of
+  help, quit`
+
+// A repl is a Model being driven by hand.
+type repl struct {
+	m    *Model
+	act  ActivationOrder
+	seed int64
+	w    io.Writer
+}
+
+// reset builds a new Model of r.act.
+func (r *repl) reset() {
+	r.m = NewRewindableModel(r.act, cellSeed(r.seed, int(r.act), 0))
+	fmt.Fprintf(r.w, "%s, %d agents, %s rule\n", r.act, r.m.Pop.Len(), RuleName)
+	r.summary()
+}
+
+// summary writes a line summing up the Model's population.
+func (r *repl) summary() {
+	mean, sd := Asdw(r.m.Pop)
+	sorted := append([]float64(nil), r.m.Pop.Wealth...)
+	sort.Float64s(sorted)
+	fmt.Fprintf(r.w, "turn %d: %d agents, mean %g, SD %g, Gini %.4f\n",
+		r.m.Turn, r.m.Pop.Len(), mean, sd, gini(sorted, mean*float64(len(sorted))))
+}
+
+// find returns the index of the agent who names.
+func (r *repl) find(who string) (int, error) {
+	if sel, ok := agentSelectors[who]; ok {
+		return sel(r.m.Pop), nil
+	}
+	id, err := strconv.Atoi(who)
+	if err != nil {
+		return 0, fmt.Errorf("no agent %q", who)
+	}
+	i := r.m.Pop.Find(id)
+	if i < 0 {
+		return 0, fmt.Errorf("no agent with ID %d", id)
+	}
+	return i, nil
+}
+
+// describe writes what there is to know about agent i.
+func (r *repl) describe(i int) {
+	a := r.m.Pop.Agents[i]
+	fmt.Fprintf(r.w, "agent %d: wealth %g, %d activations, tag %d", a.id, r.m.Pop.Wealth[i], a.activations, a.tag)
+	if r.m.Types != nil {
+		fmt.Fprintf(r.w, ", %s", r.m.Types.Types[a.kind].Name)
+	}
+	if RiskAversion != "" {
+		fmt.Fprintf(r.w, ", risk aversion %g", a.risk)
+	}
+	fmt.Fprintln(r.w)
+}
+
+// exec carries out a command, and reports whether it was to quit.
+func (r *repl) exec(cmd []string) (quit bool, err error) {
+	count := func(i, def int) (int, error) {
+		if len(cmd) <= i {
+			return def, nil
+		}
+		n, err := strconv.Atoi(cmd[i])
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("%s: bad count %q", cmd[0], cmd[i])
+		}
+		return n, nil
+	}
+	if cmd[0] == "quit" || cmd[0] == "exit" {
+		return true, nil
+	} else if cmd[0] == "help" {
+		fmt.Fprintln(r.w, replHelp)
+	} else if cmd[0] == "new" {
+		if len(cmd) > 1 {
+			act, err := ParseActivation(strings.Join(cmd[1:], " "))
+			if err != nil {
+				return false, err
+			}
+			r.act = act
+		}
+		r.reset()
+	} else if cmd[0] == "step" {
+		n, err := count(1, 1)
+		if err != nil {
+			return false, err
+		}
+		for i := 0; i < n; i++ {
+			r.m.Step()
+			r.summary()
+		}
+	} else if cmd[0] == "agent" && len(cmd) == 2 {
+		i, err := r.find(cmd[1])
+		if err != nil {
+			return false, err
+		}
+		r.describe(i)
+	} else if cmd[0] == "top" {
+		k, err := count(1, 10)
+		if err != nil {
+			return false, err
+		}
+		order := make([]int, r.m.Pop.Len())
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(a, b int) bool { return r.m.Pop.Wealth[order[a]] > r.m.Pop.Wealth[order[b]] })
+		if k > len(order) {
+			k = len(order)
+		}
+		for _, i := range order[:k] {
+			r.describe(i)
+		}
+	} else if cmd[0] == "metrics" {
+		names := cmd[1:]
+		if len(names) == 0 {
+			names = []string{"gini", "quantiles"}
+		}
+		metrics, err := lookupMetrics(names)
+		if err != nil {
+			return false, err
+		}
+		values := computeMetrics(metrics, &snapshot{wealth: r.m.Pop.Wealth}, 1)
+		for k, metric := range metrics {
+			for c, column := range metric.Columns {
+				fmt.Fprintf(r.w, "%s %g\n", column, values[k][c])
+			}
+		}
+	} else if cmd[0] == "set" && len(cmd) == 3 {
+		v, err := strconv.ParseFloat(cmd[2], 64)
+		if err != nil {
+			return false, err
+		} else if err := checkSetting(cmd[1], v); err != nil {
+			return false, err
+		} else if cmd[1] == "turns" {
+			return false, errors.New("step as many turns as you like")
+		}
+		liveSetters[cmd[1]](r.m, v)
+		fmt.Fprintf(r.w, "%s set to %v\n", cmd[1], v)
+	} else if cmd[0] == "tax" && len(cmd) == 2 {
+		rate, err := strconv.ParseFloat(cmd[1], 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return false, fmt.Errorf("tax: rate %q isn't from 0 to 1", cmd[1])
+		}
+		fmt.Fprintf(r.w, "collected and shared out %g\n", r.m.tax(rate))
+		r.summary()
+	} else if cmd[0] == "give" && len(cmd) == 3 {
+		i, err := r.find(cmd[1])
+		if err != nil {
+			return false, err
+		}
+		amount, err := strconv.ParseFloat(cmd[2], 64)
+		if err != nil {
+			return false, err
+		} else if r.m.Pop.Wealth[i]+amount < 0 {
+			return false, fmt.Errorf("agent %s has only %g", cmd[1], r.m.Pop.Wealth[i])
+		}
+		r.m.Pop.Wealth[i] += amount
+		r.describe(i)
+	} else if cmd[0] == "rewind" && len(cmd) == 2 {
+		turn, err := count(1, 0)
+		if err != nil {
+			return false, err
+		} else if err := r.m.Rewind(turn); err != nil {
+			return false, err
+		}
+		r.summary()
+	} else if cmd[0] == "snapshots" {
+		if r.m.Snapshots == nil {
+			return false, errors.New("no snapshots are kept; set SnapshotEvery")
+		}
+		fmt.Fprintf(r.w, "snapshots of turns %v\n", r.m.Snapshots.Turns())
+	} else {
+		return false, fmt.Errorf("don't know %q; try help", strings.Join(cmd, " "))
+	}
+	return false, nil
+}
+
+// session runs commands read from in, a line each, until it ends or one
+// says to quit.
+func (r *repl) session(in io.Reader) {
+	lines := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(r.w, "> ")
+		if !lines.Scan() {
+			fmt.Fprintln(r.w)
+			return
+		}
+		cmd := strings.Fields(lines.Text())
+		if len(cmd) == 0 {
+			continue
+		}
+		quit, err := r.exec(cmd)
+		if err != nil {
+			fmt.Fprintln(r.w, "error:", err)
+		} else if quit {
+			return
+		}
+	}
+}
+
+// runREPL is the repl subcommand.
+func runREPL(args []string) error {
+	fs := flag.NewFlagSet("repl", flag.ExitOnError)
+	seed := fs.Int64("seed", time.Now().UTC().UnixNano(), "master seed")
+	regime := fs.String("regime", "", "regime to start with (the first of Activations)")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		return errors.New("usage: repl [-seed n] [-regime name]")
+	}
+	if err := checkChoices(*seed); err != nil {
+		return err
+	}
+	acts, err := experimentActivations()
+	if err != nil {
+		return err
+	}
+	r := &repl{act: acts[0], seed: *seed, w: os.Stdout}
+	if *regime != "" {
+		if r.act, err = ParseActivation(*regime); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(r.w, "Master seed %d; type help for commands\n", *seed)
+	r.reset()
+	r.session(os.Stdin)
+	return nil
+}
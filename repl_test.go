@@ -0,0 +1,72 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestREPL(t *testing.T) {
+	defer func(rule string, agents int) { RuleName, NumOfAgents = rule, agents }(RuleName, NumOfAgents)
+	RuleName, NumOfAgents = "partial", 20
+	var out bytes.Buffer
+	r := &repl{act: uniform, seed: 1, w: &out}
+	r.reset()
+	r.session(strings.NewReader(`step 2
+agent richest
+give 3 100
+agent 3
+set fraction 0.1
+set turns 5
+tax 1
+metrics gini population
+top 2
+new random
+frobnicate
+quit
+step
+`))
+	for _, want := range []string{
+		"uniform, 20 agents, partial rule\nturn 0: 20 agents, mean 10.5",
+		"turn 2: 20 agents",
+		"agent 3: wealth 10",
+		"fraction set to 0.1",
+		"error: step as many turns as you like",
+		"collected and shared out",
+		"gini 0\nagents 20\n",
+		"random, 20 agents",
+		`error: don't know "frobnicate"`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("session lacks %q:\n%s", want, out.String())
+		}
+	}
+	if r.m.Turn != 0 {
+		t.Errorf("stepped after quitting")
+	}
+	if p, ok := r.m.Rule.(PartialLeveler); !ok || p.Fraction != LevelingFraction {
+		t.Errorf("new kept the setting: %#v", r.m.Rule)
+	}
+}
+
+func TestREPLRewind(t *testing.T) {
+	defer func(every, agents int) { SnapshotEvery, NumOfAgents = every, agents }(SnapshotEvery, NumOfAgents)
+	SnapshotEvery, NumOfAgents = 1, 20
+	var out bytes.Buffer
+	r := &repl{act: random, seed: 1, w: &out}
+	r.reset()
+	r.session(strings.NewReader("step 3\nrewind 1\nsnapshots\nrewind 3\n"))
+	if n := strings.Count(out.String(), "> turn 1: 20 agents, mean 10.25"); n != 2 {
+		t.Errorf("turn 1 summed up %d times, not twice:\n%s", n, out.String())
+	}
+	for _, want := range []string{
+		"snapshots of turns [0 1]",
+		"error: no snapshot of turn 3",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("session lacks %q:\n%s", want, out.String())
+		}
+	}
+}
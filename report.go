@@ -0,0 +1,369 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/GaryBoone/GoStats/stats"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+	"gonum.org/v1/plot/vg"
+	"html/template"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/* Comparing scenarios */
+
+/*
+ * "report [-o report.md] [-format svg] dir dir ..." compares the
+ * experiments whose results are in two or more directories, each written
+ * with -plots, from their trajectories.csv. For every regime, it tables
+ * each scenario's mean gradient and final Gini over its runs, with 95%
+ * confidence intervals; tests every pair of scenarios for a difference in
+ * them, with Welch's t-test; and overlays the scenarios' mean SD and Gini
+ * trajectories, drawn as plot draws them, in one figure each. The report is
+ * one document, in HTML if -o ends in .html and in Markdown otherwise, with
+ * the figures beside it.
+ */
+
+// A scenario is one experiment's trajectories, named by their directory.
+type scenario struct {
+	name string
+	t    *trajectories
+}
+
+// A reportTable is a table of the report.
+type reportTable struct {
+	Caption string
+	Header  []string
+	Rows    [][]string
+}
+
+// A reportFigure is a figure of the report, saved beside it.
+type reportFigure struct {
+	Caption, File string
+}
+
+// A report is a comparison of scenarios, ready to write out.
+type report struct {
+	Scenarios []string
+	Tables    []reportTable
+	Figures   []reportFigure
+}
+
+// runReport is the report subcommand.
+func runReport(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	out := fs.String("o", "report.md", "file to write the report to: .html for HTML, or else Markdown")
+	format := fs.String("format", PlotFormat, `"png" or "svg", for the figures`)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		return errors.New("usage: report [-o report.md|report.html] [-format png|svg] dir dir ...")
+	}
+	if err := checkPlotFormat(*format); err != nil {
+		return err
+	}
+	var scenarios []scenario
+	for _, dir := range fs.Args() {
+		f, err := os.Open(filepath.Join(dir, "trajectories.csv"))
+		if err != nil {
+			return err
+		}
+		t, err := readTrajectories(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", dir, err)
+		}
+		scenarios = append(scenarios, scenario{filepath.Clean(dir), t})
+	}
+	r, err := compareScenarios(scenarios, strings.TrimSuffix(*out, filepath.Ext(*out)), *format)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if ext := filepath.Ext(*out); ext == ".html" || ext == ".htm" {
+		err = r.writeHTML(f)
+	} else {
+		err = r.writeMarkdown(f)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// compareScenarios builds the report comparing scenarios, drawing its
+// figures to files named from stem.
+func compareScenarios(scenarios []scenario, stem, format string) (*report, error) {
+	r := &report{}
+	for _, s := range scenarios {
+		r.Scenarios = append(r.Scenarios, s.name)
+	}
+	regimes := scenarioRegimes(scenarios)
+	measures := []struct {
+		name  string
+		value func(tr trajectory, length float64) float64
+	}{
+		{"Gradient", func(tr trajectory, length float64) float64 { return gradientOver(tr.SD, length) }},
+		{"Final Gini", func(tr trajectory, length float64) float64 { return tr.Gini[len(tr.Gini)-1] }},
+	}
+	for _, measure := range measures {
+		summary := reportTable{Caption: measure.name + " by regime: mean [95% CI] over runs",
+			Header: append([]string{"Regime"}, r.Scenarios...)}
+		tests := reportTable{Caption: measure.name + ": Welch's t-tests between scenarios",
+			Header: []string{"Regime", "Scenario", "Against", "Difference", "t", "df", "p"}}
+		for _, regime := range regimes {
+			row := []string{regime}
+			samples := make([][]float64, len(scenarios))
+			for k, s := range scenarios {
+				for _, tr := range s.t.runs[regime] {
+					if len(tr.SD) > 0 {
+						samples[k] = append(samples[k], measure.value(tr, s.t.length))
+					}
+				}
+				row = append(row, formatInterval(samples[k]))
+			}
+			summary.Rows = append(summary.Rows, row)
+			for a := range scenarios {
+				for b := a + 1; b < len(scenarios); b++ {
+					if len(samples[a]) < 2 || len(samples[b]) < 2 {
+						continue
+					}
+					diff, t, df, p := welch(samples[a], samples[b])
+					tests.Rows = append(tests.Rows, []string{regime, r.Scenarios[a], r.Scenarios[b],
+						fmt.Sprintf("%.6g", diff), fmt.Sprintf("%.4g", t), fmt.Sprintf("%.1f", df), fmt.Sprintf("%.4g", p)})
+				}
+			}
+		}
+		r.Tables = append(r.Tables, summary, tests)
+	}
+	for _, regime := range regimes {
+		for _, fig := range []struct {
+			name, label string
+			logScale    bool
+			series      func(trajectory) []float64
+		}{
+			{"sd", "Wealth SD", true, func(tr trajectory) []float64 { return tr.SD }},
+			{"gini", "Gini", false, func(tr trajectory) []float64 { return tr.Gini }},
+		} {
+			file := fmt.Sprintf("%s_%s_%s.%s", stem, fig.name, strings.Replace(regime, " ", "_", -1), format)
+			if err := drawScenarios(scenarios, regime, fig.label, fig.logScale, fig.series, file); err != nil {
+				return nil, err
+			}
+			r.Figures = append(r.Figures, reportFigure{fmt.Sprintf("%s by turn, %s", fig.label, regime), filepath.Base(file)})
+		}
+	}
+	return r, nil
+}
+
+// scenarioRegimes returns the regimes of any of scenarios, in the order they
+// first appear.
+func scenarioRegimes(scenarios []scenario) []string {
+	seen := make(map[string]bool)
+	var regimes []string
+	for _, s := range scenarios {
+		for _, regime := range s.t.regimes {
+			if !seen[regime] {
+				seen[regime] = true
+				regimes = append(regimes, regime)
+			}
+		}
+	}
+	return regimes
+}
+
+// drawScenarios draws each scenario's mean series of regime, in a band of
+// one SD, to file.
+func drawScenarios(scenarios []scenario, regime, label string, logScale bool, series func(trajectory) []float64, file string) error {
+	p := plot.New()
+	p.Title.Text = fmt.Sprintf("%s by turn, %s", label, regime)
+	p.X.Label.Text = timeLabel(scenarios[0].t.length)
+	p.Y.Label.Text = label
+	if logScale {
+		p.Y.Scale = plot.LogScale{}
+		p.Y.Tick.Marker = plot.LogTicks{Prec: -1}
+	}
+	for k, s := range scenarios {
+		var runs [][]float64
+		for _, tr := range s.t.runs[regime] {
+			runs = append(runs, series(tr))
+		}
+		if len(runs) == 0 {
+			continue
+		}
+		c := plotutil.Color(k)
+		mean, lo, hi := meanBand(runs, logScale)
+		band, err := plotter.NewPolygon(append(turnXYs(lo, s.t.length, logScale), reverseXYs(turnXYs(hi, s.t.length, logScale))...))
+		if err != nil {
+			return err
+		}
+		band.Color = fade(c, 0x30)
+		band.LineStyle.Width = 0
+		line, err := plotter.NewLine(turnXYs(mean, s.t.length, logScale))
+		if err != nil {
+			return err
+		}
+		line.LineStyle.Color = c
+		line.LineStyle.Width = vg.Points(2)
+		p.Add(band, line)
+		p.Legend.Add(s.name, line)
+	}
+	if logScale {
+		logRange(p)
+	}
+	return p.Save(8*vg.Inch, 5*vg.Inch, file)
+}
+
+// formatInterval formats the mean of sample with its 95% confidence
+// interval, and its size.
+func formatInterval(sample []float64) string {
+	if len(sample) == 0 {
+		return "-"
+	}
+	var s stats.Stats
+	s.UpdateArray(sample)
+	if len(sample) < 2 {
+		return fmt.Sprintf("%.6g (n=1)", s.Mean())
+	}
+	half := tQuantile(0.975, float64(len(sample)-1)) * s.SampleStandardDeviation() / math.Sqrt(float64(len(sample)))
+	return fmt.Sprintf("%.6g [%.6g, %.6g] (n=%d)", s.Mean(), s.Mean()-half, s.Mean()+half, len(sample))
+}
+
+// welch returns the difference between the means of a and b, and Welch's t
+// statistic for it, with its degrees of freedom and two-sided p-value.
+func welch(a, b []float64) (diff, t, df, p float64) {
+	var sa, sb stats.Stats
+	sa.UpdateArray(a)
+	sb.UpdateArray(b)
+	va, vb := sa.SampleVariance()/float64(len(a)), sb.SampleVariance()/float64(len(b))
+	diff = sa.Mean() - sb.Mean()
+	if va+vb == 0 {
+		if diff == 0 {
+			return diff, 0, math.NaN(), 1
+		}
+		return diff, math.Inf(int(math.Copysign(1, diff))), math.NaN(), 0
+	}
+	t = diff / math.Sqrt(va+vb)
+	df = (va + vb) * (va + vb) / (va*va/float64(len(a)-1) + vb*vb/float64(len(b)-1))
+	return diff, t, df, tTail(t, df)
+}
+
+// tTail returns the two-sided p-value of t under Student's t distribution
+// with df degrees of freedom.
+func tTail(t, df float64) float64 {
+	return incompleteBeta(df/2, 0.5, df/(df+t*t))
+}
+
+// tQuantile returns the p quantile, for p > 0.5, of Student's t distribution
+// with df degrees of freedom, by bisection.
+func tQuantile(p, df float64) float64 {
+	lo, hi := 0.0, 1e6
+	for i := 0; i < 200; i++ {
+		mid := (lo + hi) / 2
+		if tTail(mid, df) > 2*(1-p) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2
+}
+
+// incompleteBeta returns the regularized incomplete beta function I_x(a, b),
+// from its continued fraction.
+func incompleteBeta(a, b, x float64) float64 {
+	if x <= 0 {
+		return 0
+	} else if x >= 1 {
+		return 1
+	}
+	la, _ := math.Lgamma(a)
+	lb, _ := math.Lgamma(b)
+	lab, _ := math.Lgamma(a + b)
+	front := math.Exp(lab - la - lb + a*math.Log(x) + b*math.Log(1-x))
+	if x < (a+1)/(a+b+2) {
+		return front * betaFraction(a, b, x) / a
+	}
+	return 1 - front*betaFraction(b, a, 1-x)/b
+}
+
+// betaFraction evaluates the continued fraction of incompleteBeta by
+// Lentz's method.
+func betaFraction(a, b, x float64) float64 {
+	const eps, tiny = 1e-15, 1e-300
+	nonzero := func(v float64) float64 {
+		if math.Abs(v) < tiny {
+			return tiny
+		}
+		return v
+	}
+	c, d := 1.0, 1/nonzero(1-(a+b)*x/(a+1))
+	h := d
+	for m := 1.0; m <= 300; m++ {
+		num := m * (b - m) * x / ((a + 2*m - 1) * (a + 2*m))
+		d = 1 / nonzero(1+num*d)
+		c = nonzero(1 + num/c)
+		h *= d * c
+		num = -(a + m) * (a + b + m) * x / ((a + 2*m) * (a + 2*m + 1))
+		d = 1 / nonzero(1+num*d)
+		c = nonzero(1 + num/c)
+		h *= d * c
+		if math.Abs(d*c-1) < eps {
+			break
+		}
+	}
+	return h
+}
+
+// writeMarkdown writes r as Markdown.
+func (r *report) writeMarkdown(w io.Writer) error {
+	fmt.Fprintf(w, "# Scenario comparison\n\nScenarios: %s\n", strings.Join(r.Scenarios, ", "))
+	for _, t := range r.Tables {
+		fmt.Fprintf(w, "\n## %s\n\n| %s |\n|%s\n", t.Caption, strings.Join(t.Header, " | "), strings.Repeat(" --- |", len(t.Header)))
+		for _, row := range t.Rows {
+			fmt.Fprintf(w, "| %s |\n", strings.Join(row, " | "))
+		}
+	}
+	fmt.Fprintf(w, "\n## Trajectories\n")
+	for _, f := range r.Figures {
+		fmt.Fprintf(w, "\n![%s](%s)\n", f.Caption, f.File)
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+var reportHTML = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Scenario comparison</title>
+<style>body { font-family: sans-serif; } table { border-collapse: collapse; } td, th { border: 1px solid #ccc; padding: 4px 8px; }</style>
+</head>
+<body>
+<h1>Scenario comparison</h1>
+<p>Scenarios: {{range $i, $s := .Scenarios}}{{if $i}}, {{end}}{{$s}}{{end}}</p>
+{{range .Tables}}<h2>{{.Caption}}</h2>
+<table>
+<tr>{{range .Header}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+{{end}}<h2>Trajectories</h2>
+{{range .Figures}}<figure><img src="{{.File}}" alt="{{.Caption}}"><figcaption>{{.Caption}}</figcaption></figure>
+{{end}}</body>
+</html>
+`))
+
+// writeHTML writes r as an HTML page.
+func (r *report) writeHTML(w io.Writer) error {
+	return reportHTML.Execute(w, r)
+}
@@ -0,0 +1,64 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStudentT(t *testing.T) {
+	for _, c := range []struct{ df, q float64 }{{1, 12.7062047}, {9, 2.2621572}, {1000, 1.9623391}} {
+		if q := tQuantile(0.975, c.df); math.Abs(q-c.q) > 1e-6 {
+			t.Errorf("0.975 quantile with %v df is %v, want %v", c.df, q, c.q)
+		}
+		if p := tTail(c.q, c.df); math.Abs(p-0.05) > 1e-7 {
+			t.Errorf("p of %v with %v df is %v, want 0.05", c.q, c.df, p)
+		}
+	}
+	diff, tstat, df, p := welch([]float64{1, 2, 3, 4}, []float64{3, 5, 7, 9})
+	// by hand: variances 5/3 and 20/3 over 4 each, so t = -3.5/sqrt(25/12)
+	if diff != -3.5 || math.Abs(tstat+3.5/math.Sqrt(25.0/12)) > 1e-12 || math.Abs(df-4.4118) > 1e-4 || !(p > 0.06 && p < 0.07) {
+		t.Errorf("welch gave %v, %v, %v, %v", diff, tstat, df, p)
+	}
+}
+
+// TestReport checks that two experiments' directories make a report, in
+// Markdown and HTML, with its figures.
+func TestReport(t *testing.T) {
+	dir, _ := os.Getwd()
+	defer os.Chdir(dir)
+	os.Chdir(t.TempDir())
+	for _, name := range []string{"before", "after"} {
+		os.Mkdir(name, 0755)
+		f, err := os.Create(filepath.Join(name, "trajectories.csv"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeTrajectories(f, testTrajectories())
+		f.Close()
+	}
+	if err := runReport([]string{"before"}); err == nil {
+		t.Error("reported on one scenario")
+	}
+	for _, out := range []string{"report.md", "report.html"} {
+		if err := runReport([]string{"-o", out, "before", "after"}); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{"Scenarios: before, after", "Welch", "uniform", "report_sd_uniform.png"} {
+			if !strings.Contains(string(data), want) {
+				t.Errorf("%s lacks %q:\n%s", out, want, data)
+			}
+		}
+	}
+	if _, err := os.Stat("report_gini_random.png"); err != nil {
+		t.Error(err)
+	}
+}
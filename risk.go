@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+)
+
+/* Risk aversion */
+
+/*
+ * The stochastic rules, "yardsale" and "bargain", have each agent stake
+ * part of its wealth on the exchange: in a yard sale, the amount that
+ * changes hands is limited by what the more cautious of the two will
+ * stake; in a bargain, each puts what it will stake into a pool that is
+ * split between them at random. How much an agent stakes is 1 less its
+ * risk aversion, which is 0 -- everything staked -- unless RiskAversion
+ * says how to draw it, for each agent at the start of every run, from a
+ * distribution over [0, 1] whose parameters are RiskParams, in order, with
+ * defaults for any left out:
+ *
+ *	equal    everyone's risk aversion (0.5)
+ *	uniform  low (0) and high (1)
+ *	beta     alpha (2) and beta (2)
+ *
+ * Agents' risk aversion is written out with their wealth by TrackAgents.
+ *
+ * The outcome of each exchange, like an agent type's refusals, is decided by
+ * a hash of the Model's seed, the turn and the two agents' IDs rather than
+ * by the Model's random stream, so exchanges can still run in parallel and
+ * give the same results.
+ */
+
+// riskParams returns RiskParams, or defaults where it stops short.
+func riskParams(defaults ...float64) ([]float64, error) {
+	if len(RiskParams) > len(defaults) {
+		return nil, fmt.Errorf("RiskAversion %q takes %d RiskParams, not %d", RiskAversion, len(defaults), len(RiskParams))
+	}
+	return append(append([]float64(nil), RiskParams...), defaults[len(RiskParams):]...), nil
+}
+
+// riskDistribution returns a function that draws an agent's risk aversion
+// as RiskAversion says.
+func riskDistribution() (func(rng *rand.Rand) float64, error) {
+	if RiskAversion == "equal" {
+		p, err := riskParams(0.5)
+		if err != nil {
+			return nil, err
+		} else if p[0] < 0 || p[0] > 1 {
+			return nil, errors.New("equal needs risk aversion from 0 to 1")
+		}
+		return func(rng *rand.Rand) float64 { return p[0] }, nil
+	} else if RiskAversion == "uniform" {
+		p, err := riskParams(0, 1)
+		if err != nil {
+			return nil, err
+		} else if p[0] < 0 || p[1] < p[0] || p[1] > 1 {
+			return nil, errors.New("uniform needs 0 <= low <= high <= 1")
+		}
+		lo, hi := p[0], p[1]
+		return func(rng *rand.Rand) float64 { return lo + (hi-lo)*rng.Float64() }, nil
+	} else if RiskAversion == "beta" {
+		p, err := riskParams(2, 2)
+		if err != nil {
+			return nil, err
+		} else if p[0] <= 0 || p[1] <= 0 {
+			return nil, errors.New("beta needs alpha > 0 and beta > 0")
+		}
+		a, b := p[0], p[1]
+		return func(rng *rand.Rand) float64 {
+			x := gammaDraw(a, rng)
+			return x / (x + gammaDraw(b, rng))
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown RiskAversion %q", RiskAversion)
+}
+
+// gammaDraw draws from the gamma distribution with the given shape and
+// scale 1, by Marsaglia and Tsang's method.
+func gammaDraw(shape float64, rng *rand.Rand) float64 {
+	if shape < 1 {
+		return gammaDraw(shape+1, rng) * math.Pow(rng.Float64(), 1/shape)
+	}
+	d := shape - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := rng.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := rng.Float64()
+		if math.Log(u) < x*x/2+d-d*v+d*math.Log(v) {
+			return d * v
+		}
+	}
+}
+
+// checkRiskAversion reports whether RiskAversion can be drawn, if it's set.
+func checkRiskAversion() error {
+	if YardSaleFraction < 0 || YardSaleFraction > 1 {
+		return fmt.Errorf("YardSaleFraction %v isn't from 0 to 1", YardSaleFraction)
+	} else if RiskAversion == "" {
+		return nil
+	}
+	_, err := riskDistribution()
+	return err
+}
+
+// usesStakes reports whether any of m's rules is a StakeRule.
+func (m *Model) usesStakes() bool {
+	rules := []Rule{m.Rule}
+	if m.Market != nil {
+		rules = append(rules, m.Market.Rules[:]...)
+	}
+	if m.Types != nil {
+		rules = append(rules, m.Types.rules...)
+	}
+	for _, r := range rules {
+		if _, ok := r.(StakeRule); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// setStakes draws each agent's risk aversion from rng, as RiskAversion
+// says, and seeds the outcomes of m's StakeRules' exchanges, if it has any.
+func (m *Model) setStakes(rng *rand.Rand) {
+	if RiskAversion != "" {
+		draw, err := riskDistribution()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for i := range m.Pop.Agents {
+			m.Pop.Agents[i].risk = draw(rng)
+		}
+	}
+	if m.usesStakes() {
+		m.coinSeed = uint64(rng.Int63())
+	}
+}
+
+// apply applies rule to agents a and b, whose wealth is wa and wb, with the
+// stakes they're willing to make if it's a StakeRule. An agent activated
+// with itself has nothing to stake against.
+func (m *Model) apply(rule Rule, a, b *Agent, wa, wb *float64) {
+	if r, ok := rule.(StakeRule); ok {
+		if wa == wb {
+			return
+		}
+		r.ApplyStakes(wa, wb, 1-a.risk, 1-b.risk, pairDraw(m.coinSeed, m.Turn, a, b))
+		return
+	}
+	rule.Apply(wa, wb)
+}
+
+// pairDraw returns a number in [0, 1) that looks uniformly random but is
+// fixed by seed, the turn and a's and b's IDs.
+func pairDraw(seed uint64, turn int, a, b *Agent) float64 {
+	h := splitmix64(seed ^ splitmix64(uint64(turn)<<42^uint64(a.id)<<21^uint64(b.id)))
+	return float64(h>>11) / (1 << 53)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRiskDistribution(t *testing.T) {
+	defer func(mode string, params []float64) { RiskAversion, RiskParams = mode, params }(RiskAversion, RiskParams)
+	rng := rand.New(rand.NewSource(1))
+	for _, mode := range []string{"equal", "uniform", "beta"} {
+		RiskAversion, RiskParams = mode, nil
+		draw, err := riskDistribution()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum := 0.0
+		for i := 0; i < 10000; i++ {
+			r := draw(rng)
+			if r < 0 || r > 1 {
+				t.Fatalf("%s drew %v", mode, r)
+			}
+			sum += r
+		}
+		if mean := sum / 10000; math.Abs(mean-0.5) > 0.02 {
+			t.Errorf("%s: mean risk aversion %v, want 0.5", mode, mean)
+		}
+	}
+	for _, bad := range []struct {
+		mode   string
+		params []float64
+	}{{"equal", []float64{2}}, {"uniform", []float64{0.5, 0.2}}, {"beta", []float64{0, 1}}, {"beta", []float64{1, 1, 1}}, {"reckless", nil}} {
+		RiskAversion, RiskParams = bad.mode, bad.params
+		if err := checkRiskAversion(); err == nil {
+			t.Errorf("accepted %s %v", bad.mode, bad.params)
+		}
+	}
+}
+
+// TestStakeRules checks that stochastic exchanges conserve wealth, keep it
+// non-negative and stake nothing for an agent that is wholly risk averse.
+func TestStakeRules(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for _, r := range []StakeRule{YardSale{Fraction: 0.3}, Bargain{}} {
+		for i := 0; i < 1000; i++ {
+			a, b := 100*rng.Float64(), 100*rng.Float64()
+			sa, sb := rng.Float64(), rng.Float64()
+			na, nb := a, b
+			r.ApplyStakes(&na, &nb, sa, sb, rng.Float64())
+			if math.Abs(na+nb-a-b) > 1e-9 || na < 0 || nb < 0 {
+				t.Fatalf("%T: %v, %v staking %v, %v became %v, %v", r, a, b, sa, sb, na, nb)
+			}
+		}
+		a, b := 10.0, 30.0
+		r.ApplyStakes(&a, &b, 0, 1, 0.25)
+		if _, ok := r.(YardSale); ok && (a != 10 || b != 30) {
+			t.Errorf("%T: the averse agent staked; %v, %v", r, a, b)
+		} else if a < 10 {
+			t.Errorf("%T: the averse agent lost; %v, %v", r, a, b)
+		}
+	}
+}
+
+// TestRiskTracked checks that tracked agents' risk aversion is written out,
+// and that a yard sale changes wealth.
+func TestRiskTracked(t *testing.T) {
+	defer func(names []string, rule, mode string, agents, turns int) {
+		TrackAgents, RuleName, RiskAversion, NumOfAgents, NumTurns = names, rule, mode, agents, turns
+	}(TrackAgents, RuleName, RiskAversion, NumOfAgents, NumTurns)
+	TrackAgents, RuleName, RiskAversion, NumOfAgents, NumTurns = []string{"3"}, "yardsale", "uniform", 20, 4
+	dir, _ := os.Getwd()
+	defer os.Chdir(dir)
+	os.Chdir(t.TempDir())
+
+	m := NewModel(random, rand.New(rand.NewSource(6)))
+	start := append([]float64(nil), m.Pop.Wealth...)
+	risk := m.Pop.Agents[3].risk
+	runCell(m, 0, io.Discard)
+	data, err := os.ReadFile("agents_random_run1.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if lines[0] != "turn,agent,id,wealth,risk" {
+		t.Errorf("header %q", lines[0])
+	}
+	if want := "0,3,3,4," + strconv.FormatFloat(risk, 'g', -1, 64); lines[1] != want {
+		t.Errorf("line 2 is %q, want %q", lines[1], want)
+	}
+	total, changed := 0.0, false
+	for i, w := range m.Pop.Wealth {
+		total += w - start[i]
+		changed = changed || w != start[i]
+	}
+	if !changed || math.Abs(total) > 1e-9 {
+		t.Errorf("yard sales changed wealth %v, by %v in total", changed, total)
+	}
+}
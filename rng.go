@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+	"math/rand"
+)
+
+/* Random number generators */
+
+/*
+ * Every Model draws from a *rand.Rand, but the Source underneath it can be
+ * chosen with RNG: Go's own math/rand generator, PCG (the 128-bit XSL-RR
+ * variant, NumPy's PCG64) or xoshiro256**. The
+ * exponential draws of the Poisson regimes are bound by the generator at
+ * scale, and checking results against another implementation requires
+ * knowing -- and matching -- which one produced them.
+ */
+
+// NewSource returns the named generator, seeded with seed.
+func NewSource(name string, seed int64) (rand.Source64, error) {
+	if name == "math/rand" {
+		return rand.NewSource(seed).(rand.Source64), nil
+	} else if name == "pcg" {
+		s := &pcgSource{}
+		s.Seed(seed)
+		return s, nil
+	} else if name == "xoshiro" {
+		s := &xoshiroSource{}
+		s.Seed(seed)
+		return s, nil
+	}
+	return nil, fmt.Errorf("unknown RNG %q (want math/rand, pcg or xoshiro)", name)
+}
+
+// A purpose is something a cell draws random numbers for. Each purpose has
+// a stream of its own, derived from the cell's seed, so that what one of
+// them draws -- or whether it runs at all, as network statistics don't under
+// LargeScale -- can't shift the numbers another sees. The dynamics, which
+// populate the Model and drive its turns, draw from the cell's seed itself.
+type purpose uint64
+
+const (
+	purposeDynamics purpose = iota
+	purposeStats            // sampling for statistics that don't feed back into the run
+)
+
+// streamSeed returns the seed of the stream for purpose p of the cell
+// seeded with seed.
+func streamSeed(seed int64, p purpose) int64 {
+	if p == purposeDynamics {
+		return seed
+	}
+	return int64(splitmix64(uint64(seed) ^ splitmix64(uint64(p))))
+}
+
+// statsStream returns the stream m draws statistics' samples from: its own
+// if it was given one, or else the dynamics'.
+func (m *Model) statsStream() *rand.Rand {
+	if m.statsRng != nil {
+		return m.statsRng
+	}
+	return m.rng
+}
+
+// newRand returns a *rand.Rand drawing from the RNG generator. main checks
+// RNG before anything is seeded, so an unknown name here is a bug.
+func newRand(seed int64) *rand.Rand {
+	src, err := NewSource(RNG, seed)
+	if err != nil {
+		panic(err)
+	}
+	return rand.New(src)
+}
+
+// pcgSource is PCG XSL-RR 128/64: a 128-bit LCG whose state is folded and
+// rotated into 64 output bits.
+type pcgSource struct {
+	hi, lo uint64
+}
+
+const (
+	pcgMulHi = 2549297995355413924
+	pcgMulLo = 4865540595714422341
+	pcgIncHi = 6364136223846793005
+	pcgIncLo = 1442695040888963407
+)
+
+func (s *pcgSource) Seed(seed int64) {
+	s.hi, s.lo = splitmix64(uint64(seed)), splitmix64(^uint64(seed))
+}
+
+func (s *pcgSource) Uint64() uint64 {
+	// state = state*mul + inc, mod 2^128
+	hi, lo := bits.Mul64(s.lo, pcgMulLo)
+	hi += s.hi*pcgMulLo + s.lo*pcgMulHi
+	var carry uint64
+	s.lo, carry = bits.Add64(lo, pcgIncLo, 0)
+	s.hi, _ = bits.Add64(hi, pcgIncHi, carry)
+	return bits.RotateLeft64(s.hi^s.lo, -int(s.hi>>58))
+}
+
+func (s *pcgSource) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+// xoshiroSource is Blackman and Vigna's xoshiro256**.
+type xoshiroSource struct {
+	s [4]uint64
+}
+
+// Seed fills the state from a SplitMix64 sequence, as the authors recommend.
+func (x *xoshiroSource) Seed(seed int64) {
+	for i := range x.s {
+		x.s[i] = splitmix64(uint64(seed) + uint64(i)*0x9e3779b97f4a7c15)
+	}
+}
+
+func (x *xoshiroSource) Uint64() uint64 {
+	s := &x.s
+	result := bits.RotateLeft64(s[1]*5, 7) * 9
+	t := s[1] << 17
+	s[2] ^= s[0]
+	s[3] ^= s[1]
+	s[1] ^= s[2]
+	s[0] ^= s[3]
+	s[2] ^= t
+	s[3] = bits.RotateLeft64(s[3], 45)
+	return result
+}
+
+func (x *xoshiroSource) Int63() int64 {
+	return int64(x.Uint64() >> 1)
+}
+
+// A tape is a generator that counts its draws, so that it can return to a
+// state it passed through. Each of the generators above advances one step a
+// draw, whether of Int63 or Uint64, so re-seeding one and drawing as many
+// again gets it back there.
+type tape struct {
+	src   rand.Source64
+	seed  int64
+	draws uint64
+}
+
+// newTape returns a tape of the RNG generator, seeded with seed.
+func newTape(seed int64) *tape {
+	src, err := NewSource(RNG, seed)
+	if err != nil {
+		panic(err)
+	}
+	return &tape{src: src, seed: seed}
+}
+
+func (t *tape) Seed(seed int64) {
+	t.src.Seed(seed)
+	t.seed, t.draws = seed, 0
+}
+
+func (t *tape) Int63() int64 {
+	t.draws++
+	return t.src.Int63()
+}
+
+func (t *tape) Uint64() uint64 {
+	t.draws++
+	return t.src.Uint64()
+}
+
+// seek returns t to the state it was in after its first draws draws.
+func (t *tape) seek(draws uint64) {
+	if draws < t.draws {
+		t.src.Seed(t.seed)
+		t.draws = 0
+	}
+	for ; t.draws < draws; t.draws++ {
+		t.src.Uint64()
+	}
+}
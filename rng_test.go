@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+// TestXoshiroReference checks xoshiro256** against the reference
+// implementation's output from the state {1, 2, 3, 4}.
+func TestXoshiroReference(t *testing.T) {
+	x := &xoshiroSource{s: [4]uint64{1, 2, 3, 4}}
+	for i, want := range []uint64{11520, 0, 1509978240, 1215971899390074240} {
+		if got := x.Uint64(); got != want {
+			t.Errorf("output %d = %d, want %d", i, got, want)
+		}
+	}
+}
+
+// TestPCGReference checks PCG XSL-RR 128/64 from a zero state against the
+// outputs of the reference algorithm.
+func TestPCGReference(t *testing.T) {
+	s := &pcgSource{}
+	for i, want := range []uint64{14697929703826476783, 5591422465364813936, 74029666500212977} {
+		if got := s.Uint64(); got != want {
+			t.Errorf("output %d = %d, want %d", i, got, want)
+		}
+	}
+}
+
+func TestNewSourceUnknown(t *testing.T) {
+	if _, err := NewSource("mersenne", 1); err == nil {
+		t.Error("NewSource accepted an unknown generator")
+	}
+}
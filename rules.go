@@ -0,0 +1,253 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gonum/floats"
+	"math"
+)
+
+/* Exchange rules */
+
+// Rule is an exchange between a pair of activated agents, given their wealth.
+type Rule interface {
+	Apply(a, b *float64)
+}
+
+// A BatchRule can also be applied to many disjoint pairs at once, given the
+// pairs' wealth as two parallel slices, with the same result as calling Apply
+// on each pair in turn.
+type BatchRule interface {
+	Rule
+	ApplyBatch(a, b []float64)
+}
+
+// Leveler is Ken's original rule: both agents are reset to the (integer) average.
+type Leveler struct{}
+
+// Apply levels a and b.
+func (Leveler) Apply(a, b *float64) {
+	Proc(a, b)
+}
+
+// ApplyBatch levels every pair (a[i], b[i]).
+func (Leveler) ApplyBatch(a, b []float64) {
+	floats.Add(a, b)
+	floats.Scale(0.5, a)
+	for i := range a {
+		a[i] = math.Floor(a[i]) // simulate integer division
+	}
+	copy(b, a)
+}
+
+// ConservingLeveler is Leveler without the loss: Proc's floor destroys a
+// unit of wealth whenever a pair's total is odd, and over a long run those
+// units add up. Both agents are reset to the integer average, and the unit
+// left over goes to the agent Remainder says -- the "poorer" or "richer" of
+// the two before the exchange, or either by a fair coin if "random" -- so
+// total wealth is kept exactly. The coin is the Model's draw for the pair,
+// as a StakeRule's is; staking doesn't otherwise come into it.
+type ConservingLeveler struct {
+	Remainder string
+}
+
+// Apply levels a and b, a taking the remainder on a tie or a coin.
+func (r ConservingLeveler) Apply(a, b *float64) {
+	r.ApplyStakes(a, b, 1, 1, 0)
+}
+
+// ApplyStakes levels a and b, a winning the coin if u < 1/2.
+func (r ConservingLeveler) ApplyStakes(a, b *float64, sa, sb, u float64) {
+	total := *a + *b
+	averg := math.Floor(total / 2)
+	toA := u < 0.5
+	if r.Remainder == "poorer" {
+		toA = *a <= *b
+	} else if r.Remainder == "richer" {
+		toA = *a >= *b
+	}
+	if toA {
+		*a, *b = total-averg, averg
+	} else {
+		*a, *b = averg, total-averg
+	}
+}
+
+// checkRemainder returns an error unless RemainderTo is a known choice.
+func checkRemainder() error {
+	if RemainderTo != "poorer" && RemainderTo != "richer" && RemainderTo != "random" {
+		return fmt.Errorf("unknown RemainderTo %q (want poorer, richer or random)", RemainderTo)
+	}
+	return nil
+}
+
+// PartialLeveler moves each agent Fraction of the way towards the pair's
+// average. A Fraction of 1 is equivalent to Leveler without the integer floor.
+// It is also the fixed-fraction exchange, in which each agent hands the
+// other Fraction/2 of its wealth.
+type PartialLeveler struct {
+	Fraction float64
+}
+
+// Apply partially levels a and b.
+func (r PartialLeveler) Apply(a, b *float64) {
+	averg := (*a + *b) / 2
+	*a += r.Fraction * (averg - *a)
+	*b += r.Fraction * (averg - *b)
+}
+
+// ApplyBatch partially levels every pair (a[i], b[i]).
+func (r PartialLeveler) ApplyBatch(a, b []float64) {
+	averg := make([]float64, len(a))
+	copy(averg, a)
+	floats.Add(averg, b)
+	floats.Scale(0.5, averg)
+	d := make([]float64, len(a))
+	floats.AddScaled(a, r.Fraction, floats.SubTo(d, averg, a))
+	floats.AddScaled(b, r.Fraction, floats.SubTo(d, averg, b))
+}
+
+// ProportionalTransfer has the richer agent hand the poorer Fraction of its
+// wealth, a flat tax on every meeting. Unlike the levelers, a large Fraction
+// can leave the pair's ranks swapped.
+type ProportionalTransfer struct {
+	Fraction float64
+}
+
+// Apply moves Fraction of the richer of a and b's wealth to the other.
+func (r ProportionalTransfer) Apply(a, b *float64) {
+	if *a > *b {
+		t := r.Fraction * *a
+		*a -= t
+		*b += t
+	} else if *b > *a {
+		t := r.Fraction * *b
+		*b -= t
+		*a += t
+	}
+}
+
+// checkTransferFraction returns an error unless TransferFraction is from 0
+// to 1.
+func checkTransferFraction() error {
+	if TransferFraction < 0 || TransferFraction > 1 {
+		return fmt.Errorf("TransferFraction %v isn't from 0 to 1", TransferFraction)
+	}
+	return nil
+}
+
+// A StakeRule is a stochastic exchange in which each agent stakes part of
+// its wealth: given the fractions sa and sb that a and b are willing to
+// stake, and a uniform random number u, it moves wealth between them. The
+// Model gives each agent's stake as 1 less its risk aversion (see risk.go)
+// and draws u afresh for every exchange.
+type StakeRule interface {
+	Rule
+	ApplyStakes(a, b *float64, sa, sb, u float64)
+}
+
+// YardSale is the yard-sale model: a fair coin decides which agent wins
+// Fraction of the poorer one's wealth from the other, as much of it as both
+// are willing to stake.
+type YardSale struct {
+	Fraction float64
+}
+
+// Apply makes the yard sale's expected transfer, which is none.
+func (YardSale) Apply(a, b *float64) {}
+
+// ApplyStakes holds the yard sale, a winning if u < 1/2.
+func (r YardSale) ApplyStakes(a, b *float64, sa, sb, u float64) {
+	t := r.Fraction * math.Min(sa, sb) * math.Min(*a, *b)
+	if u < 0.5 {
+		*a += t
+		*b -= t
+	} else {
+		*a -= t
+		*b += t
+	}
+}
+
+// Bargain pools what each agent is willing to stake of its wealth and
+// splits the pool between them at random, keeping the rest; with risk
+// aversion as the agents' propensity to save, this is the kinetic exchange
+// model of Chatterjee, Chakrabarti and Manna.
+type Bargain struct{}
+
+// Apply makes the bargain's expected exchange, with everything staked: the
+// pair split their wealth evenly.
+func (Bargain) Apply(a, b *float64) {
+	Bargain{}.ApplyStakes(a, b, 1, 1, 0.5)
+}
+
+// ApplyStakes strikes the bargain, a taking u of the pool.
+func (Bargain) ApplyStakes(a, b *float64, sa, sb, u float64) {
+	pool := sa**a + sb**b
+	*a += u*pool - sa**a
+	*b += (1-u)*pool - sb**b
+}
+
+// DirectedRule is an exchange along a directed network edge; d is the
+// edge's direction as seen from a.
+type DirectedRule interface {
+	ApplyDirected(a, b *float64, d Direction)
+}
+
+// orient returns the wealth of the agents at the tail and head of the edge.
+func orient(a, b *float64, d Direction) (from, to *float64) {
+	if d == Backward {
+		return b, a
+	}
+	return a, b
+}
+
+// Tribute makes the agent at the tail of the edge pay Rate of its wealth to
+// the agent at the head, whoever is richer.
+type Tribute struct {
+	Rate float64
+}
+
+// ApplyDirected collects tribute along the edge.
+func (r Tribute) ApplyDirected(a, b *float64, d Direction) {
+	from, to := orient(a, b, d)
+	t := r.Rate * *from
+	*from -= t
+	*to += t
+}
+
+// Remittance makes the agent at the tail of the edge, if it is the richer
+// one, send Fraction of the half-gap to the agent at the head; a Fraction of
+// 1 levels them. Nothing flows against the edge.
+type Remittance struct {
+	Fraction float64
+}
+
+// ApplyDirected sends a remittance along the edge.
+func (r Remittance) ApplyDirected(a, b *float64, d Direction) {
+	from, to := orient(a, b, d)
+	if *from > *to {
+		t := r.Fraction * (*from - *to) / 2
+		*from -= t
+		*to += t
+	}
+}
+
+// GroupRule is an exchange among a whole group of agents at once.
+type GroupRule interface {
+	ApplyAll(group []*float64)
+}
+
+// NeighborhoodLeveler resets every agent in the group to the group's
+// (integer) mean, the many-agent analogue of Proc.
+type NeighborhoodLeveler struct{}
+
+// ApplyAll levels the group.
+func (NeighborhoodLeveler) ApplyAll(group []*float64) {
+	total := 0.0
+	for _, w := range group {
+		total += *w
+	}
+	averg := math.Floor(total / float64(len(group)))
+	for _, w := range group {
+		*w = averg
+	}
+}
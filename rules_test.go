@@ -0,0 +1,142 @@
+package main
+
+import "testing"
+
+func TestConservingLeveler(t *testing.T) {
+	for _, c := range []struct {
+		remainder string
+		a, b, u   float64
+		wa, wb    float64
+	}{
+		{"poorer", 3, 4, 0, 4, 3},
+		{"poorer", 4, 3, 0, 3, 4},
+		{"richer", 3, 4, 0, 3, 4},
+		{"richer", 3, 3, 0, 3, 3},
+		{"random", 3, 4, 0.25, 4, 3},
+		{"random", 3, 4, 0.75, 3, 4},
+		{"random", 2, 6, 0.75, 4, 4},
+	} {
+		a, b := c.a, c.b
+		ConservingLeveler{Remainder: c.remainder}.ApplyStakes(&a, &b, 1, 1, c.u)
+		if a != c.wa || b != c.wb {
+			t.Errorf("%s, u %v: (%v, %v) leveled to (%v, %v), want (%v, %v)", c.remainder, c.u, c.a, c.b, a, b, c.wa, c.wb)
+		}
+	}
+}
+
+// TestConservingRule checks that a run under the "conserving" rule keeps
+// its total wealth, where the original leveler loses some.
+func TestConservingRule(t *testing.T) {
+	defer func(agents int, rule, to string) { NumOfAgents, RuleName, RemainderTo = agents, rule, to }(NumOfAgents, RuleName, RemainderTo)
+	NumOfAgents = 101
+	total := func(rule, to string) float64 {
+		RuleName, RemainderTo = rule, to
+		m := NewModel(random, newRand(5))
+		for turn := 0; turn < 10; turn++ {
+			m.Step()
+		}
+		sum := 0.0
+		for _, w := range m.Pop.Wealth {
+			sum += w
+		}
+		return sum
+	}
+	for _, to := range []string{"poorer", "richer", "random"} {
+		if sum := total("conserving", to); sum != 101*102/2 {
+			t.Errorf("remainder to %s: total wealth %v after 10 turns, want %v", to, sum, 101*102/2)
+		}
+	}
+	if sum := total("leveler", "poorer"); sum >= 101*102/2 {
+		t.Errorf("leveler kept total wealth %v", sum)
+	}
+	RemainderTo = "nobody"
+	if checkRemainder() == nil {
+		t.Error("accepted RemainderTo nobody")
+	}
+}
+
+func TestProportionalTransfer(t *testing.T) {
+	for _, c := range []struct {
+		a, b   float64
+		wa, wb float64
+	}{
+		{10, 2, 9, 3},
+		{2, 10, 3, 9},
+		{4, 4, 4, 4},
+	} {
+		a, b := c.a, c.b
+		ProportionalTransfer{Fraction: 0.1}.Apply(&a, &b)
+		if a != c.wa || b != c.wb {
+			t.Errorf("(%v, %v) became (%v, %v), want (%v, %v)", c.a, c.b, a, b, c.wa, c.wb)
+		}
+	}
+	defer func(name string, f float64) { RuleName, TransferFraction = name, f }(RuleName, TransferFraction)
+	RuleName, TransferFraction = "proportional", 0.25
+	if r, err := lookupRule(RuleName); err != nil || r != (ProportionalTransfer{Fraction: 0.25}) {
+		t.Errorf("lookupRule(proportional) = %#v, %v", r, err)
+	}
+	TransferFraction = 1.5
+	if err := checkTransferFraction(); err == nil {
+		t.Error("accepted a TransferFraction of 1.5")
+	}
+}
+
+// TestNeighborhoodLeveler checks that a group is leveled to its integer
+// mean, as Proc levels a pair.
+func TestNeighborhoodLeveler(t *testing.T) {
+	w := []float64{1, 2, 7}
+	NeighborhoodLeveler{}.ApplyAll([]*float64{&w[0], &w[1], &w[2]})
+	if w[0] != 3 || w[1] != 3 || w[2] != 3 {
+		t.Errorf("leveled to %v, want [3 3 3]", w)
+	}
+}
+
+// TestNeighborhoodLeveling checks that an activated pair levels each of
+// their closed neighborhoods in turn, on a network NeighborhoodLeveling
+// provides, and that every agent leveled counts as activated.
+func TestNeighborhoodLeveling(t *testing.T) {
+	defer func(agents int, leveling bool) { NumOfAgents, NeighborhoodLeveling = agents, leveling }(NumOfAgents, NeighborhoodLeveling)
+	NumOfAgents, NeighborhoodLeveling = 6, true
+	m := NewModel(random, newRand(1))
+	if _, ok := m.GroupRule.(NeighborhoodLeveler); !ok || m.Net == nil {
+		t.Fatalf("group rule %#v, network %v", m.GroupRule, m.Net)
+	}
+	net := newNetwork(6) // a path, 0-1-2-3-4-5
+	for i := 0; i+1 < 6; i++ {
+		net.addEdge(i, i+1)
+	}
+	m.SetNetwork(net)
+	copy(m.Pop.Wealth, []float64{0, 3, 6, 9, 12, 30})
+	m.exchange(1, 4)
+	for i, want := range []float64{3, 3, 3, 17, 17, 17} {
+		if m.Pop.Wealth[i] != want {
+			t.Fatalf("wealth %v, want [3 3 3 17 17 17]", m.Pop.Wealth)
+		}
+		if m.Pop.Agents[i].activations != 1 {
+			t.Errorf("agent %d activated %d times, want once", i, m.Pop.Agents[i].activations)
+		}
+	}
+}
+
+// TestDirectedRules checks that tribute and remittances flow along an edge
+// seen from either end, and that remittances never flow to the richer.
+func TestDirectedRules(t *testing.T) {
+	for _, c := range []struct {
+		rule   DirectedRule
+		a, b   float64
+		d      Direction
+		wa, wb float64
+	}{
+		{Tribute{Rate: 0.1}, 50, 10, Forward, 45, 15},
+		{Tribute{Rate: 0.1}, 50, 10, Backward, 51, 9},
+		{Remittance{Fraction: 1}, 50, 10, Forward, 30, 30},
+		{Remittance{Fraction: 0.5}, 10, 50, Backward, 20, 40},
+		{Remittance{Fraction: 1}, 10, 50, Forward, 10, 50},
+	} {
+		a, b := c.a, c.b
+		c.rule.ApplyDirected(&a, &b, c.d)
+		if a != c.wa || b != c.wb {
+			t.Errorf("%#v, %v: (%v, %v) became (%v, %v), want (%v, %v)", c.rule, c.d, c.a, c.b, a, b, c.wa, c.wb)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+)
+
+/* Poisson event counts */
+
+/*
+ * Over a turn of length TurnLength, an agent with rate lambda is activated
+ * a Poisson(lambda*TurnLength) number of times, at independent uniform
+ * times. With EventSampling "counts", Poisact draws that number directly
+ * and then its times, instead of accumulating exponential waiting times
+ * until they pass TurnLength. The two give the same distribution of events, but the count is drawn
+ * in constant expected time however large lambda is (inverse poisson gives
+ * agents near the mean enormous rates), and each event then costs one
+ * uniform draw rather than a logarithm. The random stream is consumed
+ * differently, so the two don't reproduce each other's runs.
+ */
+
+// poissonCount draws from the Poisson distribution with mean lam: by
+// inversion for small means, and by Hormann's transformed rejection (PTRS)
+// otherwise.
+func poissonCount(lam float64, rng *rand.Rand) int {
+	if lam <= 0 {
+		return 0
+	}
+	if lam < 10 {
+		limit := math.Exp(-lam)
+		k := 0
+		for p := rng.Float64(); p > limit; p *= rng.Float64() {
+			k++
+		}
+		return k
+	}
+	slam := math.Sqrt(lam)
+	loglam := math.Log(lam)
+	b := 0.931 + 2.53*slam
+	a := -0.059 + 0.02483*b
+	invalpha := 1.1239 + 1.1328/(b-3.4)
+	vr := 0.9277 - 3.6224/(b-2)
+	for {
+		u := rng.Float64() - 0.5
+		v := rng.Float64()
+		us := 0.5 - math.Abs(u)
+		k := math.Floor((2*a/us+b)*u + lam + 0.43)
+		if us >= 0.07 && v <= vr {
+			return int(k)
+		}
+		if k < 0 || (us < 0.013 && v > us) {
+			continue
+		}
+		lg, _ := math.Lgamma(k + 1)
+		if math.Log(v)+math.Log(invalpha)-math.Log(a/(us*us)+b) <= -lam+k*loglam-lg {
+			return int(k)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// TestPoissonCount checks the sample mean and variance of poissonCount on
+// both sides of the switch to rejection sampling.
+func TestPoissonCount(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	const draws = 100000
+	for _, lam := range []float64{0.1, 1.1, 9.5, 10, 42, 5000} {
+		sum, sumSq := 0.0, 0.0
+		for i := 0; i < draws; i++ {
+			k := float64(poissonCount(lam, rng))
+			sum += k
+			sumSq += k * k
+		}
+		mean := sum / draws
+		variance := sumSq/draws - mean*mean
+		// the sample mean has SD sqrt(lam/draws); allow five of them
+		if math.Abs(mean-lam) > 5*math.Sqrt(lam/draws) {
+			t.Errorf("lambda %v: mean %v", lam, mean)
+		}
+		if math.Abs(variance-lam) > 0.05*lam {
+			t.Errorf("lambda %v: variance %v", lam, variance)
+		}
+	}
+}
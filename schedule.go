@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+/* Conditional and cancellable events */
+
+/*
+ * A poisson turn draws all of its events up front and pairs them off in
+ * order of time. With EventCondition or DeferAfterLoss set, it instead
+ * dispatches them one pair at a time from a Schedule, which can change
+ * what's still to come as the turn goes on:
+ *
+ *	- An event happens only if its condition holds when it comes up. With
+ *	  EventCondition "unchanged", that's if its agent's wealth is what it
+ *	  was when the event was scheduled, at the start of the turn or when
+ *	  the agent's events were last rescheduled: an agent acts on its plans
+ *	  only while nothing has happened to upset them.
+ *	- An agent's remaining events can be cancelled, or rescheduled for
+ *	  later in the turn, which cancels any put past its end. With
+ *	  DeferAfterLoss set, an agent that loses more than that share of its
+ *	  wealth in an exchange puts off the rest of its events by DeferDelay.
+ *
+ * An event that doesn't happen leaves its place to the next, so a turn can
+ * see fewer exchanges than usual. Cancel and Reschedule are there for other
+ * behavior, too -- a plugin's rule or script, say -- and act on the turn
+ * in progress.
+ */
+
+// An EventPredicate reports whether agent's event still happens in m, given
+// the agent's wealth when it was scheduled.
+type EventPredicate func(m *Model, agent int, then float64) bool
+
+// eventConditions are the EventPredicates EventCondition can name.
+var eventConditions = map[string]EventPredicate{
+	"unchanged": func(m *Model, agent int, then float64) bool {
+		return m.Pop.Wealth[agent] == then
+	},
+}
+
+// A Schedule dispatches a poisson turn's events conditionally.
+type Schedule struct {
+	Condition      EventPredicate // if set, events it doesn't hold for are skipped
+	DeferAfterLoss float64        // if > 0, the loss that has an agent put off its events
+	DeferDelay     float64        // and by how much of a turn
+
+	pending events    // the turn's events still to come, in order
+	then    []float64 // each agent's wealth when its events were scheduled
+	wealth  []float64 // and now
+}
+
+// checkSchedule reports whether the event scheduling Choices make sense.
+func checkSchedule() error {
+	if _, ok := eventConditions[EventCondition]; !ok && EventCondition != "" {
+		return fmt.Errorf("unknown EventCondition %q (want unchanged)", EventCondition)
+	} else if DeferAfterLoss < 0 || DeferAfterLoss > 1 {
+		return errors.New("DeferAfterLoss must be from 0 to 1")
+	} else if DeferAfterLoss > 0 && !(DeferDelay > 0) {
+		return errors.New("DeferDelay must be positive")
+	}
+	return nil
+}
+
+// newSchedule returns the Schedule the Choices ask for, or nil if they ask
+// for none.
+func newSchedule() *Schedule {
+	if EventCondition == "" && DeferAfterLoss == 0 {
+		return nil
+	}
+	if err := checkSchedule(); err != nil {
+		panic(err)
+	}
+	return &Schedule{Condition: eventConditions[EventCondition], DeferAfterLoss: DeferAfterLoss, DeferDelay: DeferDelay}
+}
+
+// clone returns a copy of s with no turn in progress.
+func (s *Schedule) clone() *Schedule {
+	c := *s
+	c.pending, c.then, c.wealth = nil, nil, nil
+	return &c
+}
+
+// dispatch pairs off aTimes, a turn's sorted events, as their conditions
+// allow, and has the pairs exchange.
+func (s *Schedule) dispatch(m *Model, aTimes events) {
+	s.pending = append(events(nil), aTimes...)
+	s.then = append(s.then[:0], m.Pop.Wealth...)
+	s.wealth = m.Pop.Wealth
+	defer func() { s.pending, s.wealth = nil, nil }()
+	for len(s.pending) >= 2 {
+		if !s.holds(m, s.pending[0]) {
+			s.pending = s.pending[1:]
+			continue
+		}
+		x := 1
+		if m.constrained() {
+			m.drawing("partner")
+			if weight := m.affinity(&m.Pop.Agents[int(s.pending[0].agent)]); weight != nil {
+				x = m.nextEligible(s.pending, weight)
+			}
+		}
+		if x < 0 {
+			s.pending = s.pending[1:]
+			continue
+		} else if !s.holds(m, s.pending[x]) {
+			s.pending = append(s.pending[:x], s.pending[x+1:]...)
+			continue
+		}
+		alpha, beta := int(s.pending[0].agent), int(s.pending[x].agent)
+		s.pending = append(s.pending[1:x], s.pending[x+1:]...)
+		wa, wb := s.wealth[alpha], s.wealth[beta]
+		m.exchange(alpha, beta)
+		s.react(alpha, wa)
+		s.react(beta, wb)
+	}
+}
+
+// holds reports whether event e still happens.
+func (s *Schedule) holds(m *Model, e event) bool {
+	return s.Condition == nil || s.Condition(m, int(e.agent), s.then[e.agent])
+}
+
+// react has agent, whose wealth was before its exchange, put off its
+// events if it lost enough.
+func (s *Schedule) react(agent int, before float64) {
+	if s.DeferAfterLoss > 0 && s.wealth[agent] < before*(1-s.DeferAfterLoss) {
+		s.Reschedule(agent, s.DeferDelay*TurnLength)
+	}
+}
+
+// Cancel drops agent's remaining events this turn.
+func (s *Schedule) Cancel(agent int) {
+	s.Reschedule(agent, TurnLength)
+}
+
+// Reschedule puts off agent's remaining events this turn by delay, in
+// simulated time,
+// cancelling any put past its end, and counts them as scheduled now.
+func (s *Schedule) Reschedule(agent int, delay float64) {
+	if s.wealth == nil {
+		return
+	}
+	s.then[agent] = s.wealth[agent]
+	var moved events
+	kept := s.pending[:0]
+	for _, e := range s.pending {
+		if int(e.agent) != agent {
+			kept = append(kept, e)
+		} else if e.time += delay; e.time < TurnLength {
+			moved = append(moved, e)
+		}
+	}
+	for _, e := range moved {
+		k := sort.Search(len(kept), func(k int) bool { return !kept[k].before(e) })
+		kept = append(kept, event{})
+		copy(kept[k+1:], kept[k:])
+		kept[k] = e
+	}
+	s.pending = kept
+}
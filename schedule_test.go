@@ -0,0 +1,91 @@
+package main
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// TestSchedule checks that a turn's events are skipped, and put off, as the
+// Schedule says, on a population of four.
+func TestSchedule(t *testing.T) {
+	defer func(rule string, agents int) { RuleName, NumOfAgents = rule, agents }(RuleName, NumOfAgents)
+	RuleName, NumOfAgents = "leveler", 4
+	for k, c := range []struct {
+		schedule    Schedule
+		third       int32 // the agent of the third event
+		start, want []float64
+	}{
+		{Schedule{}, 0, []float64{1, 3, 5, 9}, []float64{3, 2, 3, 9}},
+		{Schedule{Condition: eventConditions["unchanged"]}, 0, []float64{1, 3, 5, 9}, []float64{2, 2, 7, 7}},
+		{Schedule{}, 1, []float64{1, 9, 5, 3}, []float64{5, 5, 5, 3}},
+		{Schedule{DeferAfterLoss: 0.3, DeferDelay: 0.5}, 1, []float64{1, 9, 5, 3}, []float64{5, 5, 4, 4}},
+	} {
+		m := NewModel(poisson, rand.New(rand.NewSource(1)))
+		copy(m.Pop.Wealth, c.start)
+		c.schedule.dispatch(m, events{{0.1, 0}, {0.2, 1}, {0.3, c.third}, {0.4, 2}, {0.5, 3}})
+		if !reflect.DeepEqual(m.Pop.Wealth, c.want) {
+			t.Errorf("case %d: wealth %v, want %v", k, m.Pop.Wealth, c.want)
+		}
+	}
+}
+
+func TestReschedule(t *testing.T) {
+	s := &Schedule{
+		pending: events{{0.1, 0}, {0.2, 1}, {0.3, 0}, {0.6, 2}, {0.9, 0}},
+		then:    make([]float64, 3),
+		wealth:  []float64{4, 5, 6},
+	}
+	s.Reschedule(0, 0.4)
+	if want := (events{{0.2, 1}, {0.5, 0}, {0.6, 2}, {0.7, 0}}); !reflect.DeepEqual(s.pending, want) {
+		t.Errorf("rescheduled to %v, want %v", s.pending, want)
+	}
+	if s.then[0] != 4 {
+		t.Errorf("rescheduled events scheduled at wealth %v", s.then[0])
+	}
+	s.Cancel(0)
+	if want := (events{{0.2, 1}, {0.6, 2}}); !reflect.DeepEqual(s.pending, want) {
+		t.Errorf("cancelled to %v, want %v", s.pending, want)
+	}
+}
+
+func TestCheckSchedule(t *testing.T) {
+	defer func(condition string, loss, delay float64) {
+		EventCondition, DeferAfterLoss, DeferDelay = condition, loss, delay
+	}(EventCondition, DeferAfterLoss, DeferDelay)
+	for _, bad := range []struct {
+		condition   string
+		loss, delay float64
+	}{{"whenever", 0, 0.25}, {"", 1.5, 0.25}, {"", 0.5, 0}} {
+		EventCondition, DeferAfterLoss, DeferDelay = bad.condition, bad.loss, bad.delay
+		if err := checkSchedule(); err == nil {
+			t.Errorf("accepted %+v", bad)
+		}
+	}
+}
+
+// TestScheduledTurns checks that every poisson regime runs with its events
+// scheduled conditionally, and that the Schedule changes how it goes.
+func TestScheduledTurns(t *testing.T) {
+	defer func(condition string, loss float64, agents int) {
+		EventCondition, DeferAfterLoss, NumOfAgents = condition, loss, agents
+	}(EventCondition, DeferAfterLoss, NumOfAgents)
+	NumOfAgents = 100
+	for _, act := range []ActivationOrder{poisson, inversePoisson, naturalPoisson, localPoisson} {
+		EventCondition, DeferAfterLoss = "", 0
+		plain := NewModel(act, rand.New(rand.NewSource(5)))
+		EventCondition, DeferAfterLoss = "unchanged", 0.2
+		m := NewModel(act, rand.New(rand.NewSource(5)))
+		if m.Schedule == nil || !reflect.DeepEqual(m.Pop.Wealth, plain.Pop.Wealth) {
+			t.Fatalf("%s: no Schedule, or a different start", act)
+		}
+		plain.Step()
+		m.Step()
+		if m.Schedule.pending != nil {
+			t.Errorf("%s: events left pending", act)
+		}
+		if reflect.DeepEqual(m.Pop.Wealth, plain.Pop.Wealth) {
+			t.Errorf("%s: the Schedule changed nothing", act)
+		}
+	}
+}
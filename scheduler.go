@@ -0,0 +1,268 @@
+package main
+
+import (
+	"log"
+	"math"
+	"math/rand"
+)
+
+/* Schedulers */
+
+/*
+ * Most activation regimes only decide who meets whom in a turn. A Scheduler
+ * is such a regime, written against the Population alone: Activate draws
+ * the turn's pairs from rng, and the Model has them exchange, in order, as
+ * it does the built-in regimes' pairs. Pairs needn't be disjoint -- an agent
+ * can meet several others in a turn, or none. RegisterScheduler makes one a
+ * regime like any other, to choose by name in Activations,
+ *
+ *	func init() {
+ *		RegisterScheduler("bursty", Poisson{Rate: burstyRate})
+ *	}
+ *
+ * Uniform, Random, Poisson (with PoissonRate, InversePoissonRate or
+ * NaturalPoissonRate) and LocalPoisson are the built-in regimes themselves.
+ * Besides Activate, each runs a Model's whole turn of its regime, drawing
+ * the same way from the same functions, but also pairing within networks,
+ * districts and markets, measuring rates against the Model's reference
+ * mean and keeping its rates and events for Schedules and traces. A
+ * registered Scheduler sees none of that, so its pairs ignore any partner
+ * restrictions.
+ */
+
+// A Pair is two agents, by index, who meet.
+type Pair struct {
+	A, B int
+}
+
+// A Scheduler decides who meets whom in a turn.
+type Scheduler interface {
+	Activate(pop Population, rng *rand.Rand) []Pair
+}
+
+// RegisterScheduler makes s available as the regime name, and returns the
+// regime.
+func RegisterScheduler(name string, s Scheduler) ActivationOrder {
+	return RegisterActivation(name, func(m *Model) { m.activate(s) })
+}
+
+// activate has the pairs s draws for this turn exchange, in order.
+func (m *Model) activate(s Scheduler) {
+	m.mark()
+	pairs := m.order[:0]
+	for _, p := range s.Activate(m.Pop, m.rng) {
+		pairs = append(pairs, p.A, p.B)
+	}
+	m.order = pairs
+	m.lap(phasePairing)
+	m.exchangePairs(pairs)
+	m.lap(phaseExchange)
+}
+
+// A modelScheduler runs a Model's whole turn of its regime.
+type modelScheduler interface {
+	Scheduler
+	turn(m *Model)
+}
+
+// A rater sets the poisson rates of a Model's agents for a turn.
+type rater interface {
+	rates(m *Model, lam []float64)
+}
+
+// builtinSchedulers are the built-in regimes, by ActivationOrder.
+var builtinSchedulers = [...]modelScheduler{
+	uniform:        Uniform{},
+	random:         Random{},
+	poisson:        Poisson{Rate: PoissonRate},
+	inversePoisson: Poisson{Rate: InversePoissonRate},
+	naturalPoisson: Poisson{Rate: NaturalPoissonRate},
+	localPoisson:   LocalPoisson{},
+}
+
+// step runs one turn of regime act on m.
+func (act ActivationOrder) step(m *Model) {
+	if c := act.custom(); c != nil {
+		m.drawing("custom")
+		c.step(m)
+		return
+	}
+	builtinSchedulers[act].turn(m)
+}
+
+// Uniform pairs off every agent once a turn, at random.
+type Uniform struct{}
+
+func (Uniform) Activate(pop Population, rng *rand.Rand) []Pair {
+	order := make([]int, pop.Len())
+	for i := range order {
+		order[i] = i
+	}
+	shufflePairs(order, rng)
+	return toPairs(order)
+}
+
+func (Uniform) turn(m *Model) {
+	m.Unifact()
+}
+
+// shufflePairs puts order's agents in random pairs, by partial shuffle: the
+// same draws as picking each pair's first and then second agent from a
+// shrinking turn list, but without the O(N) removals. An odd one out is
+// left last.
+func shufflePairs(order []int, rng *rand.Rand) {
+	n := len(order)
+	for k := 0; k+1 < n; k += 2 {
+		x := k + rng.Intn(n-k)
+		order[k], order[x] = order[x], order[k]
+		x = k + 1 + rng.Intn(n-k-1)
+		order[k+1], order[x] = order[x], order[k+1]
+	}
+}
+
+// Random draws half a Population's worth of pairs, with replacement.
+type Random struct{}
+
+func (Random) Activate(pop Population, rng *rand.Rand) []Pair {
+	return toPairs(randomPairs(nil, pop.Len(), rng))
+}
+
+func (Random) turn(m *Model) {
+	m.Randmact()
+}
+
+// randomPairs appends n/2 pairs of agents drawn from n with replacement to
+// pairs, each pair's two agents in turn.
+func randomPairs(pairs []int, n int, rng *rand.Rand) []int {
+	for i := 0; i < n/2; i++ {
+		pairs = append(pairs, rng.Intn(n), rng.Intn(n))
+	}
+	return pairs
+}
+
+// Poisson activates each agent at a rate given by Rate, of its wealth, the
+// mean and the agents' total distance from it, normalized as Normalize
+// does. The turn's events are paired off in order of time.
+type Poisson struct {
+	Rate func(wealth, mean, spread float64) float64
+}
+
+func (p Poisson) Activate(pop Population, rng *rand.Rand) []Pair {
+	lam := make([]float64, pop.Len())
+	ref := &GlobalMean{}
+	ref.Prepare(pop)
+	setRates(lam, pop.Wealth, ref, p.Rate)
+	return poissonPairs(lam, rng)
+}
+
+func (Poisson) turn(m *Model) {
+	m.Poisact()
+}
+
+// rates sets m's agents' rates for the turn, against m's reference mean. A
+// LambdaScript stands in for the poisson regime's own rate.
+func (p Poisson) rates(m *Model, lam []float64) {
+	ref := m.Reference // mean wealth, globally or locally
+	ref.Prepare(m.Pop)
+	if m.Lambda == nil || m.Activation != poisson {
+		setRates(lam, m.Pop.Wealth, ref, p.Rate)
+		return
+	}
+	setRates(lam, m.Pop.Wealth, ref, m.Lambda)
+	for i := range lam {
+		if !(lam[i] >= 0) { // negative or NaN rates would never stop drawing events
+			log.Fatalf("Lambda gave agent %d with wealth %v the rate %v; rates can't be negative", i, m.Pop.Wealth[i], lam[i])
+		}
+	}
+}
+
+// LocalPoisson activates each agent at the wealth SD of its neighborhood in
+// Net, so that unequal neighborhoods activate faster. As a built-in regime,
+// it uses the Model's network instead.
+type LocalPoisson struct {
+	Net *Network
+}
+
+func (p LocalPoisson) Activate(pop Population, rng *rand.Rand) []Pair {
+	lam := make([]float64, pop.Len())
+	localRates(lam, p.Net, pop)
+	return poissonPairs(lam, rng)
+}
+
+func (LocalPoisson) turn(m *Model) {
+	m.Poisact()
+}
+
+func (LocalPoisson) rates(m *Model, lam []float64) {
+	localRates(lam, m.Net, m.Pop)
+}
+
+// localRates sets each agent's rate to the wealth SD of its neighborhood in
+// net.
+func localRates(lam []float64, net *Network, pop Population) {
+	net.Aggregate(pop)
+	for i := range lam {
+		lam[i] = net.LocalSD(i)
+	}
+}
+
+// poissonPairs normalizes rates lam, draws a turn's events at them and
+// pairs them off in order of time.
+func poissonPairs(lam []float64, rng *rand.Rand) []Pair {
+	n := len(lam)
+	normalizeRates(lam)
+	q := EventQueue{eventHeap{getEvents(expectedEvents(n))}}
+	defer func() { putEvents(q.h.events) }()
+	drawEvents(&q, lam, rng)
+	q.heapify(nil)
+	kept := keptEvents(&q, n)
+	pairs := make([]Pair, 0, kept/2)
+	for j := 0; j+1 < kept; j += 2 {
+		_, a := q.PopNext()
+		_, b := q.PopNext()
+		pairs = append(pairs, Pair{a, b})
+	}
+	return pairs
+}
+
+// drawEvents queues a turn's activations of agents at rates lam in q, as
+// EventSampling says to draw them.
+func drawEvents(q *EventQueue, lam []float64, rng *rand.Rand) {
+	if EventSampling == "counts" {
+		for i := range lam {
+			for k := poissonCount(lam[i]*TurnLength, rng); k > 0; k-- {
+				q.add(rng.Float64()*TurnLength, i)
+			}
+		}
+		return
+	}
+	for i := range lam {
+		// find the agent's first activation time
+		nextT := -1 * math.Log(rng.Float64()) / lam[i]
+		for nextT < TurnLength {
+			// will only put the even on the scheduler if it's within the turn
+			q.add(nextT, i)
+			nextT += -1 * math.Log(rng.Float64()) / lam[i]
+		}
+	}
+}
+
+// keptEvents returns how many of q's events a turn of n agents keeps: an
+// even number, and at most a Population's worth per unit of time.
+func keptEvents(q *EventQueue, n int) int {
+	kept := q.Len() &^ 1 // make sure list is even
+	if limit := turnEvents(n); kept > limit {
+		// truncate list to Population size, per unit of time
+		kept = limit
+	}
+	return kept
+}
+
+// toPairs pairs off agents, two by two.
+func toPairs(agents []int) []Pair {
+	pairs := make([]Pair, 0, len(agents)/2)
+	for k := 0; k+1 < len(agents); k += 2 {
+		pairs = append(pairs, Pair{agents[k], agents[k+1]})
+	}
+	return pairs
+}
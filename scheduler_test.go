@@ -0,0 +1,87 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+var schedulerRegimes = map[ActivationOrder]ActivationOrder{}
+
+func init() {
+	schedulerRegimes[RegisterScheduler("scheduled uniform", Uniform{})] = uniform
+	schedulerRegimes[RegisterScheduler("scheduled random", Random{})] = random
+	schedulerRegimes[RegisterScheduler("scheduled poisson", Poisson{Rate: PoissonRate})] = poisson
+	schedulerRegimes[RegisterScheduler("scheduled inverse poisson", Poisson{Rate: InversePoissonRate})] = inversePoisson
+	schedulerRegimes[RegisterScheduler("scheduled natural poisson", Poisson{Rate: NaturalPoissonRate})] = naturalPoisson
+}
+
+// TestSchedulers checks that the built-in regimes' Schedulers, registered,
+// run the same turns as the regimes themselves.
+func TestSchedulers(t *testing.T) {
+	defer func(agents int) { NumOfAgents = agents }(NumOfAgents)
+	NumOfAgents = 50
+	for scheduled, builtIn := range schedulerRegimes {
+		want := NewModel(builtIn, rand.New(rand.NewSource(3)))
+		got := NewModel(scheduled, rand.New(rand.NewSource(3)))
+		for turn := 1; turn <= 5; turn++ {
+			want.Step()
+			got.Step()
+			for i := range want.Pop.Wealth {
+				if got.Pop.Wealth[i] != want.Pop.Wealth[i] {
+					t.Fatalf("%s, turn %d: agent %d has %v, want %v as under %s",
+						scheduled, turn, i, got.Pop.Wealth[i], want.Pop.Wealth[i], builtIn)
+				}
+			}
+		}
+	}
+}
+
+// TestRandomSchedulerRepeats checks that Random draws its pairs with
+// replacement, so that an agent can meet several others in a turn.
+func TestRandomSchedulerRepeats(t *testing.T) {
+	pop := NewPopulation(20)
+	pairs := Random{}.Activate(pop, rand.New(rand.NewSource(1)))
+	if len(pairs) != 10 {
+		t.Fatalf("%d pairs of 20 agents, want 10", len(pairs))
+	}
+	meetings := make(map[int]int)
+	for _, p := range pairs {
+		if p.A < 0 || p.A >= 20 || p.B < 0 || p.B >= 20 {
+			t.Errorf("pair %v is out of range", p)
+		}
+		meetings[p.A]++
+		meetings[p.B]++
+	}
+	if len(meetings) == 20 {
+		t.Error("every agent met exactly one other, as if drawn without replacement")
+	}
+}
+
+// TestLocalPoissonScheduler checks that LocalPoisson activates the agents
+// of unequal neighborhoods faster than those of equal ones.
+func TestLocalPoissonScheduler(t *testing.T) {
+	pop := NewPopulation(20)
+	for i := range pop.Wealth {
+		pop.Wealth[i] = 1
+	}
+	pop.Wealth[0] = 100 // only 19, 0 and 1 see any inequality
+	net := newNetwork(20)
+	for i := 0; i < 20; i++ {
+		net.addEdge(i, (i+1)%20)
+	}
+	unequal, all := 0, 0
+	rng := rand.New(rand.NewSource(2))
+	for turn := 0; turn < 20; turn++ {
+		for _, p := range (LocalPoisson{Net: net}).Activate(pop, rng) {
+			for _, a := range []int{p.A, p.B} {
+				if a == 19 || a == 0 || a == 1 {
+					unequal++
+				}
+				all++
+			}
+		}
+	}
+	if all == 0 || unequal < all/2 {
+		t.Errorf("%d of %d activations were in unequal neighborhoods, want most", unequal, all)
+	}
+}
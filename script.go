@@ -0,0 +1,132 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"fmt"
+	starmath "go.starlark.net/lib/math"
+	"go.starlark.net/starlark"
+	"log"
+	"sync"
+)
+
+/* Scripted rules */
+
+/*
+ * The exchange rule and the poisson regime's lambda can be written as short
+ * Starlark scripts -- a small dialect of Python -- in the Choices, to try out
+ * variations without recompiling. RuleScript defines exchange(a, b), which
+ * returns the pair's wealth afterwards, and is registered as the rule
+ * "script", for RuleName:
+ *
+ *	def exchange(a, b):
+ *	    m = math.floor((a + b) / 2)
+ *	    return m, m
+ *
+ * LambdaScript defines lam(wealth, mean, spread), an agent's activation rate
+ * under the poisson regime given its wealth, the reference mean and the
+ * total distance of all agents from it; the built-in rate is
+ * abs(wealth - mean) / spread. The rates are normalized to average 1
+ * afterwards, as usual. The math module is predeclared for both.
+ *
+ * A script is a good deal slower than the Go it replaces, and since it may
+ * not preserve equal wealth, runs using one are never cut short as
+ * equalized. A call that fails stops the experiment.
+ */
+
+// A script is a function defined by a Starlark script. It can be called
+// from any goroutine.
+type script struct {
+	name    string
+	fn      starlark.Value
+	threads sync.Pool // of *starlark.Thread, which can't be shared
+}
+
+// loadScript runs src, from the Choice called file, and returns the
+// function it defines called name.
+func loadScript(file, src, name string) (*script, error) {
+	globals, err := starlark.ExecFile(&starlark.Thread{Name: file}, file, src, starlark.StringDict{"math": starmath.Module})
+	if err != nil {
+		return nil, err
+	}
+	fn, ok := globals[name]
+	if !ok {
+		return nil, fmt.Errorf("%s doesn't define %s", file, name)
+	} else if _, ok := fn.(starlark.Callable); !ok {
+		return nil, fmt.Errorf("%s: %s is a %s, not a function", file, name, fn.Type())
+	}
+	s := &script{name: name, fn: fn}
+	s.threads.New = func() interface{} { return &starlark.Thread{Name: file} }
+	return s, nil
+}
+
+// call calls the function with args and returns its results, which must
+// be numbers: one, or a tuple of them.
+func (s *script) call(args ...float64) ([]float64, error) {
+	tuple := make(starlark.Tuple, len(args))
+	for i, a := range args {
+		tuple[i] = starlark.Float(a)
+	}
+	thread := s.threads.Get().(*starlark.Thread)
+	v, err := starlark.Call(thread, s.fn, tuple, nil)
+	s.threads.Put(thread)
+	if err != nil {
+		return nil, err
+	}
+	results, ok := v.(starlark.Tuple)
+	if !ok {
+		results = starlark.Tuple{v}
+	}
+	out := make([]float64, len(results))
+	for i, r := range results {
+		if out[i], ok = starlark.AsFloat(r); !ok {
+			return nil, fmt.Errorf("%s returned a %s, not a number", s.name, r.Type())
+		}
+	}
+	return out, nil
+}
+
+// mustCall is call for the model's hot loops, which can't return errors:
+// it stops the experiment if the call fails or doesn't return n results.
+func (s *script) mustCall(n int, args ...float64) []float64 {
+	out, err := s.call(args...)
+	if err == nil && len(out) != n {
+		err = fmt.Errorf("%s returned %d values, want %d", s.name, len(out), n)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	return out
+}
+
+// A scriptRule is a Rule defined by RuleScript.
+type scriptRule struct {
+	s *script
+}
+
+// Apply sets a and b to what exchange returns for them.
+func (r scriptRule) Apply(a, b *float64) {
+	out := r.s.mustCall(2, *a, *b)
+	*a, *b = out[0], out[1]
+}
+
+// loadScripts compiles RuleScript and LambdaScript, if they are set.
+func loadScripts() error {
+	if RuleScript != "" {
+		s, err := loadScript("RuleScript", RuleScript, "exchange")
+		if err != nil {
+			return err
+		}
+		RegisterRule("script", func() Rule { return scriptRule{s} })
+	}
+	if LambdaScript != "" {
+		s, err := loadScript("LambdaScript", LambdaScript, "lam")
+		if err != nil {
+			return err
+		}
+		scriptedLambda = func(wealth, mean, spread float64) float64 {
+			return s.mustCall(1, wealth, mean, spread)[0]
+		}
+	}
+	return nil
+}
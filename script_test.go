@@ -0,0 +1,45 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestScriptRule(t *testing.T) {
+	s, err := loadScript("RuleScript", "def exchange(a, b):\n    m = math.floor((a + b) / 2)\n    return m, m\n", "exchange")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := 3.0, 6.0
+	scriptRule{s}.Apply(&a, &b)
+	if a != 4 || b != 4 {
+		t.Errorf("scripted leveling of 3 and 6 gave %v and %v, want 4 and 4", a, b)
+	}
+	if _, err := s.call(1); err == nil {
+		t.Error("called exchange with one argument")
+	}
+	if _, err := loadScript("RuleScript", "def swap(a, b):\n    return b, a\n", "exchange"); err == nil {
+		t.Error("loaded a script without exchange")
+	}
+}
+
+func TestScriptLambda(t *testing.T) {
+	defer func(src string, lambda func(w, m, s float64) float64, agents int) {
+		LambdaScript, scriptedLambda, NumOfAgents = src, lambda, agents
+	}(LambdaScript, scriptedLambda, NumOfAgents)
+	// the richer an agent, the faster it activates
+	LambdaScript = "def lam(wealth, mean, spread):\n    return wealth\n"
+	NumOfAgents = 10
+	if err := loadScripts(); err != nil {
+		t.Fatal(err)
+	}
+	m := NewModel(poisson, rand.New(rand.NewSource(1)))
+	m.Step()
+	for i := 1; i < m.Pop.Len(); i++ {
+		if m.Pop.Lam[i] <= m.Pop.Lam[i-1] {
+			t.Fatalf("rates %v don't rise with wealth", m.Pop.Lam)
+		}
+	}
+}
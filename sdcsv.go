@@ -0,0 +1,85 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+)
+
+/* Long-format SD trajectories */
+
+/*
+ * With SDFile set (-csv on the command line), every turn of every run is
+ * also written to that CSV file in long format, a row per run and turn, for
+ * analysis in R or pandas:
+ *
+ *	activation,run,turn,mean,sd
+ *
+ * run counts from 1 and turn from 0, before the first; mean and sd are of
+ * the agents' wealth after the turn, sd as in the gradient regression. Each
+ * run's rows are written together when it completes, so runs appear in the
+ * order they finish.
+ */
+
+var sdHeader = []string{"activation", "run", "turn", "mean", "sd"}
+
+// An sdWriter is an Observer that writes every turn's mean wealth and SD.
+type sdWriter struct {
+	mu   sync.Mutex
+	f    *os.File
+	w    *csv.Writer
+	rows map[cell][][]string // of runs in progress
+}
+
+// newSDWriter creates the file name and writes its header.
+func newSDWriter(name string) (*sdWriter, error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	s := &sdWriter{f: f, w: csv.NewWriter(f), rows: make(map[cell][][]string)}
+	if err := s.w.Write(sdHeader); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *sdWriter) Turn(act ActivationOrder, ri, turn int, sd float64, wealth []float64) {
+	var total float64
+	for _, w := range wealth {
+		total += w
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := cell{int(act), ri}
+	s.rows[c] = append(s.rows[c], []string{
+		act.String(), strconv.Itoa(ri + 1), strconv.Itoa(turn),
+		strconv.FormatFloat(total/float64(len(wealth)), 'g', -1, 64), strconv.FormatFloat(sd, 'g', -1, 64),
+	})
+}
+
+// Done writes the run's rows.
+func (s *sdWriter) Done(act ActivationOrder, ri int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := cell{int(act), ri}
+	s.w.WriteAll(s.rows[c]) // errors are sticky; close reports them
+	delete(s.rows, c)
+}
+
+// close flushes and closes the file.
+func (s *sdWriter) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.w.Flush()
+	if err := s.w.Error(); err != nil {
+		s.f.Close()
+		return fmt.Errorf("%s: %v", s.f.Name(), err)
+	}
+	return s.f.Close()
+}
@@ -0,0 +1,36 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSDWriter(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "sds.csv")
+	s, err := newSDWriter(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Turn(poisson, 1, 0, 0.5, []float64{1, 1, 1, 1})
+	s.Turn(uniform, 0, 0, 0.75, []float64{1, 2, 3})
+	s.Turn(poisson, 1, 1, 0.25, []float64{0, 0, 0, 6})
+	s.Done(poisson, 1)
+	s.Done(uniform, 0)
+	if err := s.close(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "activation,run,turn,mean,sd\n" +
+		"poisson,2,0,1,0.5\n" +
+		"poisson,2,1,1.5,0.25\n" +
+		"uniform,1,0,2,0.75\n"
+	if string(got) != want {
+		t.Errorf("wrote\n%s\nwant\n%s", got, want)
+	}
+}
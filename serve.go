@@ -0,0 +1,296 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* HTTP service */
+
+/*
+ * "serve -listen :8080" turns the model into a small service that other
+ * programs can drive over HTTP:
+ *
+ *	POST /runs               submit a RunConfig (JSON); answers with the job
+ *	GET  /runs               every job, oldest first
+ *	GET  /runs/{id}          one job: its config and status
+ *	GET  /runs/{id}/result   the RunResult as JSON, or with ?format=csv the
+ *	                         SDs as turn,sd rows
+ *
+ * Run sets the configuration globals, so jobs run one at a time, in the order
+ * they were submitted, each starting from the configuration the server was
+ * started with. Jobs are kept in memory until the server stops. The same
+ * jobs can be driven over gRPC (see grpcserver.go), which can also follow a
+ * job's turns as they complete.
+ */
+
+// errQueueFull is returned by enqueue when no more jobs can wait.
+var errQueueFull = errors.New("too many jobs queued")
+
+// Job statuses.
+const (
+	jobQueued  = "queued"
+	jobRunning = "running"
+	jobDone    = "done"
+	jobFailed  = "failed"
+)
+
+// A job is one submitted run.
+type job struct {
+	ID        string     `json:"id"`
+	Config    RunConfig  `json:"config"`
+	Status    string     `json:"status"`
+	Error     string     `json:"error,omitempty"`
+	Submitted time.Time  `json:"submitted"`
+	Started   *time.Time `json:"started,omitempty"`
+	Finished  *time.Time `json:"finished,omitempty"`
+
+	result  *RunResult
+	turns   []TurnMetrics // one per turn completed so far, starting with turn 0
+	changed chan struct{} // closed, and replaced, whenever turns or Status change
+}
+
+// TurnMetrics summarizes a job's population after one turn.
+type TurnMetrics struct {
+	Turn int     `json:"turn"`
+	SD   float64 `json:"sd"`
+	Gini float64 `json:"gini"`
+}
+
+// jobServer queues runs submitted over HTTP and runs them in turn.
+type jobServer struct {
+	defaults Params // the configuration every job starts from
+	queue    chan *job
+
+	mu    sync.Mutex // guards jobs, order and every job's fields
+	jobs  map[string]*job
+	order []string
+}
+
+// newJobServer returns a server whose jobs start from the current
+// configuration, with its runner started.
+func newJobServer() *jobServer {
+	s := &jobServer{defaults: currentParams(), queue: make(chan *job, 1024), jobs: make(map[string]*job)}
+	go s.runJobs()
+	return s
+}
+
+// runJobs runs queued jobs one after another.
+func (s *jobServer) runJobs() {
+	for j := range s.queue {
+		s.mu.Lock()
+		now := time.Now()
+		j.Status, j.Started = jobRunning, &now
+		cfg := j.Config
+		s.mu.Unlock()
+
+		s.defaults.apply()
+		var sorted []float64
+		res, err := runConfig(cfg, func(turn int, sd float64, wealth []float64) {
+			sorted = append(sorted[:0], wealth...)
+			sort.Float64s(sorted)
+			total := 0.0
+			for _, w := range sorted {
+				total += w
+			}
+			s.mu.Lock()
+			j.turns = append(j.turns, TurnMetrics{turn, sd, gini(sorted, total)})
+			j.notify()
+			s.mu.Unlock()
+		})
+
+		s.mu.Lock()
+		now = time.Now()
+		j.Finished = &now
+		if err != nil {
+			j.Status, j.Error = jobFailed, err.Error()
+		} else {
+			j.Status, j.result = jobDone, res
+		}
+		j.notify()
+		s.mu.Unlock()
+	}
+}
+
+// notify wakes everyone waiting on j to change. The caller holds the
+// server's lock.
+func (j *job) notify() {
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+// enqueue validates cfg and queues a job to run it.
+func (s *jobServer) enqueue(cfg RunConfig) (*job, error) {
+	if cfg.Activation != "" {
+		if _, err := ParseActivation(cfg.Activation); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.RNG != "" {
+		if _, err := NewSource(cfg.RNG, cfg.Seed); err != nil {
+			return nil, err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j := &job{ID: strconv.Itoa(len(s.order) + 1), Config: cfg, Status: jobQueued, Submitted: time.Now(),
+		changed: make(chan struct{})}
+	select {
+	case s.queue <- j:
+	default:
+		return nil, errQueueFull
+	}
+	s.jobs[j.ID] = j
+	s.order = append(s.order, j.ID)
+	return j, nil
+}
+
+// progress returns the turns job id has completed so far, whether it is
+// over, and a channel that is closed when that changes. ok is false if there
+// is no such job.
+func (s *jobServer) progress(id string) (turns []TurnMetrics, over bool, changed <-chan struct{}, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return nil, false, nil, false
+	}
+	return j.turns, j.Status == jobDone || j.Status == jobFailed, j.changed, true
+}
+
+// lookup returns job id's status, result and error message.
+func (s *jobServer) lookup(id string) (status string, res *RunResult, msg string, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return "", nil, "", false
+	}
+	return j.Status, j.result, j.Error, true
+}
+
+func (s *jobServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/runs"), "/")
+	if path == "" {
+		if r.Method == http.MethodPost {
+			s.submit(w, r)
+		} else if r.Method == http.MethodGet {
+			s.list(w)
+		} else {
+			http.Error(w, "GET or POST /runs", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET "+r.URL.Path, http.StatusMethodNotAllowed)
+		return
+	}
+	id, rest := path, ""
+	if i := strings.Index(path, "/"); i >= 0 {
+		id, rest = path[:i], path[i+1:]
+	}
+	s.mu.Lock()
+	j, ok := s.jobs[id]
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, fmt.Sprintf("no job %q", id), http.StatusNotFound)
+		return
+	}
+	if rest == "" {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		writeJSON(w, http.StatusOK, j)
+	} else if rest == "result" {
+		s.result(w, r, j)
+	} else {
+		http.NotFound(w, r)
+	}
+}
+
+// submit queues the RunConfig in the request body.
+func (s *jobServer) submit(w http.ResponseWriter, r *http.Request) {
+	var cfg RunConfig
+	if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	j, err := s.enqueue(cfg)
+	if err == errQueueFull {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Location", "/runs/"+j.ID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusAccepted, j)
+}
+
+// list writes every job.
+func (s *jobServer) list(w http.ResponseWriter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]*job, len(s.order))
+	for i, id := range s.order {
+		jobs[i] = s.jobs[id]
+	}
+	writeJSON(w, http.StatusOK, jobs)
+}
+
+// result writes j's result, if it has one.
+func (s *jobServer) result(w http.ResponseWriter, r *http.Request, j *job) {
+	status, res, msg, _ := s.lookup(j.ID)
+	if status == jobFailed {
+		http.Error(w, "job failed: "+msg, http.StatusConflict)
+		return
+	} else if status != jobDone {
+		http.Error(w, "job is "+status, http.StatusConflict)
+		return
+	}
+	if r.URL.Query().Get("format") == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"turn", "sd"})
+		for t, sd := range res.SDs {
+			cw.Write([]string{strconv.Itoa(t), strconv.FormatFloat(sd, 'g', -1, 64)})
+		}
+		cw.Flush()
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// writeJSON writes v as the JSON body of a response with the given status.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// runServe serves runs over HTTP until the server fails.
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	listen := fs.String("listen", ":8080", "address to serve on")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	s := newJobServer()
+	mux.Handle("/runs", s)
+	mux.Handle("/runs/", s)
+	log.Printf("serving runs on %s", *listen)
+	server := &http.Server{Addr: *listen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	return server.ListenAndServe()
+}
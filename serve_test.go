@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestServeRun submits a run over HTTP, waits for it and fetches its result
+// both ways.
+func TestServeRun(t *testing.T) {
+	defer currentParams().apply()
+	server := httptest.NewServer(newJobServer())
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/runs", "application/json",
+		strings.NewReader(`{"activation": "poisson", "agents": 100, "turns": 4, "seed": 5}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var j job
+	json.NewDecoder(resp.Body).Decode(&j)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted || j.ID == "" {
+		t.Fatalf("submit: status %d, job %+v", resp.StatusCode, j)
+	}
+
+	for deadline := time.Now().Add(10 * time.Second); j.Status != jobDone; {
+		if j.Status == jobFailed || time.Now().After(deadline) {
+			t.Fatalf("job %+v", j)
+		}
+		time.Sleep(10 * time.Millisecond)
+		resp, err := http.Get(server.URL + "/runs/" + j.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		json.NewDecoder(resp.Body).Decode(&j)
+		resp.Body.Close()
+	}
+
+	resp, err = http.Get(server.URL + "/runs/" + j.ID + "/result")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res RunResult
+	json.NewDecoder(resp.Body).Decode(&res)
+	resp.Body.Close()
+	if len(res.SDs) != 5 || len(res.Wealth) != 100 {
+		t.Errorf("result has %d SDs and %d agents, want 5 and 100", len(res.SDs), len(res.Wealth))
+	}
+
+	resp, err = http.Get(server.URL + "/runs/" + j.ID + "/result?format=csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if lines := strings.Count(string(body), "\n"); lines != 6 {
+		t.Errorf("CSV result has %d lines, want 6:\n%s", lines, body)
+	}
+}
+
+func TestServeUnknownJob(t *testing.T) {
+	server := httptest.NewServer(newJobServer())
+	defer server.Close()
+	resp, err := http.Get(server.URL + "/runs/99")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown job: status %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
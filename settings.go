@@ -0,0 +1,141 @@
+package main
+
+/* Sweepable Choices */
+
+/*
+ * The Choices that can be set by name. Those that change what a run does are
+ * sweepable: varied by a sweep (see sweep.go), set by a configuration file
+ * (see config.go), and checked to match between a coordinator and its remote
+ * workers (see distributed.go). The rest -- the files runs read, what they
+ * write and report, and how hard the experiment works the machine -- are
+ * only configurable, by file. Input files are named rather than compared, so
+ * remote workers must be given the same ones.
+ */
+
+// sweepable are the Choices a sweep can vary, by normalized name.
+var sweepable = map[string]interface{}{
+	"numofagents":          &NumOfAgents,
+	"numturns":             &NumTurns,
+	"numruns":              &NumRuns,
+	"activations":          &Activations,
+	"rulename":             &RuleName,
+	"remainderto":          &RemainderTo,
+	"rng":                  &RNG,
+	"neighborhoodradius":   &NeighborhoodRadius,
+	"migrationrate":        &MigrationRate,
+	"couplingrate":         &CouplingRate,
+	"homophily":            &Homophily,
+	"homophilyquantiles":   &HomophilyQuantiles,
+	"districts":            &Districts,
+	"crossdistrict":        &CrossDistrict,
+	"crossregion":          &CrossRegion,
+	"directedrate":         &DirectedRate,
+	"sellershare":          &SellerShare,
+	"classrules":           &ClassRules,
+	"distancedecay":        &DistanceDecay,
+	"decayscale":           &DecayScale,
+	"decayexponent":        &DecayExponent,
+	"mobilityplaces":       &MobilityPlaces,
+	"mobilityrate":         &MobilityRate,
+	"lambdareference":      &LambdaReference,
+	"initialwealth":        &InitialWealth,
+	"initialtotal":         &InitialTotal,
+	"initialmean":          &InitialMean,
+	"cohorts":              &Cohorts,
+	"hoarderrefusal":       &HoarderRefusal,
+	"learnerrefusal":       &LearnerRefusal,
+	"learningrate":         &LearningRate,
+	"yardsalefraction":     &YardSaleFraction,
+	"riskaversion":         &RiskAversion,
+	"birthrate":            &BirthRate,
+	"exitrate":             &ExitRate,
+	"birthwealth":          &BirthWealth,
+	"exitwealth":           &ExitWealth,
+	"levelingfraction":     &LevelingFraction,
+	"transferfraction":     &TransferFraction,
+	"eventcondition":       &EventCondition,
+	"deferafterloss":       &DeferAfterLoss,
+	"deferdelay":           &DeferDelay,
+	"turnlength":           &TurnLength,
+	"eventsampling":        &EventSampling,
+	"compareregimes":       &CompareRegimes,
+	"regionrules":          &RegionRules,
+	"districtsperregion":   &DistrictsPerRegion,
+	"directededges":        &DirectedEdges,
+	"directedexchange":     &DirectedExchange,
+	"networkpairing":       &NetworkPairing,
+	"bipartitemarket":      &BipartiteMarket,
+	"neighborhoodleveling": &NeighborhoodLeveling,
+	"skipequalized":        &SkipEqualized,
+	"equalizedtolerance":   &EqualizedTolerance,
+	"precision":            &Precision,
+	"wealthparams":         &WealthParams,
+	"agenttypes":           &AgentTypes,
+	"riskparams":           &RiskParams,
+	"birthparams":          &BirthParams,
+	"interventions":        &Interventions,
+}
+
+// configurable are the other Choices a configuration file can set, by
+// normalized name.
+var configurable = map[string]interface{}{
+	"workers":               &Workers,
+	"parallelthreshold":     &ParallelThreshold,
+	"batchexchange":         &BatchExchange,
+	"parallelsortthreshold": &ParallelSortThreshold,
+	"remoteworkers":         &RemoteWorkers,
+	"remoteslots":           &RemoteSlots,
+	"remotetimeout":         &RemoteTimeout,
+	"regionactivations":     &RegionActivations,
+	"edgelistfile":          &EdgeListFile,
+	"temporaledgelistfile":  &TemporalEdgeListFile,
+	"coordinatesfile":       &CoordinatesFile,
+	"initialwealthfile":     &InitialWealthFile,
+	"wealthdatafile":        &WealthDataFile,
+	"cohortfile":            &CohortFile,
+	"warmstartfile":         &WarmStartFile,
+	"plugins":               &Plugins,
+	"rulescript":            &RuleScript,
+	"lambdascript":          &LambdaScript,
+	"networkstatssources":   &NetworkStatsSources,
+	"networksnapshotturns":  &NetworkSnapshotTurns,
+	"networksnapshotformat": &NetworkSnapshotFormat,
+	"largescale":            &LargeScale,
+	"memorylimit":           &MemoryLimit,
+	"reporttimings":         &ReportTimings,
+	"reportmemory":          &ReportMemory,
+	"streamresults":         &StreamResults,
+	"rawrowsfile":           &RawRowsFile,
+	"distributionsfile":     &DistributionsFile,
+	"quantileslopes":        &QuantileSlopes,
+	"metrics":               &Metrics,
+	"metricworkers":         &MetricWorkers,
+	"metricsample":          &MetricSample,
+	"auditprecision":        &AuditPrecision,
+	"tui":                   &TUI,
+	"plotsdir":              &PlotsDir,
+	"plotformat":            &PlotFormat,
+	"lorenzeveryturn":       &LorenzEveryTurn,
+	"animaterun":            &AnimateRun,
+	"animationbins":         &AnimationBins,
+	"histogramsfile":        &HistogramsFile,
+	"histogrambins":         &HistogramBins,
+	"histogramruns":         &HistogramRuns,
+	"sdfile":                &SDFile,
+	"activationhistory":     &ActivationHistory,
+	"replicationtrace":      &ReplicationTrace,
+	"trackagents":           &TrackAgents,
+	"cohortmetrics":         &CohortMetrics,
+	"savefinalstate":        &SaveFinalState,
+	"auditdraws":            &AuditDraws,
+	"snapshotevery":         &SnapshotEvery,
+	"snapshotkeep":          &SnapshotKeep,
+	"hookeveryrun":          &HookEveryRun,
+	"dashboardaddr":         &DashboardAddr,
+	"flightaddr":            &FlightAddr,
+	"webhookurl":            &WebhookURL,
+	"hookcommand":           &HookCommand,
+	"mesafile":              &MesaFile,
+	"reloadfile":            &ReloadFile,
+	"controladdr":           &ControlAddr,
+}
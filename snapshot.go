@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+)
+
+/* Snapshots and rewinding */
+
+/*
+ * With SnapshotEvery set, a Model built by NewRewindableModel, as the
+ * repl's are, keeps a copy of itself every SnapshotEvery turns, starting
+ * from turn 0, and holds on to the last SnapshotKeep of them. Rewind takes
+ * it back to any of those turns, random number generator and all, so a
+ * trajectory that took an interesting turn can be replayed from just
+ * before it -- or explored down another branch, after changing a setting or
+ * intervening. Anything changed since the snapshot, the live settings (see
+ * live.go) included, is as it was then.
+ *
+ * The generator is taken back by replaying its draws (see tape in rng.go),
+ * which costs far less than the turns that made them and works for any
+ * RNG. Snapshots of turns after the one rewound to belong to the branch
+ * left behind, so they're dropped.
+ */
+
+// Snapshots are the copies of itself a Model keeps to rewind to.
+type Snapshots struct {
+	Every, Keep int
+
+	tape  *tape        // the Model's generator
+	taken []checkpoint // oldest first
+}
+
+// A checkpoint is a Model as it was after a turn, and the number of draws
+// its generator had made by then.
+type checkpoint struct {
+	m     *Model
+	draws uint64
+}
+
+// checkSnapshots reports whether the snapshot Choices make sense.
+func checkSnapshots() error {
+	if SnapshotEvery < 0 {
+		return errors.New("SnapshotEvery can't be negative")
+	} else if SnapshotEvery > 0 && SnapshotKeep < 1 {
+		return errors.New("SnapshotKeep must be at least 1")
+	}
+	return nil
+}
+
+// NewRewindableModel returns a new Model of act drawing from the RNG
+// generator seeded with seed, which keeps Snapshots if the Choices ask for
+// them.
+func NewRewindableModel(act ActivationOrder, seed int64) *Model {
+	if SnapshotEvery == 0 {
+		return NewModel(act, newRand(seed))
+	}
+	if err := checkSnapshots(); err != nil {
+		panic(err)
+	}
+	t := newTape(seed)
+	m := NewModel(act, rand.New(t))
+	m.Snapshots = &Snapshots{Every: SnapshotEvery, Keep: SnapshotKeep, tape: t}
+	m.Snapshots.Take(m)
+	return m
+}
+
+// Take keeps a copy of m if its turn is one to, making room by dropping the
+// oldest.
+func (s *Snapshots) Take(m *Model) {
+	if m.Turn%s.Every != 0 {
+		return
+	}
+	if len(s.taken) == s.Keep {
+		s.taken = append(s.taken[:0], s.taken[1:]...)
+	}
+	s.taken = append(s.taken, checkpoint{m.snapshot(), s.tape.draws})
+}
+
+// Turns returns the turns there are snapshots of, in order.
+func (s *Snapshots) Turns() []int {
+	turns := make([]int, len(s.taken))
+	for k, cp := range s.taken {
+		turns[k] = cp.m.Turn
+	}
+	return turns
+}
+
+// Rewind restores m to how it was after the given turn, which must be one
+// it has a snapshot of.
+func (m *Model) Rewind(turn int) error {
+	s := m.Snapshots
+	if s == nil {
+		return errors.New("no snapshots to rewind to; set SnapshotEvery")
+	}
+	for k, cp := range s.taken {
+		if cp.m.Turn == turn {
+			m.Release()
+			*m = *cp.m.snapshot()
+			m.Snapshots = s
+			s.tape.seek(cp.draws)
+			s.taken = s.taken[:k+1]
+			return nil
+		}
+	}
+	return fmt.Errorf("no snapshot of turn %d (there are of %v)", turn, s.Turns())
+}
+
+// snapshot returns a copy of m as Clone does, but carrying on its run
+// rather than starting a new one: its types' refusals and its births and
+// exits are counted from where m's are, and it keeps no snapshots.
+func (m *Model) snapshot() *Model {
+	c := m.Clone()
+	c.Snapshots = nil
+	if m.Types != nil {
+		c.Types.refusals = m.Types.refusals
+	}
+	if m.Demography != nil {
+		next := c.Demography.nextID
+		*c.Demography = *m.Demography
+		c.Demography.nextID = next
+	}
+	return c
+}
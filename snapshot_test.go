@@ -0,0 +1,60 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestRewind checks that a Model rewound to a snapshot retraces its steps
+// exactly, with every generator and regime, births and exits included.
+func TestRewind(t *testing.T) {
+	defer func(every, keep, agents int, births, exits float64, rng string) {
+		SnapshotEvery, SnapshotKeep, NumOfAgents, BirthRate, ExitRate, RNG = every, keep, agents, births, exits, rng
+	}(SnapshotEvery, SnapshotKeep, NumOfAgents, BirthRate, ExitRate, RNG)
+	SnapshotEvery, SnapshotKeep, NumOfAgents, BirthRate, ExitRate = 2, 3, 50, 0.05, 0.05
+	for _, rng := range []string{"math/rand", "pcg", "xoshiro"} {
+		RNG = rng
+		for _, act := range []ActivationOrder{uniform, random, poisson} {
+			m := NewRewindableModel(act, 4)
+			var wealth [][]float64
+			for turn := 0; turn < 10; turn++ {
+				m.Step()
+				wealth = append(wealth, append([]float64(nil), m.Pop.Wealth...))
+			}
+			if turns := m.Snapshots.Turns(); !reflect.DeepEqual(turns, []int{6, 8, 10}) {
+				t.Fatalf("%s, %s: snapshots of %v", rng, act, turns)
+			}
+			if err := m.Rewind(4); err == nil {
+				t.Errorf("%s, %s: rewound to a dropped snapshot", rng, act)
+			}
+			births := m.Demography.Births
+			for _, back := range []int{6, 8} {
+				if err := m.Rewind(back); err != nil {
+					t.Fatal(err)
+				}
+				if m.Turn != back || !reflect.DeepEqual(m.Pop.Wealth, wealth[back-1]) {
+					t.Errorf("%s, %s: turn %d after rewinding to %d", rng, act, m.Turn, back)
+				}
+				for m.Turn < 10 {
+					m.Step()
+					if !reflect.DeepEqual(m.Pop.Wealth, wealth[m.Turn-1]) {
+						t.Errorf("%s, %s: turn %d differs after rewinding to %d", rng, act, m.Turn, back)
+					}
+				}
+				if m.Demography.Births != births {
+					t.Errorf("%s, %s: %d births, want %d", rng, act, m.Demography.Births, births)
+				}
+			}
+		}
+	}
+}
+
+func TestNoSnapshots(t *testing.T) {
+	m := NewRewindableModel(uniform, 1)
+	if m.Snapshots != nil {
+		t.Fatal("snapshots kept without SnapshotEvery")
+	}
+	if err := m.Rewind(0); err == nil {
+		t.Error("rewound without snapshots")
+	}
+}
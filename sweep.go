@@ -0,0 +1,238 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+/* Parameter sweeps */
+
+/*
+ * "sweep design.xml" runs the experiment at every point of a grid of Choices
+ * and writes a row per run to sweep.csv (-o), ready for "plot -sweep": the
+ * varied parameters, in the design's order, then the regime, the run and its
+ * gradient and final wealth SD. The design is a NetLogo BehaviorSpace
+ * experiment or a Repast Simphony batch parameter file (see paramfiles.go),
+ * so existing experiment designs carry over. Their parameters are matched to
+ * the sweepable Choices (see settings.go) by name, ignoring case, hyphens
+ * and underscores, or by a few common aliases ("num-agents", "steps",
+ * "rule"); parameters that don't match any are an error rather than
+ * silently ignored.
+ * Every point uses the same master seed, so points differ only in their
+ * parameters.
+ */
+
+// sweepAliases are other names parameter files commonly use for Choices.
+var sweepAliases = map[string]string{
+	"n":          "numofagents",
+	"agents":     "numofagents",
+	"numagents":  "numofagents",
+	"population": "numofagents",
+	"turns":      "numturns",
+	"steps":      "numturns",
+	"ticks":      "numturns",
+	"runs":       "numruns",
+	"activation": "activations",
+	"regime":     "activations",
+	"rule":       "rulename",
+}
+
+// normalizeParam returns the key of sweepable a parameter name matches.
+func normalizeParam(name string) string {
+	key := strings.ToLower(name)
+	for _, sep := range []string{"-", "_", " ", "?"} {
+		key = strings.Replace(key, sep, "", -1)
+	}
+	if alias, ok := sweepAliases[key]; ok {
+		return alias
+	}
+	return key
+}
+
+// A sweepParam is a parameter of a sweep and the values it takes.
+type sweepParam struct {
+	Name   string
+	Values []string
+}
+
+// A sweepDesign is a grid of parameter values to run the experiment at.
+type sweepDesign struct {
+	Params      []sweepParam
+	Repetitions int // runs at each point, if > 0; otherwise NumRuns
+}
+
+// varied returns the parameters that take more than one value.
+func (d *sweepDesign) varied() []sweepParam {
+	var ps []sweepParam
+	for _, p := range d.Params {
+		if len(p.Values) > 1 {
+			ps = append(ps, p)
+		}
+	}
+	return ps
+}
+
+// points returns every combination of the parameters' values, the last
+// parameter varying fastest.
+func (d *sweepDesign) points() [][]string {
+	points := [][]string{nil}
+	for _, p := range d.Params {
+		var next [][]string
+		for _, point := range points {
+			for _, v := range p.Values {
+				next = append(next, append(append([]string(nil), point...), v))
+			}
+		}
+		points = next
+	}
+	return points
+}
+
+// check reports whether every parameter names a sweepable Choice.
+func (d *sweepDesign) check() error {
+	for _, p := range d.Params {
+		if _, ok := sweepable[normalizeParam(p.Name)]; !ok {
+			return fmt.Errorf("can't sweep %q: it isn't a sweepable Choice", p.Name)
+		} else if len(p.Values) == 0 {
+			return fmt.Errorf("parameter %q has no values", p.Name)
+		}
+	}
+	return nil
+}
+
+// setParam sets the Choice a parameter names to value.
+func setParam(name, value string) error {
+	var err error
+	switch v := sweepable[normalizeParam(name)].(type) {
+	case *int:
+		var f float64 // NetLogo writes whole numbers as 100.0, too
+		if f, err = strconv.ParseFloat(value, 64); err == nil {
+			if *v = int(f); float64(*v) != f {
+				err = errors.New("not a whole number")
+			}
+		}
+	case *float64:
+		*v, err = strconv.ParseFloat(value, 64)
+	case *bool:
+		*v, err = strconv.ParseBool(value)
+	case *string:
+		*v = value
+	case *[]string:
+		*v = []string{value}
+	case *[]float64: // separated by spaces or commas, in brackets or not
+		fields := strings.FieldsFunc(strings.Trim(value, "[]"), func(r rune) bool { return r == ',' || unicode.IsSpace(r) })
+		list := make([]float64, len(fields))
+		for i, f := range fields {
+			if list[i], err = strconv.ParseFloat(f, 64); err != nil {
+				break
+			}
+		}
+		*v = list
+	default:
+		return fmt.Errorf("can't sweep %q", name)
+	}
+	if err != nil {
+		return fmt.Errorf("%s = %q: %v", name, value, err)
+	}
+	return nil
+}
+
+// runSweep is the sweep subcommand.
+func runSweep(args []string) error {
+	fs := flag.NewFlagSet("sweep", flag.ExitOnError)
+	out := fs.String("o", "sweep.csv", "file to write the runs to")
+	experiment := fs.String("experiment", "", "BehaviorSpace experiment to run, if the file has several")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: sweep [-o sweep.csv] [-experiment name] design.xml")
+	}
+	design, err := readParamFile(fs.Arg(0), *experiment)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	err = sweep(design, time.Now().UTC().UnixNano(), w)
+	w.Flush()
+	if err == nil {
+		err = w.Error()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// sweep runs the experiment at every point of the design with the given
+// master seed, writing the runs to w.
+func sweep(d *sweepDesign, seed int64, w *csv.Writer) error {
+	if err := d.check(); err != nil {
+		return err
+	}
+	if d.Repetitions > 0 {
+		NumRuns = d.Repetitions
+	}
+	varied := make(map[string]bool)
+	header := []string{}
+	for _, p := range d.varied() {
+		varied[p.Name] = true
+		header = append(header, p.Name)
+	}
+	if err := w.Write(append(header, "regime", "run", "gradient", "final_sd")); err != nil {
+		return err
+	}
+	points := d.points()
+	for i, point := range points {
+		var columns []string
+		for j, p := range d.Params {
+			if err := setParam(p.Name, point[j]); err != nil {
+				return err
+			}
+			if varied[p.Name] {
+				columns = append(columns, point[j])
+			}
+		}
+		fmt.Printf("Sweep point %d of %d: %s\n", i+1, len(points), strings.Join(columns, ", "))
+		if err := sweepPoint(seed, columns, w); err != nil {
+			return fmt.Errorf("sweep point %d (%s): %v", i+1, strings.Join(columns, ", "), err)
+		}
+	}
+	return nil
+}
+
+// sweepPoint runs the experiment as the Choices now are, writing each run
+// to w after the point's columns.
+func sweepPoint(seed int64, columns []string, w *csv.Writer) error {
+	if err := checkChoices(seed); err != nil {
+		return err
+	}
+	acts, err := experimentActivations()
+	if err != nil {
+		return err
+	}
+	var werr error
+	collect := func(res cellResult) {
+		row := append(append([]string(nil), columns...), acts[res.act].String(), strconv.Itoa(res.run+1),
+			strconv.FormatFloat(gradient(res.sds), 'g', -1, 64),
+			strconv.FormatFloat(res.sds[len(res.sds)-1], 'g', -1, 64))
+		if err := w.Write(row); err != nil && werr == nil {
+			werr = err
+		}
+	}
+	if err := (Experiment{acts, seed}).Run(collect); err != nil {
+		return err
+	}
+	return werr
+}
@@ -0,0 +1,67 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestSweepPoints(t *testing.T) {
+	d := &sweepDesign{Params: []sweepParam{{"a", []string{"1", "2"}}, {"b", []string{"x"}}, {"c", []string{"3", "4"}}}}
+	want := [][]string{{"1", "x", "3"}, {"1", "x", "4"}, {"2", "x", "3"}, {"2", "x", "4"}}
+	if got := d.points(); !reflect.DeepEqual(got, want) {
+		t.Errorf("points %v, want %v", got, want)
+	}
+	if err := (&sweepDesign{Params: []sweepParam{{"tax-rate", []string{"1"}}}}).check(); err == nil {
+		t.Error("swept a parameter the model doesn't have")
+	}
+}
+
+func TestSetParam(t *testing.T) {
+	defer func(market bool, params []float64) {
+		BipartiteMarket, WealthParams = market, params
+	}(BipartiteMarket, WealthParams)
+	if err := setParam("bipartite-market", "true"); err != nil || !BipartiteMarket {
+		t.Errorf("set BipartiteMarket %v, %v", BipartiteMarket, err)
+	}
+	if err := setParam("WealthParams", "[1.5 2]"); err != nil || !reflect.DeepEqual(WealthParams, []float64{1.5, 2}) {
+		t.Errorf("set WealthParams %v, %v", WealthParams, err)
+	}
+	for _, bad := range [][2]string{{"bipartitemarket", "sometimes"}, {"wealthparams", "1,x"}, {"edgelistfile", "net.txt"}} {
+		if err := setParam(bad[0], bad[1]); err == nil {
+			t.Errorf("set %s to %q", bad[0], bad[1])
+		}
+	}
+}
+
+func TestSweep(t *testing.T) {
+	defer func(runs, turns, agents int, homophily float64, acts []string, out io.Writer) {
+		NumRuns, NumTurns, NumOfAgents, Homophily, Activations, cellOutput = runs, turns, agents, homophily, acts, out
+	}(NumRuns, NumTurns, NumOfAgents, Homophily, Activations, cellOutput)
+	NumTurns, cellOutput = 3, io.Discard
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	d := &sweepDesign{Repetitions: 2, Params: []sweepParam{
+		{"num-agents", []string{"10.0", "20"}},
+		{"Homophily", []string{"0", "0.5"}},
+		{"activation", []string{"poisson"}},
+	}}
+	if err := sweep(d, 1, w); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+	g, err := readSweep(&buf, "final_sd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(g.xs, []float64{10, 20}) || !reflect.DeepEqual(g.ys, []float64{0, 0.5}) || !g.complete() {
+		t.Errorf("sweep grid over %v by %v, complete %v", g.xs, g.ys, g.complete())
+	}
+	if NumOfAgents != 20 || NumRuns != 2 {
+		t.Errorf("left NumOfAgents %d and NumRuns %d", NumOfAgents, NumRuns)
+	}
+}
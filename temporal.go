@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+/* Temporal networks */
+
+type timedEdge struct {
+	i, j       int
+	w          float64
+	d          Direction
+	start, end int
+}
+
+/*
+ * A TemporalNetwork is an edge list in which every edge is only present
+ * during a range of turns, as in empirically observed contact networks.
+ * Turns are counted from 1, the first Step of a run.
+ */
+type TemporalNetwork struct {
+	n     int
+	edges []timedEdge
+}
+
+// LoadTemporalEdgeList reads a time-stamped network over n agents. Each line
+// holds two agent indices, the first and last turn during which the edge is
+// active, and an optional weight, in the format of LoadEdgeList.
+func LoadTemporalEdgeList(r io.Reader, n int, directed bool) (*TemporalNetwork, error) {
+	tn := &TemporalNetwork{n: n}
+	d := Undirected
+	if directed {
+		d = Forward
+	}
+	err := scanEdges(r, n, 2, func(i, j int, w float64, extra []string) error {
+		start, err := strconv.Atoi(extra[0])
+		if err != nil {
+			return err
+		}
+		end, err := strconv.Atoi(extra[1])
+		if err != nil {
+			return err
+		}
+		if end < start {
+			return fmt.Errorf("edge %d-%d ends (turn %d) before it starts (turn %d)", i, j, end, start)
+		}
+		tn.edges = append(tn.edges, timedEdge{i, j, w, d, start, end})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tn, nil
+}
+
+// At returns the network of edges active during the given turn.
+func (tn *TemporalNetwork) At(turn int) *Network {
+	net := newNetwork(tn.n)
+	for _, e := range tn.edges {
+		if e.start <= turn && turn <= e.end {
+			net.addDirectedEdge(e.i, e.j, e.w, e.d)
+		}
+	}
+	return net
+}
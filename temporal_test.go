@@ -0,0 +1,64 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+const temporalEdges3 = `# i j first last [weight]
+0 1 1 2
+1 2 2 3 0.5
+`
+
+// TestTemporalEdgeList checks which edges are active on which turns, with
+// their weights, and that an edge may not end before it starts.
+func TestTemporalEdgeList(t *testing.T) {
+	tn, err := LoadTemporalEdgeList(strings.NewReader(temporalEdges3), 3, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for turn, want := range [][]int{{}, {0}, {0, 2}, {2}, {}} {
+		if got := tn.At(turn).Neighbors(1); len(got) != len(want) {
+			t.Errorf("turn %d: agent 1's neighbors are %v, want %v", turn, got, want)
+		} else {
+			for k := range got {
+				if got[k] != want[k] {
+					t.Errorf("turn %d: agent 1's neighbors are %v, want %v", turn, got, want)
+				}
+			}
+		}
+	}
+	if w := tn.At(3).Weights(2); len(w) != 1 || w[0] != 0.5 {
+		t.Errorf("turn 3: agent 2's weights are %v", w)
+	}
+	if _, err := LoadTemporalEdgeList(strings.NewReader("0 1 3 2\n"), 3, false); err == nil {
+		t.Error("edge ending before it starts accepted")
+	}
+	directed, err := LoadTemporalEdgeList(strings.NewReader(temporalEdges3), 3, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := directed.At(2).Direction(2, 1); d != Backward {
+		t.Errorf("directed edge 1-2 seen from 2 is %v", d)
+	}
+}
+
+// TestTemporalModel checks that a Model steps through the snapshots of its
+// temporal network, turn by turn.
+func TestTemporalModel(t *testing.T) {
+	defer func(agents int) { NumOfAgents = agents }(NumOfAgents)
+	NumOfAgents = 3
+	m := NewModel(random, rand.New(rand.NewSource(1)))
+	tn, err := LoadTemporalEdgeList(strings.NewReader(temporalEdges3), 3, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Temporal = tn
+	for turn, want := range []int{1, 2, 1, 0} { // agent 1's degree on turns 1 to 4
+		m.Step()
+		if got := len(m.Net.Neighbors(1)); got != want {
+			t.Errorf("turn %d: agent 1 has %d neighbors, want %d", turn+1, got, want)
+		}
+	}
+}
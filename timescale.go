@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+/* Turn length */
+
+/*
+ * A turn stands for TurnLength of simulated time. The poisson regimes draw
+ * each agent's events over that long, at the rate Normalize gives it per
+ * unit of time, and pair off at most a Pop's worth of them per unit: a
+ * turn of 5 activates about five times as many agents as a turn of 1, and
+ * a turn of 0.2 about a fifth, with the same rates. So how often the
+ * population is observed can be set apart from how intensely it is
+ * activated. The other regimes activate a Pop's worth a turn whatever its
+ * length, and a Schedule's DeferDelay is a share of a turn.
+ *
+ * Trajectories are reported against time rather than turns: gradients are
+ * slopes of log wealth SD per unit of time, trajectories.csv has each
+ * turn's time, and the plots are drawn against it.
+ */
+
+// checkTurnLength returns an error unless TurnLength is positive.
+func checkTurnLength() error {
+	if !(TurnLength > 0) || math.IsInf(TurnLength, 1) {
+		return fmt.Errorf("TurnLength %v isn't positive", TurnLength)
+	}
+	return nil
+}
+
+// turnEvents returns how many events a poisson turn of n agents pairs off at
+// most.
+func turnEvents(n int) int {
+	return int(float64(n) * TurnLength)
+}
+
+// timeLabel labels an axis of turns of the given length.
+func timeLabel(length float64) string {
+	if length == 1 {
+		return "Turn"
+	}
+	return fmt.Sprintf("Time (%g a turn)", length)
+}
@@ -0,0 +1,62 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+// TestTurnLength checks that longer turns activate more agents a turn, and
+// that trajectories and gradients are against time.
+func TestTurnLength(t *testing.T) {
+	defer func(length float64, agents int) { TurnLength, NumOfAgents = length, agents }(TurnLength, NumOfAgents)
+	NumOfAgents = 100
+	activated := func(length float64) int {
+		TurnLength = length
+		m := NewModel(poisson, newRand(3))
+		m.Step()
+		total := 0
+		for _, a := range m.Pop.Agents {
+			total += a.activations
+		}
+		return total
+	}
+	if one, four := activated(1), activated(4); one > 100 || four <= 3*one || four > 400 {
+		t.Errorf("%d activations in a turn of 1, %d in a turn of 4", one, four)
+	}
+
+	TurnLength = 2
+	if got, want := gradient([]float64{1, math.E, math.E * math.E}), 0.5; math.Abs(got-want) > 1e-12 {
+		t.Errorf("gradient %v over turns of 2, want %v", got, want)
+	}
+	tr := newTrajectories()
+	tr.set("poisson", 0, 0, 4, 0.5)
+	tr.set("poisson", 0, 1, 2, 0.25)
+	var buf bytes.Buffer
+	if err := writeTrajectories(&buf, tr); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "poisson,1,1,2,0.25,2\n") {
+		t.Errorf("trajectories:\n%s", buf.String())
+	}
+	TurnLength = 1
+	if got, err := readTrajectories(&buf); err != nil || got.length != 2 {
+		t.Errorf("read back turns of %v, %v; want 2", got.length, err)
+	}
+	if got, err := readTrajectories(strings.NewReader("regime,run,turn,sd,gini\npoisson,1,1,2,0.25\n")); err != nil || got.length != 1 {
+		t.Errorf("read turns of %v from a file without times, %v; want 1", got.length, err)
+	}
+}
+
+func TestCheckTurnLength(t *testing.T) {
+	defer func(length float64) { TurnLength = length }(TurnLength)
+	for _, bad := range []float64{0, -1, math.NaN(), math.Inf(1)} {
+		TurnLength = bad
+		if checkTurnLength() == nil {
+			t.Errorf("accepted TurnLength %v", bad)
+		}
+	}
+}
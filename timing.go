@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+/* Per-phase timing */
+
+/*
+ * Every turn passes through some of these phases, and the Model adds the
+ * time each one takes to its Times. Where constrained pairing interleaves
+ * finding partners with exchanging, the whole loop counts as exchange.
+ */
+type phase int
+
+const (
+	phaseLambda phase = iota
+	phaseEvents
+	phaseSort
+	phasePairing
+	phaseExchange
+	phaseStats
+	numPhases
+)
+
+var phaseNames = [numPhases]string{"lambdas", "events", "sort", "pairing", "exchange", "statistics"}
+
+// PhaseTimes is the time spent in each phase, summed over turns.
+type PhaseTimes [numPhases]time.Duration
+
+// Add adds q's times to p's.
+func (p *PhaseTimes) Add(q PhaseTimes) {
+	for i := range p {
+		p[i] += q[i]
+	}
+}
+
+// String lists the phases that took any time, with their share of the total.
+func (p PhaseTimes) String() string {
+	var total time.Duration
+	for _, d := range p {
+		total += d
+	}
+	parts := make([]string, 0)
+	for i, d := range p {
+		if d > 0 {
+			parts = append(parts, fmt.Sprintf("%s %v (%.0f%%)", phaseNames[i], d, 100*float64(d)/float64(total)))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// mark starts timing the next phase.
+func (m *Model) mark() {
+	m.lastLap = time.Now()
+}
+
+// lap ends the current phase, charging the time since the last mark or lap
+// to ph.
+func (m *Model) lap(ph phase) {
+	now := time.Now()
+	m.Times[ph] += now.Sub(m.lastLap)
+	m.lastLap = now
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestPhaseTimes checks that times add phase by phase and print with their
+// shares, leaving out phases that took none.
+func TestPhaseTimes(t *testing.T) {
+	var p PhaseTimes
+	p.Add(PhaseTimes{phaseLambda: time.Second, phaseSort: time.Second})
+	p.Add(PhaseTimes{phaseSort: 2 * time.Second})
+	if p[phaseLambda] != time.Second || p[phaseSort] != 3*time.Second || p[phaseStats] != 0 {
+		t.Errorf("added up to %v", [numPhases]time.Duration(p))
+	}
+	if got, want := p.String(), "lambdas 1s (25%), sort 3s (75%)"; got != want {
+		t.Errorf("String() is %q, want %q", got, want)
+	}
+}
+
+// TestRunTimings checks that a run charges its time to the Model's phases
+// and, with ReportTimings, reports them.
+func TestRunTimings(t *testing.T) {
+	defer func(agents, turns int, report bool) {
+		NumOfAgents, NumTurns, ReportTimings = agents, turns, report
+	}(NumOfAgents, NumTurns, ReportTimings)
+	NumOfAgents, NumTurns, ReportTimings = 200, 5, true
+	m := NewModel(poisson, rand.New(rand.NewSource(1)))
+	var out bytes.Buffer
+	runCell(m, 0, &out)
+	var total time.Duration
+	for _, d := range m.Times {
+		if d < 0 {
+			t.Fatalf("negative phase time in %v", m.Times)
+		}
+		total += d
+	}
+	if total == 0 {
+		t.Error("no time charged to any phase")
+	}
+	if !strings.Contains(out.String(), "Timing (poisson run 1): ") {
+		t.Errorf("no timing report in\n%s", out.String())
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/* Replication traces */
+
+/*
+ * The Go port doesn't reproduce the Python original's poisson results (see
+ * the header of redistribution.go), and the gradients alone can't say why.
+ * With ReplicationTrace set, every run of a poisson regime writes what
+ * decides its turns to trace_<regime>_run<N>.csv, a row per value:
+ *
+ *	turn,kind,index,agent,value
+ *
+ * For each turn, from 1, come the agents' normalized rates, kind "lambda",
+ * indexed by position in the population, then how many of the turn's
+ * events each agent has, kind "activations", likewise, then the events
+ * themselves, kind "event", in order of time, indexed by that order and valued by their
+ * time within the turn. Without partner restrictions or a Schedule that's
+ * the order they're paired off in, the first with the second, the third
+ * with the fourth and so on. agent is the agent's ID throughout.
+ *
+ * A trace from the Python model written the same way can be set against
+ * one of these: "trace-diff [-tol 1e-9] ours.csv theirs.csv" reports the
+ * first row where they part, values agreeing within a relative tolerance
+ * of tol, so the divergence can be found turn by turn: in the rates, in how
+ * many events they give, or in the order those are paired (see
+ * tracediff.go).
+ */
+
+var traceHeader = []string{"turn", "kind", "index", "agent", "value"}
+
+// A traceRow is a row of a replication trace.
+type traceRow struct {
+	Turn  int
+	Kind  string
+	Index int
+	Agent int
+	Value float64
+}
+
+// A replicationTrace records a poisson run's turns, and keeps the rows until
+// the run is over.
+type replicationTrace struct {
+	rows bytes.Buffer
+	w    *csv.Writer
+}
+
+// newReplicationTrace returns a trace of m's run, or nil without
+// ReplicationTrace or if m's regime isn't a poisson one.
+func newReplicationTrace(m *Model) *replicationTrace {
+	if !ReplicationTrace || m.Activation == uniform || m.Activation == random || m.Activation.custom() != nil {
+		return nil
+	}
+	t := &replicationTrace{}
+	t.w = csv.NewWriter(&t.rows)
+	t.w.Write(traceHeader)
+	return t
+}
+
+// record adds the rows of m's last turn, the given one.
+func (t *replicationTrace) record(m *Model, turn int) {
+	g := func(v float64) string { return strconv.FormatFloat(v, 'g', -1, 64) }
+	row := func(kind string, index, agent int, value string) {
+		t.w.Write([]string{strconv.Itoa(turn), kind, strconv.Itoa(index), strconv.Itoa(agent), value})
+	}
+	counts := make(map[int]int)
+	for _, f := range m.firings {
+		counts[f.id]++
+	}
+	for i, a := range m.Pop.Agents {
+		row("lambda", i, a.id, g(m.Pop.Lam[i]))
+	}
+	for i, a := range m.Pop.Agents {
+		row("activations", i, a.id, strconv.Itoa(counts[a.id]))
+	}
+	for k, f := range m.firings {
+		row("event", k, f.id, g(f.time))
+	}
+}
+
+// save writes the recorded rows for the given run of act.
+func (t *replicationTrace) save(act ActivationOrder, run int) {
+	t.w.Flush()
+	name := fmt.Sprintf("trace_%s_run%d.csv", strings.Replace(act.String(), " ", "_", -1), run+1)
+	if err := os.WriteFile(name, t.rows.Bytes(), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,90 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/* Comparing replication traces */
+
+// readTrace reads a replication trace, ours or the Python model's.
+func readTrace(r io.Reader) ([]traceRow, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 || strings.Join(rows[0], ",") != strings.Join(traceHeader, ",") {
+		return nil, fmt.Errorf("not a trace: want the header %s", strings.Join(traceHeader, ","))
+	}
+	trace := make([]traceRow, 0, len(rows)-1)
+	for i, row := range rows[1:] {
+		if len(row) != len(traceHeader) {
+			return nil, fmt.Errorf("trace line %d: %d fields, want %d", i+2, len(row), len(traceHeader))
+		}
+		turn, err1 := strconv.Atoi(row[0])
+		index, err2 := strconv.Atoi(row[2])
+		agent, err3 := strconv.Atoi(row[3])
+		value, err4 := strconv.ParseFloat(row[4], 64)
+		if err := firstError(err1, err2, err3, err4); err != nil {
+			return nil, fmt.Errorf("trace line %d: %v", i+2, err)
+		}
+		trace = append(trace, traceRow{turn, row[1], index, agent, value})
+	}
+	return trace, nil
+}
+
+// traceDivergence describes the first row where traces a and b part, with
+// values agreeing to a relative tolerance of tol, or returns "" if they
+// don't.
+func traceDivergence(a, b []traceRow, tol float64) string {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		x, y := a[i], b[i]
+		if x.Turn != y.Turn || x.Kind != y.Kind || x.Index != y.Index {
+			return fmt.Sprintf("row %d: turn %d %s %d here, turn %d %s %d there", i+1, x.Turn, x.Kind, x.Index, y.Turn, y.Kind, y.Index)
+		} else if x.Agent != y.Agent {
+			return fmt.Sprintf("turn %d %s %d: agent %d here, %d there", x.Turn, x.Kind, x.Index, x.Agent, y.Agent)
+		} else if math.Abs(x.Value-y.Value) > tol*math.Max(math.Abs(x.Value), math.Abs(y.Value)) {
+			return fmt.Sprintf("turn %d %s %d (agent %d): %v here, %v there", x.Turn, x.Kind, x.Index, x.Agent, x.Value, y.Value)
+		}
+	}
+	if len(a) != len(b) {
+		return fmt.Sprintf("%d rows here, %d there", len(a), len(b))
+	}
+	return ""
+}
+
+// runTraceDiff is the trace-diff subcommand.
+func runTraceDiff(args []string) error {
+	fs := flag.NewFlagSet("trace-diff", flag.ExitOnError)
+	tol := fs.Float64("tol", 1e-9, "relative tolerance within which values agree")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		return errors.New("usage: trace-diff [-tol 1e-9] ours.csv theirs.csv")
+	}
+	var traces [2][]traceRow
+	for k, name := range fs.Args() {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		traces[k], err = readTrace(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	if d := traceDivergence(traces[0], traces[1], *tol); d != "" {
+		return fmt.Errorf("the traces part at %s", d)
+	}
+	fmt.Printf("The traces agree, all %d rows\n", len(traces[0]))
+	return nil
+}
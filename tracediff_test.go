@@ -0,0 +1,65 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestReplicationTrace(t *testing.T) {
+	defer func(agents int, trace bool) { NumOfAgents, ReplicationTrace = agents, trace }(NumOfAgents, ReplicationTrace)
+	NumOfAgents, ReplicationTrace = 20, true
+	if newReplicationTrace(NewModel(uniform, newRand(1))) != nil {
+		t.Error("traced a uniform run")
+	}
+	m := NewModel(inversePoisson, newRand(1))
+	trace := newReplicationTrace(m)
+	for turn := 1; turn <= 2; turn++ {
+		m.Step()
+		trace.record(m, turn)
+	}
+	trace.w.Flush()
+	rows, err := readTrace(&trace.rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lambdas, activations, events := 0.0, 0.0, 0
+	for _, r := range rows {
+		if r.Turn != 1 {
+			continue
+		}
+		if r.Kind == "lambda" {
+			lambdas += r.Value
+		} else if r.Kind == "activations" {
+			activations += r.Value
+		} else if r.Kind == "event" {
+			events++
+		}
+	}
+	if math.Abs(lambdas-1.1*20) > 1e-9 {
+		t.Errorf("turn 1's rates total %v, want %v", lambdas, 1.1*20)
+	}
+	if events == 0 || activations != float64(events) {
+		t.Errorf("turn 1 has %d events but %v activations", events, activations)
+	}
+
+	if d := traceDivergence(rows, rows, 1e-9); d != "" {
+		t.Errorf("a trace parts from itself at %s", d)
+	}
+	changed := append([]traceRow(nil), rows...)
+	changed[len(rows)/2].Value *= 1.001
+	if d := traceDivergence(rows, changed, 1e-9); !strings.Contains(d, "here") {
+		t.Errorf("a changed value parts at %q", d)
+	}
+	if d := traceDivergence(rows, changed, 0.01); d != "" {
+		t.Errorf("a change within tolerance parts at %q", d)
+	}
+	if d := traceDivergence(rows, rows[:len(rows)-1], 1e-9); d == "" {
+		t.Error("a truncated trace doesn't part")
+	}
+	if _, err := readTrace(strings.NewReader("turn,kind\n")); err == nil {
+		t.Error("read a trace without its header")
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/* Agent tracking */
+
+/*
+ * Every agent has an ID, given by Populate and kept for the rest of the
+ * run wherever the agent goes: its index in the initial population, offset
+ * by region in a World so that IDs stay unique as agents migrate. With
+ * TrackAgents set, the wealth of the agents it names is followed through
+ * every run and written, one row per agent and turn, to
+ * agents_<regime>_run<N>.csv. An agent can be named by its ID or by where
+ * it starts out:
+ *
+ *	richest  the agent with the most initial wealth
+ *	poorest  the agent with the least
+ *	median   the agent whose initial wealth is the median's
+ *
+ * with ties going to the lowest ID. The rows give the name, the ID it
+ * picked out and the agent's wealth after the turn, and with RiskAversion
+ * set, its risk aversion (see risk.go). An agent that exits (see
+ * demography.go) has no more rows.
+ */
+
+// An agentSelector picks an agent out of the initial population, and
+// returns its index.
+type agentSelector func(Pop Population) int
+
+var agentSelectors = map[string]agentSelector{
+	"richest": func(Pop Population) int { return extremeAgent(Pop, 1) },
+	"poorest": func(Pop Population) int { return extremeAgent(Pop, -1) },
+	"median":  medianAgent,
+}
+
+// extremeAgent returns the index of the richest agent if sign is 1, or the
+// poorest if it's -1.
+func extremeAgent(Pop Population, sign float64) int {
+	best := 0
+	for i := 1; i < Pop.Len(); i++ {
+		d := sign * (Pop.Wealth[i] - Pop.Wealth[best])
+		if d > 0 || (d == 0 && Pop.Agents[i].id < Pop.Agents[best].id) {
+			best = i
+		}
+	}
+	return best
+}
+
+// medianAgent returns the index of the agent in the middle of the
+// population ordered by wealth and then ID -- the lower of the two middle
+// ones if there's an even number.
+func medianAgent(Pop Population) int {
+	order := make([]int, Pop.Len())
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool {
+		wa, wb := Pop.Wealth[order[a]], Pop.Wealth[order[b]]
+		return wa < wb || (wa == wb && Pop.Agents[order[a]].id < Pop.Agents[order[b]].id)
+	})
+	return order[(len(order)-1)/2]
+}
+
+// checkTrackAgents reports whether every name in TrackAgents picks out an
+// agent.
+func checkTrackAgents() error {
+	for _, name := range TrackAgents {
+		if _, ok := agentSelectors[name]; ok {
+			continue
+		}
+		if id, err := strconv.Atoi(name); err != nil || id < 0 || id >= NumOfAgents {
+			return fmt.Errorf("can't track agent %q: give an ID from 0 to %d, or one of richest, poorest and median", name, NumOfAgents-1)
+		}
+	}
+	return nil
+}
+
+// An agentTracker follows the wealth of the agents named in TrackAgents
+// through a run and keeps the rows until it's over.
+type agentTracker struct {
+	names []string
+	ids   []int
+	index []int // where each agent was last found; -1 once it has exited
+	rows  bytes.Buffer
+}
+
+// newAgentTracker returns a tracker for the agents TrackAgents names in
+// the initial population Pop, or nil if it names none.
+func newAgentTracker(Pop Population) *agentTracker {
+	if len(TrackAgents) == 0 {
+		return nil
+	}
+	if err := checkTrackAgents(); err != nil {
+		log.Fatal(err)
+	}
+	t := &agentTracker{names: TrackAgents}
+	for _, name := range TrackAgents {
+		if sel, ok := agentSelectors[name]; ok {
+			i := sel(Pop)
+			t.ids = append(t.ids, Pop.Agents[i].id)
+			t.index = append(t.index, i)
+		} else {
+			id, _ := strconv.Atoi(name)
+			t.ids = append(t.ids, id)
+			t.index = append(t.index, id)
+		}
+	}
+	t.rows.WriteString("turn,agent,id,wealth")
+	if RiskAversion != "" {
+		t.rows.WriteString(",risk")
+	}
+	t.rows.WriteString("\n")
+	return t
+}
+
+// record adds a row for each tracked agent with its wealth in Pop after the
+// given turn.
+func (t *agentTracker) record(Pop Population, turn int) {
+	for k, id := range t.ids {
+		i := t.index[k]
+		if i < 0 {
+			continue
+		} else if i >= Pop.Len() || Pop.Agents[i].id != id {
+			if i = Pop.Find(id); i < 0 {
+				t.index[k] = -1
+				continue
+			}
+			t.index[k] = i
+		}
+		fmt.Fprintf(&t.rows, "%d,%s,%d,%s", turn, t.names[k], id, strconv.FormatFloat(Pop.Wealth[i], 'g', -1, 64))
+		if RiskAversion != "" {
+			t.rows.WriteString("," + strconv.FormatFloat(Pop.Agents[i].risk, 'g', -1, 64))
+		}
+		t.rows.WriteString("\n")
+	}
+}
+
+// save writes the recorded rows for the given run of act.
+func (t *agentTracker) save(act ActivationOrder, run int) {
+	name := fmt.Sprintf("agents_%s_run%d.csv", strings.Replace(act.String(), " ", "_", -1), run+1)
+	if err := os.WriteFile(name, t.rows.Bytes(), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
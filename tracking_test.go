@@ -0,0 +1,102 @@
+package main
+
+import (
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestAgentSelectors(t *testing.T) {
+	Pop := NewPopulation(6)
+	copy(Pop.Wealth, []float64{3, 9, 1, 9, 5, 1})
+	for i := range Pop.Agents {
+		Pop.Agents[i].id = 10 + i
+	}
+	Pop.Agents[1].id, Pop.Agents[3].id = 14, 11 // the later of the tied richest has the lower ID
+	for name, want := range map[string]int{"richest": 3, "poorest": 2, "median": 0} {
+		if got := agentSelectors[name](Pop); got != want {
+			t.Errorf("%s: agent %d, want %d", name, got, want)
+		}
+	}
+	if i := Pop.Find(14); i != 1 {
+		t.Errorf("found ID 14 at %d, want 1", i)
+	}
+	if i := Pop.Find(99); i != -1 {
+		t.Errorf("found ID 99 at %d", i)
+	}
+}
+
+func TestCheckTrackAgents(t *testing.T) {
+	defer func(names []string) { TrackAgents = names }(TrackAgents)
+	TrackAgents = []string{"richest", "0", "median"}
+	if err := checkTrackAgents(); err != nil {
+		t.Error(err)
+	}
+	for _, bad := range []string{"-1", "1000000000", "luckiest"} {
+		TrackAgents = []string{bad}
+		if err := checkTrackAgents(); err == nil {
+			t.Errorf("tracked %q", bad)
+		}
+	}
+}
+
+// TestTrackAgents checks the rows a run writes for its tracked agents.
+func TestTrackAgents(t *testing.T) {
+	defer func(names []string, agents, turns int) {
+		TrackAgents, NumOfAgents, NumTurns = names, agents, turns
+	}(TrackAgents, NumOfAgents, NumTurns)
+	TrackAgents, NumOfAgents, NumTurns = []string{"richest", "poorest", "7"}, 20, 3
+	dir, _ := os.Getwd()
+	defer os.Chdir(dir)
+	os.Chdir(t.TempDir())
+
+	runCell(NewModel(poisson, rand.New(rand.NewSource(4))), 1, io.Discard)
+	data, err := os.ReadFile("agents_poisson_run2.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1+3*(NumTurns+1) {
+		t.Fatalf("%d lines, want %d:\n%s", len(lines), 1+3*(NumTurns+1), data)
+	}
+	for i, want := range []string{"turn,agent,id,wealth", "0,richest,19,20", "0,poorest,0,1", "0,7,7,8"} {
+		if lines[i] != want {
+			t.Errorf("line %d is %q, want %q", i+1, lines[i], want)
+		}
+	}
+	if !strings.HasPrefix(lines[len(lines)-1], "3,7,7,") {
+		t.Errorf("last line %q", lines[len(lines)-1])
+	}
+}
+
+// TestWorldIDs checks that IDs are unique across a World's regions and
+// travel with migrating agents.
+func TestWorldIDs(t *testing.T) {
+	defer func(agents int) { NumOfAgents = agents }(NumOfAgents)
+	NumOfAgents = 30
+	w := NewWorld([]ActivationOrder{uniform, random, poisson}, 0.5, rand.New(rand.NewSource(2)))
+	wealth := make(map[int]float64)
+	for _, m := range w.Regions {
+		for i, a := range m.Pop.Agents {
+			wealth[a.id] = m.Pop.Wealth[i]
+		}
+	}
+	if len(wealth) != 3*NumOfAgents {
+		t.Fatalf("%d distinct IDs among %d agents", len(wealth), 3*NumOfAgents)
+	}
+	w.Migrate()
+	n := 0
+	for _, m := range w.Regions {
+		for i, a := range m.Pop.Agents {
+			if m.Pop.Wealth[i] != wealth[a.id] {
+				t.Errorf("agent %d arrived with wealth %v, left with %v", a.id, m.Pop.Wealth[i], wealth[a.id])
+			}
+			n++
+		}
+	}
+	if n != 3*NumOfAgents {
+		t.Errorf("%d agents after migrating, want %d", n, 3*NumOfAgents)
+	}
+}
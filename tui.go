@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* Terminal UI */
+
+/*
+ * With -tui, an experiment run in a terminal -- over SSH, say -- draws its
+ * progress in place instead of scrolling: for each regime, how many of its
+ * runs are done and sparklines of the mean wealth SD and Gini by turn over
+ * the runs so far, then the throughput, then the last few lines the runs
+ * have printed. It is plain ANSI escapes on the alternate screen, redrawn a
+ * few times a second, and the terminal is given back once every run is
+ * done. Gini is estimated from a sample of at most tuiSample agents, as it
+ * is only there to be looked at.
+ */
+
+const (
+	tuiSample  = 10000
+	tuiWidth   = 48 // columns of sparkline
+	tuiLogRows = 6
+)
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// A TerminalUI is an Observer that draws the experiment's progress in a terminal.
+// It is also an io.Writer, for the runs' own output.
+type TerminalUI struct {
+	out   io.Writer
+	names []string
+	start time.Time
+
+	mu      sync.Mutex
+	sd      [][]float64 // summed over runs, by regime and turn
+	gini    [][]float64
+	count   [][]int // runs that have reached each turn
+	done    []int   // runs finished, by regime
+	turns   int     // turns simulated in all
+	agents  int     // agent-turns simulated in all, for throughput
+	lines   []string
+	sampler *wealthSampler
+	over    chan struct{}
+	closed  chan struct{}
+}
+
+// NewTerminalUI returns a TerminalUI for an experiment over acts, drawing on out.
+func NewTerminalUI(acts []ActivationOrder, out io.Writer) *TerminalUI {
+	t := &TerminalUI{out: out, start: time.Now(), sampler: newWealthSampler(tuiSample),
+		over: make(chan struct{}), closed: make(chan struct{})}
+	for _, act := range acts {
+		t.names = append(t.names, act.String())
+		t.sd = append(t.sd, make([]float64, NumTurns+1))
+		t.gini = append(t.gini, make([]float64, NumTurns+1))
+		t.count = append(t.count, make([]int, NumTurns+1))
+	}
+	t.done = make([]int, len(acts))
+	return t
+}
+
+// Turn adds run ri of act's turn to the regime's means.
+func (t *TerminalUI) Turn(act ActivationOrder, ri, turn int, sd float64, wealth []float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	a := t.index(act)
+	if a < 0 || turn >= len(t.sd[a]) {
+		return
+	}
+	t.sd[a][turn] += sd
+	sorted, total := t.sampler.sorted(wealth)
+	t.gini[a][turn] += gini(sorted, total)
+	t.count[a][turn]++
+	if turn > 0 {
+		t.turns++
+		t.agents += len(wealth)
+	}
+}
+
+// Done counts run ri of act as finished, and once every run is, draws the
+// last frame and gives back the terminal.
+func (t *TerminalUI) Done(act ActivationOrder, ri int) {
+	t.mu.Lock()
+	a := t.index(act)
+	all := true
+	if a >= 0 {
+		t.done[a]++
+	}
+	for _, d := range t.done {
+		all = all && d >= NumRuns
+	}
+	t.mu.Unlock()
+	if all {
+		close(t.over)
+		<-t.closed
+	}
+}
+
+func (t *TerminalUI) index(act ActivationOrder) int {
+	for i, name := range t.names {
+		if name == act.String() {
+			return i
+		}
+	}
+	return -1
+}
+
+// Write keeps the last few lines of the runs' output for the log pane.
+func (t *TerminalUI) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		t.lines = append(t.lines, line)
+	}
+	if len(t.lines) > tuiLogRows {
+		t.lines = t.lines[len(t.lines)-tuiLogRows:]
+	}
+	return len(p), nil
+}
+
+// Run draws the UI until every run is done.
+func (t *TerminalUI) Run() {
+	fmt.Fprint(t.out, "\x1b[?1049h\x1b[?25l") // alternate screen, no cursor
+	tick := time.NewTicker(250 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			t.draw()
+		case <-t.over:
+			t.draw()
+			fmt.Fprint(t.out, "\x1b[?25h\x1b[?1049l")
+			close(t.closed)
+			return
+		}
+	}
+}
+
+// draw renders one frame.
+func (t *TerminalUI) draw() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var b bytes.Buffer
+	b.WriteString("\x1b[H\x1b[2J")
+	fmt.Fprintf(&b, "Redistribution: %d agents, %d runs of %d turns per regime\r\n\r\n", NumOfAgents, NumRuns, NumTurns)
+	fmt.Fprintf(&b, "%-15s  %-12s  %-*s  %s\r\n", "regime", "runs", tuiWidth, "mean wealth SD (log)", "mean Gini")
+	for a, name := range t.names {
+		sd, g := t.means(a)
+		fmt.Fprintf(&b, "%-15s  %-12s  %s  %s\r\n", name, progressBar(t.done[a], NumRuns),
+			sparkline(sd, true), sparkline(g, false))
+	}
+	elapsed := time.Since(t.start).Seconds()
+	fmt.Fprintf(&b, "\r\n%.1f turns/s, %.3g agent-turns/s, %s elapsed\r\n\r\n",
+		float64(t.turns)/elapsed, float64(t.agents)/elapsed, time.Since(t.start).Round(time.Second))
+	for _, line := range t.lines {
+		if len(line) > 120 {
+			line = line[:120]
+		}
+		b.WriteString(line + "\r\n")
+	}
+	t.out.Write(b.Bytes())
+}
+
+// means returns regime a's mean SD and Gini for every turn some run has
+// reached, squeezed into tuiWidth columns.
+func (t *TerminalUI) means(a int) (sd, g []float64) {
+	for turn, n := range t.count[a] {
+		if n == 0 {
+			break
+		}
+		sd = append(sd, t.sd[a][turn]/float64(n))
+		g = append(g, t.gini[a][turn]/float64(n))
+	}
+	return squeeze(sd, tuiWidth), squeeze(g, tuiWidth)
+}
+
+// squeeze averages x down to at most width values.
+func squeeze(x []float64, width int) []float64 {
+	if len(x) <= width {
+		return x
+	}
+	out := make([]float64, width)
+	for i := range out {
+		lo, hi := i*len(x)/width, (i+1)*len(x)/width
+		for _, v := range x[lo:hi] {
+			out[i] += v / float64(hi-lo)
+		}
+	}
+	return out
+}
+
+// sparkline draws x, scaled to its own range and padded to tuiWidth.
+func sparkline(x []float64, logScale bool) string {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	scaled := make([]float64, len(x))
+	for i, v := range x {
+		if logScale {
+			v = math.Log(math.Max(v, 1e-3))
+		}
+		scaled[i] = v
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	var b strings.Builder
+	for _, v := range scaled {
+		k := 0
+		if hi > lo {
+			k = int(float64(len(sparks)-1) * (v - lo) / (hi - lo))
+		}
+		b.WriteRune(sparks[k])
+	}
+	return b.String() + strings.Repeat(" ", tuiWidth-len(x))
+}
+
+// progressBar draws done out of total as a bar and a count.
+func progressBar(done, total int) string {
+	const width = 6
+	filled := 0
+	if total > 0 {
+		filled = width * done / total
+	}
+	return fmt.Sprintf("%s%s %d/%d", strings.Repeat("█", filled), strings.Repeat("░", width-filled), done, total)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSparkline(t *testing.T) {
+	s := sparkline([]float64{0, 1, 2, 3, 4, 5, 6, 7}, false)
+	if !strings.HasPrefix(s, "▁▂▃▄▅▆▇█") {
+		t.Errorf("sparkline = %q", s)
+	}
+	if n := utf8.RuneCountInString(s); n != tuiWidth {
+		t.Errorf("sparkline is %d columns, want %d", n, tuiWidth)
+	}
+	if s := sparkline([]float64{3, 3, 3}, true); !strings.HasPrefix(s, "▁▁▁") {
+		t.Errorf("flat sparkline = %q", s)
+	}
+}
+
+func TestSqueeze(t *testing.T) {
+	x := make([]float64, 100)
+	for i := range x {
+		x[i] = float64(i)
+	}
+	s := squeeze(x, 10)
+	if len(s) != 10 || s[0] != 4.5 || s[9] != 94.5 {
+		t.Errorf("squeeze = %v", s)
+	}
+}
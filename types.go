@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+/* Agent types */
+
+/*
+ * With AgentTypes set, e.g. {"hoarder:0.2", "leveler:0.8"}, the population
+ * is a mix of agent types, each a random share of it. An agent's type says
+ * which rule it applies to the exchanges it initiates -- RuleName unless
+ * the type names another -- and how often it refuses an exchange that would
+ * leave it poorer, in which case neither agent's wealth changes:
+ *
+ *	leveler  always goes through with an exchange
+ *	hoarder  refuses with probability HoarderRefusal
+ *	learner  refuses with a probability of its own, starting at
+ *	         LearnerRefusal, that it learns from its wealth
+ *
+ * A learner's probability is reinforced by how it fares: after every turn
+ * that leaves it poorer than the last, it moves LearningRate of the way
+ * toward 1, and after every turn that leaves it richer, as far toward 0. So
+ * agents that lose out in exchanges grow guarded and those that gain grow
+ * open, and how willing each is to trade comes out of the run rather than
+ * being fixed at the start.
+ *
+ * More can be registered with RegisterAgentType. Whether an agent refuses
+ * is decided by a hash of the Model's seed, the turn and the two agents'
+ * IDs, not by the Model's random stream, so that exchanges can still run
+ * in parallel and give the same results. At the end of each run, the
+ * agents' wealth is reported by type, with the variance between types and
+ * the exchanges refused.
+ */
+
+// An AgentType is how one kind of agent behaves in exchanges.
+type AgentType struct {
+	Name    string
+	Rule    string  // rule of the exchanges it initiates; "" for RuleName
+	Refusal float64 // probability of refusing an exchange that would leave it poorer
+	Rate    float64 // if > 0, each agent learns its own Refusal, at this rate
+}
+
+// agentTypeTable holds the types AgentTypes can name.
+var agentTypeTable = map[string]func() AgentType{
+	"leveler": func() AgentType { return AgentType{Name: "leveler"} },
+	"hoarder": func() AgentType { return AgentType{Name: "hoarder", Refusal: HoarderRefusal} },
+	"learner": func() AgentType { return AgentType{Name: "learner", Refusal: LearnerRefusal, Rate: LearningRate} },
+}
+
+// RegisterAgentType makes the type newType returns available as name.
+func RegisterAgentType(name string, newType func() AgentType) {
+	if _, ok := agentTypeTable[name]; ok {
+		panic("agent type " + name + " registered twice")
+	}
+	agentTypeTable[name] = newType
+}
+
+// A TypeMix is a population's mix of agent types.
+type TypeMix struct {
+	Types  []AgentType
+	Shares []float64 // of the population, by type; they sum to 1
+
+	rules    []Rule // by type; nil for the Model's Rule
+	seed     uint64 // decides refusals
+	refusals int64  // exchanges refused so far, updated atomically
+}
+
+// newAgentTypes returns the mix of types specs name, each "type:share".
+func newAgentTypes(specs []string) (*TypeMix, error) {
+	ts := &TypeMix{}
+	total := 0.0
+	for _, spec := range specs {
+		i := strings.LastIndexByte(spec, ':')
+		if i < 0 {
+			return nil, fmt.Errorf("agent type %q: want type:share", spec)
+		}
+		newType, ok := agentTypeTable[spec[:i]]
+		if !ok {
+			return nil, fmt.Errorf("unknown agent type %q", spec[:i])
+		}
+		share, err := strconv.ParseFloat(spec[i+1:], 64)
+		if err != nil || share < 0 {
+			return nil, fmt.Errorf("agent type %q: bad share %q", spec[:i], spec[i+1:])
+		}
+		t := newType()
+		var rule Rule
+		if t.Rule != "" {
+			if rule, err = lookupRule(t.Rule); err != nil {
+				return nil, fmt.Errorf("agent type %q: %v", t.Name, err)
+			}
+		}
+		if t.Refusal < 0 || t.Refusal > 1 {
+			return nil, fmt.Errorf("agent type %q: refusal probability %v", t.Name, t.Refusal)
+		} else if t.Rate < 0 || t.Rate > 1 {
+			return nil, fmt.Errorf("agent type %q: learning rate %v", t.Name, t.Rate)
+		}
+		ts.Types = append(ts.Types, t)
+		ts.Shares = append(ts.Shares, share)
+		ts.rules = append(ts.rules, rule)
+		total += share
+	}
+	if math.Abs(total-1) > 1e-9 {
+		return nil, fmt.Errorf("agent type shares add up to %v, not 1", total)
+	}
+	return ts, nil
+}
+
+// checkAgentTypes reports whether AgentTypes is a valid mix, if it's set.
+func checkAgentTypes() error {
+	if len(AgentTypes) == 0 {
+		return nil
+	}
+	_, err := newAgentTypes(AgentTypes)
+	return err
+}
+
+// Assign gives each type its share of the population, at random, and seeds
+// the refusals from rng. Learners start out with their type's Refusal.
+func (ts *TypeMix) Assign(Pop Population, rng *rand.Rand) {
+	ts.seed = uint64(rng.Int63())
+	perm := rng.Perm(Pop.Len())
+	cum, k := 0.0, 0
+	for kind, share := range ts.Shares {
+		cum += share
+		end := int(math.Floor(cum*float64(Pop.Len()) + 0.5))
+		if kind == len(ts.Shares)-1 {
+			end = Pop.Len()
+		}
+		for ; k < end; k++ {
+			a := &Pop.Agents[perm[k]]
+			a.kind, a.refusal, a.seen = kind, ts.Types[kind].Refusal, Pop.Wealth[perm[k]]
+		}
+	}
+}
+
+// clone returns a copy of ts with no refusals yet.
+func (ts *TypeMix) clone() *TypeMix {
+	c := *ts
+	c.refusals = 0
+	return &c
+}
+
+// exchange has m apply the rule of a's type, or else rule, to a and b
+// unless either refuses.
+func (ts *TypeMix) exchange(m *Model, rule Rule, a, b *Agent, wa, wb *float64) {
+	if r := ts.rules[a.kind]; r != nil {
+		rule = r
+	}
+	if wa == wb { // an agent can't refuse itself
+		m.apply(rule, a, b, wa, wb)
+		return
+	}
+	na, nb := *wa, *wb
+	m.apply(rule, a, b, &na, &nb)
+	if (na < *wa && ts.refuses(a, b, m.Turn)) || (nb < *wb && ts.refuses(b, a, m.Turn)) {
+		atomic.AddInt64(&ts.refusals, 1)
+		return
+	}
+	*wa, *wb = na, nb
+}
+
+// refuses decides whether a refuses an exchange with b that would leave it
+// poorer, in the given turn.
+func (ts *TypeMix) refuses(a, b *Agent, turn int) bool {
+	p := ts.Types[a.kind].Refusal
+	if ts.Types[a.kind].Rate > 0 {
+		p = a.refusal
+	}
+	if p <= 0 {
+		return false
+	} else if p >= 1 {
+		return true
+	}
+	return pairDraw(ts.seed, turn, a, b) < p
+}
+
+// Learn reinforces each learner's refusal probability by the change in its
+// wealth since it last learned.
+func (ts *TypeMix) Learn(Pop Population) {
+	for i := range Pop.Agents {
+		a := &Pop.Agents[i]
+		rate := ts.Types[a.kind].Rate
+		if rate <= 0 {
+			continue
+		}
+		if w := Pop.Wealth[i]; w < a.seen {
+			a.refusal += rate * (1 - a.refusal)
+		} else if w > a.seen {
+			a.refusal -= rate * a.refusal
+		}
+		a.seen = Pop.Wealth[i]
+	}
+}
+
+// Refusals returns the number of exchanges refused so far.
+func (ts *TypeMix) Refusals() int64 {
+	return atomic.LoadInt64(&ts.refusals)
+}
+
+// printTypes reports the final state of each type of agent in m.
+func printTypes(w io.Writer, m *Model, act ActivationOrder, run int) {
+	ts := m.Types
+	wealth := make([][]float64, len(ts.Types))
+	refusal := make([]float64, len(ts.Types))
+	for i := range m.Pop.Agents {
+		k := m.Pop.Agents[i].kind
+		wealth[k] = append(wealth[k], m.Pop.Wealth[i])
+		refusal[k] += m.Pop.Agents[i].refusal
+	}
+	for k, t := range ts.Types {
+		if len(wealth[k]) == 0 {
+			fmt.Fprintf(w, "Final %ss (%s run %d): no agents\n", t.Name, act, run+1)
+			continue
+		}
+		sort.Float64s(wealth[k])
+		total := 0.0
+		for _, x := range wealth[k] {
+			total += x
+		}
+		_, sd := Asdw(Population{Wealth: wealth[k]})
+		fmt.Fprintf(w, "Final %ss (%s run %d): %d agents, mean wealth %f, SD %f, Gini %f",
+			t.Name, act, run+1, len(wealth[k]), total/float64(len(wealth[k])), sd, gini(wealth[k], total))
+		if t.Rate > 0 {
+			fmt.Fprintf(w, ", mean refusal %f", refusal[k]/float64(len(wealth[k])))
+		}
+		fmt.Fprintln(w)
+	}
+	_, between := Decompose(m.Pop, func(a *Agent) int { return a.kind })
+	fmt.Fprintf(w, "Variance between types (%s run %d): %f; %d exchanges refused\n", act, run+1, between, ts.Refusals())
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewAgentTypes(t *testing.T) {
+	ts, err := newAgentTypes([]string{"hoarder:0.25", "leveler:0.75"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ts.Types) != 2 || ts.Types[0].Name != "hoarder" || ts.Types[0].Refusal != HoarderRefusal {
+		t.Errorf("types %+v", ts.Types)
+	}
+	for _, specs := range [][]string{{"hoarder"}, {"miser:1"}, {"hoarder:0.5", "leveler:0.4"}, {"hoarder:-1", "leveler:2"}} {
+		if _, err := newAgentTypes(specs); err == nil {
+			t.Errorf("accepted %q", specs)
+		}
+	}
+}
+
+func TestAssignAgentTypes(t *testing.T) {
+	ts, _ := newAgentTypes([]string{"hoarder:0.3", "leveler:0.7"})
+	Pop := NewPopulation(10)
+	ts.Assign(Pop, rand.New(rand.NewSource(1)))
+	counts := make([]int, 2)
+	for _, a := range Pop.Agents {
+		counts[a.kind]++
+	}
+	if counts[0] != 3 || counts[1] != 7 {
+		t.Errorf("%d hoarders and %d levelers, want 3 and 7", counts[0], counts[1])
+	}
+}
+
+// TestHoardersNeverLose checks that hoarders who always refuse never lose
+// wealth, and that the refusals are counted and reported.
+func TestHoardersNeverLose(t *testing.T) {
+	defer func(types []string, refusal float64, agents int) {
+		AgentTypes, HoarderRefusal, NumOfAgents = types, refusal, agents
+	}(AgentTypes, HoarderRefusal, NumOfAgents)
+	AgentTypes, HoarderRefusal, NumOfAgents = []string{"hoarder:0.5", "leveler:0.5"}, 1, 50
+	m := NewModel(random, rand.New(rand.NewSource(3)))
+	start := append([]float64(nil), m.Pop.Wealth...)
+	for turn := 0; turn < 5; turn++ {
+		m.Step()
+	}
+	for i, a := range m.Pop.Agents {
+		if a.kind == 0 && m.Pop.Wealth[i] < start[i] {
+			t.Errorf("hoarder %d went from %v to %v", i, start[i], m.Pop.Wealth[i])
+		}
+	}
+	if m.Types.Refusals() == 0 {
+		t.Error("no exchanges refused")
+	}
+	var buf bytes.Buffer
+	printTypes(&buf, m, random, 0)
+	for _, want := range []string{"Final hoarders (random run 1): 25 agents", "Final levelers", "exchanges refused"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("report lacks %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+// TestAgentTypesDeterministic checks that refusals don't depend on whether
+// the exchanges run in parallel.
+func TestAgentTypesDeterministic(t *testing.T) {
+	defer func(types []string, runs, turns, workers, pairs int, out io.Writer) {
+		AgentTypes, NumRuns, NumTurns, Workers, ParallelThreshold, cellOutput = types, runs, turns, workers, pairs, out
+	}(AgentTypes, NumRuns, NumTurns, Workers, ParallelThreshold, cellOutput)
+	AgentTypes, NumRuns, NumTurns, cellOutput = []string{"hoarder:0.4", "leveler:0.6"}, 2, 5, io.Discard
+	acts := []ActivationOrder{uniform, poisson}
+	var want []float64
+	for _, workers := range []int{1, 4} {
+		Workers, ParallelThreshold = workers, 1
+		matrices, collect := resultMatrices(acts)
+		if err := (Experiment{acts, 8}).Run(collect); err != nil {
+			t.Fatal(err)
+		}
+		var got []float64
+		for _, m := range matrices {
+			for r := 0; r < NumRuns; r++ {
+				for turn := 0; turn < NumTurns; turn++ {
+					got = append(got, m.At(r, turn))
+				}
+			}
+		}
+		if want == nil {
+			want = got
+		} else if !reflect.DeepEqual(got, want) {
+			t.Errorf("%d workers: SDs %v, want %v", workers, got, want)
+		}
+	}
+}
+
+// TestLearnersAdapt checks that learners grow more guarded after a turn
+// that leaves them poorer and more open after one that leaves them richer.
+func TestLearnersAdapt(t *testing.T) {
+	defer func(types []string, refusal, rate float64, agents int) {
+		AgentTypes, LearnerRefusal, LearningRate, NumOfAgents = types, refusal, rate, agents
+	}(AgentTypes, LearnerRefusal, LearningRate, NumOfAgents)
+	AgentTypes, LearnerRefusal, LearningRate, NumOfAgents = []string{"learner:1"}, 0.5, 0.2, 50
+	m := NewModel(random, rand.New(rand.NewSource(5)))
+	for turn := 0; turn < 3; turn++ {
+		before := append([]float64(nil), m.Pop.Wealth...)
+		refusals := make([]float64, m.Pop.Len())
+		for i, a := range m.Pop.Agents {
+			refusals[i] = a.refusal
+		}
+		m.Step()
+		for i, a := range m.Pop.Agents {
+			w := m.Pop.Wealth[i]
+			if a.refusal < 0 || a.refusal > 1 ||
+				(w < before[i] && a.refusal <= refusals[i]) ||
+				(w > before[i] && a.refusal >= refusals[i]) ||
+				(w == before[i] && a.refusal != refusals[i]) {
+				t.Errorf("turn %d: agent %d went from %v to %v and its refusal from %v to %v",
+					turn+1, i, before[i], w, refusals[i], a.refusal)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	printTypes(&buf, m, random, 0)
+	if !strings.Contains(buf.String(), "mean refusal") {
+		t.Errorf("report lacks the learners' refusal:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,308 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/GaryBoone/GoStats/stats"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+/* Parity with the Python model */
+
+/*
+ * "validate reference.csv" checks this port against trajectories from Ken's
+ * original Python model. The file has a row per regime, run and turn, with
+ * the wealth SD after that turn (turn 0 being the initial population), in
+ * columns headed regime (or activation), run, turn and sd, as
+ * trajectories.csv has them; any other columns are ignored. Regimes are
+ * matched to ours by name, ignoring case, underscores and hyphens.
+ *
+ * validate then does as many runs of each regime the file has, over as many
+ * turns, and compares the two sets of runs: at every turn, the distribution
+ * of wealth SD across runs, and the distribution of gradients, with
+ * two-sample Kolmogorov-Smirnov tests. The report (to stdout, or -o) gives
+ * each regime's mean gradient in each model and the tests' statistics and p
+ * values. A regime is flagged as differing if its gradients do at -alpha, or
+ * its SDs do at any turn at -alpha divided by the turns compared
+ * (Bonferroni's correction). NumOfAgents and the other Choices should be set
+ * to match the Python runs.
+ *
+ * The Poisson regime is the one known not to match (see redistribution.go),
+ * so this is mostly for tracking that discrepancy down.
+ */
+
+// A referenceRegime is the Python model's runs of a regime, each the wealth
+// SDs before the first turn and after each.
+type referenceRegime struct {
+	act  ActivationOrder
+	runs [][]float64
+}
+
+// matchActivation returns the regime the Python model calls name.
+func matchActivation(name string) (ActivationOrder, error) {
+	key := strings.ToLower(strings.TrimSpace(name))
+	key = strings.Replace(strings.Replace(key, "_", " ", -1), "-", " ", -1)
+	return ParseActivation(strings.Join(strings.Fields(key), " "))
+}
+
+// readReference reads the Python model's trajectories, in the order their
+// regimes first appear.
+func readReference(r io.Reader) ([]*referenceRegime, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) < 2 {
+		return nil, errors.New("reference: no trajectories")
+	}
+	cols := map[string]int{"regime": -1, "run": -1, "turn": -1, "sd": -1}
+	for i, name := range rows[0] {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "activation" {
+			name = "regime"
+		}
+		if c, ok := cols[name]; ok && c < 0 {
+			cols[name] = i
+		}
+	}
+	for _, name := range []string{"regime", "run", "turn", "sd"} {
+		if cols[name] < 0 {
+			return nil, fmt.Errorf("reference: no %s column", name)
+		}
+	}
+	var regimes []*referenceRegime
+	byAct := make(map[ActivationOrder]*referenceRegime)
+	for i, row := range rows[1:] {
+		if len(row) != len(rows[0]) {
+			return nil, fmt.Errorf("reference line %d: %d fields, want %d", i+2, len(row), len(rows[0]))
+		}
+		act, err1 := matchActivation(row[cols["regime"]])
+		run, err2 := strconv.Atoi(strings.TrimSpace(row[cols["run"]]))
+		turn, err3 := strconv.Atoi(strings.TrimSpace(row[cols["turn"]]))
+		sd, err4 := strconv.ParseFloat(strings.TrimSpace(row[cols["sd"]]), 64)
+		if err := firstError(err1, err2, err3, err4); err != nil {
+			return nil, fmt.Errorf("reference line %d: %v", i+2, err)
+		} else if run < 0 || turn < 0 {
+			return nil, fmt.Errorf("reference line %d: no run %d, turn %d", i+2, run, turn)
+		}
+		reg, ok := byAct[act]
+		if !ok {
+			reg = &referenceRegime{act: act}
+			byAct[act] = reg
+			regimes = append(regimes, reg)
+		}
+		for len(reg.runs) <= run {
+			reg.runs = append(reg.runs, nil)
+		}
+		for len(reg.runs[run]) <= turn {
+			reg.runs[run] = append(reg.runs[run], math.NaN())
+		}
+		reg.runs[run][turn] = sd
+	}
+	for _, reg := range regimes {
+		// runs may be numbered from 0 or 1, and the Python model skips none
+		if len(reg.runs) > 0 && reg.runs[0] == nil {
+			reg.runs = reg.runs[1:]
+		}
+		for ri, sds := range reg.runs {
+			if sds == nil {
+				return nil, fmt.Errorf("reference: %s has no run %d", reg.act, ri+1)
+			} else if len(sds) != len(reg.runs[0]) {
+				return nil, fmt.Errorf("reference: %s runs have %d and %d turns", reg.act, len(reg.runs[0])-1, len(sds)-1)
+			}
+			for turn, sd := range sds {
+				if math.IsNaN(sd) {
+					return nil, fmt.Errorf("reference: %s run %d has no turn %d", reg.act, ri+1, turn)
+				}
+			}
+		}
+		if len(reg.runs) == 0 || len(reg.runs[0]) < 2 {
+			return nil, fmt.Errorf("reference: %s runs have no turns", reg.act)
+		}
+	}
+	if len(regimes) > 1 {
+		for _, reg := range regimes[1:] {
+			if len(reg.runs[0]) != len(regimes[0].runs[0]) {
+				return nil, fmt.Errorf("reference: %s and %s runs have different numbers of turns", regimes[0].act, reg.act)
+			}
+		}
+	}
+	return regimes, nil
+}
+
+// ksTest returns the two-sample Kolmogorov-Smirnov statistic of a and b,
+// the largest difference between their empirical distributions, and its
+// asymptotic p value.
+func ksTest(a, b []float64) (d, p float64) {
+	a = append([]float64(nil), a...)
+	b = append([]float64(nil), b...)
+	sort.Float64s(a)
+	sort.Float64s(b)
+	na, nb := float64(len(a)), float64(len(b))
+	var i, j int
+	var fa, fb float64
+	for i < len(a) && j < len(b) {
+		x, y := a[i], b[j]
+		if x <= y {
+			i++
+			fa = float64(i) / na
+		}
+		if y <= x {
+			j++
+			fb = float64(j) / nb
+		}
+		if diff := math.Abs(fa - fb); diff > d {
+			d = diff
+		}
+	}
+	n := math.Sqrt(na * nb / (na + nb))
+	return d, kolmogorovQ((n + 0.12 + 0.11/n) * d)
+}
+
+// kolmogorovQ returns the probability that the Kolmogorov distribution
+// exceeds lambda.
+func kolmogorovQ(lambda float64) float64 {
+	if lambda < 0.2 { // the series converges too slowly, to about 1
+		return 1
+	}
+	sum, sign := 0.0, 1.0
+	for j := 1; j <= 100; j++ {
+		term := sign * 2 * math.Exp(-2*float64(j*j)*lambda*lambda)
+		sum += term
+		if math.Abs(term) < 1e-12*sum {
+			break
+		}
+		sign = -sign
+	}
+	return math.Max(0, math.Min(1, sum))
+}
+
+// meanSD returns the mean and sample standard deviation of xs.
+func meanSD(xs []float64) (mean, sd float64) {
+	var s stats.Stats
+	s.UpdateArray(xs)
+	if s.Count() < 2 {
+		return s.Mean(), 0
+	}
+	return s.Mean(), s.SampleStandardDeviation()
+}
+
+// validate runs the regimes of the reference with the given master seed,
+// and writes the report comparing them to it to w. It returns whether every
+// regime was consistent with the reference.
+func validate(ref []*referenceRegime, seed int64, alpha float64, w io.Writer) (bool, error) {
+	acts := make([]ActivationOrder, len(ref))
+	NumRuns = 0
+	for a, reg := range ref {
+		acts[a] = reg.act
+		if len(reg.runs) > NumRuns {
+			NumRuns = len(reg.runs)
+		}
+	}
+	NumTurns = len(ref[0].runs[0]) - 1
+	if err := checkChoices(seed); err != nil {
+		return false, err
+	}
+	runs := make([][][]float64, len(acts))
+	for a := range runs {
+		runs[a] = make([][]float64, NumRuns)
+	}
+	collect := func(res cellResult) { runs[res.act][res.run] = res.sds }
+	if err := (Experiment{acts, seed}).Run(collect); err != nil {
+		return false, err
+	}
+
+	fmt.Fprintf(w, "Parity of %d regimes over %d turns, %d agents; %s master seed %d\n\n", len(acts), NumTurns, NumOfAgents, RNG, seed)
+	consistent := true
+	for a, reg := range ref {
+		ours := runs[a][:len(reg.runs)]
+		refGrads, ourGrads := make([]float64, len(reg.runs)), make([]float64, len(ours))
+		for ri := range reg.runs {
+			refGrads[ri], ourGrads[ri] = gradient(reg.runs[ri]), gradient(ours[ri])
+		}
+		refMean, refSD := meanSD(refGrads)
+		ourMean, ourSD := meanSD(ourGrads)
+		gd, gp := ksTest(refGrads, ourGrads)
+		fmt.Fprintf(w, "%s: %d runs each\n", reg.act, len(reg.runs))
+		fmt.Fprintf(w, "  gradient: Python %.4g (SD %.3g), Go %.4g (SD %.3g); KS D = %.3f, p = %.3g\n",
+			refMean, refSD, ourMean, ourSD, gd, gp)
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "\tturn\tPython SD\tGo SD\tKS D\tp\t")
+		perTurn := alpha / float64(NumTurns)
+		differ := 0
+		for turn := 1; turn <= NumTurns; turn++ {
+			refSDs, ourSDs := make([]float64, len(reg.runs)), make([]float64, len(ours))
+			for ri := range reg.runs {
+				refSDs[ri], ourSDs[ri] = reg.runs[ri][turn], ours[ri][turn]
+			}
+			d, p := ksTest(refSDs, ourSDs)
+			mark := ""
+			if p < perTurn {
+				mark = "*"
+				differ++
+			}
+			refMean, _ := meanSD(refSDs)
+			ourMean, _ := meanSD(ourSDs)
+			fmt.Fprintf(tw, "\t%d\t%.4g\t%.4g\t%.3f\t%.3g%s\t\n", turn, refMean, ourMean, d, p, mark)
+		}
+		tw.Flush()
+		if differ > 0 || gp < alpha {
+			consistent = false
+			fmt.Fprintf(w, "  DIFFERS: gradients p = %.3g, SDs at %d of %d turns (* p < %.3g)\n\n", gp, differ, NumTurns, perTurn)
+		} else {
+			fmt.Fprintf(w, "  consistent at alpha = %g\n\n", alpha)
+		}
+	}
+	return consistent, nil
+}
+
+// runValidate is the validate subcommand.
+func runValidate(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	out := fs.String("o", "", "file to write the report to, instead of stdout")
+	alpha := fs.Float64("alpha", 0.05, "significance level of the tests")
+	seed := fs.Int64("seed", time.Now().UTC().UnixNano(), "master seed of the Go runs")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: validate [-o report.txt] [-alpha 0.05] [-seed n] reference.csv")
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	ref, err := readReference(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %v", fs.Arg(0), err)
+	}
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		rf, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer rf.Close()
+		w = rf
+	}
+	cellOutput = io.Discard
+	consistent, err := validate(ref, *seed, *alpha, w)
+	if err != nil {
+		return err
+	}
+	if !consistent {
+		fmt.Fprintln(os.Stderr, "validate: some regimes differ from the reference")
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestKSTest(t *testing.T) {
+	a := []float64{1, 2, 3, 4, 5, 6, 7, 8}
+	if d, p := ksTest(a, a); d != 0 || p != 1 {
+		t.Errorf("same samples: D %v, p %v", d, p)
+	}
+	b := []float64{11, 12, 13, 14, 15, 16, 17, 18}
+	if d, p := ksTest(a, b); d != 1 || p > 0.001 {
+		t.Errorf("disjoint samples: D %v, p %v", d, p)
+	}
+	if d, _ := ksTest([]float64{1, 2, 2, 3}, []float64{2, 2, 3, 4}); d != 0.25 {
+		t.Errorf("tied samples: D %v, want 0.25", d)
+	}
+	// the Kolmogorov distribution's 5% critical value
+	if q := kolmogorovQ(1.3581); math.Abs(q-0.05) > 0.0005 {
+		t.Errorf("Q(1.3581) = %v, want 0.05", q)
+	}
+}
+
+// referenceCSV returns the runs of regime act as the Python model might
+// write them, with every SD after the first turn scaled by scale.
+func referenceCSV(act ActivationOrder, seed int64, scale float64) string {
+	var buf strings.Builder
+	buf.WriteString("activation,run,turn,sd,mean\n")
+	RunExperiment([]ActivationOrder{act}, seed, func(res cellResult) {
+		for turn, sd := range res.sds {
+			if turn > 0 {
+				sd *= scale
+			}
+			fmt.Fprintf(&buf, "%s,%d,%d,%v,0\n", strings.Replace(act.String(), " ", "_", -1), res.run, turn, sd)
+		}
+	})
+	return buf.String()
+}
+
+func TestValidate(t *testing.T) {
+	defer func(runs, turns, agents int, out io.Writer) {
+		NumRuns, NumTurns, NumOfAgents, cellOutput = runs, turns, agents, out
+	}(NumRuns, NumTurns, NumOfAgents, cellOutput)
+	NumRuns, NumTurns, NumOfAgents, cellOutput = 20, 8, 100, io.Discard
+	for _, c := range []struct {
+		scale      float64
+		consistent bool
+	}{{1, true}, {3, false}} {
+		ref, err := readReference(strings.NewReader(referenceCSV(inversePoisson, 1, c.scale)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ref) != 1 || ref[0].act != inversePoisson || len(ref[0].runs) != 20 || len(ref[0].runs[0]) != 9 {
+			t.Fatalf("read %d regimes", len(ref))
+		}
+		NumRuns, NumTurns = 0, 0 // validate takes them from the reference
+		var report bytes.Buffer
+		consistent, err := validate(ref, 2, 0.05, &report)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if consistent != c.consistent {
+			t.Errorf("reference scaled by %v: consistent %v, want %v\n%s", c.scale, consistent, c.consistent, report.String())
+		}
+		if NumRuns != 20 || NumTurns != 8 {
+			t.Errorf("ran %d runs of %d turns, want 20 of 8", NumRuns, NumTurns)
+		}
+	}
+}
+
+func TestReadReferenceErrors(t *testing.T) {
+	for _, in := range []string{
+		"regime,run,sd\npoisson,1,3\n",
+		"regime,run,turn,sd\ntelepathy,1,0,3\n",
+		"regime,run,turn,sd\npoisson,1,0,3\npoisson,1,1,2\npoisson,3,0,3\npoisson,3,1,2\n",
+		"regime,run,turn,sd\npoisson,1,0,3\npoisson,1,1,2\npoisson,2,0,3\n",
+		"regime,run,turn,sd\npoisson,1,0,3\npoisson,1,1,2\nuniform,1,0,3\n",
+	} {
+		if _, err := readReference(strings.NewReader(in)); err == nil {
+			t.Errorf("read %q", in)
+		}
+	}
+}
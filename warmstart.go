@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/* Warm starts */
+
+/*
+ * An experiment can be done in phases -- equalize under one regime, say,
+ * then perturb under another -- by starting the runs of one phase where a
+ * run of the last left off. With SaveFinalState set, each run writes its
+ * population after the last turn to state_<regime>_run<N>.csv, a row per
+ * agent:
+ *
+ *	id,wealth,tag,district,class,kind,refusal,seen,risk,place,activations
+ *
+ * With WarmStartFile set to one of those files, every run starts from the
+ * population in it rather than Populate's, however many agents it has:
+ * their wealth isn't drawn or rescaled, and each agent keeps its tag,
+ * district, class, type, learnt refusal, risk aversion, place and count of
+ * activations. So the phase should lay the population out as the last did
+ * -- the same AgentTypes, Districts and so on -- while anything laid out
+ * afresh, like a network, is.
+ */
+
+// stateColumns are the columns of a state file.
+var stateColumns = []string{"id", "wealth", "tag", "district", "class", "kind", "refusal", "seen", "risk", "place", "activations"}
+
+// saveState writes Pop to run's state file.
+func saveState(Pop Population, act ActivationOrder, run int) {
+	name := fmt.Sprintf("state_%s_run%d.csv", strings.Replace(act.String(), " ", "_", -1), run+1)
+	f, err := os.Create(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = writeState(f, Pop)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// writeState writes Pop as a state file.
+func writeState(w io.Writer, Pop Population) error {
+	cw := csv.NewWriter(w)
+	cw.Write(stateColumns)
+	g := func(v float64) string { return strconv.FormatFloat(v, 'g', -1, 64) }
+	for i, a := range Pop.Agents {
+		cw.Write([]string{strconv.Itoa(a.id), g(Pop.Wealth[i]), strconv.Itoa(a.tag), strconv.Itoa(a.district),
+			strconv.Itoa(a.class), strconv.Itoa(a.kind), g(a.refusal), g(a.seen), g(a.risk),
+			strconv.Itoa(a.place), strconv.Itoa(a.activations)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// LoadState reads a population from a state file.
+func LoadState(r io.Reader) (Population, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return Population{}, err
+	} else if len(rows) < 3 {
+		return Population{}, errors.New("state: fewer than two agents")
+	} else if strings.Join(rows[0], ",") != strings.Join(stateColumns, ",") {
+		return Population{}, fmt.Errorf("state: header %q, want %q", strings.Join(rows[0], ","), strings.Join(stateColumns, ","))
+	}
+	Pop := NewPopulation(len(rows) - 1)
+	seen := make(map[int]bool)
+	for i, row := range rows[1:] {
+		a := &Pop.Agents[i]
+		ints := []*int{&a.id, nil, &a.tag, &a.district, &a.class, &a.kind, nil, nil, nil, &a.place, &a.activations}
+		floats := []*float64{nil, &Pop.Wealth[i], nil, nil, nil, nil, &a.refusal, &a.seen, &a.risk, nil, nil}
+		for c, field := range row {
+			var err error
+			if ints[c] != nil {
+				*ints[c], err = strconv.Atoi(field)
+			} else {
+				*floats[c], err = strconv.ParseFloat(field, 64)
+			}
+			if err != nil {
+				return Population{}, fmt.Errorf("state line %d: %v", i+2, err)
+			}
+		}
+		if Pop.Wealth[i] < 0 {
+			return Population{}, fmt.Errorf("state line %d: negative wealth %v", i+2, Pop.Wealth[i])
+		} else if seen[a.id] {
+			return Population{}, fmt.Errorf("state line %d: agent %d again", i+2, a.id)
+		}
+		seen[a.id] = true
+	}
+	return Pop, nil
+}
+
+// restoreState gives m's agents back the traits warmState saved, over any
+// NewModel gave them.
+func (m *Model) restoreState() {
+	for i, saved := range warmState.Agents {
+		a := &m.Pop.Agents[i]
+		if m.Types != nil && (saved.kind < 0 || saved.kind >= len(m.Types.Types)) {
+			log.Fatalf("WarmStartFile: agent %d is of type %d, but there are %d", saved.id, saved.kind, len(m.Types.Types))
+		}
+		a.tag, a.district, a.class, a.kind = saved.tag, saved.district, saved.class, saved.kind
+		a.refusal, a.seen, a.risk, a.place, a.activations = saved.refusal, saved.seen, saved.risk, saved.place, saved.activations
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io"
+	"math/rand"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestWarmStart checks that a run can start where one of another regime
+// left off, with its agents' wealth and traits, births included.
+func TestWarmStart(t *testing.T) {
+	defer func(save bool, types []string, births float64, agents, turns int) {
+		SaveFinalState, AgentTypes, BirthRate, NumOfAgents, NumTurns, warmState = save, types, births, agents, turns, nil
+	}(SaveFinalState, AgentTypes, BirthRate, NumOfAgents, NumTurns)
+	SaveFinalState, AgentTypes, BirthRate, NumOfAgents, NumTurns = true, []string{"learner:0.5", "leveler:0.5"}, 0.05, 40, 6
+	dir, _ := os.Getwd()
+	defer os.Chdir(dir)
+	os.Chdir(t.TempDir())
+
+	before := NewModel(uniform, rand.New(rand.NewSource(1)))
+	runCell(before, 1, io.Discard)
+	f, err := os.Open("state_uniform_run2.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := LoadState(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(state.Wealth, before.Pop.Wealth) || state.Len() == NumOfAgents {
+		t.Fatalf("saved %d agents' wealth %v, not %d's %v", state.Len(), state.Wealth, before.Pop.Len(), before.Pop.Wealth)
+	}
+	warmState, BirthRate = &state, 0
+	after := NewModel(random, rand.New(rand.NewSource(2)))
+	if !reflect.DeepEqual(after.Pop.Wealth, before.Pop.Wealth) {
+		t.Errorf("warm start with wealth %v, want %v", after.Pop.Wealth, before.Pop.Wealth)
+	}
+	for i, a := range after.Pop.Agents {
+		b := before.Pop.Agents[i]
+		if a.id != b.id || a.kind != b.kind || a.refusal != b.refusal || a.activations != b.activations {
+			t.Errorf("agent %d warm started as %+v, was %+v", i, a, b)
+			break
+		}
+	}
+	after.Step()
+}
+
+func TestLoadState(t *testing.T) {
+	header := strings.Join(stateColumns, ",") + "\n"
+	for _, bad := range []string{
+		"",
+		header + "0,1,0,0,0,0,0,0,0,0,0\n",
+		"id,wealth\n0,1\n1,2\n",
+		header + "0,1,0,0,0,0,0,0,0,0,0\n0,2,0,0,0,0,0,0,0,0,0\n",
+		header + "0,1,0,0,0,0,0,0,0,0,0\n1,-2,0,0,0,0,0,0,0,0,0\n",
+		header + "0,1,0,0,0,0,0,0,0,0,0\n1,x,0,0,0,0,0,0,0,0,0\n",
+	} {
+		if _, err := LoadState(strings.NewReader(bad)); err == nil {
+			t.Errorf("loaded %q", bad)
+		}
+	}
+}
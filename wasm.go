@@ -0,0 +1,49 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"encoding/json"
+	"syscall/js"
+)
+
+/* WebAssembly */
+
+/*
+ * Built with GOOS=js GOARCH=wasm, the program doesn't run an experiment but
+ * defines a global runModel(config) for the page that loads it, e.g.
+ *
+ *	const r = runModel({activation: "poisson", agents: 500, turns: 30, seed: 7});
+ *	// r.sds, r.wealth, or r.error
+ *
+ * The config and result are the JSON forms of RunConfig and RunResult.
+ */
+
+func main() {
+	js.Global().Set("runModel", js.FuncOf(runModel))
+	select {} // keep the runtime alive for the callback
+}
+
+// runModel is the JavaScript-facing wrapper around Run.
+func runModel(this js.Value, args []js.Value) interface{} {
+	var cfg RunConfig
+	if len(args) > 0 && args[0].Truthy() {
+		text := js.Global().Get("JSON").Call("stringify", args[0]).String()
+		if err := json.Unmarshal([]byte(text), &cfg); err != nil {
+			return jsError(err)
+		}
+	}
+	res, err := Run(cfg)
+	if err != nil {
+		return jsError(err)
+	}
+	text, err := json.Marshal(res)
+	if err != nil {
+		return jsError(err)
+	}
+	return js.Global().Get("JSON").Call("parse", string(text))
+}
+
+func jsError(err error) interface{} {
+	return map[string]interface{}{"error": err.Error()}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+)
+
+/* Multi-population worlds */
+
+/*
+ * A World runs several Models side by side -- countries or regions, each with
+ * its own activation regime and exchange rule (RegionRules, or else RuleName)
+ * -- in lockstep, and couples them two ways. Migration[i][j] is the per-turn
+ * probability that an agent living in region i moves to region j; whatever
+ * is left of row i is the probability of staying put. Coupling[i][j] is the
+ * share of its wealth every agent in region i sends to region j each turn,
+ * where what arrives is shared equally among the agents. With CouplingRate
+ * set, every region sends that share of its wealth in all, split equally
+ * among the others; each turn reports every region's wealth SD and size,
+ * and the run ends with a summary of each region's wealth and what flowed
+ * into it.
+ */
+type World struct {
+	Names     []string
+	Regions   []*Model
+	Migration [][]float64
+	Coupling  [][]float64 // nil for none
+	Inflow    []float64   // the wealth each region has received, net of what it sent
+
+	rng *rand.Rand // shared by the regions, which step one after another
+}
+
+// NewWorld builds one freshly populated region per activation type, with
+// the rule RegionRules gives it, connected by UniformMigration at the given
+// rate and coupled at CouplingRate.
+func NewWorld(acts []ActivationOrder, rate float64, rng *rand.Rand) *World {
+	w := &World{Migration: UniformMigration(len(acts), rate), Inflow: make([]float64, len(acts)), rng: rng}
+	if CouplingRate > 0 {
+		w.Coupling = UniformMigration(len(acts), CouplingRate)
+	}
+	next := 0
+	for r, act := range acts {
+		m := NewModel(act, rng)
+		for i := range m.Pop.Agents { // IDs are unique across the World
+			m.Pop.Agents[i].id = next
+			next++
+		}
+		name := act.String()
+		if len(RegionRules) > 0 {
+			rule, err := lookupRule(RegionRules[r])
+			if err != nil {
+				panic(err) // checkWorld vets RegionRules first
+			}
+			m.Rule = rule
+			name += "/" + RegionRules[r]
+		}
+		w.Names = append(w.Names, name)
+		w.Regions = append(w.Regions, m)
+	}
+	for _, m := range w.Regions { // and so are children's
+		if m.Demography != nil {
+			m.Demography.nextID = &next
+		}
+	}
+	return w
+}
+
+// checkWorld reports whether the Choices make a valid World, if they ask for
+// one.
+func checkWorld() error {
+	if len(RegionRules) > 0 && len(RegionRules) != len(RegionActivations) {
+		return fmt.Errorf("%d RegionRules for %d RegionActivations", len(RegionRules), len(RegionActivations))
+	}
+	for _, name := range RegionRules {
+		if _, err := lookupRule(name); err != nil {
+			return err
+		}
+	}
+	if CouplingRate < 0 || CouplingRate > 1 {
+		return fmt.Errorf("CouplingRate %v isn't from 0 to 1", CouplingRate)
+	}
+	return nil
+}
+
+// UniformMigration returns a k-region migration matrix in which every agent
+// leaves its region with probability rate, all destinations being equally
+// likely. It serves as a coupling matrix, too, with rate the share of wealth
+// sent.
+func UniformMigration(k int, rate float64) [][]float64 {
+	mig := make([][]float64, k)
+	for i := 0; i < k; i++ {
+		mig[i] = make([]float64, k)
+		for j := 0; j < k; j++ {
+			if i != j {
+				mig[i][j] = rate / float64(k-1)
+			}
+		}
+	}
+	return mig
+}
+
+// Step advances every region by one turn, then migrates agents and moves
+// wealth between the regions.
+func (w *World) Step() {
+	for _, m := range w.Regions {
+		m.Step()
+	}
+	w.Migrate()
+	if w.Coupling != nil {
+		w.Couple()
+	}
+}
+
+// Migrate moves agents between regions. Every agent's move is decided before
+// any arrivals are added, so nobody migrates twice in one turn.
+func (w *World) Migrate() {
+	arrivals := make([]Population, len(w.Regions))
+	for i, m := range w.Regions {
+		stay := Population{m.Pop.Wealth[:0], m.Pop.Lam[:0], m.Pop.Agents[:0]}
+		for a := 0; a < m.Pop.Len(); a++ {
+			if dest := w.destination(i); dest != i {
+				arrivals[dest].Add(m.Pop, a)
+			} else {
+				stay.Add(m.Pop, a)
+			}
+		}
+		m.Pop = stay
+	}
+	for j, m := range w.Regions {
+		m.Pop.Append(arrivals[j])
+		m.fitNetwork(w.rng)
+	}
+}
+
+// Couple moves wealth between regions as Coupling says. Every region sends
+// before any receives, and none sends to a region left empty.
+func (w *World) Couple() {
+	arrivals := make([]float64, len(w.Regions))
+	for i, m := range w.Regions {
+		for a, wealth := range m.Pop.Wealth {
+			for j, share := range w.Coupling[i] {
+				if j == i || share == 0 || w.Regions[j].Pop.Len() == 0 {
+					continue
+				}
+				arrivals[j] += share * wealth
+				w.Inflow[i] -= share * wealth
+				m.Pop.Wealth[a] -= share * wealth
+			}
+		}
+	}
+	for j, m := range w.Regions {
+		for a := range m.Pop.Wealth {
+			m.Pop.Wealth[a] += arrivals[j] / float64(m.Pop.Len())
+		}
+		w.Inflow[j] += arrivals[j]
+	}
+}
+
+// destination draws the region an agent currently in region i moves to.
+func (w *World) destination(i int) int {
+	u := w.rng.Float64()
+	cum := 0.0
+	for j, p := range w.Migration[i] {
+		if j == i {
+			continue
+		}
+		cum += p
+		if u < cum {
+			return j
+		}
+	}
+	return i
+}
+
+// Asdw returns the mean and standard deviation of wealth in each region, and
+// over the whole World. The World's figures are pooled from the regions'
+// rather than computed over a concatenated copy of every population.
+func (w *World) Asdw() (means, sds []float64, mean, sd float64) {
+	n, total := 0, 0.0
+	for _, m := range w.Regions {
+		mn, s := Asdw(m.Pop)
+		means = append(means, mn)
+		sds = append(sds, s)
+		if m.Pop.Len() > 0 {
+			n += m.Pop.Len()
+			total += mn * float64(m.Pop.Len())
+		}
+	}
+	mean = total / float64(n)
+	ss := 0.0 // sum of squared deviations from the World mean
+	for i, m := range w.Regions {
+		k := float64(m.Pop.Len())
+		if k > 1 {
+			ss += (k - 1) * sds[i] * sds[i]
+		}
+		if k > 0 {
+			ss += k * (means[i] - mean) * (means[i] - mean)
+		}
+	}
+	return means, sds, mean, math.Sqrt(ss / float64(n-1))
+}
+
+// Report writes a line for each region and one for the World as a whole,
+// giving their size, mean wealth, SD and Gini, and each region's inflow.
+func (w *World) Report(out io.Writer) {
+	means, sds, mean, sd := w.Asdw()
+	var all []float64
+	for i, m := range w.Regions {
+		sorted := append([]float64(nil), m.Pop.Wealth...)
+		sort.Float64s(sorted)
+		all = append(all, sorted...)
+		fmt.Fprintf(out, "%s: %d agents, mean %f, SD %f, Gini %.4f, net inflow %f\n", w.Names[i],
+			m.Pop.Len(), means[i], sds[i], gini(sorted, means[i]*float64(len(sorted))), w.Inflow[i])
+	}
+	sort.Float64s(all)
+	fmt.Fprintf(out, "World: %d agents, mean %f, SD %f, Gini %.4f\n", len(all), mean, sd, gini(all, mean*float64(len(all))))
+}
+
+// RunWorld runs a single World built from RegionActivations, printing the
+// size and wealth SD of each region and of the World every turn, then its
+// Report.
+func RunWorld(rng *rand.Rand) {
+	w := NewWorld(RegionActivations, MigrationRate, rng)
+	fmt.Printf("World of %d regions, %d agents each, migration rate %v, coupling rate %v\n",
+		len(w.Regions), NumOfAgents, MigrationRate, CouplingRate)
+	fmt.Printf("Turn")
+	for _, name := range w.Names {
+		fmt.Printf("\t%-15s\tN", name)
+	}
+	fmt.Printf("\tglobal\n")
+	for t := 0; t <= NumTurns; t++ {
+		if t > 0 {
+			w.Step()
+		}
+		_, sds, _, sd := w.Asdw()
+		fmt.Printf("%d", t)
+		for i, m := range w.Regions {
+			fmt.Printf("\t%-15f\t%d", sds[i], m.Pop.Len())
+		}
+		fmt.Printf("\t%f\n", sd)
+	}
+	w.Report(os.Stdout)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// TestMigrate checks that migration moves agents, and their wealth, between
+// regions without losing or duplicating any, and that agents keep the IDs,
+// unique across the World, they were born with.
+func TestMigrate(t *testing.T) {
+	defer func(agents int) { NumOfAgents = agents }(NumOfAgents)
+	NumOfAgents = 40
+	w := NewWorld([]ActivationOrder{uniform, random, poisson}, 0.3, rand.New(rand.NewSource(1)))
+	wealth := map[int]float64{}
+	for _, m := range w.Regions {
+		for i, a := range m.Pop.Agents {
+			wealth[a.id] = m.Pop.Wealth[i]
+		}
+	}
+	if len(wealth) != 120 {
+		t.Fatalf("%d distinct IDs among 120 agents", len(wealth))
+	}
+	w.Migrate()
+	moved, seen := false, 0
+	for _, m := range w.Regions {
+		if m.Pop.Len() != 40 {
+			moved = true
+		}
+		for i, a := range m.Pop.Agents {
+			if v, ok := wealth[a.id]; !ok || v != m.Pop.Wealth[i] {
+				t.Fatalf("agent %d arrived with wealth %v, had %v", a.id, m.Pop.Wealth[i], v)
+			}
+			seen++
+		}
+	}
+	if seen != 120 {
+		t.Errorf("%d agents after migrating, want 120", seen)
+	}
+	if !moved {
+		t.Error("no region changed size at migration rate 0.3")
+	}
+}
+
+// TestUniformMigration checks that every region's outflows add up to the
+// rate, split equally, and that nobody migrates when the rate is 0.
+func TestUniformMigration(t *testing.T) {
+	for i, row := range UniformMigration(4, 0.3) {
+		total := 0.0
+		for j, p := range row {
+			if (i == j && p != 0) || (i != j && math.Abs(p-0.1) > 1e-12) {
+				t.Errorf("rate %d to %d is %v", i, j, p)
+			}
+			total += p
+		}
+		if math.Abs(total-0.3) > 1e-12 {
+			t.Errorf("region %d sends %v, want 0.3", i, total)
+		}
+	}
+	defer func(agents int) { NumOfAgents = agents }(NumOfAgents)
+	NumOfAgents = 20
+	w := NewWorld([]ActivationOrder{uniform, random}, 0, rand.New(rand.NewSource(1)))
+	before := append([]Agent(nil), w.Regions[0].Pop.Agents...)
+	w.Migrate()
+	for i, a := range w.Regions[0].Pop.Agents {
+		if a.id != before[i].id {
+			t.Fatalf("agent %d moved at migration rate 0", before[i].id)
+		}
+	}
+}
+
+// TestWorldAsdw checks the World's pooled mean and SD against those of every
+// agent's wealth taken together.
+func TestWorldAsdw(t *testing.T) {
+	defer func(agents int) { NumOfAgents = agents }(NumOfAgents)
+	NumOfAgents = 30
+	w := NewWorld([]ActivationOrder{uniform, random, poisson}, 0.2, rand.New(rand.NewSource(3)))
+	for turn := 0; turn < 5; turn++ {
+		w.Step()
+	}
+	var all Population
+	for _, m := range w.Regions {
+		all.Append(m.Pop)
+	}
+	mean, sd := Asdw(all)
+	_, _, gotMean, gotSD := w.Asdw()
+	if math.Abs(gotMean-mean) > 1e-9*mean || math.Abs(gotSD-sd) > 1e-9*sd {
+		t.Errorf("World mean %v and SD %v, want %v and %v", gotMean, gotSD, mean, sd)
+	}
+}
+
+// TestCouple checks that coupling moves wealth between regions, shares it
+// equally on arrival, and keeps the World's total.
+func TestCouple(t *testing.T) {
+	defer func(agents int, rate float64) { NumOfAgents, CouplingRate = agents, rate }(NumOfAgents, CouplingRate)
+	NumOfAgents, CouplingRate = 4, 0.5
+	w := NewWorld([]ActivationOrder{uniform, random}, 0, rand.New(rand.NewSource(1)))
+	copy(w.Regions[0].Pop.Wealth, []float64{2, 2, 2, 2})
+	copy(w.Regions[1].Pop.Wealth, []float64{0, 4, 8, 12})
+	w.Couple()
+	for r, want := range [][]float64{{4, 4, 4, 4}, {1, 3, 5, 7}} {
+		for i, v := range w.Regions[r].Pop.Wealth {
+			if v != want[i] {
+				t.Errorf("region %d: wealth %v, want %v", r, w.Regions[r].Pop.Wealth, want)
+				break
+			}
+		}
+	}
+	if w.Inflow[0] != 8 || w.Inflow[1] != -8 {
+		t.Errorf("inflows %v, want [8 -8]", w.Inflow)
+	}
+}
+
+// TestCoupledWorld checks that regions keep the rules RegionRules gives
+// them, and that a coupled World conserves wealth and reports on itself.
+func TestCoupledWorld(t *testing.T) {
+	defer func(agents int, rate float64, rules []string) {
+		NumOfAgents, CouplingRate, RegionRules = agents, rate, rules
+	}(NumOfAgents, CouplingRate, RegionRules)
+	NumOfAgents, CouplingRate, RegionRules = 50, 0.1, []string{"bargain", "yardsale", "partial"}
+	w := NewWorld([]ActivationOrder{uniform, random, poisson}, 0.05, rand.New(rand.NewSource(2)))
+	if _, ok := w.Regions[1].Rule.(YardSale); !ok || w.Names[1] != "random/yardsale" {
+		t.Errorf("region %s has rule %#v", w.Names[1], w.Regions[1].Rule)
+	}
+	_, _, mean, _ := w.Asdw()
+	for turn := 0; turn < 10; turn++ {
+		w.Step()
+	}
+	if _, _, after, _ := w.Asdw(); math.Abs(after-mean) > 1e-9*mean {
+		t.Errorf("mean wealth went from %v to %v", mean, after)
+	}
+	if math.Abs(w.Inflow[0]+w.Inflow[1]+w.Inflow[2]) > 1e-9*mean {
+		t.Errorf("inflows %v don't balance", w.Inflow)
+	}
+	var out bytes.Buffer
+	w.Report(&out)
+	if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); len(lines) != 4 ||
+		!strings.HasPrefix(lines[0], "uniform/bargain: ") || !strings.HasPrefix(lines[3], "World: 150 agents") {
+		t.Errorf("report:\n%s", out.String())
+	}
+}
+
+func TestCheckWorld(t *testing.T) {
+	defer func(acts []ActivationOrder, rules []string, rate float64) {
+		RegionActivations, RegionRules, CouplingRate = acts, rules, rate
+	}(RegionActivations, RegionRules, CouplingRate)
+	RegionActivations = []ActivationOrder{uniform, random}
+	for _, bad := range []struct {
+		rules []string
+		rate  float64
+	}{{[]string{"leveler"}, 0}, {[]string{"leveler", "robinhood"}, 0}, {nil, 2}} {
+		RegionRules, CouplingRate = bad.rules, bad.rate
+		if err := checkWorld(); err == nil {
+			t.Errorf("accepted %+v", bad)
+		}
+	}
+}